@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"github.com/msune/l2radar/l2rctl/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configFile   string
+	configImage  string
+	configEditor string
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Edit, check and reload the probe configuration file",
+	Long: `Manage the probe configuration file used with "l2rctl start --config".
+
+The file lives on the host; its directory is mounted read-only into the
+probe container, which re-reads it on reload without restarting.`,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration, validate it and reload the probe",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := NewRunner()
+		return config.Edit(r, config.Opts{
+			File:   configFile,
+			Image:  configImage,
+			Editor: configEditor,
+		})
+	},
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validate the configuration file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := NewRunner()
+		return config.Check(r, config.Opts{File: configFile, Image: configImage})
+	},
+}
+
+var configReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the running probe re-read its configuration file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := NewRunner()
+		return config.Reload(r)
+	},
+}
+
+func init() {
+	configCmd.PersistentFlags().StringVar(&configFile, "file", config.DefaultFile, "probe configuration file on the host")
+	configCmd.PersistentFlags().StringVar(&configImage, "probe-image", "ghcr.io/msune/l2radar:latest", "probe image used to validate the file")
+	configEditCmd.Flags().StringVar(&configEditor, "editor", "", "editor command (default $VISUAL, $EDITOR or vi)")
+
+	configCmd.AddCommand(configEditCmd, configCheckCmd, configReloadCmd)
+	rootCmd.AddCommand(configCmd)
+}
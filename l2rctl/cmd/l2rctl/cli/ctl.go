@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"github.com/msune/l2radar/l2rctl/internal/ctl"
+	"github.com/spf13/cobra"
+)
+
+var ctlCmd = &cobra.Command{
+	Use:   "ctl <command> [args...]",
+	Short: "Control the running probe without restarting it",
+	Long: `Run "l2radar ctl" inside the running probe container. Arguments are
+passed through unchanged, e.g.:
+
+  l2rctl ctl status
+  l2rctl ctl add-iface eth1
+  l2rctl ctl remove-iface eth1
+  l2rctl ctl forget --iface eth0 02:00:00:00:00:01
+  l2rctl ctl flush --iface eth0 --older-than 24h
+  l2rctl ctl set-interval 10s`,
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+			return cmd.Help()
+		}
+		r := NewRunner()
+		return ctl.Ctl(r, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ctlCmd)
+}
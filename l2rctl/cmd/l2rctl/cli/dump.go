@@ -1,27 +1,92 @@
 package cli
 
 import (
+	"strings"
+	"time"
+
 	"github.com/msune/l2radar/l2rctl/internal/dump"
 	"github.com/spf13/cobra"
 )
 
-var dumpOutput string
+var (
+	dumpOutput  string
+	dumpColumns []string
+
+	dumpMAC        string
+	dumpIP         string
+	dumpSubnet     string
+	dumpVendor     string
+	dumpSeenWithin time.Duration
+	dumpHasIPv6    bool
+	dumpSort       string
+	dumpOrder      string
+	dumpLimit      int
+
+	dumpOffline    bool
+	dumpFrom       string
+	dumpVolumeName string
+	dumpExportDir  string
+	dumpImage      string
+)
 
 var dumpCmd = &cobra.Command{
-	Use:   "dump <interface>",
+	Use:   "dump <interface>...",
 	Short: "Dump neighbour table",
-	Args:  cobra.ExactArgs(1),
+	Long: `Dump the neighbour table of an interface from the running probe.
+
+Several interfaces, or "all", are listed in one table with an interface
+column. Neighbours can be filtered (--mac, --ip, --subnet, --vendor,
+--seen-within, --has-ipv6), sorted (--sort, --order) and limited
+(--limit) across all the interfaces listed.
+
+With --offline, read the last export from the shared volume instead, so
+the table is available when the probe is stopped; with --from, read an
+export directory or file on this host (e.g. copied from another host).
+The age of the exported data is printed to stderr.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		r := NewRunner()
 		return dump.Dump(r, dump.Opts{
-			Iface:  args[0],
-			Output: dumpOutput,
+			Ifaces:  args,
+			Output:  dumpOutput,
+			Columns: dumpColumns,
+
+			MAC:        dumpMAC,
+			IP:         dumpIP,
+			Subnet:     dumpSubnet,
+			Vendor:     dumpVendor,
+			SeenWithin: dumpSeenWithin,
+			HasIPv6:    dumpHasIPv6,
+			Sort:       dumpSort,
+			Order:      dumpOrder,
+			Limit:      dumpLimit,
+
+			Offline:    dumpOffline,
+			From:       dumpFrom,
+			VolumeName: dumpVolumeName,
+			ExportDir:  dumpExportDir,
+			Image:      dumpImage,
 		})
 	},
 }
 
 func init() {
-	dumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "table", "output format (table|json)")
+	dumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "table", "output format ("+strings.Join(dump.Formats, "|")+")")
+	dumpCmd.Flags().StringSliceVar(&dumpColumns, "columns", nil, "fields to print, in order: mac, vendor, ipv4, ipv6, first_seen, last_seen, interface (not with -o json)")
+	dumpCmd.Flags().StringVar(&dumpMAC, "mac", "", "only MAC addresses starting with this prefix, e.g. 02:42")
+	dumpCmd.Flags().StringVar(&dumpIP, "ip", "", "only neighbours holding this address (or CIDR)")
+	dumpCmd.Flags().StringVar(&dumpSubnet, "subnet", "", "only neighbours holding an address in this CIDR")
+	dumpCmd.Flags().StringVar(&dumpVendor, "vendor", "", "only vendors containing this string (case-insensitive)")
+	dumpCmd.Flags().DurationVar(&dumpSeenWithin, "seen-within", 0, "only neighbours seen within this duration, e.g. 10m")
+	dumpCmd.Flags().BoolVar(&dumpHasIPv6, "has-ipv6", false, "only neighbours with an IPv6 address")
+	dumpCmd.Flags().StringVar(&dumpSort, "sort", "", "sort key: "+strings.Join(dump.SortKeys, ", ")+" (default last_seen)")
+	dumpCmd.Flags().StringVar(&dumpOrder, "order", "", "sort order: asc or desc (default desc for times, asc otherwise)")
+	dumpCmd.Flags().IntVar(&dumpLimit, "limit", 0, "print at most this many neighbours (0 for all)")
+	dumpCmd.Flags().BoolVar(&dumpOffline, "offline", false, "read the last export from the shared volume instead of the running probe")
+	dumpCmd.Flags().StringVar(&dumpFrom, "from", "", "read the export from this host directory or neigh-<iface>.json file (implies --offline)")
+	dumpCmd.Flags().StringVar(&dumpVolumeName, "volume-name", "l2radar-data", "Docker named volume holding the exports")
+	dumpCmd.Flags().StringVar(&dumpExportDir, "export-dir", "/var/lib/l2radar", "probe export directory (path inside containers)")
+	dumpCmd.Flags().StringVar(&dumpImage, "probe-image", "ghcr.io/msune/l2radar:latest", "probe image used to read exports offline")
 
 	rootCmd.AddCommand(dumpCmd)
 }
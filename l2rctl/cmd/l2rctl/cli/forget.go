@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/msune/l2radar/l2rctl/internal/forget"
+	"github.com/spf13/cobra"
+)
+
+var flushOlderThan time.Duration
+
+var forgetCmd = &cobra.Command{
+	Use:   "forget <interface> <mac>...",
+	Short: "Delete neighbour entries by MAC address",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := NewRunner()
+		return forget.Forget(r, forget.Opts{
+			Iface: args[0],
+			MACs:  args[1:],
+		})
+	},
+}
+
+var flushCmd = &cobra.Command{
+	Use:   "flush <interface>",
+	Short: "Delete all neighbour entries, or those not seen recently",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := NewRunner()
+		return forget.Flush(r, forget.FlushOpts{
+			Iface:     args[0],
+			OlderThan: flushOlderThan,
+		})
+	},
+}
+
+func init() {
+	flushCmd.Flags().DurationVar(&flushOlderThan, "older-than", 0, "only delete entries not seen for this long")
+
+	rootCmd.AddCommand(forgetCmd)
+	rootCmd.AddCommand(flushCmd)
+}
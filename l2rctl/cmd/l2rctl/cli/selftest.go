@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"github.com/msune/l2radar/l2rctl/internal/selftest"
+	"github.com/spf13/cobra"
+)
+
+var selftestOutput string
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run the probe selftest inside the probe container",
+	Long: `Run "l2radar selftest" inside the running probe container. It creates a
+temporary netns and veth pair, attaches the probe, injects crafted frames
+and prints a pass/fail report for the kernel, bpffs, TCX and the parser.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := NewRunner()
+		return selftest.Selftest(r, selftest.Opts{
+			Output: selftestOutput,
+		})
+	},
+}
+
+func init() {
+	selftestCmd.Flags().StringVarP(&selftestOutput, "output", "o", "table", "output format (table|json)")
+
+	rootCmd.AddCommand(selftestCmd)
+}
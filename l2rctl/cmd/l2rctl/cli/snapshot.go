@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"strings"
+	"time"
+
+	"github.com/msune/l2radar/l2rctl/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotExportDir string
+	diffOutput        string
+	diffImage         string
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [dir]",
+	Short: "Save the probe's current exports to a local directory",
+	Long: `Copy the running probe's interface exports (neigh-<iface>.json) and
+manifest to a local directory, by default l2radar-snapshot-<time> in the
+current directory, to compare later with "l2rctl diff".`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := snapshot.DefaultDir(time.Now())
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		r := NewRunner()
+		return snapshot.Save(r, snapshot.Opts{Dir: dir, ExportDir: snapshotExportDir})
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> [new]",
+	Short: "Compare snapshots, or a snapshot with the running probe",
+	Long: `Compare two snapshot directories (or two interface export files) with
+"l2radar diff" in a throwaway probe container. With a single snapshot,
+compare it with the running probe's current exports.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := snapshot.DiffOpts{
+			Old:       args[0],
+			Output:    diffOutput,
+			ExportDir: snapshotExportDir,
+			Image:     diffImage,
+		}
+		if len(args) > 1 {
+			opts.New = args[1]
+		}
+		r := NewRunner()
+		return snapshot.Diff(r, opts)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{snapshotCmd, diffCmd} {
+		cmd.Flags().StringVar(&snapshotExportDir, "export-dir", "/var/lib/l2radar", "probe export directory (path inside containers)")
+	}
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "table", "output format ("+strings.Join(snapshot.Formats, "|")+")")
+	diffCmd.Flags().StringVar(&diffImage, "probe-image", "ghcr.io/msune/l2radar:latest", "probe image used to compare")
+
+	rootCmd.AddCommand(snapshotCmd, diffCmd)
+}
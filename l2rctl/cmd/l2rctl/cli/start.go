@@ -29,6 +29,16 @@ var (
 	startPinPath         string
 	startProbeImage      string
 	startProbeDockerArgs string
+	startSkipPreflight   bool
+	startConfig          string
+	startAPIListen       string
+	startAPITokenFile    string
+	startMetricsTextfile string
+	startSyslog          string
+	startSyslogFormat    string
+	startSyslogFacility  string
+	startSyslogTLSCA     string
+	startJournal         bool
 
 	// UI flags
 	startTLSDir       string
@@ -53,6 +63,16 @@ func addStartFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&startPinPath, "pin-path", "/sys/fs/bpf/l2radar", "BPF pin path")
 	cmd.Flags().StringVar(&startProbeImage, "probe-image", "ghcr.io/msune/l2radar:latest", "probe image")
 	cmd.Flags().StringVar(&startProbeDockerArgs, "probe-docker-args", "", "extra docker args for probe")
+	cmd.Flags().BoolVar(&startSkipPreflight, "skip-preflight", false, "do not run the kernel preflight check before starting the probe")
+	cmd.Flags().StringVar(&startAPIListen, "api-listen", "", "serve the probe HTTP API on this host address, e.g. 127.0.0.1:9110")
+	cmd.Flags().StringVar(&startAPITokenFile, "api-token-file", "", "host file holding the bearer token for the probe HTTP API")
+	cmd.Flags().StringVar(&startMetricsTextfile, "metrics-textfile", "", "host path of a node_exporter textfile (*.prom) for probe metrics")
+	cmd.Flags().StringVar(&startSyslog, "syslog", "", "send probe neighbour events to syslog: udp://host[:port], tcp://..., tls://..., unix:///path or \"local\" (host /dev/log)")
+	cmd.Flags().StringVar(&startSyslogFormat, "syslog-format", "", "syslog payload format: rfc5424 (default), cef or leef")
+	cmd.Flags().StringVar(&startSyslogFacility, "syslog-facility", "", "syslog facility (default local0)")
+	cmd.Flags().StringVar(&startSyslogTLSCA, "syslog-tls-ca", "", "host PEM file of CAs to verify a tls:// syslog server")
+	cmd.Flags().BoolVar(&startJournal, "journal", false, "keep a neighbour event journal in the data volume (see \"l2radar journal\")")
+	cmd.Flags().StringVar(&startConfig, "config", "", "probe configuration file on the host (mounted read-only; see \"l2rctl config\")")
 
 	// UI flags
 	cmd.Flags().StringVar(&startTLSDir, "tls-dir", "", "TLS cert directory")
@@ -83,7 +103,19 @@ func runStartOrInstall(cmd *cobra.Command, args []string, restartPolicy string)
 	}
 
 	ifaces := startIfaces
-	if len(ifaces) == 0 {
+	exportInterval, pinPath := startExportInterval, startPinPath
+	if startConfig != "" {
+		// Leave interfaces and settings to the file unless given explicitly.
+		if _, err := os.Stat(startConfig); err != nil {
+			return fmt.Errorf("probe config: %w", err)
+		}
+		if !cmd.Flags().Changed("export-interval") {
+			exportInterval = ""
+		}
+		if !cmd.Flags().Changed("pin-path") {
+			pinPath = ""
+		}
+	} else if len(ifaces) == 0 {
 		ifaces = []string{"external"}
 	}
 
@@ -121,14 +153,24 @@ func runStartOrInstall(cmd *cobra.Command, args []string, restartPolicy string)
 	}
 
 	probeOpts := start.ProbeOpts{
-		Ifaces:         ifaces,
-		ExportDir:      startExportDir,
-		VolumeName:     startVolumeName,
-		ExportInterval: startExportInterval,
-		PinPath:        startPinPath,
-		Image:          startProbeImage,
-		ExtraArgs:      startProbeDockerArgs,
-		RestartPolicy:  restartPolicy,
+		Ifaces:          ifaces,
+		ExportDir:       startExportDir,
+		VolumeName:      startVolumeName,
+		ExportInterval:  exportInterval,
+		PinPath:         pinPath,
+		Image:           startProbeImage,
+		ExtraArgs:       startProbeDockerArgs,
+		RestartPolicy:   restartPolicy,
+		SkipPreflight:   startSkipPreflight,
+		ConfigFile:      startConfig,
+		APIListen:       startAPIListen,
+		APITokenFile:    startAPITokenFile,
+		MetricsTextfile: startMetricsTextfile,
+		Syslog:          startSyslog,
+		SyslogFormat:    startSyslogFormat,
+		SyslogFacility:  startSyslogFacility,
+		SyslogTLSCA:     startSyslogTLSCA,
+		Journal:         startJournal,
 	}
 
 	uiOpts := start.UIOpts{
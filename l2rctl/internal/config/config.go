@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const ProbeContainer = "l2radar"
+
+// DefaultFile is the default probe configuration file on the host.
+const DefaultFile = "/etc/l2radar/l2radar.yaml"
+
+// checkDir is where the file's directory is mounted when validating.
+const checkDir = "/etc/l2radar"
+
+// Template seeds a configuration file that does not exist yet.
+const Template = `# l2radar probe configuration. Reload with "l2rctl config reload".
+interfaces:
+  - external
+  # - name: eth1
+  #   export: false
+  #   filters:
+  #     ignore_macs: ["02:42"]
+export:
+  dir: /var/lib/l2radar
+  interval: 5s
+filters:
+  ignore_subnets: []
+`
+
+// Opts holds config command options.
+type Opts struct {
+	File  string
+	Image string
+	// Editor is the editor command; $VISUAL, $EDITOR or vi if empty.
+	Editor string
+	// RunEditor runs the editor on path; overridable for testing.
+	RunEditor func(editor, path string) error
+	// Out receives progress messages; os.Stdout if nil.
+	Out io.Writer
+}
+
+// Check validates file with "l2radar config check" in a throwaway probe
+// container, so the host needs no probe binary.
+func Check(r docker.Runner, opts Opts) error {
+	abs, err := filepath.Abs(opts.File)
+	if err != nil {
+		return err
+	}
+	return r.RunAttached("run", "--rm",
+		"-v", fmt.Sprintf("%s:%s:ro", filepath.Dir(abs), checkDir),
+		opts.Image,
+		"config", "check", checkDir+"/"+filepath.Base(abs),
+	)
+}
+
+// Reload makes the running probe re-read its configuration file.
+func Reload(r docker.Runner) error {
+	_, stderr, err := r.Run("kill", "--signal", "HUP", ProbeContainer)
+	if err != nil {
+		return fmt.Errorf("reloading probe: %s", stderr)
+	}
+	return nil
+}
+
+// Edit opens a copy of the configuration file in an editor, validates it
+// and, if valid, replaces the file and reloads the probe if it is running.
+// An invalid edit leaves the file unchanged and keeps the copy.
+func Edit(r docker.Runner, opts Opts) error {
+	if opts.File == "" {
+		return fmt.Errorf("config file is required")
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(opts.File); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	current, err := os.ReadFile(opts.File)
+	if os.IsNotExist(err) {
+		current = []byte(Template)
+		if err := os.MkdirAll(filepath.Dir(opts.File), 0755); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// Edit next to the original so the validated copy can be renamed over it.
+	tmp, err := os.CreateTemp(filepath.Dir(opts.File), ".edit-*"+filepath.Ext(opts.File))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(current)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	run := opts.RunEditor
+	if run == nil {
+		run = runEditor
+	}
+	if err := run(editorCommand(opts.Editor), tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return err
+	}
+	if string(edited) == string(current) {
+		os.Remove(tmpPath)
+		fmt.Fprintln(out, "No changes.")
+		return nil
+	}
+
+	if err := Check(r, Opts{File: tmpPath, Image: opts.Image}); err != nil {
+		return fmt.Errorf("invalid configuration, %s left unchanged (edits kept in %s)", opts.File, tmpPath)
+	}
+	if err := os.Rename(tmpPath, opts.File); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Saved %s.\n", opts.File)
+
+	if probeRunning(r) {
+		if err := Reload(r); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Probe reloaded.")
+	}
+	return nil
+}
+
+func probeRunning(r docker.Runner) bool {
+	stdout, _, err := r.Run("inspect", "-f", "{{.State.Running}}", ProbeContainer)
+	return err == nil && stdout == "true\n"
+}
+
+func editorCommand(editor string) string {
+	for _, e := range []string{editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+func runEditor(editor, path string) error {
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "editor", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const image = "ghcr.io/msune/l2radar:latest"
+
+// writeEditor returns a RunEditor that replaces the file with content.
+func writeEditor(content string) func(string, string) error {
+	return func(_, path string) error {
+		return os.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Check(m, Opts{File: "/etc/l2radar/l2radar.yaml", Image: image}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"run", "--rm", "-v", "/etc/l2radar:/etc/l2radar:ro", image,
+		"config", "check", "/etc/l2radar/l2radar.yaml"}
+	if len(m.Calls) != 1 || !slices.Equal(m.Calls[0], want) {
+		t.Errorf("expected %v, got %v", want, m.Calls)
+	}
+}
+
+func TestReload(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Reload(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"kill", "--signal", "HUP", "l2radar"}
+	if len(m.Calls) != 1 || !slices.Equal(m.Calls[0], want) {
+		t.Errorf("expected %v, got %v", want, m.Calls)
+	}
+
+	m = &docker.MockRunner{
+		ErrFn:    func([]string) error { return errors.New("exit 1") },
+		StderrFn: func([]string) string { return "No such container: l2radar" },
+	}
+	if err := Reload(m); err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Errorf("expected docker error, got %v", err)
+	}
+}
+
+func TestEditValidSavesAndReloads(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "l2radar.yaml")
+	if err := os.WriteFile(file, []byte("interfaces: [eth0]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &docker.MockRunner{
+		StdoutFn: func(args []string) string {
+			if args[0] == "inspect" {
+				return "true\n"
+			}
+			return ""
+		},
+	}
+	err := Edit(m, Opts{File: file, Image: image, RunEditor: writeEditor("interfaces: [eth1]\n"), Out: io.Discard})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, _ := os.ReadFile(file)
+	if string(b) != "interfaces: [eth1]\n" {
+		t.Errorf("file not updated: %q", b)
+	}
+	var cmds []string
+	for _, c := range m.Calls {
+		cmds = append(cmds, c[0])
+	}
+	if strings.Join(cmds, ",") != "run,inspect,kill" {
+		t.Errorf("expected check, inspect, reload; got %v", m.Calls)
+	}
+	if left, _ := filepath.Glob(filepath.Join(filepath.Dir(file), ".edit-*")); len(left) != 0 {
+		t.Errorf("temporary files left: %v", left)
+	}
+}
+
+func TestEditInvalidKeepsFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "l2radar.yaml")
+	if err := os.WriteFile(file, []byte("interfaces: [eth0]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &docker.MockRunner{ErrFn: func(args []string) error {
+		if args[0] == "run" {
+			return errors.New("exit 1")
+		}
+		return nil
+	}}
+	err := Edit(m, Opts{File: file, Image: image, RunEditor: writeEditor("interfaces: [\n"), Out: io.Discard})
+	if err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Fatalf("expected invalid configuration error, got %v", err)
+	}
+	b, _ := os.ReadFile(file)
+	if string(b) != "interfaces: [eth0]\n" {
+		t.Errorf("file changed: %q", b)
+	}
+	for _, c := range m.Calls {
+		if c[0] == "kill" {
+			t.Error("probe reloaded after invalid edit")
+		}
+	}
+}
+
+func TestEditNewFileUsesTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sub", "l2radar.yaml")
+	var seen string
+	run := func(_, path string) error {
+		b, err := os.ReadFile(path)
+		seen = string(b)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(b, "# edited\n"...), 0644)
+	}
+	m := &docker.MockRunner{}
+	if err := Edit(m, Opts{File: file, Image: image, RunEditor: run, Out: io.Discard}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen != Template {
+		t.Errorf("editor did not get the template: %q", seen)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("file not created: %v", err)
+	}
+}
+
+func TestEditNoChanges(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "l2radar.yaml")
+	if err := os.WriteFile(file, []byte("interfaces: [eth0]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &docker.MockRunner{}
+	noop := func(string, string) error { return nil }
+	if err := Edit(m, Opts{File: file, Image: image, RunEditor: noop, Out: io.Discard}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(m.Calls) != 0 {
+		t.Errorf("expected no docker calls, got %v", m.Calls)
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "nano")
+	if got := editorCommand(""); got != "nano" {
+		t.Errorf("expected $EDITOR, got %q", got)
+	}
+	if got := editorCommand("code -w"); got != "code -w" {
+		t.Errorf("expected explicit editor, got %q", got)
+	}
+	t.Setenv("EDITOR", "")
+	if got := editorCommand(""); got != "vi" {
+		t.Errorf("expected vi, got %q", got)
+	}
+}
@@ -0,0 +1,19 @@
+package ctl
+
+import (
+	"fmt"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const ProbeContainer = "l2radar"
+
+// Ctl runs "l2radar ctl <args>" inside the running probe container, which
+// talks to the probe over its control socket.
+func Ctl(r docker.Runner, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing ctl command (status, add-iface, remove-iface, forget, flush, set-interval)")
+	}
+	execArgs := append([]string{"exec", ProbeContainer, "/l2radar", "ctl"}, args...)
+	return r.RunAttached(execArgs...)
+}
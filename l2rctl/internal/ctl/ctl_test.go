@@ -0,0 +1,43 @@
+package ctl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+func TestCtlArgs(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Ctl(m, []string{"flush", "--iface", "eth0", "--older-than", "1h"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(m.Calls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(m.Calls))
+	}
+	want := "exec l2radar /l2radar ctl flush --iface eth0 --older-than 1h"
+	if got := strings.Join(m.Calls[0], " "); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCtlNoArgs(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Ctl(m, nil); err == nil {
+		t.Fatal("expected error without command")
+	}
+	if len(m.Calls) != 0 {
+		t.Fatalf("expected no docker calls, got %d", len(m.Calls))
+	}
+}
+
+func TestCtlFailurePropagates(t *testing.T) {
+	m := &docker.MockRunner{
+		ErrFn: func(args []string) error { return fmt.Errorf("exit status 1") },
+	}
+	if err := Ctl(m, []string{"status"}); err == nil {
+		t.Fatal("expected error to propagate")
+	}
+}
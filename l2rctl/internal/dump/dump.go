@@ -2,30 +2,152 @@ package dump
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/msune/l2radar/l2rctl/internal/docker"
 )
 
 const ProbeContainer = "l2radar"
 
+// Formats are the output formats of the probe's dump command.
+var Formats = []string{"table", "json", "csv", "ndjson", "yaml"}
+
+// SortKeys are the sort keys of the probe's dump command.
+var SortKeys = []string{"mac", "ip", "vendor", "first_seen", "last_seen"}
+
 // Opts holds dump command options.
 type Opts struct {
-	Iface  string
+	// Ifaces are the interfaces to list in one table, or "all".
+	Ifaces []string
 	Output string
+	// Columns selects and orders the fields (all if empty); not
+	// supported with json.
+	Columns []string
+
+	// Filters, passed to the probe; zero values match everything.
+	MAC        string
+	IP         string
+	Subnet     string
+	Vendor     string
+	SeenWithin time.Duration
+	HasIPv6    bool
+	// Sort is one of SortKeys (the probe default, last_seen, if empty)
+	// and Order asc or desc (the probe default for the key if empty).
+	Sort  string
+	Order string
+	// Limit caps the number of neighbours printed; 0 for all.
+	Limit int
+
+	// Offline reads the last export from the shared volume in a
+	// throwaway probe container instead of exec'ing into the probe, so it
+	// works when the probe is stopped.
+	Offline bool
+	// From reads the export from this host directory or file instead of
+	// the volume (e.g. copied from another host); implies Offline.
+	From       string
+	VolumeName string
+	// ExportDir is the export directory inside the probe container.
+	ExportDir string
+	Image     string
 }
 
+// offlineDir is where an export directory from the host is mounted.
+const offlineDir = "/export"
+
 // Dump executes the dump command.
 func Dump(r docker.Runner, opts Opts) error {
-	if opts.Iface == "" {
+	if len(opts.Ifaces) == 0 {
 		return fmt.Errorf("interface name is required")
 	}
-	if opts.Output != "" && opts.Output != "table" && opts.Output != "json" {
-		return fmt.Errorf("invalid output format %q (supported: table, json)", opts.Output)
+	if opts.Output != "" && !slices.Contains(Formats, opts.Output) {
+		return fmt.Errorf("invalid output format %q (supported: %s)", opts.Output, strings.Join(Formats, ", "))
+	}
+	if opts.Output == "json" && len(opts.Columns) > 0 {
+		return fmt.Errorf("--columns is not supported with -o json")
+	}
+	if opts.Sort != "" && !slices.Contains(SortKeys, opts.Sort) {
+		return fmt.Errorf("invalid sort %q (supported: %s)", opts.Sort, strings.Join(SortKeys, ", "))
+	}
+	if opts.Order != "" && opts.Order != "asc" && opts.Order != "desc" {
+		return fmt.Errorf("invalid order %q (supported: asc, desc)", opts.Order)
+	}
+	if opts.Limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
 	}
 
-	args := []string{"exec", ProbeContainer, "/l2radar", "dump", "--iface", opts.Iface}
+	var args []string
+	switch {
+	case opts.From != "":
+		mount, path, err := fromMount(opts.From)
+		if err != nil {
+			return err
+		}
+		args = []string{"run", "--rm", "-v", mount, opts.Image, "dump", "--from-export", path}
+	case opts.Offline:
+		args = []string{"run", "--rm", "-v", fmt.Sprintf("%s:%s:ro", opts.VolumeName, opts.ExportDir),
+			opts.Image, "dump", "--from-export", opts.ExportDir}
+	default:
+		args = []string{"exec", ProbeContainer, "/l2radar", "dump"}
+	}
+	for _, iface := range opts.Ifaces {
+		args = append(args, "--iface", iface)
+	}
 	if opts.Output != "" {
 		args = append(args, "-o", opts.Output)
 	}
+	if len(opts.Columns) > 0 {
+		args = append(args, "--columns", strings.Join(opts.Columns, ","))
+	}
+	args = append(args, queryArgs(opts)...)
 	return r.RunAttached(args...)
 }
+
+// queryArgs returns the probe flags of the filter, sort and limit options.
+func queryArgs(opts Opts) []string {
+	var args []string
+	for _, f := range []struct{ flag, value string }{
+		{"--mac", opts.MAC},
+		{"--ip", opts.IP},
+		{"--subnet", opts.Subnet},
+		{"--vendor", opts.Vendor},
+		{"--sort", opts.Sort},
+		{"--order", opts.Order},
+	} {
+		if f.value != "" {
+			args = append(args, f.flag, f.value)
+		}
+	}
+	if opts.SeenWithin > 0 {
+		args = append(args, "--seen-within", opts.SeenWithin.String())
+	}
+	if opts.HasIPv6 {
+		args = append(args, "--has-ipv6")
+	}
+	if opts.Limit > 0 {
+		args = append(args, "--limit", strconv.Itoa(opts.Limit))
+	}
+	return args
+}
+
+// fromMount returns the read-only bind mount of an export directory, or
+// of the directory of an export file, and the export's path in the
+// container.
+func fromMount(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", "", err
+	}
+	if fi.IsDir() {
+		return fmt.Sprintf("%s:%s:ro", abs, offlineDir), offlineDir, nil
+	}
+	return fmt.Sprintf("%s:%s:ro", filepath.Dir(abs), offlineDir), offlineDir + "/" + filepath.Base(abs), nil
+}
@@ -2,8 +2,11 @@ package dump
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/msune/l2radar/l2rctl/internal/docker"
 )
@@ -11,7 +14,7 @@ import (
 func TestDumpTableMode(t *testing.T) {
 	m := &docker.MockRunner{}
 	opts := Opts{
-		Iface:  "eth0",
+		Ifaces: []string{"eth0"},
 		Output: "table",
 	}
 
@@ -39,7 +42,7 @@ func TestDumpTableMode(t *testing.T) {
 func TestDumpDefaultModeWithoutOutputFlag(t *testing.T) {
 	m := &docker.MockRunner{}
 	opts := Opts{
-		Iface: "eth0",
+		Ifaces: []string{"eth0"},
 	}
 
 	err := Dump(m, opts)
@@ -66,7 +69,7 @@ func TestDumpDefaultModeWithoutOutputFlag(t *testing.T) {
 func TestDumpJSONMode(t *testing.T) {
 	m := &docker.MockRunner{}
 	opts := Opts{
-		Iface:  "eth0",
+		Ifaces: []string{"eth0"},
 		Output: "json",
 	}
 
@@ -93,9 +96,7 @@ func TestDumpJSONMode(t *testing.T) {
 
 func TestDumpRequiresIface(t *testing.T) {
 	m := &docker.MockRunner{}
-	opts := Opts{
-		Iface: "",
-	}
+	opts := Opts{}
 
 	err := Dump(m, opts)
 	if err == nil {
@@ -113,7 +114,7 @@ func TestDumpDockerError(t *testing.T) {
 		},
 	}
 	opts := Opts{
-		Iface: "eth0",
+		Ifaces: []string{"eth0"},
 	}
 
 	err := Dump(m, opts)
@@ -128,8 +129,8 @@ func TestDumpDockerError(t *testing.T) {
 func TestDumpInvalidOutput(t *testing.T) {
 	m := &docker.MockRunner{}
 	opts := Opts{
-		Iface:  "eth0",
-		Output: "yaml",
+		Ifaces: []string{"eth0"},
+		Output: "xml",
 	}
 
 	err := Dump(m, opts)
@@ -143,3 +144,127 @@ func TestDumpInvalidOutput(t *testing.T) {
 		t.Fatalf("expected no docker calls on invalid output, got %d", len(m.Calls))
 	}
 }
+
+func TestDumpCSVColumns(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := Opts{
+		Ifaces:  []string{"eth0"},
+		Output:  "csv",
+		Columns: []string{"mac", "ipv4", "vendor"},
+	}
+
+	if err := Dump(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "exec l2radar /l2radar dump --iface eth0 -o csv --columns mac,ipv4,vendor"
+	if len(m.Calls) != 1 || strings.Join(m.Calls[0], " ") != want {
+		t.Fatalf("expected args %q, got %v", want, m.Calls)
+	}
+}
+
+func TestDumpJSONRejectsColumns(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := Opts{
+		Ifaces:  []string{"eth0"},
+		Output:  "json",
+		Columns: []string{"mac"},
+	}
+
+	err := Dump(m, opts)
+	if err == nil || !strings.Contains(err.Error(), "--columns") {
+		t.Fatalf("expected --columns error, got: %v", err)
+	}
+	if len(m.Calls) != 0 {
+		t.Fatalf("expected no docker calls, got %d", len(m.Calls))
+	}
+}
+
+func TestDumpOffline(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := Opts{
+		Ifaces:     []string{"eth0"},
+		Output:     "table",
+		Offline:    true,
+		VolumeName: "l2radar-data",
+		ExportDir:  "/var/lib/l2radar",
+		Image:      "ghcr.io/msune/l2radar:latest",
+	}
+
+	if err := Dump(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "run --rm -v l2radar-data:/var/lib/l2radar:ro ghcr.io/msune/l2radar:latest dump --from-export /var/lib/l2radar --iface eth0 -o table"
+	if len(m.Calls) != 1 || strings.Join(m.Calls[0], " ") != want {
+		t.Fatalf("expected args %q, got %v", want, m.Calls)
+	}
+}
+
+func TestDumpFrom(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "neigh-eth0.json")
+	if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for from, want := range map[string]string{
+		dir:  "run --rm -v " + dir + ":/export:ro img dump --from-export /export --iface eth0",
+		file: "run --rm -v " + dir + ":/export:ro img dump --from-export /export/neigh-eth0.json --iface eth0",
+	} {
+		m := &docker.MockRunner{}
+		if err := Dump(m, Opts{Ifaces: []string{"eth0"}, From: from, Image: "img"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(m.Calls) != 1 || strings.Join(m.Calls[0], " ") != want {
+			t.Errorf("expected args %q, got %v", want, m.Calls)
+		}
+	}
+
+	m := &docker.MockRunner{}
+	if err := Dump(m, Opts{Ifaces: []string{"eth0"}, From: filepath.Join(dir, "missing"), Image: "img"}); err == nil {
+		t.Error("expected error for a missing path")
+	}
+	if len(m.Calls) != 0 {
+		t.Fatalf("expected no docker calls, got %d", len(m.Calls))
+	}
+}
+
+func TestDumpQuery(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := Opts{
+		Ifaces:     []string{"eth0", "wlan0"},
+		Output:     "csv",
+		Subnet:     "192.168.1.0/24",
+		Vendor:     "raspberry",
+		SeenWithin: 10 * time.Minute,
+		HasIPv6:    true,
+		Sort:       "ip",
+		Order:      "desc",
+		Limit:      20,
+	}
+	if err := Dump(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "exec l2radar /l2radar dump --iface eth0 --iface wlan0 -o csv --subnet 192.168.1.0/24 --vendor raspberry --sort ip --order desc --seen-within 10m0s --has-ipv6 --limit 20"
+	if len(m.Calls) != 1 || strings.Join(m.Calls[0], " ") != want {
+		t.Fatalf("expected args %q, got %v", want, m.Calls)
+	}
+}
+
+func TestDumpInvalidQuery(t *testing.T) {
+	for _, opts := range []Opts{
+		{Ifaces: []string{"all"}, Sort: "hostname"},
+		{Ifaces: []string{"all"}, Order: "up"},
+		{Ifaces: []string{"all"}, Limit: -1},
+	} {
+		m := &docker.MockRunner{}
+		if err := Dump(m, opts); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+		if len(m.Calls) != 0 {
+			t.Errorf("expected no docker calls, got %d", len(m.Calls))
+		}
+	}
+}
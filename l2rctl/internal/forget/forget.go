@@ -0,0 +1,53 @@
+package forget
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const ProbeContainer = "l2radar"
+
+// Opts holds forget command options.
+type Opts struct {
+	Iface string
+	MACs  []string
+}
+
+// FlushOpts holds flush command options.
+type FlushOpts struct {
+	Iface     string
+	OlderThan time.Duration
+}
+
+// Forget deletes MAC addresses from an interface's neighbour table.
+func Forget(r docker.Runner, opts Opts) error {
+	if opts.Iface == "" {
+		return fmt.Errorf("interface name is required")
+	}
+	if len(opts.MACs) == 0 {
+		return fmt.Errorf("at least one MAC address is required")
+	}
+
+	args := []string{"exec", ProbeContainer, "/l2radar", "forget", "--iface", opts.Iface}
+	args = append(args, opts.MACs...)
+	return r.RunAttached(args...)
+}
+
+// Flush deletes all entries of an interface's neighbour table, or only
+// those not seen for OlderThan if set.
+func Flush(r docker.Runner, opts FlushOpts) error {
+	if opts.Iface == "" {
+		return fmt.Errorf("interface name is required")
+	}
+	if opts.OlderThan < 0 {
+		return fmt.Errorf("older-than must not be negative")
+	}
+
+	args := []string{"exec", ProbeContainer, "/l2radar", "flush", "--iface", opts.Iface}
+	if opts.OlderThan > 0 {
+		args = append(args, "--older-than", opts.OlderThan.String())
+	}
+	return r.RunAttached(args...)
+}
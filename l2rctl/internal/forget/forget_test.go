@@ -0,0 +1,81 @@
+package forget
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+func TestForgetArgs(t *testing.T) {
+	m := &docker.MockRunner{}
+	err := Forget(m, Opts{Iface: "eth0", MACs: []string{"02:00:00:00:00:01", "02:00:00:00:00:02"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(m.Calls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(m.Calls))
+	}
+	want := []string{"exec", "l2radar", "/l2radar", "forget", "--iface", "eth0",
+		"02:00:00:00:00:01", "02:00:00:00:00:02"}
+	if !slices.Equal(m.Calls[0], want) {
+		t.Errorf("expected args %v, got %v", want, m.Calls[0])
+	}
+}
+
+func TestForgetValidation(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Forget(m, Opts{MACs: []string{"02:00:00:00:00:01"}}); err == nil {
+		t.Error("expected error for missing interface")
+	}
+	if err := Forget(m, Opts{Iface: "eth0"}); err == nil {
+		t.Error("expected error for missing MACs")
+	}
+	if len(m.Calls) != 0 {
+		t.Errorf("expected no calls, got %v", m.Calls)
+	}
+}
+
+func TestFlushArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts FlushOpts
+		want []string
+	}{
+		{
+			name: "all",
+			opts: FlushOpts{Iface: "eth0"},
+			want: []string{"exec", "l2radar", "/l2radar", "flush", "--iface", "eth0"},
+		},
+		{
+			name: "older than",
+			opts: FlushOpts{Iface: "eth0", OlderThan: time.Hour},
+			want: []string{"exec", "l2radar", "/l2radar", "flush", "--iface", "eth0", "--older-than", "1h0m0s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &docker.MockRunner{}
+			if err := Flush(m, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(m.Calls) != 1 || !slices.Equal(m.Calls[0], tt.want) {
+				t.Errorf("expected args %v, got %v", tt.want, m.Calls)
+			}
+		})
+	}
+}
+
+func TestFlushValidation(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Flush(m, FlushOpts{}); err == nil {
+		t.Error("expected error for missing interface")
+	}
+	if err := Flush(m, FlushOpts{Iface: "eth0", OlderThan: -time.Second}); err == nil {
+		t.Error("expected error for negative older-than")
+	}
+	if len(m.Calls) != 0 {
+		t.Errorf("expected no calls, got %v", m.Calls)
+	}
+}
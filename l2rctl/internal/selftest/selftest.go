@@ -0,0 +1,27 @@
+package selftest
+
+import (
+	"fmt"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const ProbeContainer = "l2radar"
+
+// Opts holds selftest command options.
+type Opts struct {
+	Output string
+}
+
+// Selftest runs the probe's selftest inside the running probe container.
+func Selftest(r docker.Runner, opts Opts) error {
+	if opts.Output != "" && opts.Output != "table" && opts.Output != "json" {
+		return fmt.Errorf("invalid output format %q (supported: table, json)", opts.Output)
+	}
+
+	args := []string{"exec", ProbeContainer, "/l2radar", "selftest"}
+	if opts.Output != "" {
+		args = append(args, "-o", opts.Output)
+	}
+	return r.RunAttached(args...)
+}
@@ -0,0 +1,60 @@
+package selftest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+func TestSelftestArgs(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Selftest(m, Opts{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(m.Calls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(m.Calls))
+	}
+	want := []string{"exec", "l2radar", "/l2radar", "selftest"}
+	got := m.Calls[0]
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected args %v, got %v", want, got)
+	}
+}
+
+func TestSelftestJSONOutput(t *testing.T) {
+	m := &docker.MockRunner{}
+	if err := Selftest(m, Opts{Output: "json"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"exec", "l2radar", "/l2radar", "selftest", "-o", "json"}
+	got := m.Calls[0]
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected args %v, got %v", want, got)
+	}
+}
+
+func TestSelftestInvalidOutput(t *testing.T) {
+	m := &docker.MockRunner{}
+	err := Selftest(m, Opts{Output: "yaml"})
+	if err == nil || !strings.Contains(err.Error(), "invalid output format") {
+		t.Fatalf("expected invalid output format error, got: %v", err)
+	}
+	if len(m.Calls) != 0 {
+		t.Fatalf("expected no docker calls, got %d", len(m.Calls))
+	}
+}
+
+func TestSelftestFailurePropagates(t *testing.T) {
+	m := &docker.MockRunner{
+		ErrFn: func(args []string) error {
+			return fmt.Errorf("exit status 1")
+		},
+	}
+	if err := Selftest(m, Opts{}); err == nil {
+		t.Fatal("expected error from failed selftest")
+	}
+}
@@ -0,0 +1,180 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const ProbeContainer = "l2radar"
+
+// manifestFile is the index the probe writes to its export directory.
+const manifestFile = "manifest.json"
+
+// mountDir is where Diff mounts the snapshots in the probe container.
+const mountDir = "/snapshots"
+
+// Formats are the output formats of the probe's diff command.
+var Formats = []string{"table", "json"}
+
+// Opts holds snapshot command options.
+type Opts struct {
+	// Dir is the snapshot directory on the host; created if missing, and
+	// must be empty if it exists.
+	Dir string
+	// ExportDir is the probe's export directory inside its container.
+	ExportDir string
+	// Out receives progress messages; os.Stdout if nil.
+	Out io.Writer
+}
+
+// DefaultDir returns the default snapshot directory name for time t.
+func DefaultDir(t time.Time) string {
+	return "l2radar-snapshot-" + t.UTC().Format("20060102T150405Z")
+}
+
+// manifest holds the manifest fields Save needs.
+type manifest struct {
+	Interfaces []struct {
+		Name  string `json:"name"`
+		Files []struct {
+			Format string `json:"format"`
+			Name   string `json:"name"`
+		} `json:"files"`
+	} `json:"interfaces"`
+}
+
+// Save copies the running probe's JSON exports and manifest to a local
+// directory, for a later Diff.
+func Save(r docker.Runner, opts Opts) error {
+	if opts.Dir == "" {
+		return fmt.Errorf("snapshot directory is required")
+	}
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	if entries, err := os.ReadDir(opts.Dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", opts.Dir)
+	}
+
+	mb, err := readExport(r, opts.ExportDir, manifestFile)
+	if err != nil {
+		return err
+	}
+	var m manifest
+	if err := json.Unmarshal(mb, &m); err != nil {
+		return fmt.Errorf("parsing probe manifest: %w", err)
+	}
+	var names []string
+	for _, ifc := range m.Interfaces {
+		for _, f := range ifc.Files {
+			if f.Format == "json" {
+				names = append(names, f.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("the probe has not exported any interface yet")
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		b, err := readExport(r, opts.ExportDir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(opts.Dir, name), b, 0644); err != nil {
+			return err
+		}
+	}
+	// The manifest last, as the probe does.
+	if err := os.WriteFile(filepath.Join(opts.Dir, manifestFile), mb, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Saved %d interface(s) to %s\n", len(names), opts.Dir)
+	return nil
+}
+
+// readExport returns a file of the probe's export directory.
+func readExport(r docker.Runner, exportDir, name string) ([]byte, error) {
+	stdout, stderr, err := r.Run("exec", ProbeContainer, "cat", exportDir+"/"+name)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from probe: %s", name, strings.TrimSpace(stderr))
+	}
+	return []byte(stdout), nil
+}
+
+// DiffOpts holds diff command options.
+type DiffOpts struct {
+	// Old and New are snapshot directories or interface export files on
+	// the host. With an empty New, Old is compared with the running
+	// probe's exports.
+	Old string
+	New string
+	// Output is the output format, table if empty.
+	Output    string
+	ExportDir string
+	Image     string
+}
+
+// Diff compares two snapshots with "l2radar diff" in a throwaway probe
+// container, so the host needs no probe binary.
+func Diff(r docker.Runner, opts DiffOpts) error {
+	if opts.Old == "" {
+		return fmt.Errorf("snapshot to compare is required")
+	}
+	if opts.Output != "" && !slices.Contains(Formats, opts.Output) {
+		return fmt.Errorf("invalid output format %q (supported: %s)", opts.Output, strings.Join(Formats, ", "))
+	}
+
+	args := []string{"run", "--rm"}
+	var paths []string
+	for _, s := range []struct{ path, name string }{{opts.Old, "old"}, {opts.New, "new"}} {
+		if s.path == "" {
+			// The running probe's export volume.
+			args = append(args, "--volumes-from", ProbeContainer+":ro")
+			paths = append(paths, opts.ExportDir)
+			continue
+		}
+		mount, path, err := snapshotMount(s.path, mountDir+"/"+s.name)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", mount)
+		paths = append(paths, path)
+	}
+	args = append(args, opts.Image, "diff")
+	if opts.Output != "" {
+		args = append(args, "-o", opts.Output)
+	}
+	args = append(args, paths...)
+	return r.RunAttached(args...)
+}
+
+// snapshotMount returns the read-only bind mount of a snapshot directory,
+// or of the directory of a snapshot file, at target, and the snapshot's
+// path in the container.
+func snapshotMount(path, target string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", "", err
+	}
+	if fi.IsDir() {
+		return fmt.Sprintf("%s:%s:ro", abs, target), target, nil
+	}
+	return fmt.Sprintf("%s:%s:ro", filepath.Dir(abs), target), target + "/" + filepath.Base(abs), nil
+}
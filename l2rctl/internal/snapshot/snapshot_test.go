@@ -0,0 +1,147 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msune/l2radar/l2rctl/internal/docker"
+)
+
+const testManifest = `{"schema_version": 1, "interfaces": [
+  {"name": "eth0", "files": [{"format": "json", "name": "neigh-eth0.json"}, {"format": "csv", "name": "neigh-eth0.csv"}]},
+  {"name": "wlan0", "files": [{"format": "json", "name": "neigh-wlan0.json"}]}
+]}`
+
+// probeFiles serves the probe's export directory to "docker exec cat".
+func probeFiles(files map[string]string) *docker.MockRunner {
+	return &docker.MockRunner{
+		StdoutFn: func(args []string) string {
+			return files[filepath.Base(args[len(args)-1])]
+		},
+		StderrFn: func(args []string) string {
+			if _, ok := files[filepath.Base(args[len(args)-1])]; !ok {
+				return "cat: No such file or directory\n"
+			}
+			return ""
+		},
+		ErrFn: func(args []string) error {
+			if _, ok := files[filepath.Base(args[len(args)-1])]; !ok {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+	}
+}
+
+func TestSave(t *testing.T) {
+	m := probeFiles(map[string]string{
+		"manifest.json":    testManifest,
+		"neigh-eth0.json":  `{"interface": "eth0"}`,
+		"neigh-wlan0.json": `{"interface": "wlan0"}`,
+	})
+	dir := filepath.Join(t.TempDir(), "snap")
+	var out strings.Builder
+	if err := Save(m, Opts{Dir: dir, ExportDir: "/var/lib/l2radar", Out: &out}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"exec l2radar cat /var/lib/l2radar/manifest.json",
+		"exec l2radar cat /var/lib/l2radar/neigh-eth0.json",
+		"exec l2radar cat /var/lib/l2radar/neigh-wlan0.json",
+	}
+	if len(m.Calls) != len(want) {
+		t.Fatalf("expected %d calls, got %v", len(want), m.Calls)
+	}
+	for i, w := range want {
+		if got := strings.Join(m.Calls[i], " "); got != w {
+			t.Errorf("call %d: expected %q, got %q", i, w, got)
+		}
+	}
+	for name, content := range map[string]string{"manifest.json": testManifest, "neigh-eth0.json": `{"interface": "eth0"}`} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(b) != content {
+			t.Errorf("%s: unexpected content %q %v", name, b, err)
+		}
+	}
+	if !strings.Contains(out.String(), "Saved 2 interface(s)") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	// The snapshot directory must be new or empty.
+	if err := Save(m, Opts{Dir: dir, ExportDir: "/var/lib/l2radar", Out: &out}); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("expected error for a non-empty directory, got %v", err)
+	}
+}
+
+func TestSaveErrors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snap")
+	err := Save(probeFiles(nil), Opts{Dir: dir, ExportDir: "/var/lib/l2radar", Out: &strings.Builder{}})
+	if err == nil || !strings.Contains(err.Error(), "reading manifest.json from probe: cat: No such file") {
+		t.Errorf("expected manifest error, got %v", err)
+	}
+	err = Save(probeFiles(map[string]string{"manifest.json": `{"interfaces": []}`}), Opts{Dir: dir, ExportDir: "/var/lib/l2radar", Out: &strings.Builder{}})
+	if err == nil || !strings.Contains(err.Error(), "not exported any interface") {
+		t.Errorf("expected error without interfaces, got %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("the snapshot directory should not be created on error")
+	}
+}
+
+func TestDefaultDir(t *testing.T) {
+	if got := DefaultDir(time.Date(2026, 10, 18, 12, 30, 5, 0, time.UTC)); got != "l2radar-snapshot-20261018T123005Z" {
+		t.Errorf("unexpected default dir %q", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := t.TempDir()
+	newer := t.TempDir()
+	file := filepath.Join(newer, "neigh-eth0.json")
+	os.WriteFile(file, []byte("{}"), 0644)
+
+	tests := map[string]struct {
+		opts DiffOpts
+		want string
+	}{
+		"two snapshots": {
+			DiffOpts{Old: old, New: newer, Output: "json"},
+			"run --rm -v " + old + ":/snapshots/old:ro -v " + newer + ":/snapshots/new:ro img diff -o json /snapshots/old /snapshots/new",
+		},
+		"running probe": {
+			DiffOpts{Old: old},
+			"run --rm -v " + old + ":/snapshots/old:ro --volumes-from l2radar:ro img diff /snapshots/old /var/lib/l2radar",
+		},
+		"file": {
+			DiffOpts{Old: file, New: file},
+			"run --rm -v " + newer + ":/snapshots/old:ro -v " + newer + ":/snapshots/new:ro img diff /snapshots/old/neigh-eth0.json /snapshots/new/neigh-eth0.json",
+		},
+	}
+	for name, tt := range tests {
+		m := &docker.MockRunner{}
+		tt.opts.ExportDir = "/var/lib/l2radar"
+		tt.opts.Image = "img"
+		if err := Diff(m, tt.opts); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := strings.Join(m.Calls[0], " "); got != tt.want {
+			t.Errorf("%s:\nexpected %q\ngot      %q", name, tt.want, got)
+		}
+	}
+
+	for name, opts := range map[string]DiffOpts{
+		"no snapshot": {},
+		"bad output":  {Old: old, Output: "yaml"},
+		"missing":     {Old: filepath.Join(old, "nope")},
+	} {
+		if err := Diff(&docker.MockRunner{}, opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package start
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/msune/l2radar/l2rctl/internal/docker"
 )
@@ -16,24 +19,164 @@ type ProbeOpts struct {
 	Image          string
 	ExtraArgs      string
 	RestartPolicy  string
+	SkipPreflight  bool
+	// ConfigFile is a probe configuration file on the host. Its directory
+	// is mounted read-only so edits (which usually replace the file) are
+	// seen by the probe on reload.
+	ConfigFile string
+	// APIListen enables the probe's HTTP API on this address (host
+	// network).
+	APIListen string
+	// APITokenFile is a host file holding the API bearer token; mounted
+	// read-only at ProbeAPITokenPath.
+	APITokenFile string
+	// MetricsTextfile is a host path for the probe's node_exporter
+	// textfile; its directory is mounted at ProbeMetricsDir.
+	MetricsTextfile string
+	// Syslog is the probe's --syslog address. A unix socket ("local" or
+	// unix://path) is mounted from the host.
+	Syslog         string
+	SyslogFormat   string
+	SyslogFacility string
+	// SyslogTLSCA is a host CA file for a tls:// syslog server; mounted
+	// read-only at ProbeSyslogCAPath.
+	SyslogTLSCA string
+	// Journal enables the probe's event journal in the export volume.
+	Journal bool
+}
+
+// ProbeMetricsDir is where the directory of ProbeOpts.MetricsTextfile is
+// mounted.
+const ProbeMetricsDir = "/run/l2radar-metrics"
+
+// ProbeAPITokenPath is where ProbeOpts.APITokenFile is mounted.
+const ProbeAPITokenPath = "/run/secrets/l2radar-api-token"
+
+// ProbeSyslogCAPath is where ProbeOpts.SyslogTLSCA is mounted.
+const ProbeSyslogCAPath = "/run/secrets/l2radar-syslog-ca.pem"
+
+// syslogSocket returns the unix socket path of a --syslog address, if it
+// is one.
+func syslogSocket(addr string) (string, bool) {
+	if addr == "local" {
+		return "/dev/log", true
+	}
+	return strings.CutPrefix(addr, "unix://")
+}
+
+// ProbeEventsDir is where the events volume (EventsVolumeName) is mounted
+// in the probe and UI containers.
+const ProbeEventsDir = "/run/l2radar-events"
+
+// ProbeEventsSocket is the probe's events-only socket (--events-socket).
+// The UI's nginx proxies /events to it, so no token is needed.
+const ProbeEventsSocket = ProbeEventsDir + "/events.sock"
+
+// UIGroupID is the group nginx runs as in the UI image, given access to
+// ProbeEventsSocket.
+const UIGroupID = 101
+
+// EventsVolumeName returns the named volume holding the events socket.
+// It is shared by the probe and UI only, unlike the export volume.
+func EventsVolumeName(volumeName string) string {
+	return volumeName + "-events"
+}
+
+// ProbeConfigDir is where the directory of ProbeOpts.ConfigFile is
+// mounted inside the probe container.
+const ProbeConfigDir = "/etc/l2radar"
+
+// ProbeConfigPath returns the container path of a host config file.
+func ProbeConfigPath(hostFile string) string {
+	return ProbeConfigDir + "/" + filepath.Base(hostFile)
+}
+
+// preflight runs "l2radar check-kernel" in a throwaway container with the
+// same privileges as the probe, so kernel problems are reported with hints
+// before the probe container is created.
+func preflight(r docker.Runner, opts ProbeOpts) error {
+	args := []string{"run", "--rm",
+		"--privileged",
+		"--network=host",
+		"-v", "/sys/fs/bpf:/sys/fs/bpf",
+		opts.Image,
+		"check-kernel",
+	}
+	for _, iface := range opts.Ifaces {
+		args = append(args, "--iface", iface)
+	}
+	if opts.PinPath != "" {
+		args = append(args, "--pin-path", opts.PinPath)
+	}
+
+	stdout, stderr, err := r.Run(args...)
+	if err != nil {
+		return fmt.Errorf("kernel preflight failed (use --skip-preflight to bypass):\n%s%s", stdout, stderr)
+	}
+	return nil
 }
 
 // StartProbe starts the l2radar probe container.
 func StartProbe(r docker.Runner, opts ProbeOpts) error {
+	if opts.APITokenFile != "" && opts.APIListen == "" {
+		return fmt.Errorf("--api-token-file requires --api-listen")
+	}
+	if opts.Syslog == "" && (opts.SyslogFormat != "" || opts.SyslogFacility != "" || opts.SyslogTLSCA != "") {
+		return fmt.Errorf("--syslog-format, --syslog-facility and --syslog-tls-ca require --syslog")
+	}
 	if err := ensureNotRunning(r, ProbeContainer); err != nil {
 		return err
 	}
 	if err := pullImage(r, opts.Image); err != nil {
 		return err
 	}
+	if !opts.SkipPreflight {
+		if err := preflight(r, opts); err != nil {
+			return err
+		}
+	}
 
 	args := []string{"run", "-d",
 		"--privileged",
 		"--network=host",
 		"-v", "/sys/fs/bpf:/sys/fs/bpf",
 		"-v", fmt.Sprintf("%s:%s", opts.VolumeName, opts.ExportDir),
+		"-v", fmt.Sprintf("%s:%s", EventsVolumeName(opts.VolumeName), ProbeEventsDir),
 		"--name", ProbeContainer,
 	}
+	if opts.ConfigFile != "" {
+		abs, err := filepath.Abs(opts.ConfigFile)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", filepath.Dir(abs), ProbeConfigDir))
+	}
+	if opts.APITokenFile != "" {
+		abs, err := filepath.Abs(opts.APITokenFile)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", abs, ProbeAPITokenPath))
+	}
+
+	if opts.MetricsTextfile != "" {
+		abs, err := filepath.Abs(opts.MetricsTextfile)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s", filepath.Dir(abs), ProbeMetricsDir))
+	}
+
+	if path, ok := syslogSocket(opts.Syslog); ok {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", path, path))
+	}
+	if opts.SyslogTLSCA != "" {
+		abs, err := filepath.Abs(opts.SyslogTLSCA)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", abs, ProbeSyslogCAPath))
+	}
 
 	if opts.RestartPolicy != "" {
 		args = append(args, "--restart", opts.RestartPolicy)
@@ -43,13 +186,47 @@ func StartProbe(r docker.Runner, opts ProbeOpts) error {
 
 	args = append(args, opts.Image)
 
-	// Container command args
+	// Container command args. With a config file, only the flags that were
+	// given are passed since they override the file; --export-dir always
+	// is, to match the volume mount.
+	if opts.ConfigFile != "" {
+		args = append(args, "--config", ProbeConfigPath(opts.ConfigFile))
+	}
 	for _, iface := range opts.Ifaces {
 		args = append(args, "--iface", iface)
 	}
 	args = append(args, "--export-dir", opts.ExportDir)
-	args = append(args, "--export-interval", opts.ExportInterval)
-	args = append(args, "--pin-path", opts.PinPath)
+	if opts.ExportInterval != "" {
+		args = append(args, "--export-interval", opts.ExportInterval)
+	}
+	if opts.Journal {
+		args = append(args, "--journal")
+	}
+	if opts.PinPath != "" {
+		args = append(args, "--pin-path", opts.PinPath)
+	}
+	args = append(args, "--events-socket", ProbeEventsSocket, "--events-socket-gid", strconv.Itoa(UIGroupID))
+	if opts.APIListen != "" {
+		args = append(args, "--listen", opts.APIListen)
+	}
+	if opts.APITokenFile != "" {
+		args = append(args, "--listen-token-file", ProbeAPITokenPath)
+	}
+	if opts.MetricsTextfile != "" {
+		args = append(args, "--metrics-textfile", ProbeMetricsDir+"/"+filepath.Base(opts.MetricsTextfile))
+	}
+	if opts.Syslog != "" {
+		args = append(args, "--syslog", opts.Syslog)
+	}
+	if opts.SyslogFormat != "" {
+		args = append(args, "--syslog-format", opts.SyslogFormat)
+	}
+	if opts.SyslogFacility != "" {
+		args = append(args, "--syslog-facility", opts.SyslogFacility)
+	}
+	if opts.SyslogTLSCA != "" {
+		args = append(args, "--syslog-tls-ca", ProbeSyslogCAPath)
+	}
 
 	_, _, err := r.Run(args...)
 	return err
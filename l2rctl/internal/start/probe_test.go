@@ -8,6 +8,17 @@ import (
 	"github.com/msune/l2radar/l2rctl/internal/docker"
 )
 
+// probeRunCall returns the "docker run -d" call that creates the probe
+// container, skipping the preflight container.
+func probeRunCall(calls [][]string) []string {
+	for _, c := range calls {
+		if len(c) > 1 && c[0] == "run" && c[1] == "-d" {
+			return c
+		}
+	}
+	return nil
+}
+
 func TestStartProbeDefaultArgs(t *testing.T) {
 	m := &docker.MockRunner{}
 	opts := ProbeOpts{
@@ -29,13 +40,7 @@ func TestStartProbeDefaultArgs(t *testing.T) {
 	}
 
 	// Find the "run" call
-	var runCall []string
-	for _, c := range m.Calls {
-		if len(c) > 0 && c[0] == "run" {
-			runCall = c
-			break
-		}
-	}
+	runCall := probeRunCall(m.Calls)
 	if runCall == nil {
 		t.Fatal("no 'run' call found")
 	}
@@ -46,6 +51,7 @@ func TestStartProbeDefaultArgs(t *testing.T) {
 		"--network=host",
 		"-v /sys/fs/bpf:/sys/fs/bpf",
 		"-v l2radar-data:/var/lib/l2radar",
+		"-v l2radar-data-events:/run/l2radar-events",
 		"--name l2radar",
 		"ghcr.io/msune/l2radar:latest",
 		"--iface external",
@@ -75,13 +81,7 @@ func TestStartProbeNoBindMount(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	var runCall []string
-	for _, c := range m.Calls {
-		if len(c) > 0 && c[0] == "run" {
-			runCall = c
-			break
-		}
-	}
+	runCall := probeRunCall(m.Calls)
 	if runCall == nil {
 		t.Fatal("no 'run' call found")
 	}
@@ -109,13 +109,7 @@ func TestStartProbeMultipleIfaces(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	var runCall []string
-	for _, c := range m.Calls {
-		if len(c) > 0 && c[0] == "run" {
-			runCall = c
-			break
-		}
-	}
+	runCall := probeRunCall(m.Calls)
 	args := strings.Join(runCall, " ")
 	if !strings.Contains(args, "--iface eth0 --iface eth1") {
 		t.Errorf("missing multiple --iface flags in: %s", args)
@@ -139,13 +133,7 @@ func TestStartProbeExtraDockerArgs(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	var runCall []string
-	for _, c := range m.Calls {
-		if len(c) > 0 && c[0] == "run" {
-			runCall = c
-			break
-		}
-	}
+	runCall := probeRunCall(m.Calls)
 	args := strings.Join(runCall, " ")
 	if !strings.Contains(args, "--cpus 2 --memory 512m") {
 		t.Errorf("missing extra args in: %s", args)
@@ -380,13 +368,7 @@ func TestStartProbeRestartPolicy(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	var runCall []string
-	for _, c := range m.Calls {
-		if len(c) > 0 && c[0] == "run" {
-			runCall = c
-			break
-		}
-	}
+	runCall := probeRunCall(m.Calls)
 	if runCall == nil {
 		t.Fatal("no 'run' call found")
 	}
@@ -412,13 +394,7 @@ func TestStartProbeNoRestartPolicyByDefault(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	var runCall []string
-	for _, c := range m.Calls {
-		if len(c) > 0 && c[0] == "run" {
-			runCall = c
-			break
-		}
-	}
+	runCall := probeRunCall(m.Calls)
 	if runCall == nil {
 		t.Fatal("no 'run' call found")
 	}
@@ -460,3 +436,268 @@ func TestStartProbeImageOnlyNoContainer(t *testing.T) {
 		}
 	}
 }
+
+func TestStartProbePreflightArgs(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		Ifaces:         []string{"eth0"},
+		ExportDir:      "/var/lib/l2radar",
+		VolumeName:     "l2radar-data",
+		ExportInterval: "5s",
+		PinPath:        "/sys/fs/bpf/l2radar",
+		Image:          "ghcr.io/msune/l2radar:latest",
+	}
+
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	preflightIdx, runIdx := -1, -1
+	for i, c := range m.Calls {
+		if len(c) > 1 && c[0] == "run" && c[1] == "--rm" {
+			preflightIdx = i
+			args := strings.Join(c, " ")
+			for _, want := range []string{
+				"--privileged",
+				"--network=host",
+				"-v /sys/fs/bpf:/sys/fs/bpf",
+				"ghcr.io/msune/l2radar:latest check-kernel",
+				"--iface eth0",
+				"--pin-path /sys/fs/bpf/l2radar",
+			} {
+				if !strings.Contains(args, want) {
+					t.Errorf("missing %q in preflight args: %s", want, args)
+				}
+			}
+		}
+		if len(c) > 1 && c[0] == "run" && c[1] == "-d" {
+			runIdx = i
+		}
+	}
+	if preflightIdx < 0 {
+		t.Fatal("no preflight call found")
+	}
+	if preflightIdx > runIdx {
+		t.Error("preflight must happen before run")
+	}
+}
+
+func TestStartProbePreflightFailure(t *testing.T) {
+	m := &docker.MockRunner{
+		StdoutFn: func(args []string) string {
+			if len(args) > 1 && args[0] == "run" && args[1] == "--rm" {
+				return "tcx  FAIL  not supported by this kernel\n"
+			}
+			return ""
+		},
+		ErrFn: func(args []string) error {
+			if len(args) > 1 && args[0] == "run" && args[1] == "--rm" {
+				return fmt.Errorf("exit status 1")
+			}
+			return nil
+		},
+	}
+	opts := ProbeOpts{
+		Ifaces:         []string{"external"},
+		ExportDir:      "/var/lib/l2radar",
+		VolumeName:     "l2radar-data",
+		ExportInterval: "5s",
+		PinPath:        "/sys/fs/bpf/l2radar",
+		Image:          "ghcr.io/msune/l2radar:latest",
+	}
+
+	err := StartProbe(m, opts)
+	if err == nil {
+		t.Fatal("expected error on preflight failure")
+	}
+	if !strings.Contains(err.Error(), "kernel preflight failed") || !strings.Contains(err.Error(), "tcx  FAIL") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if probeRunCall(m.Calls) != nil {
+		t.Error("probe container must not be started after preflight failure")
+	}
+}
+
+func TestStartProbeSkipPreflight(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		Ifaces:         []string{"external"},
+		ExportDir:      "/var/lib/l2radar",
+		VolumeName:     "l2radar-data",
+		ExportInterval: "5s",
+		PinPath:        "/sys/fs/bpf/l2radar",
+		Image:          "ghcr.io/msune/l2radar:latest",
+		SkipPreflight:  true,
+	}
+
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range m.Calls {
+		if len(c) > 1 && c[0] == "run" && c[1] == "--rm" {
+			t.Errorf("preflight must be skipped, got: %v", c)
+		}
+	}
+	if probeRunCall(m.Calls) == nil {
+		t.Error("no probe run call found")
+	}
+}
+
+func TestStartProbeConfigFile(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		ExportDir:  "/var/lib/l2radar",
+		VolumeName: "l2radar-data",
+		Image:      "ghcr.io/msune/l2radar:latest",
+		ConfigFile: "/etc/l2radar/l2radar.yaml",
+	}
+
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runCall := probeRunCall(m.Calls)
+	if runCall == nil {
+		t.Fatal("no probe run call found")
+	}
+
+	args := strings.Join(runCall, " ")
+	for _, want := range []string{
+		"-v /etc/l2radar:/etc/l2radar:ro",
+		"ghcr.io/msune/l2radar:latest --config /etc/l2radar/l2radar.yaml --export-dir /var/lib/l2radar",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in args: %s", want, args)
+		}
+	}
+	// Settings not given explicitly are left to the file.
+	for _, unwanted := range []string{"--iface", "--export-interval", "--pin-path"} {
+		if strings.Contains(args, unwanted) {
+			t.Errorf("unexpected %q in args: %s", unwanted, args)
+		}
+	}
+}
+
+func TestStartProbeAPI(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		Ifaces:       []string{"eth0"},
+		ExportDir:    "/var/lib/l2radar",
+		VolumeName:   "l2radar-data",
+		Image:        "ghcr.io/msune/l2radar:latest",
+		APIListen:    "127.0.0.1:9110",
+		APITokenFile: "/etc/l2radar/api-token",
+	}
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := strings.Join(probeRunCall(m.Calls), " ")
+	for _, want := range []string{
+		"-v /etc/l2radar/api-token:/run/secrets/l2radar-api-token:ro",
+		"--events-socket /run/l2radar-events/events.sock --events-socket-gid 101",
+		"--listen 127.0.0.1:9110",
+		"--listen-token-file /run/secrets/l2radar-api-token",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in args: %s", want, args)
+		}
+	}
+
+	m = &docker.MockRunner{}
+	opts.APIListen = ""
+	if err := StartProbe(m, opts); err == nil {
+		t.Error("expected error for token file without listen address")
+	}
+}
+
+func TestStartProbeMetricsTextfile(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		Ifaces:          []string{"eth0"},
+		ExportDir:       "/var/lib/l2radar",
+		VolumeName:      "l2radar-data",
+		Image:           "ghcr.io/msune/l2radar:latest",
+		MetricsTextfile: "/var/lib/node_exporter/textfile/l2radar.prom",
+	}
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := strings.Join(probeRunCall(m.Calls), " ")
+	for _, want := range []string{
+		"-v /var/lib/node_exporter/textfile:/run/l2radar-metrics",
+		"--metrics-textfile /run/l2radar-metrics/l2radar.prom",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in args: %s", want, args)
+		}
+	}
+}
+
+func TestStartProbeJournal(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		Ifaces:     []string{"eth0"},
+		ExportDir:  "/var/lib/l2radar",
+		VolumeName: "l2radar-data",
+		Image:      "ghcr.io/msune/l2radar:latest",
+	}
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args := strings.Join(probeRunCall(m.Calls), " "); strings.Contains(args, "--journal") {
+		t.Errorf("unexpected --journal in args: %s", args)
+	}
+
+	m = &docker.MockRunner{}
+	opts.Journal = true
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args := strings.Join(probeRunCall(m.Calls), " "); !strings.Contains(args, "--export-dir /var/lib/l2radar --journal") {
+		t.Errorf("missing --journal in args: %s", args)
+	}
+}
+
+func TestStartProbeSyslog(t *testing.T) {
+	m := &docker.MockRunner{}
+	opts := ProbeOpts{
+		Ifaces:       []string{"eth0"},
+		ExportDir:    "/var/lib/l2radar",
+		VolumeName:   "l2radar-data",
+		Image:        "ghcr.io/msune/l2radar:latest",
+		Syslog:       "tls://siem.example:6514",
+		SyslogFormat: "cef",
+		SyslogTLSCA:  "/etc/pki/siem-ca.pem",
+	}
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := strings.Join(probeRunCall(m.Calls), " ")
+	for _, want := range []string{
+		"-v /etc/pki/siem-ca.pem:/run/secrets/l2radar-syslog-ca.pem:ro",
+		"--syslog tls://siem.example:6514 --syslog-format cef --syslog-tls-ca /run/secrets/l2radar-syslog-ca.pem",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in args: %s", want, args)
+		}
+	}
+	if strings.Contains(args, "/dev/log") || strings.Contains(args, "--syslog-facility") {
+		t.Errorf("unexpected args: %s", args)
+	}
+
+	// The local socket is mounted from the host.
+	m = &docker.MockRunner{}
+	opts.Syslog, opts.SyslogFormat, opts.SyslogTLSCA = "local", "", ""
+	if err := StartProbe(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args = strings.Join(probeRunCall(m.Calls), " ")
+	if !strings.Contains(args, "-v /dev/log:/dev/log") || !strings.Contains(args, "--syslog local") {
+		t.Errorf("local syslog not mounted: %s", args)
+	}
+
+	m = &docker.MockRunner{}
+	opts.Syslog, opts.SyslogFacility = "", "local4"
+	if err := StartProbe(m, opts); err == nil {
+		t.Error("expected error for syslog options without --syslog")
+	}
+}
@@ -33,6 +33,7 @@ func StartUI(r docker.Runner, opts UIOpts) error {
 
 	args := []string{"run", "-d",
 		"-v", fmt.Sprintf("%s:%s:ro", opts.VolumeName, opts.ExportDir),
+		"-v", fmt.Sprintf("%s:%s:ro", EventsVolumeName(opts.VolumeName), ProbeEventsDir),
 		"-p", fmt.Sprintf("%s:%d:443", opts.Bind, opts.HTTPSPort),
 		"--name", UIContainer,
 	}
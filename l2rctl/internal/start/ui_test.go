@@ -37,6 +37,7 @@ func TestStartUIDefaultArgs(t *testing.T) {
 	args := strings.Join(runCall, " ")
 	for _, want := range []string{
 		"-v l2radar-data:/var/lib/l2radar:ro",
+		"-v l2radar-data-events:/run/l2radar-events:ro",
 		"-p 127.0.0.1:12443:443",
 		"--name l2radar-ui",
 		"ghcr.io/msune/l2radar-ui:latest",
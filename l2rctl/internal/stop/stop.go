@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/msune/l2radar/l2rctl/internal/docker"
+	"github.com/msune/l2radar/l2rctl/internal/start"
 )
 
 const (
@@ -87,7 +88,14 @@ func Stop(r docker.Runner, opts Opts) error {
 		if err := stopContainer(r, UIContainer); err != nil {
 			return err
 		}
-		return removeVolume(r, opts.VolumeName)
+		if err := removeVolume(r, opts.VolumeName); err != nil {
+			return err
+		}
+		// The probe's events socket volume.
+		if opts.VolumeName == "" {
+			return nil
+		}
+		return removeVolume(r, start.EventsVolumeName(opts.VolumeName))
 	default:
 		return fmt.Errorf("invalid target: %s", opts.Target)
 	}
@@ -118,6 +118,9 @@ func TestStopAllRemovesVolume(t *testing.T) {
 	if !gotVolumeRm {
 		t.Error("missing 'volume rm l2radar-data' call")
 	}
+	if last := strings.Join(m.Calls[len(m.Calls)-1], " "); last != "volume rm l2radar-data-events" {
+		t.Errorf("expected the events volume removed last, got %q", last)
+	}
 }
 
 func TestStopAllVolumeRmAfterContainers(t *testing.T) {
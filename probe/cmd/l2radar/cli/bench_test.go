@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marc/l2radar/probe/pkg/bench"
+)
+
+func TestFormatBenchTable(t *testing.T) {
+	results := []bench.Result{
+		{Frame: "ipv4", Scenario: "empty", NsPerPacket: 41},
+		{Frame: "arp-request", Scenario: "empty", NsPerPacket: 95},
+		{Frame: "ipv4", Scenario: "full", NsPerPacket: 77},
+		{Frame: "arp-request", Scenario: "full", NsPerPacket: 130},
+		{Frame: "non-linear", Scenario: "empty", Unsupported: "linear only"},
+		{Frame: "non-linear", Scenario: "full", Unsupported: "linear only"},
+	}
+	scenarios := []bench.Scenario{{Name: "empty"}, {Name: "full"}}
+
+	var buf strings.Builder
+	formatBenchTable(&buf, results, scenarios)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if len(lines) != 5 {
+		t.Fatalf("expected header, 3 rows and a note, got:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[0], "empty (ns/pkt)") || !strings.Contains(lines[0], "full (ns/pkt)") {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	if f := strings.Fields(lines[1]); len(f) != 3 || f[0] != "ipv4" || f[1] != "41" || f[2] != "77" {
+		t.Errorf("unexpected ipv4 row: %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); len(f) != 3 || f[0] != "arp-request" || f[1] != "95" || f[2] != "130" {
+		t.Errorf("unexpected arp-request row: %q", lines[2])
+	}
+	if f := strings.Fields(lines[3]); len(f) != 3 || f[0] != "non-linear" || f[1] != "unsupported" || f[2] != "unsupported" {
+		t.Errorf("unexpected non-linear row: %q", lines[3])
+	}
+	if lines[4] != "non-linear: unsupported: linear only" {
+		t.Errorf("unexpected note: %q", lines[4])
+	}
+}
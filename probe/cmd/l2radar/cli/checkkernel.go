@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/marc/l2radar/probe/pkg/loader"
+	"github.com/marc/l2radar/probe/pkg/preflight"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkKernelPinPath string
+	checkKernelIfaces  []string
+	checkKernelOutput  string
+)
+
+var checkKernelCmd = &cobra.Command{
+	Use:   "check-kernel",
+	Short: "Check that the kernel and process can run the probe",
+	Long: `Probe the kernel for everything the probe needs (capabilities, eBPF
+program/map types and helpers, TCX, bpffs, BTF) and load the program through
+the verifier, without attaching anything. Failed checks come with a hint on
+how to fix them. Exits non-zero if any check fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if checkKernelOutput != "table" && checkKernelOutput != "json" {
+			return fmt.Errorf("invalid output format %q (supported: table, json)", checkKernelOutput)
+		}
+
+		var ifaces []string
+		if len(checkKernelIfaces) > 0 {
+			resolved, err := resolveInterfaces(checkKernelIfaces)
+			if err != nil {
+				return fmt.Errorf("failed to resolve interfaces: %w", err)
+			}
+			ifaces = resolved
+		}
+
+		report := preflight.Run(preflight.Opts{
+			PinPath: checkKernelPinPath,
+			Ifaces:  ifaces,
+		})
+
+		switch checkKernelOutput {
+		case "table":
+			report.Format(cmd.OutOrStdout())
+		case "json":
+			b, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal JSON: %w", err)
+			}
+			if _, err := cmd.OutOrStdout().Write(append(b, '\n')); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+		}
+
+		if !report.Passed() {
+			return fmt.Errorf("kernel preflight failed: %d of %d checks failed", len(report.Failures()), len(report.Checks))
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkKernelCmd.Flags().StringVar(&checkKernelPinPath, "pin-path", loader.DefaultPinPath, "base path for pinning eBPF maps")
+	checkKernelCmd.Flags().StringArrayVar(&checkKernelIfaces, "iface", nil, "interface to check for (repeatable; \"external\" and \"any\" are resolved)")
+	checkKernelCmd.Flags().StringVarP(&checkKernelOutput, "output", "o", "table", "output format (table|json)")
+
+	rootCmd.AddCommand(checkKernelCmd)
+}
@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/journal"
+	"github.com/marc/l2radar/probe/pkg/syslog"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect probe configuration files",
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check <file>",
+	Short: "Validate a configuration file",
+	Long: `Parse and validate a configuration file without starting the probe, and
+print the interfaces it resolves to. Exits non-zero if the file is invalid.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := config.Load(args[0])
+		if err != nil {
+			return err
+		}
+		want, err := wantInterfaces(c)
+		if err != nil {
+			return err
+		}
+		if _, err := newSinks(c, nil); err != nil {
+			return err
+		}
+		if c.Export.Journal.Enabled && c.Export.Dir == "" {
+			return errJournalNoDir
+		}
+		printConfigSummary(cmd.OutOrStdout(), c, want)
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configCheckCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// printConfigSummary writes the effective settings of a valid config.
+func printConfigSummary(w io.Writer, c *config.Config, want map[string]daemon.InterfaceOptions) {
+	fmt.Fprintf(w, "config OK\n")
+	fmt.Fprintf(w, "Interfaces:  %s\n", strings.Join(c.InterfaceNames(), ", "))
+	var resolved []string
+	for _, name := range sortedKeys(want) {
+		if want[name].NoExport {
+			name += " (no export)"
+		}
+		resolved = append(resolved, name)
+	}
+	fmt.Fprintf(w, "Resolved:    %s\n", strings.Join(resolved, ", "))
+	if c.Export.Dir != "" {
+		formats := c.Export.Formats
+		if len(formats) == 0 {
+			formats = []string{export.FormatJSON}
+		}
+		if c.Export.Combined {
+			formats = append(formats, "combined")
+		}
+		fmt.Fprintf(w, "Export:      %s every %s (%s)\n", c.Export.Dir, c.Export.Interval, strings.Join(formats, ", "))
+	} else {
+		fmt.Fprintf(w, "Export:      disabled\n")
+	}
+	if j := c.Export.Journal; j.Enabled {
+		fmt.Fprintf(w, "Journal:     %s (rotate at %d MiB or %s, keep %d)\n",
+			filepath.Join(c.Export.Dir, journal.FileName), j.MaxSizeMB, j.MaxAge, j.Keep)
+	} else {
+		fmt.Fprintf(w, "Journal:     disabled\n")
+	}
+	fmt.Fprintf(w, "Sinks:       %d\n", len(c.Sinks))
+	for _, s := range c.Sinks {
+		ifaces := "all interfaces"
+		if len(s.Interfaces) > 0 {
+			ifaces = strings.Join(s.Interfaces, ", ")
+		}
+		fmt.Fprintf(w, "  - %s (%s)\n", s.Type, ifaces)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadRootConfig builds the probe configuration from --config (if given)
+// and the root flags. Flags set on the command line override the file;
+// --iface replaces its interface list and --syslog adds a sink.
+func loadRootConfig(cmd *cobra.Command) (*config.Config, error) {
+	c := &config.Config{}
+	if rootConfigPath != "" {
+		loaded, err := config.Load(rootConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c = loaded
+	}
+
+	flags := cmd.Flags()
+	if rootConfigPath == "" || flags.Changed("iface") {
+		if len(rootIfaces) == 0 {
+			return nil, fmt.Errorf(`required flag(s) "iface" not set (or use --config)`)
+		}
+		c.Interfaces = nil
+		for _, name := range rootIfaces {
+			c.Interfaces = append(c.Interfaces, config.Interface{Name: name})
+		}
+	}
+	if c.PinPath == "" || flags.Changed("pin-path") {
+		c.PinPath = rootPinPath
+	}
+	if rootConfigPath == "" || flags.Changed("export-dir") {
+		c.Export.Dir = rootExportDir
+	}
+	if rootConfigPath == "" || flags.Changed("export-interval") {
+		c.Export.Interval = rootExportInterval
+	}
+	if flags.Changed("export-format") {
+		c.Export.Formats = rootExportFormats
+	}
+	if flags.Changed("export-columns") {
+		c.Export.Columns = rootExportColumns
+	}
+	if flags.Changed("export-combined") {
+		c.Export.Combined = rootExportCombined
+	}
+	if flags.Changed("journal") {
+		c.Export.Journal.Enabled = rootJournal
+	}
+
+	if rootSyslog != "" {
+		c.Sinks = append(c.Sinks, syslogSink())
+	}
+
+	c.SetDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// syslogSink returns the sink given by the --syslog flags.
+func syslogSink() config.Sink {
+	opts := map[string]any{
+		"address":  rootSyslog,
+		"format":   rootSyslogFormat,
+		"facility": rootSyslogFacility,
+	}
+	if rootSyslogTLSCA != "" {
+		opts["tls_ca"] = rootSyslogTLSCA
+	}
+	if len(rootSyslogEvents) > 0 {
+		opts["events"] = rootSyslogEvents
+	}
+	return config.Sink{Type: syslog.Type, Options: opts}
+}
+
+// wantInterfaces resolves the configured interfaces and their options.
+// An interface listed by name takes its options from that entry rather
+// than from an "external"/"any" entry that also matches it.
+func wantInterfaces(c *config.Config) (map[string]daemon.InterfaceOptions, error) {
+	want := make(map[string]daemon.InterfaceOptions)
+	explicit := make(map[string]bool)
+	for _, ifc := range c.Interfaces {
+		resolved, err := resolveInterfaces([]string{ifc.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve interfaces: %w", err)
+		}
+		keyword := len(resolved) != 1 || resolved[0] != ifc.Name
+		filter, err := c.Filters.Merge(ifc.Filters).Compile()
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", ifc.Name, err)
+		}
+		opts := daemon.InterfaceOptions{NoExport: !ifc.ExportEnabled(), Filter: filter}
+		for _, name := range resolved {
+			if explicit[name] {
+				continue
+			}
+			want[name] = opts
+			if !keyword {
+				explicit[name] = true
+			}
+		}
+	}
+	if len(want) == 0 {
+		return nil, fmt.Errorf("no interfaces found")
+	}
+	return want, nil
+}
+
+// reloadOnSignal calls reload with the running configuration on every
+// signal from sig until ctx is done. The running configuration is owned
+// by this goroutine: reload returns the next one, or the running one if
+// the reload failed, and the caller's cfg is never written.
+func reloadOnSignal(ctx context.Context, sig <-chan os.Signal, cfg *config.Config,
+	reload func(running *config.Config) (*config.Config, error), logger *slog.Logger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			next, err := reload(cfg)
+			if err != nil {
+				logger.Error("reload failed", "config", rootConfigPath, "error", err)
+			}
+			cfg = next
+		}
+	}
+}
+
+// reloadConfig re-reads the configuration and applies it to the running
+// daemon: only added or removed interfaces are attached or detached, and
+// options and the export interval are updated in place. Settings that need
+// a restart are reported and left unchanged.
+func reloadConfig(cmd *cobra.Command, d *daemon.Daemon, running *config.Config, logger *slog.Logger) (*config.Config, error) {
+	c, err := loadRootConfig(cmd)
+	if err != nil {
+		return running, err
+	}
+	want, err := wantInterfaces(c)
+	if err != nil {
+		return running, err
+	}
+	filter, err := c.Filters.Compile()
+	if err != nil {
+		return running, err
+	}
+
+	if c.PinPath != running.PinPath {
+		logger.Warn("pin_path change requires a restart", "running", running.PinPath, "config", c.PinPath)
+		c.PinPath = running.PinPath
+	}
+	if c.Export.Dir != running.Export.Dir {
+		logger.Warn("export.dir change requires a restart", "running", running.Export.Dir, "config", c.Export.Dir)
+		c.Export.Dir = running.Export.Dir
+	}
+	if !slices.Equal(c.Export.Formats, running.Export.Formats) || !slices.Equal(c.Export.Columns, running.Export.Columns) ||
+		c.Export.Combined != running.Export.Combined {
+		logger.Warn("export.formats, export.columns and export.combined changes require a restart")
+		c.Export.Formats, c.Export.Columns, c.Export.Combined = running.Export.Formats, running.Export.Columns, running.Export.Combined
+	}
+	if c.Export.Journal != running.Export.Journal {
+		logger.Warn("export.journal change requires a restart")
+		c.Export.Journal = running.Export.Journal
+	}
+	if !reflect.DeepEqual(c.Sinks, running.Sinks) {
+		logger.Warn("sinks change requires a restart")
+		c.Sinks = running.Sinks
+	}
+
+	d.SetFilter(filter)
+	added, removed, err := d.Reconcile(want)
+	logger.Info("configuration reloaded", "added", added, "removed", removed)
+	if err != nil {
+		logger.Error("reload: some interfaces failed to attach", "error", err)
+	}
+
+	// The interval also paces events, metrics and sinks without an
+	// export directory; SetExportInterval fails if nothing uses it.
+	if c.Export.Interval != running.Export.Interval {
+		if err := d.SetExportInterval(c.Export.Interval); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
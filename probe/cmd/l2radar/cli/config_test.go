@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+)
+
+func TestWantInterfacesExplicitOverridesKeyword(t *testing.T) {
+	original := listInterfaces
+	defer func() { listInterfaces = original }()
+	listInterfaces = func() ([]net.Interface, error) {
+		return []net.Interface{
+			{Name: "lo", Flags: net.FlagLoopback | net.FlagUp},
+			{Name: "eth0", Flags: net.FlagUp},
+			{Name: "eth1", Flags: net.FlagUp},
+			{Name: "docker0", Flags: net.FlagUp},
+		}, nil
+	}
+
+	no := false
+	c := &config.Config{Interfaces: []config.Interface{
+		{Name: "eth1", Export: &no},
+		{Name: "external"},
+	}}
+	want, err := wantInterfaces(c)
+	if err != nil {
+		t.Fatalf("wantInterfaces: %v", err)
+	}
+	if len(want) != 2 {
+		t.Fatalf("expected eth0 and eth1, got %v", sortedKeys(want))
+	}
+	if want["eth0"].NoExport || !want["eth1"].NoExport {
+		t.Errorf("unexpected options: %+v", want)
+	}
+}
+
+func TestLoadRootConfigFlagsOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "l2radar.yaml")
+	data := "interfaces: [eth0]\nexport: {dir: /tmp/from-file, interval: 30s}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := []any{rootConfigPath, rootIfaces, rootExportDir, rootExportInterval}
+	defer func() {
+		rootConfigPath = saved[0].(string)
+		rootIfaces = saved[1].([]string)
+		rootExportDir = saved[2].(string)
+		rootExportInterval = saved[3].(time.Duration)
+		for _, name := range []string{"config", "export-interval"} {
+			rootCmd.Flags().Lookup(name).Changed = false
+		}
+	}()
+
+	if err := rootCmd.Flags().Parse([]string{"--config", path, "--export-interval", "2s"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadRootConfig(rootCmd)
+	if err != nil {
+		t.Fatalf("loadRootConfig: %v", err)
+	}
+	if c.Export.Dir != "/tmp/from-file" || c.Export.Interval != 2*time.Second {
+		t.Errorf("unexpected export settings: %+v", c.Export)
+	}
+	if names := c.InterfaceNames(); len(names) != 1 || names[0] != "eth0" {
+		t.Errorf("unexpected interfaces: %v", names)
+	}
+}
+
+func TestNewSinks(t *testing.T) {
+	c, err := config.Parse([]byte("interfaces: [eth0]\nsinks:\n  - {type: webhook, url: \"http://127.0.0.1:9/hook\"}\n  - {type: mqtt, broker: \"tcp://127.0.0.1:1883\"}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks, err := newSinks(c, nil)
+	if err != nil || len(sinks) != 2 {
+		t.Fatalf("newSinks: %v, %v", sinks, err)
+	}
+
+	c.Sinks[0].Options["url"] = "not a url"
+	if _, err := newSinks(c, nil); err == nil {
+		t.Error("expected error for invalid webhook options")
+	}
+
+	// Sink types are only checked against the registry.
+	c, err = config.Parse([]byte("interfaces: [eth0]\nsinks: [{type: carrier-pigeon}]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSinks(c, nil); err == nil || !strings.Contains(err.Error(), `unknown sink type "carrier-pigeon"`) {
+		t.Errorf("expected unknown sink type error, got %v", err)
+	}
+}
+
+func TestLoadRootConfigSyslog(t *testing.T) {
+	saved := []any{rootIfaces, rootSyslog, rootSyslogFormat, rootSyslogEvents}
+	defer func() {
+		rootIfaces = saved[0].([]string)
+		rootSyslog = saved[1].(string)
+		rootSyslogFormat = saved[2].(string)
+		rootSyslogEvents = saved[3].([]string)
+		for _, name := range []string{"iface", "syslog", "syslog-format", "syslog-events"} {
+			rootCmd.Flags().Lookup(name).Changed = false
+		}
+	}()
+
+	if err := rootCmd.Flags().Parse([]string{"--iface", "eth0", "--syslog", "udp://127.0.0.1", "--syslog-format", "cef", "--syslog-events", "new_mac,ip_conflict"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadRootConfig(rootCmd)
+	if err != nil {
+		t.Fatalf("loadRootConfig: %v", err)
+	}
+	if len(c.Sinks) != 1 || c.Sinks[0].Type != "syslog" || c.Sinks[0].Options["format"] != "cef" {
+		t.Fatalf("unexpected sinks: %+v", c.Sinks)
+	}
+	if _, err := newSinks(c, nil); err != nil {
+		t.Errorf("newSinks: %v", err)
+	}
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	cfg := &config.Config{PinPath: "/sys/fs/bpf/l2radar"}
+	ctx, cancel := context.WithCancel(context.Background())
+	reloaded := make(chan *config.Config)
+	done := make(chan struct{})
+	go func() {
+		reloadOnSignal(ctx, hup, cfg, func(running *config.Config) (*config.Config, error) {
+			reloaded <- running
+			next := *running
+			next.Export.Interval += time.Second
+			return &next, nil
+		}, slog.New(slog.DiscardHandler))
+		close(done)
+	}()
+
+	for i := range 3 {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		// Each reload starts from the previous one's configuration.
+		if running := <-reloaded; running.Export.Interval != time.Duration(i)*time.Second {
+			t.Errorf("reload %d: running interval %s", i, running.Export.Interval)
+		}
+		// The caller keeps reading its configuration (run with -race).
+		if cfg.PinPath != "/sys/fs/bpf/l2radar" || cfg.Export.Interval != 0 {
+			t.Errorf("caller's configuration modified: %+v", cfg)
+		}
+	}
+	cancel()
+	<-done
+}
+
+// nopObserver keeps the export loop running without an export directory.
+type nopObserver struct{}
+
+func (nopObserver) Observe(daemon.Snapshot) {}
+func (nopObserver) Detached(string)         {}
+
+func TestReloadExportIntervalWithoutExportDir(t *testing.T) {
+	original := listInterfaces
+	defer func() { listInterfaces = original }()
+	listInterfaces = func() ([]net.Interface, error) {
+		return []net.Interface{{Name: "l2rtest0", Flags: net.FlagUp}}, nil
+	}
+	path := filepath.Join(t.TempDir(), "l2radar.yaml")
+	if err := os.WriteFile(path, []byte("interfaces: [l2rtest0]\nexport:\n  interval: 2s\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saved := rootConfigPath
+	rootConfigPath = path
+	defer func() { rootConfigPath = saved }()
+
+	logger := slog.New(slog.DiscardHandler)
+	d := daemon.New(daemon.Config{ExportInterval: 5 * time.Second, Logger: logger})
+	defer d.Close()
+	d.AddObserver(nopObserver{})
+
+	running := &config.Config{PinPath: rootPinPath, Export: config.Export{Interval: 5 * time.Second}}
+	// l2rtest0 does not exist: attaching fails and is only logged.
+	c, err := reloadConfig(rootCmd, d, running, logger)
+	if err != nil {
+		t.Fatalf("reloadConfig: %v", err)
+	}
+	if c.Export.Interval != 2*time.Second {
+		t.Errorf("unexpected reloaded interval %s", c.Export.Interval)
+	}
+	if got := d.Status().ExportInterval; got != "2s" {
+		t.Errorf("export interval not applied to the daemon: %q", got)
+	}
+}
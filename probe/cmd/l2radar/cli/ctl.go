@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/ctl"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ctlSocket    string
+	ctlOutput    string
+	ctlIface     string
+	ctlOlderThan time.Duration
+)
+
+var ctlCmd = &cobra.Command{
+	Use:   "ctl",
+	Short: "Control a running probe over its unix socket",
+	Long: `Send commands to a running probe (started with --ctl-socket) without
+restarting it: query live status, attach/detach interfaces, forget or flush
+neighbour entries, and change the export interval.`,
+}
+
+// formatStatus writes the daemon status as a summary and an interface table.
+func formatStatus(w io.Writer, st daemon.Status) {
+	fmt.Fprintf(w, "Started:         %s\n", st.Started.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Pin path:        %s\n", st.PinPath)
+	if st.ExportDir != "" {
+		fmt.Fprintf(w, "Export:          %s every %s\n", st.ExportDir, st.ExportInterval)
+	} else {
+		fmt.Fprintf(w, "Export:          disabled\n")
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INTERFACE\tENTRIES\tMAX\tLAST EXPORT\tEXPORT TIME\tERROR")
+	fmt.Fprintln(tw, "---------\t-------\t---\t-----------\t-----------\t-----")
+	for _, ifc := range st.Interfaces {
+		lastExport, took := "", ""
+		if !ifc.LastExport.IsZero() {
+			lastExport = ifc.LastExport.Format("2006-01-02 15:04:05")
+			took = fmt.Sprintf("%.1fms", ifc.ExportDurationMs)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n",
+			ifc.Name, ifc.MapEntries, ifc.MapMaxEntries, lastExport, took, ifc.ExportError)
+	}
+	tw.Flush()
+}
+
+var ctlStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show live probe status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if ctlOutput != "table" && ctlOutput != "json" {
+			return fmt.Errorf("invalid output format %q (supported: table, json)", ctlOutput)
+		}
+		var st daemon.Status
+		if err := ctl.Call(ctlSocket, ctl.Request{Command: ctl.CmdStatus}, &st); err != nil {
+			return err
+		}
+		switch ctlOutput {
+		case "table":
+			formatStatus(cmd.OutOrStdout(), st)
+		case "json":
+			b, err := json.MarshalIndent(st, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal JSON: %w", err)
+			}
+			if _, err := cmd.OutOrStdout().Write(append(b, '\n')); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+		}
+		return nil
+	},
+}
+
+// ctlIfaceCommand builds add-iface/remove-iface.
+func ctlIfaceCommand(command, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   command + " <iface>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res ctl.InterfacesResult
+			if err := ctl.Call(ctlSocket, ctl.Request{Command: command, Iface: args[0]}, &res); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "attached interfaces: %v\n", res.Interfaces)
+			return nil
+		},
+	}
+}
+
+var ctlForgetCmd = &cobra.Command{
+	Use:   "forget <mac>...",
+	Short: "Delete neighbour entries by MAC address",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var res ctl.DeletedResult
+		req := ctl.Request{Command: ctl.CmdForget, Iface: ctlIface, MACs: args}
+		if err := ctl.Call(ctlSocket, req, &res); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "deleted %d of %d entries\n", res.Deleted, len(args))
+		return nil
+	},
+}
+
+var ctlFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Delete all neighbour entries, or those not seen recently",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var res ctl.DeletedResult
+		req := ctl.Request{Command: ctl.CmdFlush, Iface: ctlIface}
+		if ctlOlderThan > 0 {
+			req.OlderThan = ctlOlderThan.String()
+		}
+		if err := ctl.Call(ctlSocket, req, &res); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "deleted %d entries\n", res.Deleted)
+		return nil
+	},
+}
+
+var ctlSetIntervalCmd = &cobra.Command{
+	Use:   "set-interval <duration>",
+	Short: "Change the export interval",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var res ctl.IntervalResult
+		if err := ctl.Call(ctlSocket, ctl.Request{Command: ctl.CmdSetInterval, Interval: args[0]}, &res); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "export interval: %s\n", res.ExportInterval)
+		return nil
+	},
+}
+
+func init() {
+	ctlCmd.PersistentFlags().StringVar(&ctlSocket, "socket", ctl.DefaultSocketPath, "probe control socket")
+
+	ctlStatusCmd.Flags().StringVarP(&ctlOutput, "output", "o", "table", "output format (table|json)")
+
+	for _, c := range []*cobra.Command{ctlForgetCmd, ctlFlushCmd} {
+		c.Flags().StringVar(&ctlIface, "iface", "", "interface whose table to modify (required)")
+		c.MarkFlagRequired("iface")
+	}
+	ctlFlushCmd.Flags().DurationVar(&ctlOlderThan, "older-than", 0, "only delete entries not seen for this long")
+
+	ctlCmd.AddCommand(
+		ctlStatusCmd,
+		ctlIfaceCommand(ctl.CmdAddIface, "Attach the probe to an interface"),
+		ctlIfaceCommand(ctl.CmdRemoveIface, "Detach the probe from an interface"),
+		ctlForgetCmd,
+		ctlFlushCmd,
+		ctlSetIntervalCmd,
+	)
+	rootCmd.AddCommand(ctlCmd)
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/daemon"
+)
+
+func TestFormatStatus(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	st := daemon.Status{
+		Started:        now,
+		PinPath:        "/sys/fs/bpf/l2radar",
+		ExportDir:      "/var/lib/l2radar",
+		ExportInterval: "5s",
+		Interfaces: []daemon.InterfaceStatus{
+			{Name: "eth0", MapEntries: 12, MapMaxEntries: 4096, LastExport: now, ExportDurationMs: 1.5},
+			{Name: "eth1", MapEntries: 0, MapMaxEntries: 4096, ExportError: "boom"},
+		},
+	}
+
+	var buf bytes.Buffer
+	formatStatus(&buf, st)
+	out := buf.String()
+	for _, want := range []string{"/var/lib/l2radar every 5s", "INTERFACE", "eth0", "4096", "2026-01-02 03:04:05", "1.5ms", "boom"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatStatusExportDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	formatStatus(&buf, daemon.Status{})
+	if !strings.Contains(buf.String(), "Export:          disabled") {
+		t.Errorf("expected export disabled:\n%s", buf.String())
+	}
+}
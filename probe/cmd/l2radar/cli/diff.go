@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/marc/l2radar/probe/pkg/diff"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/spf13/cobra"
+)
+
+var diffOutput string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare two export snapshots",
+	Long: `Compare two interface exports (neigh-<iface>.json, or "dump -o json"
+output), or two export directories interface by interface: MAC addresses
+added (+) and removed (-), addresses gained or lost by a MAC (~), and
+addresses now held by a MAC of another vendor (!).`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffOutput != "table" && diffOutput != export.FormatJSON {
+			return fmt.Errorf("invalid output format %q (supported: table, json)", diffOutput)
+		}
+		r, err := diffSnapshots(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if diffOutput == "table" {
+			return diff.WriteTable(cmd.OutOrStdout(), r)
+		}
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\n", b)
+		return err
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "table", "output format (table|json)")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+// diffSnapshots compares two files or two directories.
+func diffSnapshots(oldPath, newPath string) (diff.Report, error) {
+	oldDir, err := isDir(oldPath)
+	if err != nil {
+		return diff.Report{}, err
+	}
+	newDir, err := isDir(newPath)
+	if err != nil {
+		return diff.Report{}, err
+	}
+	if oldDir != newDir {
+		return diff.Report{}, fmt.Errorf("cannot compare a file with a directory")
+	}
+
+	if !oldDir {
+		old, err := export.ReadJSON(oldPath)
+		if err != nil {
+			return diff.Report{}, err
+		}
+		cur, err := export.ReadJSON(newPath)
+		if err != nil {
+			return diff.Report{}, err
+		}
+		return diff.Report{Interfaces: []diff.Interface{diff.Compare(old, cur)}}, nil
+	}
+
+	var snapshots [2][]*export.InterfaceData
+	for i, dir := range []string{oldPath, newPath} {
+		if snapshots[i], err = export.ReadDir(dir); err != nil {
+			return diff.Report{}, err
+		}
+		if len(snapshots[i]) == 0 {
+			return diff.Report{}, fmt.Errorf("%s: no interface exports", dir)
+		}
+	}
+	return diff.CompareAll(snapshots[0], snapshots[1]), nil
+}
+
+func isDir(path string) (bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return fi.IsDir(), nil
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/diff"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	n := func(b byte) dump.Neighbour {
+		return dump.Neighbour{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, b}, FirstSeen: now, LastSeen: now}
+	}
+	before, after := t.TempDir(), t.TempDir()
+	export.WriteJSON("eth0", []dump.Neighbour{n(1), n(2)}, before, now, time.Second, nil, nil)
+	export.WriteJSON("eth1", nil, before, now, time.Second, nil, nil)
+	export.WriteJSON("eth0", []dump.Neighbour{n(2), n(3)}, after, now, time.Second, nil, nil)
+
+	r, err := diffSnapshots(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Interfaces) != 2 || len(r.Interfaces[0].Added) != 1 || len(r.Interfaces[0].Removed) != 1 || r.Interfaces[1].Status != diff.StatusRemoved {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	r, err = diffSnapshots(filepath.Join(before, "neigh-eth0.json"), filepath.Join(after, "neigh-eth0.json"))
+	if err != nil || len(r.Interfaces) != 1 || r.Interfaces[0].Added[0].MAC != "02:00:00:00:00:03" {
+		t.Errorf("unexpected file report: %+v %v", r, err)
+	}
+
+	if _, err := diffSnapshots(before, filepath.Join(after, "neigh-eth0.json")); err == nil {
+		t.Error("expected error comparing a directory with a file")
+	}
+	empty := t.TempDir()
+	os.WriteFile(filepath.Join(empty, "manifest.json"), []byte("{}"), 0o644)
+	if _, err := diffSnapshots(before, empty); err == nil {
+		t.Error("expected error for a directory without exports")
+	}
+}
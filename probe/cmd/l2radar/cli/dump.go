@@ -3,6 +3,11 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/marc/l2radar/probe/pkg/dump"
@@ -12,11 +17,171 @@ import (
 )
 
 var (
-	dumpIface   string
+	dumpIfaces  []string
 	dumpPinPath string
 	dumpOutput  string
+	dumpColumns []string
+	dumpQuery   neighbourQuery
+
+	dumpFromExport string
 )
 
+// allIfaces is the --iface value selecting every interface.
+const allIfaces = "all"
+
+// neighbourQuery holds the filter, sort and limit flags of dump.
+type neighbourQuery struct {
+	mac        string
+	ip         string
+	subnet     string
+	vendor     string
+	seenWithin time.Duration
+	hasIPv6    bool
+	sort       string
+	order      string
+	limit      int
+}
+
+// apply filters, sorts and limits neighbours (in place) and returns the
+// result; --seen-within is relative to now.
+func (q *neighbourQuery) apply(neighbours []dump.Neighbour, now time.Time) ([]dump.Neighbour, error) {
+	f := dump.Filter{MAC: q.mac, Vendor: q.vendor, HasIPv6: q.hasIPv6}
+	var err error
+	if q.ip != "" {
+		if f.IP, err = dump.ParseIPNet(q.ip); err != nil {
+			return nil, err
+		}
+	}
+	if q.subnet != "" {
+		if _, f.Subnet, err = net.ParseCIDR(q.subnet); err != nil {
+			return nil, fmt.Errorf("invalid subnet %q (CIDR, e.g. 192.168.1.0/24)", q.subnet)
+		}
+	}
+	if q.seenWithin < 0 {
+		return nil, fmt.Errorf("--seen-within must not be negative")
+	}
+	if q.seenWithin > 0 {
+		f.SeenSince = now.Add(-q.seenWithin)
+	}
+	if q.limit < 0 {
+		return nil, fmt.Errorf("--limit must not be negative")
+	}
+	desc := dump.DefaultDescending(q.sort)
+	switch q.order {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return nil, fmt.Errorf("invalid order %q (supported: asc, desc)", q.order)
+	}
+
+	neighbours = dump.FilterNeighbours(neighbours, f)
+	if err := dump.Sort(neighbours, q.sort, desc); err != nil {
+		return nil, err
+	}
+	if q.limit > 0 && len(neighbours) > q.limit {
+		neighbours = neighbours[:q.limit]
+	}
+	return neighbours, nil
+}
+
+// dumpSource is the neighbour table of one interface, with its export
+// when read with --from-export.
+type dumpSource struct {
+	iface      string
+	path       string
+	data       *export.InterfaceData
+	neighbours []dump.Neighbour
+}
+
+// readMaps reads the pinned maps of ifaces, or of every pinned interface
+// with "all".
+func readMaps(pinPath string, ifaces []string) ([]dumpSource, error) {
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("--iface is required")
+	}
+	if slices.Contains(ifaces, allIfaces) {
+		var err error
+		if ifaces, err = dump.PinnedInterfaces(pinPath); err != nil {
+			return nil, err
+		}
+		if len(ifaces) == 0 {
+			return nil, fmt.Errorf("no neighbour maps pinned under %s", pinPath)
+		}
+	}
+	sources := make([]dumpSource, 0, len(ifaces))
+	for _, iface := range ifaces {
+		mapPath := dump.PinPath(pinPath, iface)
+		neighbours, err := dump.ReadMap(mapPath)
+		if err != nil {
+			return nil, fmt.Errorf("read map: %w", err)
+		}
+		sources = append(sources, dumpSource{iface: iface, path: mapPath, neighbours: neighbours})
+	}
+	return sources, nil
+}
+
+// readExports reads the exports of ifaces from path, an export directory
+// or file; "all" reads every export of a directory.
+func readExports(path string, ifaces []string) ([]dumpSource, error) {
+	var paths []string
+	switch {
+	case slices.Contains(ifaces, allIfaces):
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			paths = []string{path}
+			break
+		}
+		if paths, err = filepath.Glob(filepath.Join(path, export.OutputFileName("*"))); err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no exports in %s", path)
+		}
+		ifaces = nil
+	case len(ifaces) == 0:
+		p, err := exportPath(path, "")
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{p}
+	default:
+		for _, iface := range ifaces {
+			p, err := exportPath(path, iface)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, p)
+		}
+	}
+
+	sources := make([]dumpSource, 0, len(paths))
+	for i, p := range paths {
+		data, err := export.ReadJSON(p)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(ifaces) && ifaces[i] != allIfaces && data.Interface != ifaces[i] {
+			return nil, fmt.Errorf("%s is the export of %s, not %s", p, data.Interface, ifaces[i])
+		}
+		src := dumpSource{iface: data.Interface, path: p, data: data}
+		for _, nj := range data.Neighbours {
+			n, err := nj.Neighbour()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			src.neighbours = append(src.neighbours, n)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
 func marshalDumpJSON(iface string, ts time.Time, neighbours []dump.Neighbour) ([]byte, error) {
 	ifInfo, err := export.LookupInterfaceInfo(iface)
 	if err != nil {
@@ -36,42 +201,185 @@ func marshalDumpJSON(iface string, ts time.Time, neighbours []dump.Neighbour) ([
 	return append(b, '\n'), nil
 }
 
+// dumpFormats returns the output formats of dump: table and json first,
+// then the other formats of the registry.
+func dumpFormats() []string {
+	names := []string{"table", export.FormatJSON}
+	for _, name := range dump.FormatNames() {
+		if name != "table" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 var dumpCmd = &cobra.Command{
 	Use:   "dump",
-	Short: "Dump neighbour table for an interface",
-	Args:  cobra.NoArgs,
+	Short: "Dump the neighbour tables of one or more interfaces",
+	Long: `Dump the neighbour table of an interface from its pinned map.
+
+--iface may be repeated, or "all" for every interface, to list several
+interfaces in one table with an interface column; -o json then prints an
+array of per-interface documents. Neighbours can be filtered (--mac,
+--ip, --subnet, --vendor, --seen-within, --has-ipv6), sorted (--sort,
+--order) and limited (--limit) across all the interfaces listed.
+
+With --from-export, read the last export instead (an export directory and
+--iface, or a neigh-<iface>.json file), so the table is available when
+the probe is stopped or runs on another host. The export time and the
+age of the data are printed to stderr.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		mapPath := dump.PinPath(dumpPinPath, dumpIface)
-		neighbours, err := dump.ReadMap(mapPath)
+		var format dump.Format
+		if dumpOutput != export.FormatJSON {
+			var ok bool
+			if format, ok = dump.LookupFormat(dumpOutput); !ok {
+				return fmt.Errorf("invalid output format %q (supported: %s)", dumpOutput, strings.Join(dumpFormats(), ", "))
+			}
+		} else if len(dumpColumns) > 0 {
+			return fmt.Errorf("--columns is not supported with -o json")
+		}
+		cols, err := dump.ParseColumns(dumpColumns)
 		if err != nil {
-			return fmt.Errorf("read map: %w", err)
+			return err
+		}
+
+		var sources []dumpSource
+		if dumpFromExport != "" {
+			if sources, err = readExports(dumpFromExport, dumpIfaces); err != nil {
+				return err
+			}
+			for _, src := range sources {
+				fmt.Fprintln(cmd.ErrOrStderr(), exportAge(src.path, src.data, time.Now()))
+			}
+		} else if sources, err = readMaps(dumpPinPath, dumpIfaces); err != nil {
+			return err
 		}
 
-		dump.SortByLastSeen(neighbours)
+		multi := len(sources) > 1 || slices.Contains(dumpIfaces, allIfaces)
+		var neighbours []dump.Neighbour
+		for _, src := range sources {
+			for _, n := range src.neighbours {
+				if multi {
+					n.Interface = src.iface
+				}
+				neighbours = append(neighbours, n)
+			}
+		}
+		if neighbours, err = dumpQuery.apply(neighbours, time.Now()); err != nil {
+			return err
+		}
 
-		switch dumpOutput {
-		case "table":
-			dump.FormatTable(cmd.OutOrStdout(), neighbours)
-		case "json":
-			b, err := marshalDumpJSON(dumpIface, time.Now(), neighbours)
+		if dumpOutput != export.FormatJSON {
+			// The default table has its own interface column.
+			if multi && (cols != nil || format.Name != "table") {
+				cols = dump.WithInterface(cols)
+			}
+			if err := format.Write(cmd.OutOrStdout(), neighbours, cols); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+			return nil
+		}
+		docs := make([]json.RawMessage, 0, len(sources))
+		for _, src := range sources {
+			own := neighbours
+			if multi {
+				own = nil
+				for _, n := range neighbours {
+					if n.Interface == src.iface {
+						own = append(own, n)
+					}
+				}
+			}
+			var b []byte
+			if src.data != nil {
+				b, err = marshalExportJSON(src.data, own)
+			} else {
+				b, err = marshalDumpJSON(src.iface, time.Now(), own)
+			}
 			if err != nil {
 				return err
 			}
-			if _, err := cmd.OutOrStdout().Write(b); err != nil {
-				return fmt.Errorf("write output: %w", err)
+			docs = append(docs, b)
+		}
+		b := []byte(docs[0])
+		if multi {
+			if b, err = json.MarshalIndent(docs, "", "  "); err != nil {
+				return fmt.Errorf("marshal JSON: %w", err)
 			}
-		default:
-			return fmt.Errorf("invalid output format %q (supported: table, json)", dumpOutput)
+			b = append(b, '\n')
+		}
+		if _, err := cmd.OutOrStdout().Write(b); err != nil {
+			return fmt.Errorf("write output: %w", err)
 		}
 		return nil
 	},
 }
 
+// exportPath returns the export file of iface in path if it is a
+// directory, and path otherwise.
+func exportPath(path, iface string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return path, nil
+	}
+	if iface == "" {
+		return "", fmt.Errorf("--iface is required to read from an export directory")
+	}
+	return filepath.Join(path, export.OutputFileName(iface)), nil
+}
+
+// exportAge describes when data was exported, and warns if that is long
+// ago compared with its export interval.
+func exportAge(path string, data *export.InterfaceData, now time.Time) string {
+	ts, err := time.Parse(time.RFC3339, data.Timestamp)
+	if err != nil {
+		return fmt.Sprintf("Offline data for %s from %s, export time unknown", data.Interface, path)
+	}
+	age := now.Sub(ts).Round(time.Second)
+	msg := fmt.Sprintf("Offline data for %s from %s, exported %s (%s ago)", data.Interface, path, data.Timestamp, age)
+	if interval, err := time.ParseDuration(data.ExportInterval); err == nil && interval > 0 && age > staleExports*interval {
+		msg += fmt.Sprintf("; the probe exports every %s, it may not be running", interval)
+	}
+	return msg
+}
+
+// staleExports is the number of missed export intervals after which
+// exported data is reported as stale.
+const staleExports = 3
+
+// marshalExportJSON re-encodes an export read with --from-export with
+// its neighbours in dump order.
+func marshalExportJSON(data *export.InterfaceData, neighbours []dump.Neighbour) ([]byte, error) {
+	out := *data
+	out.Neighbours = make([]export.NeighbourJSON, 0, len(neighbours))
+	for _, n := range neighbours {
+		out.Neighbours = append(out.Neighbours, export.NewNeighbourJSON(n))
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal JSON: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
 func init() {
-	dumpCmd.Flags().StringVar(&dumpIface, "iface", "", "network interface to dump (required)")
+	dumpCmd.Flags().StringSliceVar(&dumpIfaces, "iface", nil, `network interfaces to dump, repeatable, or "all" (required unless --from-export is a file)`)
 	dumpCmd.Flags().StringVar(&dumpPinPath, "pin-path", loader.DefaultPinPath, "base path for pinned eBPF maps")
-	dumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "table", "output format (table|json)")
-	dumpCmd.MarkFlagRequired("iface")
-
+	dumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "table", "output format ("+strings.Join(dumpFormats(), "|")+")")
+	dumpCmd.Flags().StringSliceVar(&dumpColumns, "columns", nil, "fields to print, in order: "+strings.Join(dump.ColumnNames(), ", ")+", "+dump.InterfaceColumn.Name+" (not with -o json)")
+	dumpCmd.Flags().StringVar(&dumpFromExport, "from-export", "", "read the last export from this directory or neigh-<iface>.json file instead of the pinned map")
+	dumpCmd.Flags().StringVar(&dumpQuery.mac, "mac", "", "only MAC addresses starting with this prefix, e.g. 02:42")
+	dumpCmd.Flags().StringVar(&dumpQuery.ip, "ip", "", "only neighbours holding this address (or CIDR)")
+	dumpCmd.Flags().StringVar(&dumpQuery.subnet, "subnet", "", "only neighbours holding an address in this CIDR")
+	dumpCmd.Flags().StringVar(&dumpQuery.vendor, "vendor", "", "only vendors containing this string (case-insensitive)")
+	dumpCmd.Flags().DurationVar(&dumpQuery.seenWithin, "seen-within", 0, "only neighbours seen within this duration, e.g. 10m")
+	dumpCmd.Flags().BoolVar(&dumpQuery.hasIPv6, "has-ipv6", false, "only neighbours with an IPv6 address")
+	dumpCmd.Flags().StringVar(&dumpQuery.sort, "sort", "last_seen", "sort key: "+strings.Join(dump.SortKeys(), ", "))
+	dumpCmd.Flags().StringVar(&dumpQuery.order, "order", "", "sort order: asc or desc (default desc for times, asc otherwise)")
+	dumpCmd.Flags().IntVar(&dumpQuery.limit, "limit", 0, "print at most this many neighbours (0 for all)")
 	rootCmd.AddCommand(dumpCmd)
 }
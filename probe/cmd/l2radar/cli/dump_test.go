@@ -3,6 +3,7 @@ package cli
 import (
 	"encoding/json"
 	"net"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -73,3 +74,158 @@ func TestMarshalDumpJSONLookupError(t *testing.T) {
 		t.Fatal("expected error for unknown interface")
 	}
 }
+
+func TestDumpFormats(t *testing.T) {
+	want := "table,json,csv,ndjson,yaml"
+	if got := strings.Join(dumpFormats(), ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestExportPath(t *testing.T) {
+	dir := t.TempDir()
+	if got, err := exportPath(dir, "eth0"); err != nil || got != filepath.Join(dir, "neigh-eth0.json") {
+		t.Errorf("directory: %q %v", got, err)
+	}
+	if _, err := exportPath(dir, ""); err == nil {
+		t.Error("expected error for a directory without --iface")
+	}
+	file := "../../../../testdata/neigh-eth0.json"
+	if got, err := exportPath(file, ""); err != nil || got != file {
+		t.Errorf("file: %q %v", got, err)
+	}
+	if _, err := exportPath(filepath.Join(dir, "missing"), "eth0"); err == nil {
+		t.Error("expected error for a missing path")
+	}
+}
+
+func TestExportAge(t *testing.T) {
+	data := &export.InterfaceData{Interface: "eth0", Timestamp: "2026-10-18T12:00:00Z", ExportInterval: "5s"}
+	ts := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	got := exportAge("/data/neigh-eth0.json", data, ts.Add(10*time.Second))
+	if got != "Offline data for eth0 from /data/neigh-eth0.json, exported 2026-10-18T12:00:00Z (10s ago)" {
+		t.Errorf("unexpected recent age: %s", got)
+	}
+	got = exportAge("/data/neigh-eth0.json", data, ts.Add(time.Hour))
+	if !strings.Contains(got, "(1h0m0s ago); the probe exports every 5s, it may not be running") {
+		t.Errorf("expected a stale warning: %s", got)
+	}
+}
+
+func TestMarshalExportJSON(t *testing.T) {
+	data, err := export.ReadJSON("../../../../testdata/neigh-eth0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var neighbours []dump.Neighbour
+	for _, nj := range data.Neighbours {
+		n, err := nj.Neighbour()
+		if err != nil {
+			t.Fatal(err)
+		}
+		neighbours = append(neighbours, n)
+	}
+	dump.SortByLastSeen(neighbours)
+
+	b, err := marshalExportJSON(data, neighbours[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed export.InterfaceData
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Interface != data.Interface || parsed.Timestamp != data.Timestamp || parsed.MAC != data.MAC || parsed.SchemaVersion != export.SchemaVersion {
+		t.Errorf("header not preserved: %+v", parsed)
+	}
+	if len(parsed.Neighbours) != 1 || parsed.Neighbours[0].MAC != neighbours[0].MAC.String() {
+		t.Errorf("unexpected neighbours: %+v", parsed.Neighbours)
+	}
+	if len(data.Neighbours) < 2 {
+		t.Error("the export read should not be modified")
+	}
+}
+
+func TestReadExports(t *testing.T) {
+	const dir = "../../../../testdata"
+	sources, err := readExports(dir, []string{"all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[0].iface != "eth0" || sources[1].iface != "wlan0" {
+		t.Fatalf("unexpected sources: %+v", sources)
+	}
+	if len(sources[0].neighbours) != 4 || sources[1].data == nil {
+		t.Errorf("unexpected neighbours: %d, data %v", len(sources[0].neighbours), sources[1].data)
+	}
+
+	if sources, err = readExports(dir, []string{"wlan0"}); err != nil || len(sources) != 1 || sources[0].iface != "wlan0" {
+		t.Errorf("single interface: %+v %v", sources, err)
+	}
+	if _, err := readExports(dir, nil); err == nil {
+		t.Error("expected error for a directory without --iface")
+	}
+	if _, err := readExports(dir+"/neigh-eth0.json", []string{"wlan0"}); err == nil || !strings.Contains(err.Error(), "not wlan0") {
+		t.Errorf("expected interface mismatch, got %v", err)
+	}
+	if _, err := readExports(t.TempDir(), []string{"all"}); err == nil {
+		t.Error("expected error for a directory without exports")
+	}
+}
+
+func TestNeighbourQuery(t *testing.T) {
+	sources, err := readExports("../../../../testdata", []string{"all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := func() []dump.Neighbour {
+		var out []dump.Neighbour
+		for _, src := range sources {
+			for _, n := range src.neighbours {
+				n.Interface = src.iface
+				out = append(out, n)
+			}
+		}
+		return out
+	}
+	now := time.Date(2026, 2, 14, 14, 33, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query neighbourQuery
+		want  []string
+	}{
+		{"default", neighbourQuery{sort: "last_seen", limit: 3}, []string{"eth0/dc:4b:a1:69:38:16", "wlan0/f0:de:f1:23:45:67", "eth0/aa:bb:cc:dd:ee:01"}},
+		{"subnet", neighbourQuery{subnet: "192.168.1.0/24", sort: "mac"}, []string{"wlan0/00:11:22:33:44:55", "eth0/02:42:ac:11:00:02", "eth0/dc:4b:a1:69:38:16", "wlan0/f0:de:f1:23:45:67"}},
+		{"ip", neighbourQuery{ip: "172.16.0.50", sort: "last_seen"}, []string{"wlan0/dc:4b:a1:69:38:16"}},
+		{"seen within", neighbourQuery{seenWithin: 2*time.Minute + 59*time.Second, sort: "last_seen", order: "asc"}, []string{"wlan0/f0:de:f1:23:45:67", "eth0/dc:4b:a1:69:38:16"}},
+		{"has ipv6 and mac", neighbourQuery{hasIPv6: true, mac: "aa:bb", sort: "ip"}, []string{"eth0/aa:bb:cc:dd:ee:01"}},
+	}
+	for _, tt := range tests {
+		got, err := tt.query.apply(all(), now)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var keys []string
+		for _, n := range got {
+			keys = append(keys, n.Interface+"/"+n.MAC.String())
+		}
+		if strings.Join(keys, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %v, want %v", tt.name, keys, tt.want)
+		}
+	}
+
+	for _, q := range []neighbourQuery{
+		{sort: "hostname"},
+		{sort: "mac", order: "up"},
+		{sort: "mac", subnet: "192.168.1.1"},
+		{sort: "mac", ip: "nope"},
+		{sort: "mac", limit: -1},
+		{sort: "mac", seenWithin: -time.Minute},
+	} {
+		if _, err := q.apply(all(), now); err == nil {
+			t.Errorf("expected error for %+v", q)
+		}
+	}
+}
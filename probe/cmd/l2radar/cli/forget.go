@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/loader"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forgetIface    string
+	forgetPinPath  string
+	flushIface     string
+	flushPinPath   string
+	flushOlderThan time.Duration
+)
+
+var forgetCmd = &cobra.Command{
+	Use:   "forget <mac>...",
+	Short: "Delete neighbour entries by MAC address",
+	Long: `Delete the given MAC addresses from an interface's pinned map, e.g. to
+remove a spoofed entry. Works while the probe is running; an entry is
+re-created if the MAC is seen again.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		macs, err := dump.ParseMACs(args)
+		if err != nil {
+			return err
+		}
+
+		m, err := dump.OpenPinned(dump.PinPath(forgetPinPath, forgetIface))
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		n, err := dump.Forget(m, macs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "deleted %d of %d entries\n", n, len(macs))
+		return nil
+	},
+}
+
+var flushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Delete all neighbour entries, or those not seen recently",
+	Long: `Delete every entry from an interface's pinned map, or with --older-than
+only the entries whose last-seen time is older than the given duration.
+Works while the probe is running.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flushOlderThan < 0 {
+			return fmt.Errorf("older-than must not be negative")
+		}
+
+		m, err := dump.OpenPinned(dump.PinPath(flushPinPath, flushIface))
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		n, err := dump.Flush(m, flushOlderThan)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "deleted %d entries\n", n)
+		return nil
+	},
+}
+
+func init() {
+	forgetCmd.Flags().StringVar(&forgetIface, "iface", "", "interface whose table to modify (required)")
+	forgetCmd.Flags().StringVar(&forgetPinPath, "pin-path", loader.DefaultPinPath, "base path for pinned eBPF maps")
+	forgetCmd.MarkFlagRequired("iface")
+
+	flushCmd.Flags().StringVar(&flushIface, "iface", "", "interface whose table to modify (required)")
+	flushCmd.Flags().StringVar(&flushPinPath, "pin-path", loader.DefaultPinPath, "base path for pinned eBPF maps")
+	flushCmd.Flags().DurationVar(&flushOlderThan, "older-than", 0, "only delete entries not seen for this long")
+	flushCmd.MarkFlagRequired("iface")
+
+	rootCmd.AddCommand(forgetCmd)
+	rootCmd.AddCommand(flushCmd)
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/journal"
+	"github.com/spf13/cobra"
+)
+
+var errJournalNoDir = errors.New("the event journal requires an export directory")
+
+var (
+	journalDir    string
+	journalIfaces []string
+	journalTypes  []string
+	journalMAC    string
+	journalIP     string
+	journalSince  string
+	journalUntil  string
+	journalLines  int
+	journalFollow bool
+	journalOutput string
+)
+
+var journalCmd = &cobra.Command{
+	Use:   "journal",
+	Short: "Show the neighbour event journal",
+	Long: `Print the events of the journal written by "l2radar --journal" to the
+export directory, oldest first, across rotated files. Filters combine;
+with --follow, events are printed as they are appended until interrupted.
+
+--since and --until take an RFC 3339 time, a date (2006-01-02, local time)
+or a duration before now (e.g. 24h).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := journalFilter(time.Now())
+		if err != nil {
+			return err
+		}
+		var print func(journal.Entry) error
+		out := cmd.OutOrStdout()
+		switch journalOutput {
+		case "text":
+			print = func(e journal.Entry) error {
+				_, err := fmt.Fprintln(out, formatJournalEntry(e))
+				return err
+			}
+		case "json":
+			enc := json.NewEncoder(out)
+			print = func(e journal.Entry) error { return enc.Encode(e) }
+		default:
+			return fmt.Errorf("invalid output format %q (supported: text, json)", journalOutput)
+		}
+		if journalLines < 0 {
+			return fmt.Errorf("lines must not be negative")
+		}
+		if _, err := os.Stat(journalDir); err != nil {
+			return err
+		}
+
+		r := journal.NewReader(journalDir, f)
+		defer r.Close()
+		var last []journal.Entry
+		err = r.ReadAll(func(e journal.Entry) error {
+			if journalLines == 0 {
+				return print(e)
+			}
+			if len(last) == journalLines {
+				last = last[1:]
+			}
+			last = append(last, e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, e := range last {
+			if err := print(e); err != nil {
+				return err
+			}
+		}
+		if journalFollow {
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			if err := r.Follow(ctx, print); err != nil {
+				return err
+			}
+		}
+		if r.Skipped > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: skipped %d malformed journal lines\n", r.Skipped)
+		}
+		return nil
+	},
+}
+
+func init() {
+	journalCmd.Flags().StringVar(&journalDir, "export-dir", "", "export directory of the probe (required)")
+	journalCmd.Flags().StringArrayVar(&journalIfaces, "iface", nil, "only events of this interface (repeatable)")
+	journalCmd.Flags().StringSliceVar(&journalTypes, "type", nil, "only these event types ("+strings.Join(events.ChangeTypes, ", ")+")")
+	journalCmd.Flags().StringVar(&journalMAC, "mac", "", "only events of this MAC address")
+	journalCmd.Flags().StringVar(&journalIP, "ip", "", "only events of this IP address")
+	journalCmd.Flags().StringVar(&journalSince, "since", "", "only events at or after this time")
+	journalCmd.Flags().StringVar(&journalUntil, "until", "", "only events before this time")
+	journalCmd.Flags().IntVarP(&journalLines, "lines", "n", 0, "only the last N matching events (all if 0)")
+	journalCmd.Flags().BoolVarP(&journalFollow, "follow", "f", false, "keep printing events as they are appended")
+	journalCmd.Flags().StringVarP(&journalOutput, "output", "o", "text", "output format (text|json)")
+	journalCmd.MarkFlagRequired("export-dir")
+
+	rootCmd.AddCommand(journalCmd)
+}
+
+// journalFilter builds the filter given by the journal flags.
+func journalFilter(now time.Time) (journal.Filter, error) {
+	f := journal.Filter{Interfaces: journalIfaces, Types: journalTypes}
+	for _, t := range f.Types {
+		if !slices.Contains(events.ChangeTypes, t) {
+			return f, fmt.Errorf("unknown event type %q (supported: %s)", t, strings.Join(events.ChangeTypes, ", "))
+		}
+	}
+	if journalMAC != "" {
+		hw, err := net.ParseMAC(journalMAC)
+		if err != nil {
+			return f, fmt.Errorf("invalid MAC %q: %w", journalMAC, err)
+		}
+		f.MAC = hw.String()
+	}
+	if journalIP != "" {
+		ip := net.ParseIP(journalIP)
+		if ip == nil {
+			return f, fmt.Errorf("invalid IP %q", journalIP)
+		}
+		f.IP = ip.String()
+	}
+	var err error
+	if f.Since, err = parseJournalTime(journalSince, now); err != nil {
+		return f, fmt.Errorf("since: %w", err)
+	}
+	if f.Until, err = parseJournalTime(journalUntil, now); err != nil {
+		return f, fmt.Errorf("until: %w", err)
+	}
+	return f, nil
+}
+
+// parseJournalTime parses an RFC 3339 time, a local date or a duration
+// before now. The empty string is the zero time.
+func parseJournalTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected RFC 3339, YYYY-MM-DD or a duration)", s)
+}
+
+// formatJournalEntry renders an entry as one line of text.
+func formatJournalEntry(e journal.Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-8s %-11s", e.Time.Local().Format(time.RFC3339), e.Interface, e.Type)
+	if e.MAC != "" {
+		b.WriteString(" mac=" + e.MAC)
+	}
+	if e.IP != "" {
+		b.WriteString(" ip=" + e.IP)
+	}
+	if len(e.MACs) > 0 {
+		b.WriteString(" macs=" + strings.Join(e.MACs, ","))
+	}
+	if e.Vendor != "" {
+		fmt.Fprintf(&b, " vendor=%q", e.Vendor)
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/journal"
+)
+
+func TestParseJournalTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"":                     {},
+		"2026-10-17T08:00:00Z": time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC),
+		"2026-10-17":           time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local),
+		"90m":                  now.Add(-90 * time.Minute),
+	}
+	for in, want := range tests {
+		got, err := parseJournalTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("%q: got %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"yesterday", "-1h", "2026-13-01"} {
+		if _, err := parseJournalTime(in, now); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestJournalFilter(t *testing.T) {
+	saved := []string{journalMAC, journalIP}
+	defer func() { journalMAC, journalIP, journalTypes = saved[0], saved[1], nil }()
+
+	journalMAC, journalIP, journalTypes = "02-00-00-00-00-0A", "10.0.0.1", []string{events.ChangeNewMAC}
+	f, err := journalFilter(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.MAC != "02:00:00:00:00:0a" || f.IP != "10.0.0.1" {
+		t.Errorf("unexpected filter %+v", f)
+	}
+	journalTypes = []string{"new_cat"}
+	if _, err := journalFilter(time.Now()); err == nil {
+		t.Error("expected error for unknown type")
+	}
+}
+
+func TestFormatJournalEntry(t *testing.T) {
+	ts := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	e := journal.Entry{Change: events.Change{
+		Type: events.ChangeIPConflict, Interface: "eth0", Time: ts,
+		MAC: "02:00:00:00:00:02", IP: "10.0.0.1", MACs: []string{"02:00:00:00:00:01", "02:00:00:00:00:02"},
+	}, Vendor: "Acme"}
+	want := ts.Format(time.RFC3339) + " eth0     ip_conflict mac=02:00:00:00:00:02 ip=10.0.0.1 macs=02:00:00:00:00:01,02:00:00:00:00:02 vendor=\"Acme\""
+	if got := formatJournalEntry(e); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/loader"
+	"github.com/marc/l2radar/probe/pkg/pcap"
+	"github.com/marc/l2radar/probe/pkg/replay"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayPcap   string
+	replayIface  string
+	replayOutput string
+)
+
+// replayLabel returns the interface name used in JSON output: --iface if
+// set, otherwise the capture file name without its extension.
+func replayLabel(iface, pcapPath string) string {
+	if iface != "" {
+		return iface
+	}
+	base := filepath.Base(pcapPath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay a pcap file through the eBPF program",
+	Long: `Load the eBPF program with a private (unpinned) map, feed every frame
+of an Ethernet pcap file through it with BPF_PROG_TEST_RUN, and print the
+resulting neighbour table. First/last seen reflect capture timestamps.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if replayOutput != "table" && replayOutput != "json" {
+			return fmt.Errorf("invalid output format %q (supported: table, json)", replayOutput)
+		}
+
+		f, err := os.Open(replayPcap)
+		if err != nil {
+			return fmt.Errorf("open pcap: %w", err)
+		}
+		defer f.Close()
+
+		r, err := pcap.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("read pcap %s: %w", replayPcap, err)
+		}
+
+		col, err := loader.Load()
+		if err != nil {
+			return err
+		}
+		defer col.Close()
+
+		res, err := replay.Replay(r, col)
+		if err != nil {
+			return fmt.Errorf("replay: %w", err)
+		}
+
+		dump.SortByLastSeen(res.Neighbours)
+
+		switch replayOutput {
+		case "table":
+			dump.FormatTable(cmd.OutOrStdout(), res.Neighbours)
+		case "json":
+			data := export.NewInterfaceData(replayLabel(replayIface, replayPcap), res.Last, 0, res.Neighbours, nil, nil)
+			b, err := json.MarshalIndent(data, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal JSON: %w", err)
+			}
+			if _, err := cmd.OutOrStdout().Write(append(b, '\n')); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+		}
+
+		fmt.Fprintf(cmd.ErrOrStderr(), "replayed %d frames (%d skipped)\n", res.Frames, res.Skipped)
+		return nil
+	},
+}
+
+func init() {
+	replayCmd.Flags().StringVar(&replayPcap, "pcap", "", "Ethernet pcap file to replay (required)")
+	replayCmd.Flags().StringVar(&replayIface, "iface", "", "interface name for JSON output (default: pcap file name)")
+	replayCmd.Flags().StringVarP(&replayOutput, "output", "o", "table", "output format (table|json)")
+	replayCmd.MarkFlagRequired("pcap")
+
+	rootCmd.AddCommand(replayCmd)
+}
@@ -6,12 +6,24 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/marc/l2radar/probe/pkg/api"
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/ctl"
+	"github.com/marc/l2radar/probe/pkg/daemon"
 	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/events"
 	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/journal"
 	"github.com/marc/l2radar/probe/pkg/loader"
+	"github.com/marc/l2radar/probe/pkg/metrics"
+	"github.com/marc/l2radar/probe/pkg/otlp"
+	"github.com/marc/l2radar/probe/pkg/preflight"
+	"github.com/marc/l2radar/probe/pkg/syslog"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +32,33 @@ var (
 	rootPinPath        string
 	rootExportDir      string
 	rootExportInterval time.Duration
+	rootExportFormats  []string
+	rootExportColumns  []string
+	rootExportCombined bool
+	rootJournal        bool
+	rootCtlSocket      string
+	rootConfigPath     string
+	rootListen         []string
+	rootTokenFile      string
+	rootEventsSocket   string
+	rootEventsGID      int
+
+	rootMetricsTextfile         string
+	rootMetricsTextfileInterval time.Duration
+	rootMetricsActiveWindow     time.Duration
+
+	rootOTLPEndpoint   string
+	rootOTLPProtocol   string
+	rootOTLPInsecure   bool
+	rootOTLPHeaders    []string
+	rootOTLPAttributes []string
+	rootOTLPInterval   time.Duration
+
+	rootSyslog         string
+	rootSyslogFormat   string
+	rootSyslogFacility string
+	rootSyslogTLSCA    string
+	rootSyslogEvents   []string
 )
 
 var rootCmd = &cobra.Command{
@@ -34,8 +73,31 @@ func init() {
 	rootCmd.Flags().StringArrayVar(&rootIfaces, "iface", nil, "network interface to monitor (repeatable; \"external\" for external, \"any\" for all L2)")
 	rootCmd.Flags().StringVar(&rootPinPath, "pin-path", loader.DefaultPinPath, "base path for pinning eBPF maps")
 	rootCmd.Flags().StringVar(&rootExportDir, "export-dir", "", "directory to write JSON files (disabled if empty)")
-	rootCmd.Flags().DurationVar(&rootExportInterval, "export-interval", 5*time.Second, "export interval (only used with --export-dir)")
-	rootCmd.MarkFlagRequired("iface")
+	rootCmd.Flags().DurationVar(&rootExportInterval, "export-interval", 5*time.Second, "export interval, also pacing events, metrics and sinks")
+	rootCmd.Flags().StringSliceVar(&rootExportFormats, "export-format", nil, "files written per interface: "+strings.Join(export.Formats(), ", ")+" (default json)")
+	rootCmd.Flags().StringSliceVar(&rootExportColumns, "export-columns", nil, "fields of the csv, ndjson and yaml exports: "+strings.Join(dump.ColumnNames(), ", ")+" (default all)")
+	rootCmd.Flags().BoolVar(&rootExportCombined, "export-combined", false, "also write "+export.CombinedFileName+", merging all interfaces")
+	rootCmd.Flags().BoolVar(&rootJournal, "journal", false, "append neighbour events to "+journal.FileName+" in --export-dir (see \"l2radar journal\")")
+	rootCmd.Flags().StringVar(&rootCtlSocket, "ctl-socket", ctl.DefaultSocketPath, "unix control socket for \"l2radar ctl\" (disabled if empty)")
+	rootCmd.Flags().StringVar(&rootConfigPath, "config", "", "YAML configuration file (re-read on SIGHUP; flags override it)")
+	rootCmd.Flags().StringArrayVar(&rootListen, "listen", nil, "serve the HTTP API on this address, e.g. 127.0.0.1:9110 or unix:/path (repeatable)")
+	rootCmd.Flags().StringVar(&rootTokenFile, "listen-token-file", "", "file holding the bearer token required by the HTTP API")
+	rootCmd.Flags().StringVar(&rootEventsSocket, "events-socket", "", "serve only the neighbour events stream, without token, on this unix socket (mode 0660)")
+	rootCmd.Flags().IntVar(&rootEventsGID, "events-socket-gid", -1, "group of the --events-socket socket, e.g. the UI's nginx")
+	rootCmd.Flags().StringVar(&rootMetricsTextfile, "metrics-textfile", "", "write Prometheus metrics to this node_exporter textfile (*.prom)")
+	rootCmd.Flags().DurationVar(&rootMetricsTextfileInterval, "metrics-textfile-interval", 15*time.Second, "metrics textfile write interval")
+	rootCmd.Flags().DurationVar(&rootMetricsActiveWindow, "metrics-active-window", metrics.DefaultActiveWindow, "neighbours seen within this window count as active")
+	rootCmd.Flags().StringVar(&rootOTLPEndpoint, "otlp-endpoint", "", "push metrics and neighbour events to this OTLP collector (host:port for grpc, URL for http)")
+	rootCmd.Flags().StringVar(&rootOTLPProtocol, "otlp-protocol", otlp.ProtocolGRPC, "OTLP protocol: grpc or http")
+	rootCmd.Flags().BoolVar(&rootOTLPInsecure, "otlp-insecure", false, "connect to the OTLP collector without TLS")
+	rootCmd.Flags().StringArrayVar(&rootOTLPHeaders, "otlp-header", nil, "OTLP request header as key=value (repeatable)")
+	rootCmd.Flags().StringArrayVar(&rootOTLPAttributes, "otlp-attribute", nil, "extra OTLP resource attribute as key=value (repeatable)")
+	rootCmd.Flags().DurationVar(&rootOTLPInterval, "otlp-interval", otlp.DefaultInterval, "OTLP export interval")
+	rootCmd.Flags().StringVar(&rootSyslog, "syslog", "", "send neighbour events to syslog: udp://host[:port], tcp://..., tls://..., unix:///path or \"local\" (/dev/log)")
+	rootCmd.Flags().StringVar(&rootSyslogFormat, "syslog-format", syslog.FormatRFC5424, "syslog payload format: rfc5424, cef or leef")
+	rootCmd.Flags().StringVar(&rootSyslogFacility, "syslog-facility", syslog.DefaultFacility, "syslog facility")
+	rootCmd.Flags().StringVar(&rootSyslogTLSCA, "syslog-tls-ca", "", "PEM file of CAs to verify a tls:// syslog server (system roots if empty)")
+	rootCmd.Flags().StringSliceVar(&rootSyslogEvents, "syslog-events", nil, "event types to send to syslog (default new_mac,new_ip,mac_expired,ip_conflict)")
 }
 
 // Execute runs the root command.
@@ -48,106 +110,262 @@ func runRoot(cmd *cobra.Command, args []string) error {
 		Level: slog.LevelInfo,
 	}))
 
-	// Resolve "external"/"any" to actual interface names.
-	resolved, err := resolveInterfaces(rootIfaces)
+	cfg, err := loadRootConfig(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to resolve interfaces: %w", err)
-	}
-	if len(resolved) == 0 {
-		return fmt.Errorf("no interfaces found")
+		return err
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	// Resolve "external"/"any" to actual interface names.
+	want, err := wantInterfaces(cfg)
+	if err != nil {
+		return err
+	}
+	resolved := sortedKeys(want)
 
-	// Attach probes to all interfaces.
-	var probes []*loader.Probe
-	for _, iface := range resolved {
-		probe, err := loader.Attach(iface, rootPinPath, logger)
-		if err != nil {
-			for _, p := range probes {
-				p.Close()
-			}
-			return fmt.Errorf("failed to attach probe to %s: %w", iface, err)
+	// Check the kernel before attaching so failures come with hints
+	// instead of a raw errno from the loader.
+	report := preflight.Run(preflight.Opts{PinPath: cfg.PinPath, Ifaces: resolved})
+	for _, c := range report.Checks {
+		if c.Status == preflight.StatusWarn {
+			logger.Warn("preflight", "check", c.Name, "detail", c.Detail, "hint", c.Hint)
 		}
-		probes = append(probes, probe)
+	}
+	if err := report.Err(); err != nil {
+		return err
 	}
 
-	logger.Info("l2radar running", "interfaces", resolved, "pin_path", rootPinPath)
+	if cfg.Export.Dir != "" && cfg.Export.Interval <= 0 {
+		return fmt.Errorf("export-interval must be positive")
+	}
+	if cfg.Export.Journal.Enabled && cfg.Export.Dir == "" {
+		return errJournalNoDir
+	}
 
-	// If export is enabled, start the export loop.
-	if rootExportDir != "" {
-		if rootExportInterval <= 0 {
-			for _, p := range probes {
-				p.Close()
-			}
-			return fmt.Errorf("export-interval must be positive")
+	if rootMetricsTextfile != "" {
+		if !strings.HasSuffix(rootMetricsTextfile, ".prom") {
+			return fmt.Errorf("metrics textfile %s must end in .prom", rootMetricsTextfile)
+		}
+		if rootMetricsTextfileInterval <= 0 {
+			return fmt.Errorf("metrics-textfile-interval must be positive")
 		}
+	}
 
-		if err := os.MkdirAll(rootExportDir, 0755); err != nil {
-			for _, p := range probes {
-				p.Close()
-			}
-			return fmt.Errorf("failed to create export directory %s: %w", rootExportDir, err)
+	var otlpOpts otlp.Opts
+	if rootOTLPEndpoint != "" {
+		headers, err := otlp.ParseKeyValues(rootOTLPHeaders)
+		if err != nil {
+			return fmt.Errorf("--otlp-header: %w", err)
+		}
+		attrs, err := otlp.ParseKeyValues(rootOTLPAttributes)
+		if err != nil {
+			return fmt.Errorf("--otlp-attribute: %w", err)
+		}
+		otlpOpts = otlp.Opts{
+			Endpoint:   rootOTLPEndpoint,
+			Protocol:   rootOTLPProtocol,
+			Insecure:   rootOTLPInsecure,
+			Headers:    headers,
+			Attributes: attrs,
+			Interval:   rootOTLPInterval,
+			Logger:     logger,
 		}
+	}
 
-		logger.Info("export enabled", "dir", rootExportDir, "interval", rootExportInterval.String())
+	if rootEventsGID != -1 && rootEventsSocket == "" {
+		return fmt.Errorf("--events-socket-gid requires --events-socket")
+	}
+	var token string
+	if rootTokenFile != "" {
+		if len(rootListen) == 0 {
+			return fmt.Errorf("--listen-token-file requires --listen")
+		}
+		b, err := os.ReadFile(rootTokenFile)
+		if err != nil {
+			return fmt.Errorf("reading API token: %w", err)
+		}
+		if token = strings.TrimSpace(string(b)); token == "" {
+			return fmt.Errorf("API token file %s is empty", rootTokenFile)
+		}
+	}
 
-		ticker := time.NewTicker(rootExportInterval)
-		defer ticker.Stop()
+	ctx, stop := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		// Export immediately, then on each tick.
-		exportAll(resolved, rootPinPath, rootExportDir, rootExportInterval, logger)
-		for {
-			select {
-			case <-ctx.Done():
-				goto shutdown
-			case <-ticker.C:
-				exportAll(resolved, rootPinPath, rootExportDir, rootExportInterval, logger)
-			}
-		}
-	} else {
-		// No export — just wait for signal.
-		<-ctx.Done()
+	exportColumns, err := dump.ParseColumns(cfg.Export.Columns)
+	if err != nil {
+		return err
 	}
+	filter, err := cfg.Filters.Compile()
+	if err != nil {
+		return err
+	}
+	d := daemon.New(daemon.Config{
+		PinPath:        cfg.PinPath,
+		ExportDir:      cfg.Export.Dir,
+		ExportInterval: cfg.Export.Interval,
+		ExportFormats:  cfg.Export.Formats,
+		ExportColumns:  exportColumns,
+		ExportCombined: cfg.Export.Combined,
+		Filter:         filter,
+		Logger:         logger,
+	})
 
-shutdown:
-	logger.Info("shutting down...")
-	for _, p := range probes {
-		if err := p.Close(); err != nil {
-			logger.Error("failed to close probe", "interface", p.Interface(), "error", err)
+	// Neighbour deltas for the SSE endpoint, computed on each export
+	// cycle.
+	var hub *events.Hub
+	if len(rootListen) > 0 || rootEventsSocket != "" {
+		hub = events.NewHub(logger)
+		d.AddObserver(hub)
+	}
+	// Metrics for /metrics, the node_exporter textfile and OTLP.
+	var collector *metrics.Collector
+	if len(rootListen) > 0 || rootMetricsTextfile != "" || rootOTLPEndpoint != "" {
+		collector = metrics.NewCollector(d, rootMetricsActiveWindow)
+		d.AddObserver(collector)
+	}
+	var otlpExporter *otlp.Exporter
+	if rootOTLPEndpoint != "" {
+		otlpExporter, err = otlp.New(collector, otlpOpts)
+		if err != nil {
+			return err
 		}
+		d.AddObserver(otlpExporter)
 	}
-	return nil
-}
-
-func exportAll(ifaces []string, pinPath, outputDir string, interval time.Duration, logger *slog.Logger) {
-	for _, iface := range ifaces {
-		mapPath := dump.PinPath(pinPath, iface)
-		neighbours, err := dump.ReadMap(mapPath)
+	if j := cfg.Export.Journal; j.Enabled {
+		jr, err := journal.Open(journal.Options{
+			Dir:       cfg.Export.Dir,
+			MaxSize:   int64(j.MaxSizeMB) << 20,
+			MaxAge:    j.MaxAge,
+			Keep:      j.Keep,
+			Retention: j.Retention,
+			Logger:    logger,
+		})
 		if err != nil {
-			logger.Error("failed to read map", "interface", iface, "error", err)
-			continue
+			return err
 		}
+		defer jr.Close()
+		d.AddObserver(jr)
+		logger.Info("event journal enabled", "path", filepath.Join(cfg.Export.Dir, journal.FileName))
+	}
+	sinks, err := newSinks(cfg, logger)
+	if err != nil {
+		return err
+	}
+	for _, s := range sinks {
+		d.AddObserver(s)
+	}
 
-		dump.SortByLastSeen(neighbours)
+	// Attach probes to all interfaces.
+	if _, _, err := d.Reconcile(want); err != nil {
+		d.Close()
+		return err
+	}
+	defer func() {
+		logger.Info("shutting down...")
+		d.Close()
+	}()
 
-		ifInfo, err := export.LookupInterfaceInfo(iface)
-		if err != nil {
-			logger.Warn("failed to lookup interface info", "interface", iface, "error", err)
+	logger.Info("l2radar running", "interfaces", resolved, "pin_path", cfg.PinPath)
+
+	// Re-read the configuration on SIGHUP. A failed reload keeps the
+	// running configuration.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go reloadOnSignal(ctx, hup, cfg, func(running *config.Config) (*config.Config, error) {
+		if rootConfigPath == "" {
+			logger.Warn("SIGHUP ignored: no --config file")
+			return running, nil
 		}
+		return reloadConfig(cmd, d, running, logger)
+	}, logger)
 
-		ifStats, err := export.LookupInterfaceStats(iface)
+	if rootCtlSocket != "" {
+		l, err := ctl.Listen(rootCtlSocket)
 		if err != nil {
-			logger.Warn("failed to lookup interface stats", "interface", iface, "error", err)
+			return fmt.Errorf("control socket: %w", err)
 		}
+		defer os.Remove(rootCtlSocket)
+		go ctl.Serve(ctx, l, d, logger)
+		logger.Info("control socket listening", "path", rootCtlSocket)
+	}
 
-		if err := export.WriteJSON(iface, neighbours, outputDir, time.Now(), interval, ifInfo, ifStats); err != nil {
-			logger.Error("failed to write JSON", "interface", iface, "error", err)
-			continue
+	if len(rootListen) > 0 {
+		srv := api.New(d, api.Opts{Token: token, Logger: logger})
+		srv.Handle("GET "+api.Prefix+"/events", hub)
+		srv.Handle("GET /metrics", collector)
+		for _, addr := range rootListen {
+			l, err := api.Listen(addr)
+			if err != nil {
+				return fmt.Errorf("HTTP API: %w", err)
+			}
+			if path, ok := strings.CutPrefix(addr, api.UnixPrefix); ok {
+				defer os.Remove(path)
+			}
+			go func() {
+				if err := api.Serve(ctx, l, srv, logger); err != nil {
+					logger.Error("HTTP API failed", "addr", addr, "error", err)
+				}
+			}()
+			logger.Info("HTTP API listening", "addr", addr, "auth", token != "")
+		}
+	}
+	if rootEventsSocket != "" {
+		l, err := api.ListenUnix(rootEventsSocket, rootEventsGID)
+		if err != nil {
+			return fmt.Errorf("events socket: %w", err)
 		}
+		defer os.Remove(rootEventsSocket)
+		go func() {
+			if err := api.Serve(ctx, l, api.EventsOnly(hub), logger); err != nil {
+				logger.Error("events socket failed", "path", rootEventsSocket, "error", err)
+			}
+		}()
+		logger.Info("events socket listening", "path", rootEventsSocket)
+	}
+
+	if rootMetricsTextfile != "" {
+		done := make(chan struct{})
+		go func() {
+			collector.RunTextfile(ctx, rootMetricsTextfile, rootMetricsTextfileInterval, logger)
+			close(done)
+		}()
+		// Wait for the textfile to be removed before exiting.
+		defer func() {
+			stop()
+			<-done
+		}()
+		logger.Info("writing metrics textfile", "path", rootMetricsTextfile)
+	}
+
+	if otlpExporter != nil {
+		done := make(chan struct{})
+		go func() {
+			otlpExporter.Run(ctx)
+			close(done)
+		}()
+		// Wait for the final export before exiting.
+		defer func() {
+			stop()
+			<-done
+		}()
+		logger.Info("OTLP export enabled", "endpoint", rootOTLPEndpoint, "protocol", rootOTLPProtocol)
+	}
 
-		logger.Debug("exported", "interface", iface, "neighbours", len(neighbours))
+	for i, s := range sinks {
+		done := make(chan struct{})
+		go func() {
+			s.Run(ctx)
+			close(done)
+		}()
+		// Wait for queued output to be delivered before exiting.
+		defer func() {
+			stop()
+			<-done
+		}()
+		logger.Info("sink enabled", "type", cfg.Sinks[i].Type, "interfaces", cfg.Sinks[i].Interfaces)
 	}
+
+	return d.Run(ctx)
 }
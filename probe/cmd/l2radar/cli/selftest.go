@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/loader"
+	"github.com/marc/l2radar/probe/pkg/selftest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selftestPinPath string
+	selftestTimeout time.Duration
+	selftestOutput  string
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Verify kernel, bpffs, TCX and parser end to end",
+	Long: `Create a temporary network namespace and veth pair, attach the probe,
+inject crafted ARP/NDP/VLAN frames, and verify the pinned map contents and
+export JSON. Everything is cleaned up afterwards; running probes are not
+affected.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if selftestOutput != "table" && selftestOutput != "json" {
+			return fmt.Errorf("invalid output format %q (supported: table, json)", selftestOutput)
+		}
+
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelWarn,
+		}))
+		report, err := selftest.Run(selftest.Opts{
+			PinPath: selftestPinPath,
+			Timeout: selftestTimeout,
+			Logger:  logger,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch selftestOutput {
+		case "table":
+			report.Format(cmd.OutOrStdout())
+		case "json":
+			b, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal JSON: %w", err)
+			}
+			if _, err := cmd.OutOrStdout().Write(append(b, '\n')); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+		}
+
+		if !report.Passed() {
+			return fmt.Errorf("selftest failed: %d of %d checks failed", report.Failed(), len(report.Checks))
+		}
+		return nil
+	},
+}
+
+func init() {
+	selftestCmd.Flags().StringVar(&selftestPinPath, "pin-path", loader.DefaultPinPath, "bpffs base path (a temporary subdirectory is used)")
+	selftestCmd.Flags().DurationVar(&selftestTimeout, "timeout", selftest.DefaultTimeout, "how long to wait for injected frames")
+	selftestCmd.Flags().StringVarP(&selftestOutput, "output", "o", "table", "output format (table|json)")
+
+	rootCmd.AddCommand(selftestCmd)
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/sink"
+
+	// Sink implementations, registered with the sink package.
+	_ "github.com/marc/l2radar/probe/pkg/elasticsearch"
+	_ "github.com/marc/l2radar/probe/pkg/influx"
+	_ "github.com/marc/l2radar/probe/pkg/mqtt"
+	_ "github.com/marc/l2radar/probe/pkg/syslog"
+	_ "github.com/marc/l2radar/probe/pkg/webhook"
+)
+
+// newSinks builds the sinks of c. Nothing is started or contacted, so
+// "config check" also uses it to validate sink options.
+func newSinks(c *config.Config, logger *slog.Logger) ([]sink.Sink, error) {
+	var sinks []sink.Sink
+	for i, s := range c.Sinks {
+		out, err := sink.New(s, logger)
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d] (%s): %w", i, s.Type, err)
+		}
+		sinks = append(sinks, out)
+	}
+	return sinks, nil
+}
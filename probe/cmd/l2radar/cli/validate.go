@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateKind   string
+	validateSchema bool
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <file|dir>...",
+	Short: "Validate export files against the JSON Schema",
+	Long: fmt.Sprintf(`Check export files against the JSON Schema of their schema version
+(%d). The kind of each file is taken from its name (manifest.json,
+neighbours.json, or an interface file otherwise) unless --kind is given.
+A directory stands for its neigh-*.json, manifest.json and
+neighbours.json files.
+
+With --schema, print the JSON Schema of --kind instead.`, export.SchemaVersion),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if validateKind != "" && !slices.Contains(export.Kinds(), validateKind) {
+			return fmt.Errorf("unknown kind %q (supported: %s)", validateKind, strings.Join(export.Kinds(), ", "))
+		}
+		if validateSchema {
+			return printSchema(cmd.OutOrStdout(), validateKind)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("no files given")
+		}
+
+		files, err := validateFiles(args)
+		if err != nil {
+			return err
+		}
+		invalid := 0
+		for _, path := range files {
+			if !validateFile(cmd.OutOrStdout(), path) {
+				invalid++
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d files invalid", invalid, len(files))
+		}
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&validateKind, "kind", "", "document kind ("+strings.Join(export.Kinds(), ", ")+"); from the file name if empty")
+	validateCmd.Flags().BoolVar(&validateSchema, "schema", false, "print the JSON Schema of --kind (default interface)")
+
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validateFiles expands the directories of args to their export files.
+func validateFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "neigh-*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{export.ManifestFileName, export.CombinedFileName} {
+			if path := filepath.Join(arg, name); fileExists(path) {
+				matches = append(matches, path)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no export files", arg)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// validateFile prints the result of validating path and reports whether
+// it is valid.
+func validateFile(w io.Writer, path string) bool {
+	kind := validateKind
+	if kind == "" {
+		kind = export.KindOf(filepath.Base(path))
+	}
+	b, err := os.ReadFile(path)
+	var errs []export.ValidationError
+	if err == nil {
+		errs, err = export.Validate(kind, b)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(w, "%s: invalid %s (%d errors)\n", path, kind, len(errs))
+		for _, e := range errs {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+		return false
+	}
+	fmt.Fprintf(w, "%s: ok (%s, schema version %d)\n", path, kind, export.SchemaVersion)
+	return true
+}
+
+func printSchema(w io.Writer, kind string) error {
+	if kind == "" {
+		kind = export.KindInterface
+	}
+	s, err := export.JSONSchema(kind)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"neigh-eth0.json", "manifest.json", "journal.ndjson", "neigh-eth0.csv"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+	}
+	files, err := validateFiles([]string{dir, "../../../../testdata/neigh-wlan0.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	if got := strings.Join(names, ","); got != "neigh-eth0.json,manifest.json,neigh-wlan0.json" {
+		t.Errorf("unexpected files %s", got)
+	}
+	if _, err := validateFiles([]string{t.TempDir()}); err == nil {
+		t.Error("expected error for a directory without export files")
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	var out strings.Builder
+	if !validateFile(&out, "../../../../testdata/neigh-eth0.json") || !strings.Contains(out.String(), "ok (interface, schema version 1)") {
+		t.Errorf("golden file: %s", out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	os.WriteFile(path, []byte(`{"schema_version": 1, "hostname": 3}`), 0o644)
+	out.Reset()
+	if validateFile(&out, path) || !strings.Contains(out.String(), "invalid manifest") || !strings.Contains(out.String(), "$.hostname: expected string, got integer") {
+		t.Errorf("invalid manifest: %s", out.String())
+	}
+}
@@ -0,0 +1,32 @@
+// Package testutil holds the fixtures shared by the probe's tests.
+package testutil
+
+import (
+	"net"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// Neighbour returns the entry of mac, first seen at firstSeen and last
+// seen at lastSeen, with the IPv4 and IPv6 addresses in ips.
+func Neighbour(mac string, firstSeen, lastSeen time.Time, ips ...string) dump.Neighbour {
+	hw, _ := net.ParseMAC(mac)
+	n := dump.Neighbour{MAC: hw, FirstSeen: firstSeen, LastSeen: lastSeen}
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if v4 := ip.To4(); v4 != nil {
+			n.IPv4 = append(n.IPv4, v4)
+		} else {
+			n.IPv6 = append(n.IPv6, ip)
+		}
+	}
+	return n
+}
+
+// NeighbourJSON returns the exported entry of mac, seen at seen, with the
+// IPv4 and IPv6 addresses in ips.
+func NeighbourJSON(mac string, seen time.Time, ips ...string) export.NeighbourJSON {
+	return export.NewNeighbourJSON(Neighbour(mac, seen, seen, ips...))
+}
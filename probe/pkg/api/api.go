@@ -0,0 +1,345 @@
+// Package api serves the probe's read-only REST API: attached interfaces,
+// their neighbour tables with filtering, sorting and paging, and lookups by
+// MAC or IP address across all interfaces.
+//
+// Responses reuse the export types (export.NeighbourJSON) so API clients
+// and export file readers see the same fields.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/oui"
+)
+
+// Prefix is the path prefix of all API endpoints.
+const Prefix = "/api/v1"
+
+// Source provides the data served by the API; implemented by
+// daemon.Daemon.
+type Source interface {
+	Interfaces() []string
+	Neighbours(iface string) ([]dump.Neighbour, error)
+}
+
+// Opts holds server options.
+type Opts struct {
+	// Token enables bearer authentication if not empty.
+	Token  string
+	Logger *slog.Logger
+}
+
+// Server is the HTTP API. Other probe endpoints (events, metrics) are
+// registered on the same mux with Handle.
+type Server struct {
+	src    Source
+	opts   Opts
+	mux    *http.ServeMux
+	lookup func(name string) (*export.InterfaceInfo, error)
+	stats  func(name string) (*export.InterfaceStats, error)
+}
+
+// New returns a server for src.
+func New(src Source, opts Opts) *Server {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	s := &Server{
+		src:    src,
+		opts:   opts,
+		mux:    http.NewServeMux(),
+		lookup: export.LookupInterfaceInfo,
+		stats:  export.LookupInterfaceStats,
+	}
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	s.Handle("GET "+Prefix+"/interfaces", http.HandlerFunc(s.handleInterfaces))
+	s.Handle("GET "+Prefix+"/interfaces/{iface}/neighbours", http.HandlerFunc(s.handleNeighbours))
+	s.Handle("GET "+Prefix+"/neighbours/{mac}", http.HandlerFunc(s.handleMAC))
+	s.Handle("GET "+Prefix+"/lookup", http.HandlerFunc(s.handleLookup))
+	return s
+}
+
+// Handle registers an authenticated handler.
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, s.auth(h))
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// auth rejects requests without the bearer token, if one is configured,
+// on every listener (unix sockets included).
+func (s *Server) auth(h http.Handler) http.Handler {
+	if s.opts.Token == "" {
+		return h
+	}
+	want := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="l2radar"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// UnixPrefix marks a unix socket listen address, e.g.
+// "unix:/run/l2radar/api.sock".
+const UnixPrefix = "unix:"
+
+// Listen listens on a TCP address or, with UnixPrefix, a unix socket (see
+// ListenUnix).
+func Listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, UnixPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	return ListenUnix(path, -1)
+}
+
+// ListenUnix listens on a unix socket at path, replacing a stale one. The
+// socket is only accessible to its owner and group (0660); gid, unless -1,
+// sets the group, e.g. to let nginx in another container connect.
+func ListenUnix(path string, gid int) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("removing stale socket %s: %w", path, err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	if gid != -1 {
+		if err := os.Chown(path, -1, gid); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("setting socket group: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// EventsOnly returns a handler serving only the events endpoint, with h
+// and without authentication, for the events socket (--events-socket):
+// access to it is controlled by the socket's permissions.
+func EventsOnly(h http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+Prefix+"/events", h)
+	return mux
+}
+
+// Serve serves s on l until ctx is cancelled.
+func Serve(ctx context.Context, l net.Listener, h http.Handler, logger *slog.Logger) error {
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Interface is an entry of GET /interfaces.
+type Interface struct {
+	Name       string                 `json:"interface"`
+	MAC        string                 `json:"mac"`
+	IPv4       []string               `json:"ipv4"`
+	IPv6       []string               `json:"ipv6"`
+	Stats      *export.InterfaceStats `json:"stats"`
+	Neighbours int                    `json:"neighbours"`
+}
+
+// Neighbour is a neighbour entry with its vendor and, for cross-interface
+// lookups, the interface it was seen on.
+type Neighbour struct {
+	Interface string `json:"interface,omitempty"`
+	export.NeighbourJSON
+	Vendor string `json:"vendor,omitempty"`
+}
+
+// NeighbourPage is the response of GET /interfaces/{iface}/neighbours.
+type NeighbourPage struct {
+	Interface  string      `json:"interface"`
+	Total      int         `json:"total"`
+	Offset     int         `json:"offset"`
+	Limit      int         `json:"limit"`
+	Neighbours []Neighbour `json:"neighbours"`
+}
+
+// ErrorResponse is the body of non-2xx responses.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleInterfaces(w http.ResponseWriter, r *http.Request) {
+	out := []Interface{}
+	for _, name := range s.src.Interfaces() {
+		ifc := Interface{Name: name, IPv4: []string{}, IPv6: []string{}}
+		if info, err := s.lookup(name); err == nil {
+			ifc.MAC = info.MAC.String()
+			for _, ip := range info.IPv4 {
+				ifc.IPv4 = append(ifc.IPv4, ip.String())
+			}
+			for _, ip := range info.IPv6 {
+				ifc.IPv6 = append(ifc.IPv6, ip.String())
+			}
+		}
+		if stats, err := s.stats(name); err == nil {
+			ifc.Stats = stats
+		}
+		if neighbours, err := s.src.Neighbours(name); err == nil {
+			ifc.Neighbours = len(neighbours)
+		}
+		out = append(out, ifc)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleNeighbours(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("iface")
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.attached(name) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("interface %s is not attached", name))
+		return
+	}
+	neighbours, err := s.src.Neighbours(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	matched, err := q.apply(neighbours)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page := NeighbourPage{
+		Interface:  name,
+		Total:      len(matched),
+		Offset:     q.offset,
+		Limit:      q.limit,
+		Neighbours: []Neighbour{},
+	}
+	if q.offset < len(matched) {
+		end := min(q.offset+q.limit, len(matched))
+		for _, n := range matched[q.offset:end] {
+			page.Neighbours = append(page.Neighbours, newNeighbour("", n))
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) handleMAC(w http.ResponseWriter, r *http.Request) {
+	mac, err := net.ParseMAC(r.PathValue("mac"))
+	if err != nil || len(mac) != 6 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid MAC %q", r.PathValue("mac")))
+		return
+	}
+	s.find(w, func(n *dump.Neighbour) bool {
+		return n.MAC.String() == mac.String()
+	})
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(r.URL.Query().Get("ip"))
+	if ip == nil {
+		writeError(w, http.StatusBadRequest, "missing or invalid ip parameter")
+		return
+	}
+	s.find(w, func(n *dump.Neighbour) bool {
+		return hasIP(n, ip)
+	})
+}
+
+// find writes the entries matching match on all interfaces, or 404.
+func (s *Server) find(w http.ResponseWriter, match func(*dump.Neighbour) bool) {
+	out := []Neighbour{}
+	for _, name := range s.src.Interfaces() {
+		neighbours, err := s.src.Neighbours(name)
+		if err != nil {
+			s.opts.Logger.Warn("api: failed to read neighbours", "interface", name, "error", err)
+			continue
+		}
+		for i := range neighbours {
+			if match(&neighbours[i]) {
+				out = append(out, newNeighbour(name, neighbours[i]))
+			}
+		}
+	}
+	if len(out) == 0 {
+		writeError(w, http.StatusNotFound, "no matching neighbour")
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) attached(name string) bool {
+	for _, n := range s.src.Interfaces() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newNeighbour(iface string, n dump.Neighbour) Neighbour {
+	return Neighbour{
+		Interface:     iface,
+		NeighbourJSON: export.NewNeighbourJSON(n),
+		Vendor:        oui.Lookup(n.MAC),
+	}
+}
+
+func hasIP(n *dump.Neighbour, ip net.IP) bool {
+	for _, list := range [][]net.IP{n.IPv4, n.IPv6} {
+		for _, a := range list {
+			if a.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, ErrorResponse{Error: strings.TrimSpace(msg)})
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+var t0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeSource serves fixed neighbour tables.
+type fakeSource map[string][]dump.Neighbour
+
+func (f fakeSource) Interfaces() []string {
+	var names []string
+	for _, name := range []string{"eth0", "eth1"} {
+		if _, ok := f[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (f fakeSource) Neighbours(iface string) ([]dump.Neighbour, error) {
+	n, ok := f[iface]
+	if !ok {
+		return nil, errors.New("not attached")
+	}
+	// Return a copy: the API filters in place.
+	return append([]dump.Neighbour(nil), n...), nil
+}
+
+func testServer(token string) *Server {
+	src := fakeSource{
+		"eth0": {
+			testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Minute), "192.168.1.10"),
+			testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(3*time.Minute), "192.168.1.2", "fe80::2"),
+			testutil.Neighbour("dc:a6:32:00:00:03", t0, t0.Add(2*time.Minute), "10.0.0.3"),
+		},
+		"eth1": {
+			testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(5*time.Minute), "172.16.0.1"),
+		},
+	}
+	s := New(src, Opts{Token: token})
+	s.lookup = func(name string) (*export.InterfaceInfo, error) {
+		return &export.InterfaceInfo{MAC: net.HardwareAddr{0xaa, 0, 0, 0, 0, 1}}, nil
+	}
+	s.stats = func(name string) (*export.InterfaceStats, error) {
+		return &export.InterfaceStats{RxPackets: 7}, nil
+	}
+	return s
+}
+
+func get(t *testing.T, h http.Handler, path string, header http.Header, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s: decoding %q: %v", path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestInterfaces(t *testing.T) {
+	var got []Interface
+	if code := get(t, testServer(""), "/api/v1/interfaces", nil, &got); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(got) != 2 || got[0].Name != "eth0" || got[0].Neighbours != 3 || got[1].Neighbours != 1 {
+		t.Fatalf("unexpected interfaces: %+v", got)
+	}
+	if got[0].MAC != "aa:00:00:00:00:01" || got[0].Stats == nil || got[0].Stats.RxPackets != 7 {
+		t.Errorf("missing interface info: %+v", got[0])
+	}
+}
+
+func TestNeighboursDefaultOrder(t *testing.T) {
+	var page NeighbourPage
+	if code := get(t, testServer(""), "/api/v1/interfaces/eth0/neighbours", nil, &page); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if page.Total != 3 || page.Limit != DefaultLimit || len(page.Neighbours) != 3 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	// Most recently seen first.
+	if page.Neighbours[0].MAC != "02:00:00:00:00:02" || page.Neighbours[2].MAC != "02:00:00:00:00:01" {
+		t.Errorf("unexpected order: %+v", page.Neighbours)
+	}
+	if page.Neighbours[0].Interface != "" {
+		t.Error("interface set on per-interface listing")
+	}
+}
+
+func TestNeighboursFilterSortPage(t *testing.T) {
+	tests := []struct {
+		query string
+		total int
+		macs  []string
+	}{
+		{"mac=02:00", 2, []string{"02:00:00:00:00:02", "02:00:00:00:00:01"}},
+		{"mac=DC-A6", 1, []string{"dc:a6:32:00:00:03"}},
+		{"ip=192.168.1.0/24&sort=ip", 2, []string{"02:00:00:00:00:02", "02:00:00:00:00:01"}},
+		{"ip=fe80::2", 1, []string{"02:00:00:00:00:02"}},
+		{"sort=mac&limit=2", 3, []string{"02:00:00:00:00:01", "02:00:00:00:00:02"}},
+		{"sort=mac&order=desc&offset=1", 3, []string{"02:00:00:00:00:02", "02:00:00:00:00:01"}},
+		{"sort=first_seen&order=asc&offset=5", 3, nil},
+	}
+	for _, tt := range tests {
+		var page NeighbourPage
+		if code := get(t, testServer(""), "/api/v1/interfaces/eth0/neighbours?"+tt.query, nil, &page); code != http.StatusOK {
+			t.Errorf("%s: status %d", tt.query, code)
+			continue
+		}
+		var macs []string
+		for _, n := range page.Neighbours {
+			macs = append(macs, n.MAC)
+		}
+		if page.Total != tt.total || len(macs) != len(tt.macs) {
+			t.Errorf("%s: total %d, macs %v", tt.query, page.Total, macs)
+			continue
+		}
+		for i := range macs {
+			if macs[i] != tt.macs[i] {
+				t.Errorf("%s: got %v, want %v", tt.query, macs, tt.macs)
+				break
+			}
+		}
+	}
+}
+
+func TestNeighboursErrors(t *testing.T) {
+	s := testServer("")
+	for path, want := range map[string]int{
+		"/api/v1/interfaces/wlan0/neighbours":          http.StatusNotFound,
+		"/api/v1/interfaces/eth0/neighbours?sort=age":  http.StatusBadRequest,
+		"/api/v1/interfaces/eth0/neighbours?order=up":  http.StatusBadRequest,
+		"/api/v1/interfaces/eth0/neighbours?limit=0":   http.StatusBadRequest,
+		"/api/v1/interfaces/eth0/neighbours?offset=-1": http.StatusBadRequest,
+		"/api/v1/interfaces/eth0/neighbours?ip=nope":   http.StatusBadRequest,
+	} {
+		if code := get(t, s, path, nil, nil); code != want {
+			t.Errorf("%s: status %d, want %d", path, code, want)
+		}
+	}
+}
+
+func TestLookupByMACAndIP(t *testing.T) {
+	s := testServer("")
+
+	var byMAC []Neighbour
+	if code := get(t, s, "/api/v1/neighbours/02-00-00-00-00-01", nil, &byMAC); code != http.StatusOK {
+		t.Fatalf("mac lookup: status %d", code)
+	}
+	if len(byMAC) != 2 || byMAC[0].Interface != "eth0" || byMAC[1].Interface != "eth1" {
+		t.Errorf("unexpected MAC lookup: %+v", byMAC)
+	}
+
+	var byIP []Neighbour
+	if code := get(t, s, "/api/v1/lookup?ip=10.0.0.3", nil, &byIP); code != http.StatusOK {
+		t.Fatalf("ip lookup: status %d", code)
+	}
+	if len(byIP) != 1 || byIP[0].MAC != "dc:a6:32:00:00:03" || byIP[0].Interface != "eth0" {
+		t.Errorf("unexpected IP lookup: %+v", byIP)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/neighbours/02:00:00:00:00:99": http.StatusNotFound,
+		"/api/v1/neighbours/nope":              http.StatusBadRequest,
+		"/api/v1/lookup?ip=10.9.9.9":           http.StatusNotFound,
+		"/api/v1/lookup":                       http.StatusBadRequest,
+	} {
+		if code := get(t, s, path, nil, nil); code != want {
+			t.Errorf("%s: status %d, want %d", path, code, want)
+		}
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	s := testServer("s3cret")
+	if code := get(t, s, "/api/v1/interfaces", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d", code)
+	}
+	bad := http.Header{"Authorization": {"Bearer nope"}}
+	if code := get(t, s, "/api/v1/interfaces", bad, nil); code != http.StatusUnauthorized {
+		t.Errorf("bad token: status %d", code)
+	}
+	good := http.Header{"Authorization": {"Bearer s3cret"}}
+	if code := get(t, s, "/api/v1/interfaces", good, nil); code != http.StatusOK {
+		t.Errorf("good token: status %d", code)
+	}
+	if code := get(t, s, "/healthz", nil, nil); code != http.StatusOK {
+		t.Errorf("healthz must not require auth: status %d", code)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// A stale socket is replaced.
+	l, err := Listen(UnixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0o660 {
+		t.Errorf("unexpected socket mode %v", fi.Mode())
+	}
+}
+
+func TestUnixSocketRequiresToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	l, err := Listen(UnixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Serve(ctx, l, testServer("s3cret"), nil)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	for _, endpoint := range []string{"/api/v1/interfaces", "/api/v1/lookup?ip=10.0.0.1"} {
+		resp, err := client.Get("http://l2radar" + endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s over a unix socket without token: status %d", endpoint, resp.StatusCode)
+		}
+	}
+}
+
+func TestEventsOnly(t *testing.T) {
+	events := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("events\n"))
+	})
+	h := EventsOnly(events)
+	if code := get(t, h, "/api/v1/events", nil, nil); code != http.StatusOK {
+		t.Errorf("events: status %d", code)
+	}
+	for _, path := range []string{"/api/v1/interfaces", "/metrics", "/healthz"} {
+		if code := get(t, h, path, nil, nil); code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, code)
+		}
+	}
+}
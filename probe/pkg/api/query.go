@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+// Paging limits for neighbour listings.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 10000
+)
+
+// query holds the filters, sort order and page of a neighbour listing.
+type query struct {
+	filter dump.Filter
+	sort   string
+	desc   bool
+	limit  int
+	offset int
+}
+
+// parseQuery parses the listing parameters:
+//
+//	mac=<prefix> ip=<addr|cidr> vendor=<substring>
+//	sort=mac|ip|vendor|first_seen|last_seen order=asc|desc
+//	limit=<n> offset=<n>
+//
+// The default is the most recently seen first and DefaultLimit entries.
+func parseQuery(v url.Values) (*query, error) {
+	q := &query{
+		filter: dump.Filter{MAC: v.Get("mac"), Vendor: v.Get("vendor")},
+		sort:   "last_seen",
+		limit:  DefaultLimit,
+	}
+
+	if s := v.Get("ip"); s != "" {
+		ipNet, err := dump.ParseIPNet(s)
+		if err != nil {
+			return nil, err
+		}
+		q.filter.IP = ipNet
+	}
+
+	if s := v.Get("sort"); s != "" {
+		if !dump.ValidSortKey(s) {
+			return nil, fmt.Errorf("invalid sort %q (supported: %s)", s, strings.Join(dump.SortKeys(), ", "))
+		}
+		q.sort = s
+	}
+	switch v.Get("order") {
+	case "":
+		q.desc = dump.DefaultDescending(q.sort)
+	case "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return nil, fmt.Errorf("invalid order %q (supported: asc, desc)", v.Get("order"))
+	}
+
+	var err error
+	if q.limit, err = intParam(v, "limit", DefaultLimit); err != nil {
+		return nil, err
+	}
+	if q.limit < 1 || q.limit > MaxLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+	if q.offset, err = intParam(v, "offset", 0); err != nil {
+		return nil, err
+	}
+	if q.offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+	return q, nil
+}
+
+func intParam(v url.Values, name string, def int) (int, error) {
+	s := v.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return n, nil
+}
+
+// apply filters and sorts neighbours (in place) and returns the matches.
+func (q *query) apply(neighbours []dump.Neighbour) ([]dump.Neighbour, error) {
+	out := dump.FilterNeighbours(neighbours, q.filter)
+	if err := dump.Sort(out, q.sort, q.desc); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
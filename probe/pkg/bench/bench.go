@@ -0,0 +1,186 @@
+// Package bench measures the per-packet cost of the eBPF program.
+//
+// Each frame of a built-in corpus is run repeatedly with BPF_PROG_TEST_RUN
+// against a private map prefilled to different levels, and the kernel's
+// average run time is reported in nanoseconds per packet.
+//
+// BPF_PROG_TEST_RUN always builds a linear skb of at most one page, so the
+// bpf_skb_pull_data path for non-linear frames cannot be exercised: the
+// "non-linear" frame is reported as unsupported rather than measured.
+package bench
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/frames"
+)
+
+// DefaultRepeat is the default number of runs per frame and scenario.
+const DefaultRepeat = 100000
+
+// Frame is a named benchmark input.
+type Frame struct {
+	Name string
+	Data []byte
+	// Unsupported, if set, is why the frame cannot be measured; Run
+	// reports it instead of running the frame.
+	Unsupported string
+}
+
+// unsupportedNonLinear is why non-linear frames are not measured.
+const unsupportedNonLinear = "BPF_PROG_TEST_RUN only builds linear skbs"
+
+// Scenario describes how full the map is while measuring.
+type Scenario struct {
+	Name string
+	// Fill is the fraction of the map's max entries prefilled with
+	// unrelated neighbours before running the corpus.
+	Fill float64
+}
+
+// Scenarios lists the built-in map-size scenarios. In "full" the map has
+// no room left, so frames from new MACs exercise the failed-insert path.
+var Scenarios = []Scenario{
+	{Name: "empty", Fill: 0},
+	{Name: "half", Fill: 0.5},
+	{Name: "full", Fill: 1},
+}
+
+// Result is the measured cost of one frame in one scenario.
+type Result struct {
+	Frame       string  `json:"frame"`
+	Scenario    string  `json:"scenario"`
+	Entries     int     `json:"entries"`
+	Repeat      int     `json:"repeat"`
+	NsPerPacket float64 `json:"ns_per_packet,omitempty"`
+	// Unsupported is set, with no measurement (ns_per_packet omitted), for
+	// frames that cannot be run (see Frame).
+	Unsupported string `json:"unsupported,omitempty"`
+}
+
+// Program is the subset of loader.Collection used by Run.
+type Program interface {
+	Program() *ebpf.Program
+	Neighbours() *ebpf.Map
+}
+
+var (
+	benchMAC    = net.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x01}
+	benchPeer   = net.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x02}
+	benchIPv4   = net.ParseIP("192.0.2.10")
+	benchPeerV4 = net.ParseIP("192.0.2.1")
+	benchIPv6   = net.ParseIP("fe80::5eff:fe10:1")
+	benchPeerV6 = net.ParseIP("fe80::5eff:fe10:2")
+)
+
+// Corpus returns the built-in frames: plain IPv4/IPv6, ARP request and
+// reply, the four NDP messages, VLAN-tagged ARP and NS, a full-size
+// (1500-byte MTU) linear frame, and a non-linear frame, which is
+// unsupported.
+func Corpus() []Frame {
+	arpReq := frames.ARP(frames.Broadcast, benchMAC, frames.ARPRequest, benchMAC, benchIPv4, nil, benchPeerV4)
+	ns := frames.NS(benchMAC, benchIPv6, benchPeerV6)
+
+	return []Frame{
+		{Name: "ipv4", Data: frames.IPv4(benchPeer, benchMAC, benchIPv4, benchPeerV4, 64)},
+		{Name: "ipv6", Data: frames.IPv6(benchPeer, benchMAC, benchIPv6, benchPeerV6, 64)},
+		{Name: "arp-request", Data: arpReq},
+		{Name: "arp-reply", Data: frames.ARP(benchPeer, benchMAC, frames.ARPReply, benchMAC, benchIPv4, benchPeer, benchPeerV4)},
+		{Name: "ndp-ns", Data: ns},
+		{Name: "ndp-na", Data: frames.NA(benchMAC, benchIPv6, benchIPv6)},
+		{Name: "ndp-rs", Data: frames.RS(benchMAC, benchIPv6)},
+		{Name: "ndp-ra", Data: frames.RA(benchMAC, benchIPv6)},
+		{Name: "vlan-arp-request", Data: frames.VLAN(arpReq, 100)},
+		{Name: "vlan-ndp-ns", Data: frames.VLAN(ns, 100)},
+		{Name: "mtu", Data: frames.IPv6(benchPeer, benchMAC, benchIPv6, benchPeerV6, 1500-40-8)},
+		{Name: "non-linear", Unsupported: unsupportedNonLinear},
+	}
+}
+
+// Run measures every frame in every scenario. repeat is the number of
+// runs per measurement.
+func Run(prog Program, corpus []Frame, scenarios []Scenario, repeat int) ([]Result, error) {
+	if repeat <= 0 {
+		return nil, fmt.Errorf("repeat must be positive")
+	}
+
+	m := prog.Neighbours()
+	var results []Result
+	for _, sc := range scenarios {
+		entries := int(sc.Fill * float64(m.MaxEntries()))
+		if err := resetMap(m, entries); err != nil {
+			return nil, fmt.Errorf("preparing scenario %s: %w", sc.Name, err)
+		}
+
+		for _, f := range corpus {
+			if f.Unsupported != "" {
+				results = append(results, Result{
+					Frame:       f.Name,
+					Scenario:    sc.Name,
+					Entries:     entries,
+					Unsupported: f.Unsupported,
+				})
+				continue
+			}
+			_, d, err := prog.Program().Benchmark(f.Data, repeat, nil)
+			if err != nil {
+				return nil, fmt.Errorf("running %s/%s: %w", f.Name, sc.Name, err)
+			}
+			results = append(results, Result{
+				Frame:       f.Name,
+				Scenario:    sc.Name,
+				Entries:     entries,
+				Repeat:      repeat,
+				NsPerPacket: float64(d.Nanoseconds()),
+			})
+		}
+	}
+	return results, nil
+}
+
+// resetMap empties m and fills it with n random locally administered MACs.
+func resetMap(m *ebpf.Map, n int) error {
+	var (
+		key  dump.MacKey
+		keys []dump.MacKey
+		val  dump.NeighbourEntry
+	)
+	iter := m.Iterate()
+	for iter.Next(&key, &val) {
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("iterating map: %w", err)
+	}
+	for _, k := range keys {
+		if err := m.Delete(&k); err != nil {
+			return fmt.Errorf("deleting entry: %w", err)
+		}
+	}
+
+	val = dump.NeighbourEntry{FirstSeen: 1, LastSeen: 1}
+	for inserted := 0; inserted < n; {
+		if _, err := rand.Read(key.Addr[:]); err != nil {
+			return err
+		}
+		// Unicast, locally administered, and never one of the corpus MACs.
+		key.Addr[0] = key.Addr[0]&^0x01 | 0x02
+		if key.Addr[0] == benchMAC[0] && key.Addr[1] == benchMAC[1] {
+			continue
+		}
+		if err := m.Update(&key, &val, ebpf.UpdateNoExist); err != nil {
+			if errors.Is(err, ebpf.ErrKeyExist) {
+				continue // random collision
+			}
+			return fmt.Errorf("prefilling map: %w", err)
+		}
+		inserted++
+	}
+	return nil
+}
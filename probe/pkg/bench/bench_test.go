@@ -0,0 +1,92 @@
+package bench
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/marc/l2radar/probe/pkg/loader"
+)
+
+func TestResultJSON(t *testing.T) {
+	b, err := json.Marshal([]Result{
+		{Frame: "ipv4", Scenario: "empty", Repeat: 10, NsPerPacket: 41},
+		{Frame: "non-linear", Scenario: "empty", Unsupported: "linear only"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0]["ns_per_packet"] != 41.0 {
+		t.Errorf("measured result: %s", b)
+	}
+	if _, ok := got[1]["ns_per_packet"]; ok || got[1]["unsupported"] != "linear only" {
+		t.Errorf("unsupported result must have no ns_per_packet: %s", b)
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, f := range Corpus() {
+		if seen[f.Name] {
+			t.Errorf("duplicate frame name %q", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Unsupported != "" {
+			continue
+		}
+		if len(f.Data) < 14 {
+			t.Errorf("frame %q shorter than an Ethernet header", f.Name)
+		}
+		if f.Data[6]&0x01 != 0 {
+			t.Errorf("frame %q has a multicast source MAC", f.Name)
+		}
+	}
+	for _, name := range []string{"ipv4", "arp-request", "arp-reply", "ndp-ns", "ndp-na", "ndp-rs", "ndp-ra", "vlan-arp-request", "mtu", "non-linear"} {
+		if !seen[name] {
+			t.Errorf("corpus missing %q", name)
+		}
+	}
+}
+
+func TestRunRejectsNonPositiveRepeat(t *testing.T) {
+	if _, err := Run(nil, nil, nil, 0); err == nil {
+		t.Fatal("expected error for repeat=0")
+	}
+}
+
+func TestRun(t *testing.T) {
+	col, err := loader.Load()
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) || errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("skipping: insufficient privileges to load eBPF programs")
+		}
+		t.Fatalf("loading eBPF objects: %v", err)
+	}
+	defer col.Close()
+
+	corpus := Corpus()[:3]
+	results, err := Run(col, corpus, Scenarios, 10)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(results) != len(corpus)*len(Scenarios) {
+		t.Fatalf("expected %d results, got %d", len(corpus)*len(Scenarios), len(results))
+	}
+
+	max := int(col.Neighbours().MaxEntries())
+	for _, r := range results {
+		if r.NsPerPacket <= 0 {
+			t.Errorf("%s/%s: expected positive ns/packet, got %v", r.Frame, r.Scenario, r.NsPerPacket)
+		}
+		if r.Scenario == "full" && r.Entries != max {
+			t.Errorf("full scenario: expected %d entries, got %d", max, r.Entries)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix is the path prefix of the probe's API endpoints.
+const apiPrefix = "/api/v1"
+
+// unixPrefix marks a unix socket address, as in the probe's --listen.
+const unixPrefix = "unix:"
+
+// API is a client of a running probe's HTTP API (--listen).
+type API struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// APIOpts holds API client options.
+type APIOpts struct {
+	// Token is sent as a bearer token if not empty.
+	Token string
+	// HTTPClient is used instead of a default client; ignored for unix
+	// socket addresses.
+	HTTPClient *http.Client
+}
+
+// NewAPI returns a client of the probe API at addr: a base URL such as
+// "http://probe:8080", or "unix:<path>" for the API socket.
+func NewAPI(addr string, opts APIOpts) (*API, error) {
+	a := &API{token: opts.Token, http: opts.HTTPClient}
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		a.base = "http://l2radar"
+		a.http = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}}
+		return a, nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid API address %q (http[s]://host[:port] or unix:<path>)", addr)
+	}
+	a.base = strings.TrimSuffix(addr, "/")
+	if a.http == nil {
+		a.http = http.DefaultClient
+	}
+	return a, nil
+}
+
+// APIError is a non-2xx response of the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api: %s (%d)", e.Message, e.StatusCode)
+}
+
+// InterfaceSummary is an attached interface, as listed by Interfaces.
+type InterfaceSummary struct {
+	Name       string
+	MAC        net.HardwareAddr // nil if unknown
+	IPv4       []net.IP
+	IPv6       []net.IP
+	Stats      *Stats // nil if unavailable
+	Neighbours int
+}
+
+// Interfaces returns the interfaces attached to the probe.
+func (a *API) Interfaces(ctx context.Context) ([]InterfaceSummary, error) {
+	var raw []struct {
+		Interface  string   `json:"interface"`
+		MAC        string   `json:"mac"`
+		IPv4       []string `json:"ipv4"`
+		IPv6       []string `json:"ipv6"`
+		Stats      *Stats   `json:"stats"`
+		Neighbours int      `json:"neighbours"`
+	}
+	if err := a.get(ctx, "/interfaces", nil, &raw); err != nil {
+		return nil, err
+	}
+	out := make([]InterfaceSummary, 0, len(raw))
+	for _, r := range raw {
+		s := InterfaceSummary{Name: r.Interface, Stats: r.Stats, Neighbours: r.Neighbours}
+		var err error
+		if r.MAC != "" {
+			if s.MAC, err = parseMAC(r.MAC); err != nil {
+				return nil, err
+			}
+		}
+		if s.IPv4, err = parseIPs("ipv4", r.IPv4); err != nil {
+			return nil, err
+		}
+		if s.IPv6, err = parseIPs("ipv6", r.IPv6); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Query selects, sorts and pages a neighbour listing; zero values use the
+// API defaults (all entries, most recently seen first, 100 per page).
+type Query struct {
+	MAC    string // MAC prefix, e.g. "02:42"
+	IP     string // address or CIDR
+	Vendor string // case-insensitive substring
+	Sort   string // mac, ip, vendor, first_seen or last_seen
+	Order  string // asc or desc
+	Limit  int
+	Offset int
+}
+
+func (q Query) values() url.Values {
+	v := url.Values{}
+	for k, s := range map[string]string{"mac": q.MAC, "ip": q.IP, "vendor": q.Vendor, "sort": q.Sort, "order": q.Order} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+	return v
+}
+
+// NeighbourPage is a page of an interface's neighbour listing.
+type NeighbourPage struct {
+	Interface string
+	// Total is the number of matches before paging.
+	Total      int
+	Offset     int
+	Limit      int
+	Neighbours []Neighbour
+}
+
+// Neighbours returns a page of the neighbours of iface.
+func (a *API) Neighbours(ctx context.Context, iface string, q Query) (*NeighbourPage, error) {
+	var raw struct {
+		Interface  string          `json:"interface"`
+		Total      int             `json:"total"`
+		Offset     int             `json:"offset"`
+		Limit      int             `json:"limit"`
+		Neighbours []neighbourJSON `json:"neighbours"`
+	}
+	if err := a.get(ctx, "/interfaces/"+url.PathEscape(iface)+"/neighbours", q.values(), &raw); err != nil {
+		return nil, err
+	}
+	neighbours, err := decodeNeighbours(raw.Neighbours)
+	if err != nil {
+		return nil, err
+	}
+	return &NeighbourPage{
+		Interface:  raw.Interface,
+		Total:      raw.Total,
+		Offset:     raw.Offset,
+		Limit:      raw.Limit,
+		Neighbours: neighbours,
+	}, nil
+}
+
+// Neighbour returns the entries of mac on every interface, none if it is
+// not known.
+func (a *API) Neighbour(ctx context.Context, mac net.HardwareAddr) ([]Neighbour, error) {
+	return a.find(ctx, "/neighbours/"+mac.String(), nil)
+}
+
+// Lookup returns the entries holding ip on every interface, none if it is
+// not known.
+func (a *API) Lookup(ctx context.Context, ip net.IP) ([]Neighbour, error) {
+	return a.find(ctx, "/lookup", url.Values{"ip": {ip.String()}})
+}
+
+// find runs a lookup, which the API answers with 404 if nothing matches.
+func (a *API) find(ctx context.Context, path string, v url.Values) ([]Neighbour, error) {
+	var raw []neighbourJSON
+	err := a.get(ctx, path, v, &raw)
+	if apiErr := (*APIError)(nil); errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return []Neighbour{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeNeighbours(raw)
+}
+
+func decodeNeighbours(raw []neighbourJSON) ([]Neighbour, error) {
+	out := make([]Neighbour, 0, len(raw))
+	for i := range raw {
+		n, err := raw[i].decode()
+		if err != nil {
+			return nil, fmt.Errorf("neighbours[%d]: %w", i, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// get decodes the JSON response of an API endpoint into out.
+func (a *API) get(ctx context.Context, path string, v url.Values, out any) error {
+	resp, err := a.do(ctx, path, v)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("api: decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends a GET request, returning an *APIError for non-2xx responses.
+func (a *API) do(ctx context.Context, path string, v url.Values) (*http.Response, error) {
+	u := a.base + apiPrefix + path
+	if len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil && body.Error != "" {
+			apiErr.Message = body.Error
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// Event types of the events stream.
+const (
+	EventSnapshot = "snapshot"
+	EventDelta    = "delta"
+	EventDetached = "detached"
+)
+
+// Event is an event of the probe's events stream. Exactly one of
+// Snapshot, Delta and Detached is set, according to Type.
+type Event struct {
+	Type     string
+	ID       uint64
+	Snapshot *Interface
+	Delta    *Delta
+	// Detached is the interface removed from the probe.
+	Detached string
+}
+
+// Delta is the change of an interface's table in one export cycle.
+type Delta struct {
+	Interface string
+	Timestamp time.Time
+	Added     []Neighbour
+	Updated   []Neighbour
+	Removed   []net.HardwareAddr
+}
+
+// EventStream reads the events stream; see Events.
+type EventStream struct {
+	body io.ReadCloser
+	sc   *bufio.Scanner
+}
+
+// maxEventSize bounds an event line; snapshots of large tables are
+// several megabytes.
+const maxEventSize = 64 << 20
+
+// Events opens the events stream, restricted to ifaces if any. The stream
+// starts with a snapshot of every interface. It is not resumed: after an
+// error, open a new stream and resync from its snapshots.
+func (a *API) Events(ctx context.Context, ifaces ...string) (*EventStream, error) {
+	resp, err := a.do(ctx, "/events", url.Values{"iface": ifaces})
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, maxEventSize)
+	return &EventStream{body: resp.Body, sc: sc}, nil
+}
+
+// Next returns the next event, blocking until it arrives. It returns
+// io.EOF when the probe closes the stream.
+func (s *EventStream) Next() (Event, error) {
+	var e Event
+	var data []byte
+	for s.sc.Scan() {
+		line := s.sc.Bytes()
+		if len(line) == 0 {
+			if e.Type == "" {
+				// Retry field, comment or keep-alive.
+				continue
+			}
+			return e, e.decode(data)
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			e.Type = string(value)
+		case "id":
+			e.ID, _ = strconv.ParseUint(string(value), 10, 64)
+		case "data":
+			data = append(data, value...)
+		}
+	}
+	if err := s.sc.Err(); err != nil {
+		return e, err
+	}
+	return e, io.EOF
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+func (e *Event) decode(data []byte) error {
+	switch e.Type {
+	case EventSnapshot:
+		var v interfaceJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("snapshot event: %w", err)
+		}
+		ifc, err := v.decode()
+		if err != nil {
+			return fmt.Errorf("snapshot event: %w", err)
+		}
+		e.Snapshot = ifc
+	case EventDelta:
+		var v struct {
+			Interface string          `json:"interface"`
+			Timestamp string          `json:"timestamp"`
+			Added     []neighbourJSON `json:"added"`
+			Updated   []neighbourJSON `json:"updated"`
+			Removed   []string        `json:"removed"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("delta event: %w", err)
+		}
+		d := &Delta{Interface: v.Interface, Removed: make([]net.HardwareAddr, 0, len(v.Removed))}
+		var err error
+		if d.Timestamp, err = parseTime("timestamp", v.Timestamp); err != nil {
+			return fmt.Errorf("delta event: %w", err)
+		}
+		if d.Added, err = decodeNeighbours(v.Added); err != nil {
+			return fmt.Errorf("delta event: added %w", err)
+		}
+		if d.Updated, err = decodeNeighbours(v.Updated); err != nil {
+			return fmt.Errorf("delta event: updated %w", err)
+		}
+		for _, s := range v.Removed {
+			mac, err := parseMAC(s)
+			if err != nil {
+				return fmt.Errorf("delta event: removed: %w", err)
+			}
+			d.Removed = append(d.Removed, mac)
+		}
+		e.Delta = d
+	case EventDetached:
+		var v struct {
+			Interface string `json:"interface"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("detached event: %w", err)
+		}
+		e.Detached = v.Interface
+	}
+	// Unknown event types from newer probes are returned undecoded.
+	return nil
+}
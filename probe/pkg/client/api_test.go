@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/api"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/events"
+)
+
+// fakeSource serves fixed neighbour tables on interfaces that do not
+// exist on the host.
+type fakeSource map[string][]dump.Neighbour
+
+func (f fakeSource) Interfaces() []string {
+	return []string{"l2rtest0"}
+}
+
+func (f fakeSource) Neighbours(iface string) ([]dump.Neighbour, error) {
+	n, ok := f[iface]
+	if !ok {
+		return nil, fmt.Errorf("not attached")
+	}
+	return append([]dump.Neighbour(nil), n...), nil
+}
+
+func testAPI(token string) (*api.Server, *events.Hub) {
+	src := fakeSource{"l2rtest0": {
+		testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Minute), "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(2*time.Minute), "10.0.0.2"),
+		testutil.Neighbour("02:00:00:00:00:03", t0, t0.Add(3*time.Minute), "10.0.0.3"),
+	}}
+	srv := api.New(src, api.Opts{Token: token})
+	hub := events.NewHub(nil)
+	srv.Handle("GET "+api.Prefix+"/events", hub)
+	return srv, hub
+}
+
+func TestAPI(t *testing.T) {
+	srv, _ := testAPI("secret")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	ctx := context.Background()
+
+	c, err := NewAPI(ts.URL, APIOpts{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifaces, err := c.Interfaces(ctx)
+	if err != nil || len(ifaces) != 1 || ifaces[0].Name != "l2rtest0" || ifaces[0].Neighbours != 3 {
+		t.Fatalf("unexpected interfaces %+v %v", ifaces, err)
+	}
+
+	page, err := c.Neighbours(ctx, "l2rtest0", Query{Sort: "mac", Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Offset != 1 || page.Limit != 2 || len(page.Neighbours) != 2 ||
+		page.Neighbours[0].MAC.String() != "02:00:00:00:00:02" || !page.Neighbours[0].LastSeen.Equal(t0.Add(2*time.Minute)) {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	found, err := c.Lookup(ctx, net.ParseIP("10.0.0.3"))
+	if err != nil || len(found) != 1 || found[0].Interface != "l2rtest0" || found[0].MAC[5] != 3 {
+		t.Errorf("unexpected lookup %+v %v", found, err)
+	}
+	found, err = c.Neighbour(ctx, net.HardwareAddr{0x02, 0, 0, 0, 0, 9})
+	if err != nil || found == nil || len(found) != 0 {
+		t.Errorf("expected no entries for an unknown MAC, got %+v %v", found, err)
+	}
+
+	var apiErr *APIError
+	if _, err := c.Neighbours(ctx, "eth9", Query{}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "interface eth9 is not attached" {
+		t.Errorf("expected a 404 APIError, got %v", err)
+	}
+	if _, err := c.Neighbours(ctx, "l2rtest0", Query{Sort: "age"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400 APIError, got %v", err)
+	}
+
+	noToken, _ := NewAPI(ts.URL, APIOpts{})
+	if _, err := noToken.Interfaces(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401 APIError, got %v", err)
+	}
+}
+
+func TestAPIUnixSocket(t *testing.T) {
+	srv, _ := testAPI("secret")
+	path := filepath.Join(t.TempDir(), "api.sock")
+	l, err := api.Listen(api.UnixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go api.Serve(ctx, l, srv, nil)
+
+	c, err := NewAPI("unix:"+path, APIOpts{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifaces, err := c.Interfaces(ctx); err != nil || len(ifaces) != 1 {
+		t.Errorf("unexpected interfaces %+v %v", ifaces, err)
+	}
+
+	// The token is required over the socket too.
+	noToken, err := NewAPI("unix:"+path, APIOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *APIError
+	if _, err := noToken.Interfaces(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401 APIError, got %v", err)
+	}
+}
+
+func TestNewAPIErrors(t *testing.T) {
+	for _, addr := range []string{"probe:8080", "ftp://probe", "http://"} {
+		if _, err := NewAPI(addr, APIOpts{}); err == nil {
+			t.Errorf("%s: expected error", addr)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	srv, hub := testAPI("")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	snapshot := func(neighbours ...dump.Neighbour) daemon.Snapshot {
+		return daemon.Snapshot{Interface: "l2rtest0", Time: t0, ExportInterval: time.Second, Neighbours: neighbours}
+	}
+	hub.Observe(snapshot(
+		testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Minute), "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(2*time.Minute), "10.0.0.2")))
+
+	c, _ := NewAPI(ts.URL, APIOpts{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.Events(ctx, "l2rtest0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	e, err := stream.Next()
+	if err != nil || e.Type != EventSnapshot || e.Snapshot == nil || e.Snapshot.Name != "l2rtest0" || len(e.Snapshot.Neighbours) != 2 {
+		t.Fatalf("expected a snapshot, got %+v %v", e, err)
+	}
+
+	hub.Observe(snapshot(
+		testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(2*time.Minute), "10.0.0.2"),
+		testutil.Neighbour("02:00:00:00:00:03", t0, t0.Add(3*time.Minute), "10.0.0.3")))
+	e, err = stream.Next()
+	if err != nil || e.Type != EventDelta || e.ID <= 1 {
+		t.Fatalf("expected a delta, got %+v %v", e, err)
+	}
+	if d := e.Delta; d.Interface != "l2rtest0" || len(d.Added) != 1 || d.Added[0].MAC[5] != 3 ||
+		len(d.Updated) != 0 || len(d.Removed) != 1 || d.Removed[0][5] != 1 {
+		t.Errorf("unexpected delta: %+v", d)
+	}
+
+	hub.Detached("l2rtest0")
+	if e, err = stream.Next(); err != nil || e.Type != EventDetached || e.Detached != "l2rtest0" {
+		t.Errorf("expected detached, got %+v %v", e, err)
+	}
+
+	ts.CloseClientConnections()
+	if _, err := stream.Next(); err == nil {
+		t.Error("expected an error after the connection closed")
+	}
+}
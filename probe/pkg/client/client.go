@@ -0,0 +1,221 @@
+// Package client reads l2radar export directories and talks to a running
+// probe's HTTP API, decoding both into typed structures (net.IP,
+// net.HardwareAddr, time.Time) so consumers do not parse the JSON
+// themselves.
+//
+// The package depends on the standard library only: importing it does not
+// pull in the eBPF loader.
+//
+// # Compatibility
+//
+// The exported types follow the module's semantic version: within a major
+// version fields are only added, never removed or changed. Documents carry
+// a schema_version (see spec/probe.md); Decode and the readers accept
+// versions up to SchemaVersion, and files written before versioning, and
+// return ErrUnsupportedVersion for newer ones.
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// SchemaVersion is the newest export schema version this package reads.
+const SchemaVersion = 1
+
+// ErrUnsupportedVersion is returned for documents of a newer schema
+// version than SchemaVersion.
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// Interface is the export of one interface (neigh-<iface>.json), also sent
+// as the snapshot event of the API.
+type Interface struct {
+	// SchemaVersion is 0 for files written before versioning.
+	SchemaVersion  int
+	Name           string
+	Timestamp      time.Time
+	ExportInterval time.Duration
+	// MAC, IPv4 and IPv6 are the interface's own addresses; MAC is nil if
+	// unknown.
+	MAC        net.HardwareAddr
+	IPv4       []net.IP
+	IPv6       []net.IP
+	Stats      *Stats // nil if unavailable
+	Neighbours []Neighbour
+}
+
+// Stats holds the kernel TX/RX counters of an interface.
+type Stats struct {
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+	RxDropped uint64 `json:"rx_dropped"`
+}
+
+// Neighbour is a neighbour entry.
+type Neighbour struct {
+	MAC       net.HardwareAddr
+	IPv4      []net.IP
+	IPv6      []net.IP
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Interface is the interface the entry was seen on; set by API
+	// lookups across interfaces.
+	Interface string
+	// Vendor is the OUI vendor; set by the API only.
+	Vendor string
+}
+
+// interfaceJSON is the wire form of Interface.
+type interfaceJSON struct {
+	SchemaVersion  int             `json:"schema_version"`
+	Interface      string          `json:"interface"`
+	Timestamp      string          `json:"timestamp"`
+	ExportInterval string          `json:"export_interval"`
+	MAC            string          `json:"mac"`
+	IPv4           []string        `json:"ipv4"`
+	IPv6           []string        `json:"ipv6"`
+	Stats          *Stats          `json:"stats"`
+	Neighbours     []neighbourJSON `json:"neighbours"`
+}
+
+// neighbourJSON is the wire form of Neighbour, in export files and API
+// responses.
+type neighbourJSON struct {
+	Interface string   `json:"interface"`
+	MAC       string   `json:"mac"`
+	IPv4      []string `json:"ipv4"`
+	IPv6      []string `json:"ipv6"`
+	FirstSeen string   `json:"first_seen"`
+	LastSeen  string   `json:"last_seen"`
+	Vendor    string   `json:"vendor"`
+}
+
+// Decode reads an interface export document.
+func Decode(r io.Reader) (*Interface, error) {
+	var v interfaceJSON
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v.decode()
+}
+
+// ReadFile reads an interface export file.
+func ReadFile(path string) (*Interface, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ifc, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ifc, nil
+}
+
+func (v *interfaceJSON) decode() (*Interface, error) {
+	if err := checkVersion(v.SchemaVersion); err != nil {
+		return nil, err
+	}
+	ifc := &Interface{
+		SchemaVersion: v.SchemaVersion,
+		Name:          v.Interface,
+		Stats:         v.Stats,
+		Neighbours:    make([]Neighbour, 0, len(v.Neighbours)),
+	}
+	var err error
+	if ifc.Timestamp, err = parseTime("timestamp", v.Timestamp); err != nil {
+		return nil, err
+	}
+	if ifc.ExportInterval, err = time.ParseDuration(v.ExportInterval); err != nil {
+		return nil, fmt.Errorf("export_interval: %w", err)
+	}
+	if v.MAC != "" {
+		if ifc.MAC, err = parseMAC(v.MAC); err != nil {
+			return nil, err
+		}
+	}
+	if ifc.IPv4, err = parseIPs("ipv4", v.IPv4); err != nil {
+		return nil, err
+	}
+	if ifc.IPv6, err = parseIPs("ipv6", v.IPv6); err != nil {
+		return nil, err
+	}
+	for i := range v.Neighbours {
+		n, err := v.Neighbours[i].decode()
+		if err != nil {
+			return nil, fmt.Errorf("neighbours[%d]: %w", i, err)
+		}
+		ifc.Neighbours = append(ifc.Neighbours, n)
+	}
+	return ifc, nil
+}
+
+func (v *neighbourJSON) decode() (Neighbour, error) {
+	n := Neighbour{Interface: v.Interface, Vendor: v.Vendor}
+	var err error
+	if n.MAC, err = parseMAC(v.MAC); err != nil {
+		return n, err
+	}
+	if n.IPv4, err = parseIPs("ipv4", v.IPv4); err != nil {
+		return n, err
+	}
+	if n.IPv6, err = parseIPs("ipv6", v.IPv6); err != nil {
+		return n, err
+	}
+	if n.FirstSeen, err = parseTime("first_seen", v.FirstSeen); err != nil {
+		return n, err
+	}
+	if n.LastSeen, err = parseTime("last_seen", v.LastSeen); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func checkVersion(v int) error {
+	if v > SchemaVersion {
+		return fmt.Errorf("%w %d (this package reads up to %d)", ErrUnsupportedVersion, v, SchemaVersion)
+	}
+	return nil
+}
+
+func parseMAC(s string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("invalid mac %q", s)
+	}
+	return mac, nil
+}
+
+func parseIPs(field string, list []string) ([]net.IP, error) {
+	ips := make([]net.IP, 0, len(list))
+	for _, s := range list {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("%s: invalid address %q", field, s)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+func parseTime(field, s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("%s: invalid time %q", field, s)
+	}
+	return t, nil
+}
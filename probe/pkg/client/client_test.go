@@ -0,0 +1,112 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+func TestReadGoldenFiles(t *testing.T) {
+	for _, file := range []string{"neigh-eth0.json", "neigh-wlan0.json"} {
+		ifc, err := ReadFile(filepath.Join("..", "..", "..", "testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifc.SchemaVersion != SchemaVersion || "neigh-"+ifc.Name+".json" != file || ifc.Timestamp.IsZero() || ifc.ExportInterval <= 0 {
+			t.Errorf("%s: unexpected header %+v", file, ifc)
+		}
+		if len(ifc.Neighbours) == 0 || len(ifc.Neighbours[0].MAC) != 6 {
+			t.Errorf("%s: unexpected neighbours %+v", file, ifc.Neighbours)
+		}
+	}
+}
+
+// TestDecodeExport checks that the client reads what the probe writes.
+func TestDecodeExport(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	n := dump.Neighbour{
+		MAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		IPv4:      []net.IP{net.ParseIP("10.0.0.1").To4()},
+		IPv6:      []net.IP{net.ParseIP("fe80::1")},
+		FirstSeen: now.Add(-time.Hour),
+		LastSeen:  now,
+	}
+	info := &export.InterfaceInfo{MAC: net.HardwareAddr{0xaa, 0, 0, 0, 0, 1}, IPv4: []net.IP{net.ParseIP("10.0.0.254").To4()}}
+	if _, err := export.WriteJSON("eth0", []dump.Neighbour{n}, dir, now, 5*time.Second, info, &export.InterfaceStats{RxPackets: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	ifc, err := ReadFile(filepath.Join(dir, export.OutputFileName("eth0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifc.Name != "eth0" || !ifc.Timestamp.Equal(now) || ifc.ExportInterval != 5*time.Second {
+		t.Errorf("unexpected header: %+v", ifc)
+	}
+	if ifc.MAC.String() != "aa:00:00:00:00:01" || len(ifc.IPv4) != 1 || !ifc.IPv4[0].Equal(info.IPv4[0]) || len(ifc.IPv6) != 0 {
+		t.Errorf("unexpected addresses: %v %v %v", ifc.MAC, ifc.IPv4, ifc.IPv6)
+	}
+	if ifc.Stats == nil || ifc.Stats.RxPackets != 7 {
+		t.Errorf("unexpected stats: %+v", ifc.Stats)
+	}
+	if len(ifc.Neighbours) != 1 {
+		t.Fatalf("expected 1 neighbour, got %d", len(ifc.Neighbours))
+	}
+	got := ifc.Neighbours[0]
+	if got.MAC.String() != n.MAC.String() || !got.IPv4[0].Equal(n.IPv4[0]) || !got.IPv6[0].Equal(n.IPv6[0]) ||
+		!got.FirstSeen.Equal(n.FirstSeen) || !got.LastSeen.Equal(n.LastSeen) {
+		t.Errorf("expected %+v, got %+v", n, got)
+	}
+	if len(got.IPv4[0]) != net.IPv4len {
+		t.Errorf("IPv4 addresses should be 4 bytes: %v", []byte(got.IPv4[0]))
+	}
+}
+
+func TestDecodeVersions(t *testing.T) {
+	doc := `{%s"interface": "eth0", "timestamp": "2026-10-18T12:00:00Z", "export_interval": "5s",
+		"mac": "", "ipv4": [], "ipv6": [], "stats": null, "neighbours": []}`
+
+	// Files written before versioning have the same fields.
+	ifc, err := Decode(strings.NewReader(strings.Replace(doc, "%s", "", 1)))
+	if err != nil || ifc.SchemaVersion != 0 || ifc.MAC != nil || ifc.Stats != nil {
+		t.Errorf("unversioned file: %+v %v", ifc, err)
+	}
+	_, err = Decode(strings.NewReader(strings.Replace(doc, "%s", `"schema_version": 2, `, 1)))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	neighbour := `{"mac": "02:00:00:00:00:01", "ipv4": ["10.0.0.1"], "ipv6": [], "first_seen": "2026-10-18T12:00:00Z", "last_seen": "2026-10-18T12:00:00Z"}`
+	doc := `{"schema_version": 1, "interface": "eth0", "timestamp": "2026-10-18T12:00:00Z", "export_interval": "5s",
+		"mac": "", "ipv4": [], "ipv6": [], "stats": null, "neighbours": [` + neighbour + `]}`
+
+	if _, err := Decode(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct{ old, new, want string }{
+		"mac":      {"02:00:00:00:00:01", "02:00", `neighbours[0]: invalid mac "02:00"`},
+		"ip":       {`"10.0.0.1"`, `"10.0.0"`, `neighbours[0]: ipv4: invalid address "10.0.0"`},
+		"time":     {`"timestamp": "2026-10-18T12:00:00Z"`, `"timestamp": "now"`, `timestamp: invalid time "now"`},
+		"interval": {`"5s"`, `"5"`, "export_interval: "},
+		"json":     {`"neighbours": [`, `"neighbours": `, "invalid character"},
+	} {
+		_, err := Decode(strings.NewReader(strings.Replace(doc, tc.old, tc.new, 1)))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", name, tc.want, err)
+		}
+	}
+
+	if _, err := ReadFile(filepath.Join(t.TempDir(), "neigh-eth9.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
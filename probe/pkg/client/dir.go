@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File names of an export directory.
+const (
+	interfacePrefix  = "neigh-"
+	interfaceSuffix  = ".json"
+	manifestFileName = "manifest.json"
+)
+
+// Dir is an export directory written by the probe (--export-dir).
+type Dir struct {
+	path string
+}
+
+// OpenDir returns the export directory at path.
+func OpenDir(path string) (*Dir, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return &Dir{path: path}, nil
+}
+
+// Path returns the directory's path.
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// Interfaces returns the names of the exported interfaces, sorted.
+func (d *Dir) Interfaces() ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := interfaceName(e.Name()); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Interface reads the export of one interface. The error wraps
+// os.ErrNotExist if the interface is not exported.
+func (d *Dir) Interface(name string) (*Interface, error) {
+	return ReadFile(filepath.Join(d.path, interfacePrefix+name+interfaceSuffix))
+}
+
+// ReadAll reads the exports of all interfaces, sorted by name.
+func (d *Dir) ReadAll() ([]*Interface, error) {
+	names, err := d.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Interface, 0, len(names))
+	for _, name := range names {
+		ifc, err := d.Interface(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ifc)
+	}
+	return out, nil
+}
+
+// interfaceName returns the interface of an export file name.
+func interfaceName(file string) (string, bool) {
+	name, ok := strings.CutPrefix(file, interfacePrefix)
+	if !ok {
+		return "", false
+	}
+	name, ok = strings.CutSuffix(name, interfaceSuffix)
+	return name, ok && name != ""
+}
+
+// Manifest is the index of an export directory (manifest.json).
+type Manifest struct {
+	SchemaVersion  int
+	ProbeVersion   string
+	Hostname       string
+	BootID         string
+	Timestamp      time.Time
+	ExportInterval time.Duration
+	Interfaces     []ManifestInterface
+	// Combined is the combined export, nil if disabled.
+	Combined *File
+}
+
+// ManifestInterface lists the files of one interface.
+type ManifestInterface struct {
+	Name       string
+	Timestamp  time.Time
+	Neighbours int
+	Files      []File
+}
+
+// File is an export file listed in the manifest.
+type File struct {
+	Format string `json:"format"`
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+type manifestJSON struct {
+	SchemaVersion  int    `json:"schema_version"`
+	ProbeVersion   string `json:"probe_version"`
+	Hostname       string `json:"hostname"`
+	BootID         string `json:"boot_id"`
+	Timestamp      string `json:"timestamp"`
+	ExportInterval string `json:"export_interval"`
+	Interfaces     []struct {
+		Name       string `json:"name"`
+		Timestamp  string `json:"timestamp"`
+		Neighbours int    `json:"neighbours"`
+		Files      []File `json:"files"`
+	} `json:"interfaces"`
+	Combined *File `json:"combined"`
+}
+
+// Manifest reads the directory's manifest. The error wraps os.ErrNotExist
+// if the probe has not written one yet.
+func (d *Dir) Manifest() (*Manifest, error) {
+	path := filepath.Join(d.path, manifestFileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var v manifestJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m, err := v.decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (v *manifestJSON) decode() (*Manifest, error) {
+	if err := checkVersion(v.SchemaVersion); err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		SchemaVersion: v.SchemaVersion,
+		ProbeVersion:  v.ProbeVersion,
+		Hostname:      v.Hostname,
+		BootID:        v.BootID,
+		Interfaces:    make([]ManifestInterface, 0, len(v.Interfaces)),
+		Combined:      v.Combined,
+	}
+	var err error
+	if m.Timestamp, err = parseTime("timestamp", v.Timestamp); err != nil {
+		return nil, err
+	}
+	if m.ExportInterval, err = time.ParseDuration(v.ExportInterval); err != nil {
+		return nil, fmt.Errorf("export_interval: %w", err)
+	}
+	for _, i := range v.Interfaces {
+		ts, err := parseTime("timestamp", i.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", i.Name, err)
+		}
+		m.Interfaces = append(m.Interfaces, ManifestInterface{
+			Name:       i.Name,
+			Timestamp:  ts,
+			Neighbours: i.Neighbours,
+			Files:      i.Files,
+		})
+	}
+	return m, nil
+}
+
+// Change is a change of an interface export seen by Watch.
+type Change struct {
+	Interface string
+	// Data is the new export, nil if the file was removed (interface
+	// detached) or could not be read.
+	Data *Interface
+	// Err is the error reading the file or, with an empty Interface,
+	// listing the directory.
+	Err error
+}
+
+// Removed reports whether the interface's export was removed.
+func (c Change) Removed() bool {
+	return c.Data == nil && c.Err == nil
+}
+
+// Watch sends a Change for every interface export on the first poll, then
+// for every file written or removed, checking every interval. The channel
+// is closed when ctx is cancelled.
+//
+// Watch polls file metadata rather than using inotify: it works on bind
+// mounts and network filesystems, and the probe replaces files with a
+// rename, so a changed file is always complete.
+func (d *Dir) Watch(ctx context.Context, interval time.Duration) <-chan Change {
+	ch := make(chan Change)
+	go func() {
+		defer close(ch)
+		seen := make(map[string]fileState)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for _, c := range d.poll(seen) {
+				select {
+				case ch <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch
+}
+
+// fileState identifies a version of an export file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// poll returns the changes since seen, which it updates.
+func (d *Dir) poll(seen map[string]fileState) []Change {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return []Change{{Err: err}}
+	}
+	var changes []Change
+	present := make(map[string]bool)
+	for _, e := range entries {
+		name, ok := interfaceName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			// Removed since listing.
+			continue
+		}
+		present[name] = true
+		st := fileState{fi.ModTime(), fi.Size()}
+		if prev, ok := seen[name]; ok && prev == st {
+			continue
+		}
+		seen[name] = st
+		ifc, err := d.Interface(name)
+		changes = append(changes, Change{Interface: name, Data: ifc, Err: err})
+	}
+	var removed []string
+	for name := range seen {
+		if !present[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		delete(seen, name)
+		changes = append(changes, Change{Interface: name})
+	}
+	return changes
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+var t0 = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func writeExport(t *testing.T, dir, iface string, macs ...byte) export.File {
+	t.Helper()
+	var neighbours []dump.Neighbour
+	for _, b := range macs {
+		neighbours = append(neighbours, dump.Neighbour{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, b}, FirstSeen: t0, LastSeen: t0})
+	}
+	f, err := export.WriteJSON(iface, neighbours, dir, t0, 5*time.Second, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	f0 := writeExport(t, dir, "eth0", 1, 2)
+	writeExport(t, dir, "wlan0", 3)
+	combined, err := export.WriteCombined(export.NewCombined(t0, nil), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Manifest(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist before the first manifest, got %v", err)
+	}
+	names, err := d.Interfaces()
+	if err != nil || len(names) != 2 || names[0] != "eth0" || names[1] != "wlan0" {
+		t.Fatalf("unexpected interfaces %v %v", names, err)
+	}
+	all, err := d.ReadAll()
+	if err != nil || len(all) != 2 || len(all[0].Neighbours) != 2 || all[1].Name != "wlan0" {
+		t.Fatalf("unexpected exports %+v %v", all, err)
+	}
+	if _, err := d.Interface("eth9"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+
+	m := export.NewManifest(t0, 5*time.Second, []export.ManifestInterface{
+		{Name: "eth0", Timestamp: "2026-10-18T12:00:00Z", Neighbours: 2, Files: []export.File{f0}},
+	}, &combined)
+	if _, err := export.WriteManifest(m, dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaVersion != SchemaVersion || !got.Timestamp.Equal(t0) || got.ExportInterval != 5*time.Second || got.Hostname != m.Hostname {
+		t.Errorf("unexpected manifest: %+v", got)
+	}
+	if len(got.Interfaces) != 1 || got.Interfaces[0].Neighbours != 2 || got.Interfaces[0].Files[0] != File(f0) {
+		t.Errorf("unexpected interfaces: %+v", got.Interfaces)
+	}
+	if got.Combined == nil || got.Combined.Name != export.CombinedFileName {
+		t.Errorf("unexpected combined: %+v", got.Combined)
+	}
+
+	if _, err := OpenDir(filepath.Join(dir, export.ManifestFileName)); err == nil {
+		t.Error("expected error for a file")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeExport(t, dir, "eth0", 1)
+	d, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := d.Watch(ctx, 10*time.Millisecond)
+
+	next := func() Change {
+		t.Helper()
+		select {
+		case c := <-ch:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a change")
+		}
+		return Change{}
+	}
+
+	if c := next(); c.Interface != "eth0" || c.Err != nil || len(c.Data.Neighbours) != 1 {
+		t.Fatalf("expected the initial export, got %+v", c)
+	}
+	writeExport(t, dir, "eth0", 1, 2)
+	if c := next(); c.Interface != "eth0" || c.Err != nil || len(c.Data.Neighbours) != 2 {
+		t.Fatalf("expected the rewritten export, got %+v", c)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "neigh-eth1.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); c.Interface != "eth1" || c.Err == nil || c.Removed() {
+		t.Fatalf("expected a read error, got %+v", c)
+	}
+	if err := os.Remove(filepath.Join(dir, export.OutputFileName("eth0"))); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); c.Interface != "eth0" || !c.Removed() {
+		t.Fatalf("expected eth0 removed, got %+v", c)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,249 @@
+// Package config loads the probe configuration file: interfaces with
+// per-interface options, export settings, sinks and filters.
+//
+// The file is YAML. Flags given on the command line override the values
+// from the file; the running probe re-reads it on SIGHUP.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// DefaultExportInterval is used when export.interval is not set.
+const DefaultExportInterval = 5 * time.Second
+
+// Journal defaults.
+const (
+	DefaultJournalMaxSizeMB = 10
+	DefaultJournalMaxAge    = 24 * time.Hour
+	DefaultJournalKeep      = 30
+)
+
+// Config is the probe configuration.
+type Config struct {
+	// PinPath is the base path for pinned maps. Changing it requires a
+	// restart.
+	PinPath    string      `yaml:"pin_path,omitempty"`
+	Interfaces []Interface `yaml:"interfaces"`
+	Export     Export      `yaml:"export,omitempty"`
+	// Filters apply to every interface, in addition to the interface's own.
+	Filters Filters `yaml:"filters,omitempty"`
+	Sinks   []Sink  `yaml:"sinks,omitempty"`
+}
+
+// Interface is a monitored interface. Name may be "external" or "any";
+// the options then apply to every interface the keyword resolves to.
+type Interface struct {
+	Name string `yaml:"name"`
+	// Export disables writing the export file for this interface if false.
+	Export  *bool   `yaml:"export,omitempty"`
+	Filters Filters `yaml:"filters,omitempty"`
+}
+
+// Export holds the JSON export settings.
+type Export struct {
+	// Dir is the export directory; export is disabled if empty. Enabling,
+	// disabling or moving it requires a restart.
+	Dir      string        `yaml:"dir,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Formats are the files written per interface (see export.Formats);
+	// json if empty. Changing them requires a restart.
+	Formats []string `yaml:"formats,omitempty"`
+	// Columns selects the fields of the csv, ndjson and yaml files (see
+	// dump.ColumnNames); all if empty.
+	Columns []string `yaml:"columns,omitempty"`
+	// Combined also writes neighbours.json, merging all interfaces.
+	Combined bool    `yaml:"combined,omitempty"`
+	Journal  Journal `yaml:"journal,omitempty"`
+}
+
+// Journal holds the settings of the neighbour event journal, written to
+// the export directory. Changing them requires a restart.
+type Journal struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MaxSizeMB rotates the journal once it reaches this size in MiB.
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	// MaxAge rotates the journal once its first event is this old.
+	MaxAge time.Duration `yaml:"max_age,omitempty"`
+	// Keep is the number of rotated files kept; older ones are deleted.
+	Keep int `yaml:"keep,omitempty"`
+	// Retention deletes rotated files older than this (no limit if 0).
+	Retention time.Duration `yaml:"retention,omitempty"`
+}
+
+// Filters drop neighbours or addresses from the export files and the
+// control/API views. The pinned maps (and "l2radar dump") are unaffected.
+type Filters struct {
+	// IgnoreMACs lists MAC addresses or OUI prefixes (e.g. "02:42:ac") to
+	// leave out.
+	IgnoreMACs []string `yaml:"ignore_macs,omitempty"`
+	// IgnoreSubnets lists CIDRs whose addresses are removed from entries.
+	IgnoreSubnets []string `yaml:"ignore_subnets,omitempty"`
+}
+
+// Sink is an additional output fed from the export loop. Type selects the
+// implementation; Options holds the remaining, type-specific keys.
+type Sink struct {
+	Type string `yaml:"type"`
+	// Interfaces restricts the sink to these interfaces (all if empty).
+	Interfaces []string       `yaml:"interfaces,omitempty"`
+	Options    map[string]any `yaml:",inline"`
+}
+
+// Decode decodes the type-specific keys of the sink into v, rejecting
+// unknown keys.
+func (s Sink) Decode(v any) error {
+	b, err := yaml.Marshal(s.Options)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML accepts either a mapping or a bare interface name.
+func (i *Interface) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		i.Name = n.Value
+		return nil
+	}
+	type plain Interface
+	return n.Decode((*plain)(i))
+}
+
+// ExportEnabled reports whether the interface's export file is written.
+func (i Interface) ExportEnabled() bool {
+	return i.Export == nil || *i.Export
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", "":
+	default:
+		return nil, fmt.Errorf("config %s: unsupported format (use .yaml or .yml)", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse decodes and validates a YAML configuration. Unknown keys are
+// rejected so typos do not go unnoticed.
+func Parse(b []byte) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	c.SetDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetDefaults fills unset values.
+func (c *Config) SetDefaults() {
+	if c.Export.Interval == 0 {
+		c.Export.Interval = DefaultExportInterval
+	}
+	if j := &c.Export.Journal; j.Enabled {
+		if j.MaxSizeMB == 0 {
+			j.MaxSizeMB = DefaultJournalMaxSizeMB
+		}
+		if j.MaxAge == 0 {
+			j.MaxAge = DefaultJournalMaxAge
+		}
+		if j.Keep == 0 {
+			j.Keep = DefaultJournalKeep
+		}
+	}
+}
+
+// Validate checks the configuration for errors.
+func (c *Config) Validate() error {
+	if len(c.Interfaces) == 0 {
+		return fmt.Errorf("no interfaces configured")
+	}
+	seen := make(map[string]bool)
+	for _, ifc := range c.Interfaces {
+		if ifc.Name == "" {
+			return fmt.Errorf("interface with empty name")
+		}
+		if seen[ifc.Name] {
+			return fmt.Errorf("interface %s listed twice", ifc.Name)
+		}
+		seen[ifc.Name] = true
+		if _, err := ifc.Filters.Compile(); err != nil {
+			return fmt.Errorf("interface %s: %w", ifc.Name, err)
+		}
+	}
+	if c.Export.Interval < 0 {
+		return fmt.Errorf("export.interval must be positive")
+	}
+	for _, f := range c.Export.Formats {
+		if !slices.Contains(export.Formats(), f) {
+			return fmt.Errorf("export.formats: unknown format %q (supported: %s)", f, strings.Join(export.Formats(), ", "))
+		}
+	}
+	if _, err := dump.ParseColumns(c.Export.Columns); err != nil {
+		return fmt.Errorf("export.columns: %w", err)
+	}
+	if j := c.Export.Journal; j.MaxSizeMB < 0 || j.MaxAge < 0 || j.Keep < 0 || j.Retention < 0 {
+		return fmt.Errorf("export.journal settings must be positive")
+	}
+	if _, err := c.Filters.Compile(); err != nil {
+		return err
+	}
+	for i, s := range c.Sinks {
+		if s.Type == "" {
+			return fmt.Errorf("sinks[%d]: missing type", i)
+		}
+	}
+	return nil
+}
+
+// InterfaceNames returns the configured interface names (keywords not
+// resolved).
+func (c *Config) InterfaceNames() []string {
+	names := make([]string, len(c.Interfaces))
+	for i, ifc := range c.Interfaces {
+		names[i] = ifc.Name
+	}
+	return names
+}
+
+// Lookup returns the interface entry for name, or nil.
+func (c *Config) Lookup(name string) *Interface {
+	for i := range c.Interfaces {
+		if c.Interfaces[i].Name == name {
+			return &c.Interfaces[i]
+		}
+	}
+	return nil
+}
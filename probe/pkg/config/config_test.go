@@ -0,0 +1,184 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+const sample = `
+pin_path: /sys/fs/bpf/test
+interfaces:
+  - external
+  - name: eth1
+    export: false
+    filters:
+      ignore_macs: ["02:42"]
+export:
+  dir: /var/lib/l2radar
+  interval: 10s
+  formats: [json, csv]
+  columns: [mac, ipv4]
+  journal:
+    enabled: true
+    keep: 5
+filters:
+  ignore_subnets: ["169.254.0.0/16"]
+`
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.PinPath != "/sys/fs/bpf/test" {
+		t.Errorf("pin_path: %q", c.PinPath)
+	}
+	if got := c.InterfaceNames(); len(got) != 2 || got[0] != "external" || got[1] != "eth1" {
+		t.Errorf("interfaces: %v", got)
+	}
+	if !c.Interfaces[0].ExportEnabled() || c.Lookup("eth1").ExportEnabled() {
+		t.Error("unexpected per-interface export settings")
+	}
+	if c.Export.Dir != "/var/lib/l2radar" || c.Export.Interval != 10*time.Second {
+		t.Errorf("export: %+v", c.Export)
+	}
+	if strings.Join(c.Export.Formats, ",") != "json,csv" || strings.Join(c.Export.Columns, ",") != "mac,ipv4" {
+		t.Errorf("export formats: %+v", c.Export)
+	}
+	if j := c.Export.Journal; !j.Enabled || j.Keep != 5 || j.MaxSizeMB != DefaultJournalMaxSizeMB || j.MaxAge != DefaultJournalMaxAge {
+		t.Errorf("journal: %+v", j)
+	}
+	if c.Lookup("wlan0") != nil {
+		t.Error("Lookup of unknown interface returned an entry")
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	c, err := Parse([]byte("interfaces: [eth0]\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.Export.Interval != DefaultExportInterval {
+		t.Errorf("expected default interval, got %s", c.Export.Interval)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"no interfaces": "export: {dir: /tmp}\n",
+		"unknown key":   "interfaces: [eth0]\nexprot: {}\n",
+		"duplicate":     "interfaces: [eth0, eth0]\n",
+		"empty name":    "interfaces: [{export: false}]\n",
+		"bad interval":  "interfaces: [eth0]\nexport: {interval: soon}\n",
+		"bad mac":       "interfaces: [eth0]\nfilters: {ignore_macs: [\"zz:00\"]}\n",
+		"bad subnet":    "interfaces: [{name: eth0, filters: {ignore_subnets: [10.0.0.0]}}]\n",
+		"sink no type":  "interfaces: [eth0]\nsinks: [{url: x}]\n",
+		"bad format":    "interfaces: [eth0]\nexport: {formats: [json, xml]}\n",
+		"bad column":    "interfaces: [eth0]\nexport: {columns: [mac, hostname]}\n",
+		"bad journal":   "interfaces: [eth0]\nexport: {journal: {enabled: true, keep: -1}}\n",
+	}
+	for name, in := range tests {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSinkDecode(t *testing.T) {
+	c, err := Parse([]byte("interfaces: [eth0]\nsinks:\n  - type: webhook\n    interfaces: [eth0]\n    url: http://x\n    timeout: 2s\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var opts struct {
+		URL     string        `yaml:"url"`
+		Timeout time.Duration `yaml:"timeout"`
+	}
+	s := c.Sinks[0]
+	if err := s.Decode(&opts); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if s.Type != "webhook" || len(s.Interfaces) != 1 || opts.URL != "http://x" || opts.Timeout != 2*time.Second {
+		t.Errorf("unexpected sink %+v, options %+v", s, opts)
+	}
+
+	s.Options["retires"] = 3
+	if err := s.Decode(&opts); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "l2radar.yaml")
+	if err := os.WriteFile(path, []byte(sample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	toml := filepath.Join(dir, "l2radar.toml")
+	if err := os.WriteFile(toml, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(toml); err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Errorf("expected unsupported format error, got %v", err)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	f, err := Filters{
+		IgnoreMACs:    []string{"02:42", "aa:bb:cc:dd:ee:ff"},
+		IgnoreSubnets: []string{"169.254.0.0/16", "fe80::/10"},
+	}.Compile()
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	mac := func(s string) net.HardwareAddr {
+		m, _ := net.ParseMAC(s)
+		return m
+	}
+	in := []dump.Neighbour{
+		{MAC: mac("02:42:ac:11:00:02")},
+		{MAC: mac("aa:bb:cc:dd:ee:ff")},
+		{
+			MAC:  mac("aa:bb:cc:dd:ee:01"),
+			IPv4: []net.IP{net.ParseIP("169.254.1.1").To4(), net.ParseIP("192.168.1.10").To4()},
+			IPv6: []net.IP{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::1")},
+		},
+	}
+	out := f.Apply(in)
+	if len(out) != 1 {
+		t.Fatalf("expected 1 neighbour, got %d", len(out))
+	}
+	if got := out[0].IPv4String(); got != "192.168.1.10" {
+		t.Errorf("IPv4: %q", got)
+	}
+	if got := out[0].IPv6String(); got != "2001:db8::1" {
+		t.Errorf("IPv6: %q", got)
+	}
+
+	var none *Filter
+	if got := none.Apply(in[:1]); len(got) != 1 {
+		t.Error("nil filter dropped entries")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := Filters{IgnoreMACs: []string{"02:42"}}
+	b := Filters{IgnoreMACs: []string{"aa"}, IgnoreSubnets: []string{"10.0.0.0/8"}}
+	m := a.Merge(b)
+	if len(m.IgnoreMACs) != 2 || len(m.IgnoreSubnets) != 1 || len(a.IgnoreMACs) != 1 {
+		t.Errorf("unexpected merge: %+v (a=%+v)", m, a)
+	}
+}
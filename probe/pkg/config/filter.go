@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+// Filter is a compiled Filters.
+type Filter struct {
+	macs    []net.HardwareAddr // full addresses and OUI prefixes
+	subnets []*net.IPNet
+}
+
+// Compile parses the filter lists.
+func (f Filters) Compile() (*Filter, error) {
+	var cf Filter
+	for _, s := range f.IgnoreMACs {
+		mac, err := parseMACPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("ignore_macs: %w", err)
+		}
+		cf.macs = append(cf.macs, mac)
+	}
+	for _, s := range f.IgnoreSubnets {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("ignore_subnets: %w", err)
+		}
+		cf.subnets = append(cf.subnets, n)
+	}
+	return &cf, nil
+}
+
+// parseMACPrefix parses a full MAC address or a prefix of 1-5 octets.
+func parseMACPrefix(s string) (net.HardwareAddr, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) == 0 || len(parts) > 6 {
+		return nil, fmt.Errorf("invalid MAC or prefix %q", s)
+	}
+	mac := make(net.HardwareAddr, len(parts))
+	for i, p := range parts {
+		b, err := strconv.ParseUint(p, 16, 8)
+		if len(p) != 2 || err != nil {
+			return nil, fmt.Errorf("invalid MAC or prefix %q", s)
+		}
+		mac[i] = byte(b)
+	}
+	return mac, nil
+}
+
+// Merge returns the union of f and other.
+func (f Filters) Merge(other Filters) Filters {
+	return Filters{
+		IgnoreMACs:    append(append([]string(nil), f.IgnoreMACs...), other.IgnoreMACs...),
+		IgnoreSubnets: append(append([]string(nil), f.IgnoreSubnets...), other.IgnoreSubnets...),
+	}
+}
+
+// Empty reports whether the filter drops nothing.
+func (f *Filter) Empty() bool {
+	return f == nil || (len(f.macs) == 0 && len(f.subnets) == 0)
+}
+
+// Apply returns the neighbours left after filtering. Entries whose MAC is
+// ignored are dropped; ignored addresses are removed from the remaining
+// entries. The input slice is reused.
+func (f *Filter) Apply(neighbours []dump.Neighbour) []dump.Neighbour {
+	if f.Empty() {
+		return neighbours
+	}
+	out := neighbours[:0]
+	for _, n := range neighbours {
+		if f.ignoreMAC(n.MAC) {
+			continue
+		}
+		n.IPv4 = f.keepIPs(n.IPv4)
+		n.IPv6 = f.keepIPs(n.IPv6)
+		out = append(out, n)
+	}
+	return out
+}
+
+func (f *Filter) ignoreMAC(mac net.HardwareAddr) bool {
+	for _, m := range f.macs {
+		if bytes.HasPrefix(mac, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) keepIPs(ips []net.IP) []net.IP {
+	if len(f.subnets) == 0 {
+		return ips
+	}
+	var kept []net.IP
+	for _, ip := range ips {
+		ignored := false
+		for _, n := range f.subnets {
+			if n.Contains(ip) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			kept = append(kept, ip)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,177 @@
+// Package ctl implements the probe's local control protocol: one JSON
+// request and one JSON response per connection over a unix socket.
+//
+// The running probe serves it (see daemon.Daemon.Handle); `l2radar ctl`
+// is the client.
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultSocketPath is the default control socket path.
+const DefaultSocketPath = "/run/l2radar/ctl.sock"
+
+// SocketPermissions restricts the control socket to its owner (root).
+const SocketPermissions os.FileMode = 0600
+
+// Commands understood by the probe.
+const (
+	CmdStatus      = "status"
+	CmdAddIface    = "add-iface"
+	CmdRemoveIface = "remove-iface"
+	CmdForget      = "forget"
+	CmdFlush       = "flush"
+	CmdSetInterval = "set-interval"
+)
+
+// Request is a control command.
+type Request struct {
+	Command   string   `json:"command"`
+	Iface     string   `json:"iface,omitempty"`
+	MACs      []string `json:"macs,omitempty"`
+	OlderThan string   `json:"older_than,omitempty"`
+	Interval  string   `json:"interval,omitempty"`
+}
+
+// Response is the reply to a Request. Result is command specific.
+type Response struct {
+	OK     bool            `json:"ok"`
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// InterfacesResult is the result of add-iface and remove-iface.
+type InterfacesResult struct {
+	Interfaces []string `json:"interfaces"`
+}
+
+// DeletedResult is the result of forget and flush.
+type DeletedResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// IntervalResult is the result of set-interval.
+type IntervalResult struct {
+	ExportInterval string `json:"export_interval"`
+}
+
+// Handler executes requests. The returned value is marshalled as the
+// response result.
+type Handler interface {
+	Handle(req Request) (any, error)
+}
+
+// Listen creates the control socket at path, replacing a stale socket
+// left by a previous run.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("removing stale socket %s: %w", path, err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, SocketPermissions); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	return l, nil
+}
+
+// Serve accepts connections on l until ctx is cancelled, then closes l.
+func Serve(ctx context.Context, l net.Listener, h Handler, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logger.Warn("control socket accept failed", "error", err)
+			continue
+		}
+		go serveConn(conn, h, logger)
+	}
+}
+
+// ioTimeout bounds reading a request and writing a response.
+const ioTimeout = 10 * time.Second
+
+func serveConn(conn net.Conn, h Handler, logger *slog.Logger) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(ioTimeout))
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		writeResponse(conn, Response{Error: fmt.Sprintf("invalid request: %v", err)}, logger)
+		return
+	}
+
+	resp := Response{OK: true}
+	result, err := h.Handle(req)
+	if err == nil && result != nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		resp = Response{Error: err.Error()}
+	}
+	logger.Info("control request", "command", req.Command, "iface", req.Iface, "ok", resp.OK)
+	writeResponse(conn, resp, logger)
+}
+
+func writeResponse(conn net.Conn, resp Response, logger *slog.Logger) {
+	conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		logger.Warn("control socket write failed", "error", err)
+	}
+}
+
+// Call sends req to the probe listening on socketPath and decodes the
+// result into result (ignored if nil). A failed command is returned as an
+// error.
+func Call(socketPath string, req Request, result any) error {
+	conn, err := net.DialTimeout("unix", socketPath, ioTimeout)
+	if err != nil {
+		return fmt.Errorf("connecting to probe at %s (is it running?): %w", socketPath, err)
+	}
+	defer conn.Close()
+
+	// Commands like add-iface load a program and may take a while; only
+	// bound the total time loosely.
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("decoding result: %w", err)
+		}
+	}
+	return nil
+}
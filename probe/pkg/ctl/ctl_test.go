@@ -0,0 +1,95 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeHandler struct {
+	got Request
+}
+
+func (f *fakeHandler) Handle(req Request) (any, error) {
+	f.got = req
+	if req.Command == "fail" {
+		return nil, errors.New("boom")
+	}
+	return DeletedResult{Deleted: len(req.MACs)}, nil
+}
+
+func startServer(t *testing.T, h Handler) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run", "ctl.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Serve(ctx, l, h, nil)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return path
+}
+
+func TestCallRoundTrip(t *testing.T) {
+	h := &fakeHandler{}
+	path := startServer(t, h)
+
+	var res DeletedResult
+	req := Request{Command: CmdForget, Iface: "eth0", MACs: []string{"02:00:00:00:00:01", "02:00:00:00:00:02"}}
+	if err := Call(path, req, &res); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if res.Deleted != 2 {
+		t.Errorf("expected 2 deleted, got %d", res.Deleted)
+	}
+	if h.got.Iface != "eth0" || h.got.Command != CmdForget {
+		t.Errorf("handler got %+v", h.got)
+	}
+}
+
+func TestCallReturnsCommandError(t *testing.T) {
+	path := startServer(t, &fakeHandler{})
+
+	err := Call(path, Request{Command: "fail"}, nil)
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected boom, got %v", err)
+	}
+}
+
+func TestListenPermissionsAndStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ctl.sock")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen over stale file: %v", err)
+	}
+	defer l.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != SocketPermissions {
+		t.Errorf("expected permissions %o, got %o", SocketPermissions, info.Mode().Perm())
+	}
+}
+
+func TestCallNoServer(t *testing.T) {
+	err := Call(filepath.Join(t.TempDir(), "missing.sock"), Request{Command: CmdStatus}, nil)
+	if err == nil {
+		t.Fatal("expected error without server")
+	}
+}
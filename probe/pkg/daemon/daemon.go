@@ -0,0 +1,589 @@
+// Package daemon runs the long-lived probe: it owns the attached probes,
+// runs the periodic export loop and applies runtime changes (interfaces,
+// export interval, forget/flush) requested over the control socket.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/loader"
+)
+
+// Config holds the daemon settings.
+type Config struct {
+	PinPath        string
+	ExportDir      string // export disabled if empty
+	ExportInterval time.Duration
+	// ExportFormats are the files written per interface (see
+	// export.Formats); json only if empty.
+	ExportFormats []string
+	// ExportColumns are the fields of the columnar formats; nil for all.
+	ExportColumns []dump.Column
+	// ExportCombined also writes export.CombinedFileName, merging all
+	// exported interfaces.
+	ExportCombined bool
+	// Filter applies to interfaces added with AddInterface: the global
+	// filters of the configuration file.
+	Filter *config.Filter
+	Logger *slog.Logger
+}
+
+// attachFunc attaches a probe; overridable for testing.
+type attachFunc func(iface, pinBase string, logger *slog.Logger) (probe, error)
+
+// probe is the subset of loader.Probe used by the daemon.
+type probe interface {
+	Interface() string
+	Map() *ebpf.Map
+	Close() error
+}
+
+// InterfaceOptions are per-interface settings that can change without
+// re-attaching the probe. The zero value exports everything.
+type InterfaceOptions struct {
+	NoExport bool
+	Filter   *config.Filter
+}
+
+// iface is the daemon's state for one attached interface.
+type iface struct {
+	probe          probe
+	opts           InterfaceOptions
+	attached       time.Time
+	lastExport     time.Time
+	exportDuration time.Duration
+	exportErr      error
+	exportErrors   uint64
+
+	// written lists the export files, for the manifest.
+	written exported
+}
+
+// exported is what an export cycle wrote for one interface.
+type exported struct {
+	time       time.Time
+	neighbours []dump.Neighbour
+	files      []export.File
+}
+
+// Snapshot is one interface's neighbour table as read by an export cycle,
+// after filtering, most recently seen first. It is shared between
+// observers and must not be modified.
+type Snapshot struct {
+	Interface      string
+	Time           time.Time
+	ExportInterval time.Duration
+	Neighbours     []dump.Neighbour
+	Info           *export.InterfaceInfo // nil if unavailable
+	Stats          *export.InterfaceStats
+}
+
+// Observer receives every export cycle's snapshots. Calls are made with
+// the daemon locked, so observers must not block or call back into it.
+type Observer interface {
+	Observe(s Snapshot)
+	// Detached is called when an interface is removed.
+	Detached(iface string)
+}
+
+// Daemon owns the attached probes and the export loop.
+type Daemon struct {
+	mu        sync.Mutex
+	cfg       Config
+	attach    attachFunc
+	ifaces    map[string]*iface
+	started   time.Time
+	interval  chan time.Duration
+	observers []Observer
+}
+
+// New returns a daemon with no interfaces attached.
+func New(cfg Config) *Daemon {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Daemon{
+		cfg: cfg,
+		attach: func(name, pinBase string, logger *slog.Logger) (probe, error) {
+			return loader.Attach(name, pinBase, logger)
+		},
+		ifaces:   make(map[string]*iface),
+		started:  time.Now(),
+		interval: make(chan time.Duration, 1),
+	}
+}
+
+// AddObserver registers o for export cycles. Observers must be added
+// before Run; with an observer the loop runs even without an export
+// directory.
+func (d *Daemon) AddObserver(o Observer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.observers = append(d.observers, o)
+}
+
+// looping reports whether Run reads the maps periodically.
+func (d *Daemon) looping() bool {
+	return d.cfg.ExportDir != "" || len(d.observers) > 0
+}
+
+// AddInterface attaches a probe to name with default options: exported,
+// with the global filter (Config.Filter).
+func (d *Daemon) AddInterface(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.addInterface(name, InterfaceOptions{Filter: d.cfg.Filter})
+}
+
+// SetFilter changes the global filter used by AddInterface; interfaces
+// already attached keep theirs.
+func (d *Daemon) SetFilter(f *config.Filter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg.Filter = f
+}
+
+func (d *Daemon) addInterface(name string, opts InterfaceOptions) error {
+	if _, ok := d.ifaces[name]; ok {
+		return fmt.Errorf("interface %s is already attached", name)
+	}
+	p, err := d.attach(name, d.cfg.PinPath, d.cfg.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to attach probe to %s: %w", name, err)
+	}
+	d.ifaces[name] = &iface{probe: p, opts: opts, attached: time.Now()}
+	return nil
+}
+
+// RemoveInterface detaches the probe from name and removes its export
+// file.
+func (d *Daemon) RemoveInterface(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.removeInterface(name)
+}
+
+func (d *Daemon) removeInterface(name string) error {
+	ifc, ok := d.ifaces[name]
+	if !ok {
+		return fmt.Errorf("interface %s is not attached", name)
+	}
+	delete(d.ifaces, name)
+	d.removeExportFile(name)
+	for _, o := range d.observers {
+		o.Detached(name)
+	}
+	return ifc.probe.Close()
+}
+
+func (d *Daemon) removeExportFile(name string) {
+	if d.cfg.ExportDir == "" {
+		return
+	}
+	for _, format := range d.exportFormats() {
+		path := filepath.Join(d.cfg.ExportDir, export.FormatFileName(name, format))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			d.cfg.Logger.Warn("failed to remove export file", "path", path, "error", err)
+		}
+	}
+}
+
+func (d *Daemon) exportFormats() []string {
+	if len(d.cfg.ExportFormats) == 0 {
+		return []string{export.FormatJSON}
+	}
+	return d.cfg.ExportFormats
+}
+
+// Reconcile makes want the set of attached interfaces: interfaces not in
+// want are detached, missing ones attached, and the options of the others
+// updated in place, so their probes and maps are kept. Attach failures
+// are returned joined; the other changes are still applied.
+func (d *Daemon) Reconcile(want map[string]InterfaceOptions) (added, removed []string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var errs []error
+	for _, name := range d.names() {
+		if _, ok := want[name]; ok {
+			continue
+		}
+		if err := d.removeInterface(name); err != nil {
+			errs = append(errs, err)
+		}
+		removed = append(removed, name)
+	}
+
+	names := make([]string, 0, len(want))
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opts := want[name]
+		ifc, ok := d.ifaces[name]
+		if !ok {
+			if err := d.addInterface(name, opts); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			added = append(added, name)
+			continue
+		}
+		if opts.NoExport && !ifc.opts.NoExport {
+			d.removeExportFile(name)
+			ifc.written = exported{}
+		}
+		ifc.opts = opts
+	}
+	return added, removed, errors.Join(errs...)
+}
+
+// Interfaces returns the attached interface names, sorted.
+func (d *Daemon) Interfaces() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.names()
+}
+
+func (d *Daemon) names() []string {
+	names := make([]string, 0, len(d.ifaces))
+	for name := range d.ifaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetExportInterval changes the export interval; the running export loop
+// picks it up immediately.
+func (d *Daemon) SetExportInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("export-interval must be positive")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.looping() {
+		return fmt.Errorf("export is disabled")
+	}
+	d.cfg.ExportInterval = interval
+	select {
+	case <-d.interval:
+	default:
+	}
+	d.interval <- interval
+	return nil
+}
+
+// withMap runs fn on the map of an attached interface.
+func (d *Daemon) withMap(name string, fn func(*ebpf.Map) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ifc, ok := d.ifaces[name]
+	if !ok {
+		return fmt.Errorf("interface %s is not attached", name)
+	}
+	return fn(ifc.probe.Map())
+}
+
+// Forget deletes the given MACs from an interface's map.
+func (d *Daemon) Forget(name string, macs []net.HardwareAddr) (int, error) {
+	var n int
+	err := d.withMap(name, func(m *ebpf.Map) (err error) {
+		n, err = dump.Forget(m, macs)
+		return err
+	})
+	return n, err
+}
+
+// Flush deletes all entries of an interface's map, or those not seen for
+// olderThan if positive.
+func (d *Daemon) Flush(name string, olderThan time.Duration) (int, error) {
+	var n int
+	err := d.withMap(name, func(m *ebpf.Map) (err error) {
+		n, err = dump.Flush(m, olderThan)
+		return err
+	})
+	return n, err
+}
+
+// Neighbours reads the current neighbour table of an interface, most
+// recently seen first.
+func (d *Daemon) Neighbours(name string) ([]dump.Neighbour, error) {
+	var neighbours []dump.Neighbour
+	err := d.withMap(name, func(m *ebpf.Map) (err error) {
+		neighbours, err = dump.ReadNeighbours(m, nil)
+		if err == nil {
+			neighbours = d.ifaces[name].opts.Filter.Apply(neighbours)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	dump.SortByLastSeen(neighbours)
+	return neighbours, nil
+}
+
+// Run exports all interfaces immediately and then every export interval
+// until ctx is cancelled. Without an export directory or observers it only
+// waits.
+func (d *Daemon) Run(ctx context.Context) error {
+	d.mu.Lock()
+	exportDir, interval, looping := d.cfg.ExportDir, d.cfg.ExportInterval, d.looping()
+	d.mu.Unlock()
+
+	if !looping {
+		<-ctx.Done()
+		return nil
+	}
+	if interval <= 0 {
+		return fmt.Errorf("export-interval must be positive")
+	}
+	if exportDir != "" {
+		if err := os.MkdirAll(exportDir, 0755); err != nil {
+			return fmt.Errorf("failed to create export directory %s: %w", exportDir, err)
+		}
+		d.cfg.Logger.Info("export enabled", "dir", exportDir, "interval", interval.String())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Export immediately, then on each tick.
+	d.ExportAll()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case iv := <-d.interval:
+			ticker.Reset(iv)
+			d.cfg.Logger.Info("export interval changed", "interval", iv.String())
+		case <-ticker.C:
+			d.ExportAll()
+		}
+	}
+}
+
+// ExportAll reads each probe's map through its open handle, writes the
+// export files and the manifest, and passes the snapshots to the
+// observers.
+func (d *Daemon) ExportAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, name := range d.names() {
+		ifc := d.ifaces[name]
+		if ifc.opts.NoExport && len(d.observers) == 0 {
+			continue
+		}
+		start := time.Now()
+		err := d.export(name, ifc)
+		ifc.lastExport = start
+		ifc.exportDuration = time.Since(start)
+		ifc.exportErr = err
+		if err != nil {
+			ifc.exportErrors++
+		}
+	}
+	if d.cfg.ExportDir != "" {
+		d.writeManifest()
+	}
+}
+
+// writeManifest writes the combined export if enabled, then the manifest
+// of the files last written for each interface.
+func (d *Daemon) writeManifest() {
+	now := time.Now()
+	var interfaces []export.ManifestInterface
+	neighbours := make(map[string][]dump.Neighbour)
+	for _, name := range d.names() {
+		w := d.ifaces[name].written
+		if len(w.files) == 0 {
+			continue
+		}
+		interfaces = append(interfaces, export.ManifestInterface{
+			Name:       name,
+			Timestamp:  w.time.UTC().Format(time.RFC3339),
+			Neighbours: len(w.neighbours),
+			Files:      w.files,
+		})
+		neighbours[name] = w.neighbours
+	}
+
+	var combined *export.File
+	if d.cfg.ExportCombined {
+		f, err := export.WriteCombined(export.NewCombined(now, neighbours), d.cfg.ExportDir)
+		if err != nil {
+			d.cfg.Logger.Error("failed to write combined export", "error", err)
+		} else {
+			combined = &f
+		}
+	}
+	m := export.NewManifest(now, d.cfg.ExportInterval, interfaces, combined)
+	if _, err := export.WriteManifest(m, d.cfg.ExportDir); err != nil {
+		d.cfg.Logger.Error("failed to write manifest", "error", err)
+	}
+}
+
+func (d *Daemon) export(name string, ifc *iface) error {
+	logger := d.cfg.Logger
+	opts := ifc.opts
+	neighbours, err := dump.ReadNeighbours(ifc.probe.Map(), nil)
+	if err != nil {
+		logger.Error("failed to read map", "interface", name, "error", err)
+		return err
+	}
+	neighbours = opts.Filter.Apply(neighbours)
+
+	dump.SortByLastSeen(neighbours)
+
+	ifInfo, err := export.LookupInterfaceInfo(name)
+	if err != nil {
+		logger.Warn("failed to lookup interface info", "interface", name, "error", err)
+	}
+
+	ifStats, err := export.LookupInterfaceStats(name)
+	if err != nil {
+		logger.Warn("failed to lookup interface stats", "interface", name, "error", err)
+	}
+
+	now := time.Now()
+	for _, o := range d.observers {
+		o.Observe(Snapshot{
+			Interface:      name,
+			Time:           now,
+			ExportInterval: d.cfg.ExportInterval,
+			Neighbours:     neighbours,
+			Info:           ifInfo,
+			Stats:          ifStats,
+		})
+	}
+
+	if d.cfg.ExportDir == "" || opts.NoExport {
+		return nil
+	}
+	var errs []error
+	written := exported{time: now, neighbours: neighbours}
+	for _, format := range d.exportFormats() {
+		var f export.File
+		var err error
+		if format == export.FormatJSON {
+			f, err = export.WriteJSON(name, neighbours, d.cfg.ExportDir, now, d.cfg.ExportInterval, ifInfo, ifStats)
+		} else {
+			f, err = export.WriteFormat(name, format, neighbours, d.cfg.ExportColumns, d.cfg.ExportDir)
+		}
+		if err != nil {
+			logger.Error("failed to write export file", "interface", name, "format", format, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		written.files = append(written.files, f)
+	}
+	if len(written.files) > 0 {
+		ifc.written = written
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	logger.Debug("exported", "interface", name, "neighbours", len(neighbours))
+	return nil
+}
+
+// Close detaches all probes.
+func (d *Daemon) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range d.names() {
+		if err := d.ifaces[name].probe.Close(); err != nil {
+			d.cfg.Logger.Error("failed to close probe", "interface", name, "error", err)
+		}
+		delete(d.ifaces, name)
+	}
+}
+
+// InterfaceStatus is the live state of one attached interface.
+type InterfaceStatus struct {
+	Name             string    `json:"name"`
+	Attached         time.Time `json:"attached"`
+	MapEntries       int       `json:"map_entries"`
+	MapMaxEntries    uint32    `json:"map_max_entries"`
+	LastExport       time.Time `json:"last_export,omitzero"`
+	ExportDurationMs float64   `json:"export_duration_ms,omitempty"`
+	ExportError      string    `json:"export_error,omitempty"`
+	ExportErrors     uint64    `json:"export_errors,omitempty"`
+}
+
+// Status is the live state of the daemon.
+type Status struct {
+	Started        time.Time         `json:"started"`
+	PinPath        string            `json:"pin_path"`
+	ExportDir      string            `json:"export_dir,omitempty"`
+	ExportInterval string            `json:"export_interval,omitempty"`
+	Interfaces     []InterfaceStatus `json:"interfaces"`
+}
+
+// Status returns the live state. Map entry counts are read from the maps.
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	st := Status{
+		Started:    d.started,
+		PinPath:    d.cfg.PinPath,
+		ExportDir:  d.cfg.ExportDir,
+		Interfaces: []InterfaceStatus{},
+	}
+	if d.looping() {
+		st.ExportInterval = d.cfg.ExportInterval.String()
+	}
+	for _, name := range d.names() {
+		ifc := d.ifaces[name]
+		m := ifc.probe.Map()
+		is := InterfaceStatus{
+			Name:          name,
+			Attached:      ifc.attached,
+			MapEntries:    countEntries(m),
+			MapMaxEntries: m.MaxEntries(),
+			LastExport:    ifc.lastExport,
+			ExportErrors:  ifc.exportErrors,
+		}
+		if !ifc.lastExport.IsZero() {
+			is.ExportDurationMs = float64(ifc.exportDuration.Microseconds()) / 1000
+		}
+		if ifc.exportErr != nil {
+			is.ExportError = ifc.exportErr.Error()
+		}
+		st.Interfaces = append(st.Interfaces, is)
+	}
+	return st
+}
+
+// countEntries counts map entries by walking the map.
+func countEntries(m *ebpf.Map) int {
+	var (
+		key dump.MacKey
+		val dump.NeighbourEntry
+		n   int
+	)
+	iter := m.Iterate()
+	for iter.Next(&key, &val) {
+		n++
+	}
+	return n
+}
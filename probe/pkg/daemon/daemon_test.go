@@ -0,0 +1,357 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/ctl"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// fakeProbe is a probe backed by an unpinned map instead of an attached
+// program.
+type fakeProbe struct {
+	name   string
+	m      *ebpf.Map
+	closed bool
+}
+
+func (p *fakeProbe) Interface() string { return p.name }
+func (p *fakeProbe) Map() *ebpf.Map    { return p.m }
+func (p *fakeProbe) Close() error {
+	p.closed = true
+	return p.m.Close()
+}
+
+// newTestDaemon returns a daemon whose probes are fakeProbes. It skips if
+// eBPF maps cannot be created (not root).
+func newTestDaemon(t *testing.T, cfg Config) (*Daemon, map[string]*fakeProbe) {
+	t.Helper()
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Skipf("memlock: %v", err)
+	}
+	m, err := ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 104, MaxEntries: 16})
+	if err != nil {
+		t.Skipf("creating map: %v", err)
+	}
+	m.Close()
+
+	probes := make(map[string]*fakeProbe)
+	d := New(cfg)
+	d.attach = func(name, pinBase string, logger *slog.Logger) (probe, error) {
+		if name == "missing0" {
+			return nil, errors.New("no such interface")
+		}
+		m, err := ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 104, MaxEntries: 16})
+		if err != nil {
+			return nil, err
+		}
+		p := &fakeProbe{name: name, m: m}
+		probes[name] = p
+		return p, nil
+	}
+	t.Cleanup(d.Close)
+	return d, probes
+}
+
+func put(t *testing.T, m *ebpf.Map, mac net.HardwareAddr, lastSeen uint64) {
+	t.Helper()
+	var key dump.MacKey
+	copy(key.Addr[:], mac)
+	val := dump.NeighbourEntry{FirstSeen: lastSeen, LastSeen: lastSeen}
+	if err := m.Put(&key, &val); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+}
+
+func TestAddRemoveInterface(t *testing.T) {
+	d, probes := newTestDaemon(t, Config{})
+
+	if err := d.AddInterface("eth0"); err != nil {
+		t.Fatalf("AddInterface: %v", err)
+	}
+	if err := d.AddInterface("eth0"); err == nil {
+		t.Error("expected error attaching twice")
+	}
+	if err := d.AddInterface("missing0"); err == nil || !strings.Contains(err.Error(), "missing0") {
+		t.Errorf("expected attach error, got %v", err)
+	}
+	if got := d.Interfaces(); len(got) != 1 || got[0] != "eth0" {
+		t.Errorf("unexpected interfaces: %v", got)
+	}
+
+	if err := d.RemoveInterface("eth0"); err != nil {
+		t.Fatalf("RemoveInterface: %v", err)
+	}
+	if !probes["eth0"].closed {
+		t.Error("probe not closed on remove")
+	}
+	if err := d.RemoveInterface("eth0"); err == nil {
+		t.Error("expected error removing unknown interface")
+	}
+}
+
+func TestAddInterfaceGlobalFilter(t *testing.T) {
+	filter, err := config.Filters{IgnoreMACs: []string{"02:42:ac"}}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, probes := newTestDaemon(t, Config{Filter: filter})
+	if err := d.AddInterface("eth0"); err != nil {
+		t.Fatalf("AddInterface: %v", err)
+	}
+	kept := net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01}
+	put(t, probes["eth0"].Map(), kept, 1)
+	put(t, probes["eth0"].Map(), net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}, 2)
+
+	got, err := d.Neighbours("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].MAC.String() != kept.String() {
+		t.Errorf("expected only %s, got %+v", kept, got)
+	}
+
+	// A changed global filter applies to interfaces added afterwards.
+	d.SetFilter(nil)
+	if err := d.AddInterface("eth1"); err != nil {
+		t.Fatalf("AddInterface: %v", err)
+	}
+	put(t, probes["eth1"].Map(), net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}, 2)
+	if got, err := d.Neighbours("eth1"); err != nil || len(got) != 1 {
+		t.Errorf("expected the entry unfiltered, got %+v %v", got, err)
+	}
+}
+
+func TestHandleForgetFlushStatus(t *testing.T) {
+	d, probes := newTestDaemon(t, Config{PinPath: "/sys/fs/bpf/test"})
+	if err := d.AddInterface("eth0"); err != nil {
+		t.Fatal(err)
+	}
+	m := probes["eth0"].m
+	put(t, m, net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, 1)
+	put(t, m, net.HardwareAddr{0x02, 0, 0, 0, 0, 2}, 1)
+	put(t, m, net.HardwareAddr{0x02, 0, 0, 0, 0, 3}, 1)
+
+	res, err := d.Handle(ctl.Request{Command: ctl.CmdForget, Iface: "eth0", MACs: []string{"02:00:00:00:00:01", "02:00:00:00:00:09"}})
+	if err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if res.(ctl.DeletedResult).Deleted != 1 {
+		t.Errorf("forget: expected 1 deleted, got %+v", res)
+	}
+
+	st, err := d.Handle(ctl.Request{Command: ctl.CmdStatus})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	status := st.(Status)
+	if len(status.Interfaces) != 1 || status.Interfaces[0].MapEntries != 2 || status.Interfaces[0].MapMaxEntries != 16 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// Entries last seen at boot+1ns are older than one second.
+	res, err = d.Handle(ctl.Request{Command: ctl.CmdFlush, Iface: "eth0", OlderThan: "1s"})
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if res.(ctl.DeletedResult).Deleted != 2 {
+		t.Errorf("flush: expected 2 deleted, got %+v", res)
+	}
+}
+
+func TestHandleErrors(t *testing.T) {
+	d := New(Config{})
+	for _, req := range []ctl.Request{
+		{Command: "bogus"},
+		{Command: ctl.CmdAddIface},
+		{Command: ctl.CmdForget, Iface: "eth0"},
+		{Command: ctl.CmdForget, Iface: "eth0", MACs: []string{"not-a-mac"}},
+		{Command: ctl.CmdFlush, Iface: "eth0", OlderThan: "soon"},
+		{Command: ctl.CmdFlush, Iface: "eth0"},
+		{Command: ctl.CmdSetInterval, Interval: "10s"}, // export disabled
+		{Command: ctl.CmdSetInterval, Interval: "-1s"},
+	} {
+		if _, err := d.Handle(req); err == nil {
+			t.Errorf("%+v: expected error", req)
+		}
+	}
+}
+
+func TestSetExportInterval(t *testing.T) {
+	d := New(Config{ExportDir: t.TempDir(), ExportInterval: time.Second})
+	if err := d.SetExportInterval(3 * time.Second); err != nil {
+		t.Fatalf("SetExportInterval: %v", err)
+	}
+	if err := d.SetExportInterval(4 * time.Second); err != nil {
+		t.Fatalf("second SetExportInterval must not block: %v", err)
+	}
+	if got := <-d.interval; got != 4*time.Second {
+		t.Errorf("expected pending interval 4s, got %s", got)
+	}
+	if d.Status().ExportInterval != "4s" {
+		t.Errorf("unexpected status interval: %s", d.Status().ExportInterval)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	dir := t.TempDir()
+	cols, err := dump.ParseColumns([]string{"mac"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, probes := newTestDaemon(t, Config{
+		ExportDir:      dir,
+		ExportInterval: time.Second,
+		ExportFormats:  []string{"json", "csv", "yaml"},
+		ExportColumns:  cols,
+	})
+	if err := d.AddInterface("eth0"); err != nil {
+		t.Fatal(err)
+	}
+	put(t, probes["eth0"].m, net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, 1)
+	d.ExportAll()
+
+	b, err := os.ReadFile(filepath.Join(dir, "neigh-eth0.csv"))
+	if err != nil || string(b) != "mac\n02:00:00:00:00:01\n" {
+		t.Errorf("unexpected csv export %q: %v", b, err)
+	}
+	for _, format := range []string{"json", "yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, export.FormatFileName("eth0", format))); err != nil {
+			t.Errorf("%s export missing: %v", format, err)
+		}
+	}
+
+	if err := d.RemoveInterface("eth0"); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "neigh-*")); len(files) != 0 {
+		t.Errorf("export files not removed: %v", files)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	d, probes := newTestDaemon(t, Config{
+		ExportDir:      dir,
+		ExportInterval: time.Second,
+		ExportFormats:  []string{"json", "ndjson"},
+		ExportCombined: true,
+	})
+	if _, _, err := d.Reconcile(map[string]InterfaceOptions{"eth0": {}, "eth1": {}, "eth2": {NoExport: true}}); err != nil {
+		t.Fatal(err)
+	}
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	put(t, probes["eth0"].m, mac, 1)
+	put(t, probes["eth1"].m, mac, 2)
+	put(t, probes["eth2"].m, net.HardwareAddr{0x02, 0, 0, 0, 0, 2}, 1)
+	d.ExportAll()
+
+	var m export.Manifest
+	b, err := os.ReadFile(filepath.Join(dir, export.ManifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Interfaces) != 2 || m.Interfaces[0].Name != "eth0" || m.Interfaces[1].Name != "eth1" {
+		t.Fatalf("unexpected manifest interfaces: %+v", m.Interfaces)
+	}
+	for _, ifc := range m.Interfaces {
+		if ifc.Neighbours != 1 || len(ifc.Files) != 2 || ifc.Files[1].Name != "neigh-"+ifc.Name+".ndjson" {
+			t.Errorf("unexpected manifest entry: %+v", ifc)
+		}
+		for _, f := range ifc.Files {
+			b, err := os.ReadFile(filepath.Join(dir, f.Name))
+			sum := sha256.Sum256(b)
+			if err != nil || f.SHA256 != hex.EncodeToString(sum[:]) || f.Size != len(b) {
+				t.Errorf("%s: hash or size mismatch: %+v", f.Name, f)
+			}
+		}
+	}
+	if m.Combined == nil || m.Combined.Name != export.CombinedFileName {
+		t.Fatalf("combined export missing from manifest: %+v", m.Combined)
+	}
+
+	var c export.Combined
+	b, err = os.ReadFile(filepath.Join(dir, export.CombinedFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Neighbours) != 1 || len(c.Neighbours[0].Sightings) != 2 || c.Neighbours[0].Sightings[1].Interface != "eth1" {
+		t.Errorf("unexpected combined export: %+v", c)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	dir := t.TempDir()
+	d, probes := newTestDaemon(t, Config{ExportDir: dir, ExportInterval: time.Second})
+
+	added, removed, err := d.Reconcile(map[string]InterfaceOptions{"eth0": {}, "eth1": {}})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(added) != 2 || len(removed) != 0 {
+		t.Errorf("unexpected changes: added %v removed %v", added, removed)
+	}
+	eth0 := probes["eth0"]
+	put(t, eth0.m, net.HardwareAddr{0x02, 0x42, 0, 0, 0, 1}, 1)
+	put(t, eth0.m, net.HardwareAddr{0x02, 0, 0, 0, 0, 2}, 1)
+	d.ExportAll()
+
+	// eth1 removed, wlan0 added with a failing one, eth0 kept with a filter
+	// and export disabled.
+	filter, err := config.Filters{IgnoreMACs: []string{"02:42"}}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, removed, err = d.Reconcile(map[string]InterfaceOptions{
+		"eth0":     {NoExport: true, Filter: filter},
+		"wlan0":    {},
+		"missing0": {},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing0") {
+		t.Errorf("expected attach error for missing0, got %v", err)
+	}
+	if len(added) != 1 || added[0] != "wlan0" || len(removed) != 1 || removed[0] != "eth1" {
+		t.Errorf("unexpected changes: added %v removed %v", added, removed)
+	}
+	if probes["eth0"] != eth0 || eth0.closed {
+		t.Error("unchanged interface was re-attached")
+	}
+	if !probes["eth1"].closed {
+		t.Error("removed interface not closed")
+	}
+	if got := d.Interfaces(); strings.Join(got, ",") != "eth0,wlan0" {
+		t.Errorf("unexpected interfaces: %v", got)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, export.OutputFileName("eth0"))); !os.IsNotExist(err) {
+		t.Errorf("export file of non-exported interface not removed: %v", err)
+	}
+	neighbours, err := d.Neighbours("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbours) != 1 || neighbours[0].MAC.String() != "02:00:00:00:00:02" {
+		t.Errorf("filter not applied: %v", neighbours)
+	}
+}
@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/ctl"
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+// Handle executes a control request; it implements ctl.Handler.
+func (d *Daemon) Handle(req ctl.Request) (any, error) {
+	switch req.Command {
+	case ctl.CmdStatus:
+		return d.Status(), nil
+
+	case ctl.CmdAddIface, ctl.CmdRemoveIface:
+		if req.Iface == "" {
+			return nil, fmt.Errorf("%s: missing interface", req.Command)
+		}
+		var err error
+		if req.Command == ctl.CmdAddIface {
+			err = d.AddInterface(req.Iface)
+		} else {
+			err = d.RemoveInterface(req.Iface)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ctl.InterfacesResult{Interfaces: d.Interfaces()}, nil
+
+	case ctl.CmdForget:
+		if req.Iface == "" {
+			return nil, fmt.Errorf("forget: missing interface")
+		}
+		macs, err := dump.ParseMACs(req.MACs)
+		if err != nil {
+			return nil, err
+		}
+		if len(macs) == 0 {
+			return nil, fmt.Errorf("forget: no MAC addresses given")
+		}
+		n, err := d.Forget(req.Iface, macs)
+		if err != nil {
+			return nil, err
+		}
+		return ctl.DeletedResult{Deleted: n}, nil
+
+	case ctl.CmdFlush:
+		if req.Iface == "" {
+			return nil, fmt.Errorf("flush: missing interface")
+		}
+		var olderThan time.Duration
+		if req.OlderThan != "" {
+			var err error
+			if olderThan, err = time.ParseDuration(req.OlderThan); err != nil {
+				return nil, fmt.Errorf("flush: invalid older-than: %w", err)
+			}
+		}
+		n, err := d.Flush(req.Iface, olderThan)
+		if err != nil {
+			return nil, err
+		}
+		return ctl.DeletedResult{Deleted: n}, nil
+
+	case ctl.CmdSetInterval:
+		interval, err := time.ParseDuration(req.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("set-interval: invalid interval: %w", err)
+		}
+		if err := d.SetExportInterval(interval); err != nil {
+			return nil, err
+		}
+		return ctl.IntervalResult{ExportInterval: interval.String()}, nil
+	}
+	return nil, fmt.Errorf("unknown command %q", req.Command)
+}
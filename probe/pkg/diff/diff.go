@@ -0,0 +1,281 @@
+// Package diff compares export snapshots ("before" and "after" an
+// incident): MAC addresses added and removed, addresses gained or lost by
+// a MAC, and addresses now held by a device of another vendor.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/oui"
+)
+
+// Interface statuses, for interfaces in only one of the snapshots.
+const (
+	StatusAdded   = "added"
+	StatusRemoved = "removed"
+)
+
+// Report is the difference between two snapshots, per interface.
+type Report struct {
+	Interfaces []Interface `json:"interfaces"`
+}
+
+// Interface is the difference between two exports of one interface. All
+// lists are sorted by MAC (or address for VendorChanges).
+type Interface struct {
+	Interface string `json:"interface"`
+	// Status is StatusAdded or StatusRemoved if the interface is in only
+	// one snapshot; its neighbours are then all added or removed.
+	Status        string         `json:"status,omitempty"`
+	OldTimestamp  string         `json:"old_timestamp,omitempty"`
+	NewTimestamp  string         `json:"new_timestamp,omitempty"`
+	Added         []Entry        `json:"added"`
+	Removed       []Entry        `json:"removed"`
+	IPChanges     []IPChange     `json:"ip_changes"`
+	VendorChanges []VendorChange `json:"vendor_changes"`
+}
+
+// Entry is a MAC address added or removed, with its addresses.
+type Entry struct {
+	MAC    string   `json:"mac"`
+	Vendor string   `json:"vendor"`
+	IPv4   []string `json:"ipv4"`
+	IPv6   []string `json:"ipv6"`
+}
+
+// IPChange lists the addresses a MAC present in both snapshots gained and
+// lost.
+type IPChange struct {
+	MAC     string   `json:"mac"`
+	Vendor  string   `json:"vendor"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// VendorChange is an address held in both snapshots by MACs of different
+// vendors, e.g. a gateway address taken over by another device.
+type VendorChange struct {
+	IP        string `json:"ip"`
+	OldMAC    string `json:"old_mac"`
+	OldVendor string `json:"old_vendor"`
+	NewMAC    string `json:"new_mac"`
+	NewVendor string `json:"new_vendor"`
+}
+
+// Empty reports whether nothing changed.
+func (d *Interface) Empty() bool {
+	return d.Status == "" && len(d.Added) == 0 && len(d.Removed) == 0 &&
+		len(d.IPChanges) == 0 && len(d.VendorChanges) == 0
+}
+
+// Empty reports whether nothing changed on any interface.
+func (r *Report) Empty() bool {
+	for i := range r.Interfaces {
+		if !r.Interfaces[i].Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare returns the difference between two exports of an interface;
+// either may be nil if the interface is in only one snapshot.
+func Compare(old, cur *export.InterfaceData) Interface {
+	d := Interface{
+		Added:         []Entry{},
+		Removed:       []Entry{},
+		IPChanges:     []IPChange{},
+		VendorChanges: []VendorChange{},
+	}
+	switch {
+	case old == nil:
+		d.Interface, d.Status = cur.Interface, StatusAdded
+		old = &export.InterfaceData{}
+	case cur == nil:
+		d.Interface, d.Status = old.Interface, StatusRemoved
+		cur = &export.InterfaceData{}
+	default:
+		d.Interface = cur.Interface
+	}
+	d.OldTimestamp, d.NewTimestamp = old.Timestamp, cur.Timestamp
+
+	prev := byMAC(old.Neighbours)
+	next := byMAC(cur.Neighbours)
+	for _, n := range cur.Neighbours {
+		p, ok := prev[n.MAC]
+		if !ok {
+			d.Added = append(d.Added, newEntry(n))
+			continue
+		}
+		c := IPChange{
+			MAC:     n.MAC,
+			Vendor:  vendor(n.MAC),
+			Added:   missing(addrs(n), addrs(p)),
+			Removed: missing(addrs(p), addrs(n)),
+		}
+		if len(c.Added) > 0 || len(c.Removed) > 0 {
+			d.IPChanges = append(d.IPChanges, c)
+		}
+	}
+	for _, n := range old.Neighbours {
+		if _, ok := next[n.MAC]; !ok {
+			d.Removed = append(d.Removed, newEntry(n))
+		}
+	}
+
+	prevOwners, nextOwners := owners(old.Neighbours), owners(cur.Neighbours)
+	for ip, macs := range nextOwners {
+		for _, oldMAC := range prevOwners[ip] {
+			if slices.Contains(macs, oldMAC) {
+				continue
+			}
+			for _, newMAC := range macs {
+				if slices.Contains(prevOwners[ip], newMAC) {
+					continue
+				}
+				if ov, nv := vendor(oldMAC), vendor(newMAC); ov != nv {
+					d.VendorChanges = append(d.VendorChanges, VendorChange{ip, oldMAC, ov, newMAC, nv})
+				}
+			}
+		}
+	}
+
+	sortByMAC(d.Added)
+	sortByMAC(d.Removed)
+	sort.Slice(d.IPChanges, func(i, j int) bool { return d.IPChanges[i].MAC < d.IPChanges[j].MAC })
+	sort.Slice(d.VendorChanges, func(i, j int) bool {
+		a, b := d.VendorChanges[i], d.VendorChanges[j]
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		return a.OldMAC+a.NewMAC < b.OldMAC+b.NewMAC
+	})
+	return d
+}
+
+// CompareAll compares two snapshots of any number of interfaces, matched
+// by name.
+func CompareAll(old, cur []*export.InterfaceData) Report {
+	prev := make(map[string]*export.InterfaceData)
+	for _, data := range old {
+		prev[data.Interface] = data
+	}
+	var r Report
+	seen := make(map[string]bool)
+	for _, data := range cur {
+		seen[data.Interface] = true
+		r.Interfaces = append(r.Interfaces, Compare(prev[data.Interface], data))
+	}
+	for _, data := range old {
+		if !seen[data.Interface] {
+			r.Interfaces = append(r.Interfaces, Compare(data, nil))
+		}
+	}
+	sort.Slice(r.Interfaces, func(i, j int) bool { return r.Interfaces[i].Interface < r.Interfaces[j].Interface })
+	if r.Interfaces == nil {
+		r.Interfaces = []Interface{}
+	}
+	return r
+}
+
+// WriteTable writes r for humans: per interface, "+" for added MACs, "-"
+// for removed ones, "~" for address changes and "!" for vendor changes.
+func WriteTable(w io.Writer, r Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, d := range r.Interfaces {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		header := d.Interface
+		switch d.Status {
+		case StatusAdded:
+			header += " (new interface)"
+		case StatusRemoved:
+			header += " (interface removed)"
+		default:
+			header += fmt.Sprintf(" (%s -> %s)", d.OldTimestamp, d.NewTimestamp)
+		}
+		fmt.Fprintln(tw, header)
+		if d.Empty() {
+			fmt.Fprintln(tw, "  no changes")
+			continue
+		}
+		for _, e := range d.Added {
+			fmt.Fprintf(tw, "  +\t%s\t%s\t%s\n", e.MAC, e.Vendor, strings.Join(slices.Concat(e.IPv4, e.IPv6), ", "))
+		}
+		for _, e := range d.Removed {
+			fmt.Fprintf(tw, "  -\t%s\t%s\t%s\n", e.MAC, e.Vendor, strings.Join(slices.Concat(e.IPv4, e.IPv6), ", "))
+		}
+		for _, c := range d.IPChanges {
+			var changes []string
+			for _, ip := range c.Added {
+				changes = append(changes, "+"+ip)
+			}
+			for _, ip := range c.Removed {
+				changes = append(changes, "-"+ip)
+			}
+			fmt.Fprintf(tw, "  ~\t%s\t%s\t%s\n", c.MAC, c.Vendor, strings.Join(changes, " "))
+		}
+		for _, c := range d.VendorChanges {
+			fmt.Fprintf(tw, "  !\t%s\t%s -> %s\t%s -> %s\n", c.IP, c.OldVendor, c.NewVendor, c.OldMAC, c.NewMAC)
+		}
+	}
+	return tw.Flush()
+}
+
+func newEntry(n export.NeighbourJSON) Entry {
+	return Entry{MAC: n.MAC, Vendor: vendor(n.MAC), IPv4: n.IPv4, IPv6: n.IPv6}
+}
+
+func vendor(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return ""
+	}
+	return oui.Lookup(hw)
+}
+
+func byMAC(neighbours []export.NeighbourJSON) map[string]export.NeighbourJSON {
+	m := make(map[string]export.NeighbourJSON, len(neighbours))
+	for _, n := range neighbours {
+		m[n.MAC] = n
+	}
+	return m
+}
+
+// owners maps each address to the MACs holding it.
+func owners(neighbours []export.NeighbourJSON) map[string][]string {
+	m := make(map[string][]string)
+	for _, n := range neighbours {
+		for _, ip := range addrs(n) {
+			m[ip] = append(m[ip], n.MAC)
+		}
+	}
+	return m
+}
+
+func addrs(n export.NeighbourJSON) []string {
+	return slices.Concat(n.IPv4, n.IPv6)
+}
+
+// missing returns the strings of a not in b, in order.
+func missing(a, b []string) []string {
+	out := []string{}
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func sortByMAC(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].MAC < entries[j].MAC })
+}
@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+func data(iface, ts string, neighbours ...export.NeighbourJSON) *export.InterfaceData {
+	return &export.InterfaceData{Interface: iface, Timestamp: ts, Neighbours: neighbours}
+}
+
+const (
+	t1 = "2026-10-18T11:00:00Z"
+	t2 = "2026-10-18T12:00:00Z"
+
+	pi    = "dc:a6:32:00:00:01" // Raspberry Pi
+	apple = "00:1b:63:00:00:01" // Apple
+)
+
+// seen is the first and last seen time of the entries, not compared.
+var seen = time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+
+func TestCompare(t *testing.T) {
+	old := data("eth0", t1,
+		testutil.NeighbourJSON(pi, seen, "10.0.0.1", "fe80::1"),
+		testutil.NeighbourJSON("02:00:00:00:00:02", seen, "10.0.0.2"),
+		testutil.NeighbourJSON("02:00:00:00:00:03", seen, "10.0.0.3"),
+	)
+	cur := data("eth0", t2,
+		testutil.NeighbourJSON("02:00:00:00:00:02", seen, "10.0.0.2"),
+		testutil.NeighbourJSON("02:00:00:00:00:03", seen, "10.0.0.4", "10.0.0.3", "fe80::3"),
+		testutil.NeighbourJSON(apple, seen, "10.0.0.1"),
+	)
+	d := Compare(old, cur)
+
+	if d.Interface != "eth0" || d.Status != "" || d.OldTimestamp != t1 || d.NewTimestamp != t2 {
+		t.Errorf("unexpected header: %+v", d)
+	}
+	if len(d.Added) != 1 || d.Added[0].MAC != apple || d.Added[0].Vendor != "Apple, Inc." || d.Added[0].IPv4[0] != "10.0.0.1" {
+		t.Errorf("unexpected added: %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].MAC != pi || len(d.Removed[0].IPv6) != 1 {
+		t.Errorf("unexpected removed: %+v", d.Removed)
+	}
+	if len(d.IPChanges) != 1 || d.IPChanges[0].MAC != "02:00:00:00:00:03" ||
+		strings.Join(d.IPChanges[0].Added, ",") != "10.0.0.4,fe80::3" || len(d.IPChanges[0].Removed) != 0 {
+		t.Errorf("unexpected ip changes: %+v", d.IPChanges)
+	}
+	want := VendorChange{IP: "10.0.0.1", OldMAC: pi, OldVendor: "Raspberry Pi Trading Ltd", NewMAC: apple, NewVendor: "Apple, Inc."}
+	if len(d.VendorChanges) != 1 || d.VendorChanges[0] != want {
+		t.Errorf("expected %+v, got %+v", want, d.VendorChanges)
+	}
+
+	if d := Compare(old, old); !d.Empty() {
+		t.Errorf("expected no changes, got %+v", d)
+	}
+}
+
+func TestCompareMovedSameVendor(t *testing.T) {
+	// An address moving between MACs of one vendor is only an address
+	// change of both.
+	d := Compare(
+		data("eth0", t1, testutil.NeighbourJSON("02:00:00:00:00:01", seen, "10.0.0.1"), testutil.NeighbourJSON("02:00:00:00:00:02", seen)),
+		data("eth0", t2, testutil.NeighbourJSON("02:00:00:00:00:01", seen), testutil.NeighbourJSON("02:00:00:00:00:02", seen, "10.0.0.1")),
+	)
+	if len(d.VendorChanges) != 0 || len(d.IPChanges) != 2 || d.IPChanges[0].Removed[0] != "10.0.0.1" || d.IPChanges[1].Added[0] != "10.0.0.1" {
+		t.Errorf("unexpected diff: %+v", d)
+	}
+}
+
+func TestCompareAll(t *testing.T) {
+	r := CompareAll(
+		[]*export.InterfaceData{data("eth0", t1, testutil.NeighbourJSON(pi, seen)), data("eth1", t1, testutil.NeighbourJSON(apple, seen))},
+		[]*export.InterfaceData{data("wlan0", t2, testutil.NeighbourJSON(pi, seen)), data("eth0", t2, testutil.NeighbourJSON(pi, seen))},
+	)
+	if len(r.Interfaces) != 3 {
+		t.Fatalf("expected 3 interfaces, got %+v", r.Interfaces)
+	}
+	eth0, eth1, wlan0 := r.Interfaces[0], r.Interfaces[1], r.Interfaces[2]
+	if eth0.Interface != "eth0" || !eth0.Empty() {
+		t.Errorf("unexpected eth0: %+v", eth0)
+	}
+	if eth1.Status != StatusRemoved || len(eth1.Removed) != 1 || eth1.OldTimestamp != t1 || eth1.NewTimestamp != "" {
+		t.Errorf("unexpected eth1: %+v", eth1)
+	}
+	if wlan0.Status != StatusAdded || len(wlan0.Added) != 1 {
+		t.Errorf("unexpected wlan0: %+v", wlan0)
+	}
+	if r.Empty() {
+		t.Error("report should not be empty")
+	}
+
+	b, _ := json.Marshal(CompareAll(nil, nil))
+	if string(b) != `{"interfaces":[]}` {
+		t.Errorf("unexpected empty report: %s", b)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	r := CompareAll(
+		[]*export.InterfaceData{data("eth0", t1, testutil.NeighbourJSON(pi, seen, "10.0.0.1"), testutil.NeighbourJSON("02:00:00:00:00:03", seen, "10.0.0.3")), data("eth1", t1)},
+		[]*export.InterfaceData{data("eth0", t2, testutil.NeighbourJSON(apple, seen, "10.0.0.1"), testutil.NeighbourJSON("02:00:00:00:00:03", seen, "10.0.0.4")), data("eth1", t2)},
+	)
+	var buf bytes.Buffer
+	if err := WriteTable(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	want := `eth0 (2026-10-18T11:00:00Z -> 2026-10-18T12:00:00Z)
+  +  00:1b:63:00:00:01  Apple, Inc.                              10.0.0.1
+  -  dc:a6:32:00:00:01  Raspberry Pi Trading Ltd                 10.0.0.1
+  ~  02:00:00:00:00:03                                           +10.0.0.4 -10.0.0.3
+  !  10.0.0.1           Raspberry Pi Trading Ltd -> Apple, Inc.  dc:a6:32:00:00:01 -> 00:1b:63:00:00:01
+
+eth1 (2026-10-18T11:00:00Z -> 2026-10-18T12:00:00Z)
+  no changes
+`
+	if buf.String() != want {
+		t.Errorf("unexpected table:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
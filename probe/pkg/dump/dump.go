@@ -2,9 +2,11 @@ package dump
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -30,8 +32,12 @@ type In6Addr struct {
 }
 
 // NeighbourEntry mirrors the eBPF neighbour_entry struct layout.
+//
+// Ipv4 holds __be32 values, kept as raw network-order bytes so decoding
+// does not depend on the host byte order. Counts and timestamps are in
+// the byte order of the kernel that wrote them.
 type NeighbourEntry struct {
-	Ipv4      [4]uint32
+	Ipv4      [4][4]uint8
 	Ipv6      [4]In6Addr
 	Ipv4Count uint8
 	Ipv6Count uint8
@@ -40,6 +46,25 @@ type NeighbourEntry struct {
 	LastSeen  uint64
 }
 
+// neighbourEntrySize is sizeof(struct neighbour_entry).
+const neighbourEntrySize = 104
+
+// UnmarshalBinary decodes a raw neighbour_entry as read from a map on this
+// host.
+func (e *NeighbourEntry) UnmarshalBinary(b []byte) error {
+	return e.decode(b, binary.NativeEndian)
+}
+
+// decode decodes a raw neighbour_entry written by a kernel with the given
+// byte order.
+func (e *NeighbourEntry) decode(b []byte, order binary.ByteOrder) error {
+	if len(b) != neighbourEntrySize {
+		return fmt.Errorf("neighbour entry: got %d bytes, want %d", len(b), neighbourEntrySize)
+	}
+	_, err := binary.Decode(b, order, e)
+	return err
+}
+
 // Neighbour is the user-facing representation of a neighbour entry.
 type Neighbour struct {
 	MAC       net.HardwareAddr
@@ -47,6 +72,9 @@ type Neighbour struct {
 	IPv6      []net.IP
 	FirstSeen time.Time
 	LastSeen  time.Time
+	// Interface is the interface the neighbour was seen on, set when
+	// listing several interfaces together.
+	Interface string
 }
 
 // IPv4String returns IPv4 addresses as a comma-separated string.
@@ -72,6 +100,20 @@ func PinPath(pinBase, iface string) string {
 	return filepath.Join(pinBase, fmt.Sprintf("neigh-%s", iface))
 }
 
+// PinnedInterfaces returns the interfaces with a map pinned under pinBase,
+// sorted.
+func PinnedInterfaces(pinBase string) ([]string, error) {
+	paths, err := filepath.Glob(PinPath(pinBase, "*"))
+	if err != nil {
+		return nil, err
+	}
+	ifaces := make([]string, 0, len(paths))
+	for _, path := range paths {
+		ifaces = append(ifaces, strings.TrimPrefix(filepath.Base(path), "neigh-"))
+	}
+	return ifaces, nil
+}
+
 // timeNow and monoNow are overridable for testing.
 var (
 	timeNow = time.Now
@@ -93,23 +135,109 @@ func defaultMonoNow() int64 {
 // ktime is CLOCK_BOOTTIME nanoseconds since boot (including suspend).
 // We derive the boot instant by subtracting CLOCK_BOOTTIME from wall clock.
 func ktimeToTime(ktime uint64) time.Time {
-	if ktime == 0 {
-		return time.Time{}
+	return bootTimeConv()(ktime)
+}
+
+// bootTimeConv returns a TimeFunc like ktimeToTime that samples the boot
+// instant once, so converting a large table costs no clock reads per entry.
+func bootTimeConv() TimeFunc {
+	bootTime := timeNow().Add(-time.Duration(monoNow()))
+	return func(ktime uint64) time.Time {
+		if ktime == 0 {
+			return time.Time{}
+		}
+		return bootTime.Add(time.Duration(ktime))
 	}
-	now := timeNow()
-	mono := monoNow()
-	bootTime := now.Add(-time.Duration(mono))
-	return bootTime.Add(time.Duration(ktime))
 }
 
-// ReadMap opens a pinned BPF map and reads all neighbour entries.
+// TimeFunc converts a raw bpf_ktime_get_boot_ns value to wall-clock time.
+type TimeFunc func(ktime uint64) time.Time
+
+// ReadMap opens a pinned BPF map and reads all neighbour entries. Maps
+// written with an unsupported schema are refused (see OpenPinned).
 func ReadMap(pinPath string) ([]Neighbour, error) {
+	m, err := OpenPinned(pinPath)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	return ReadNeighbours(m, nil)
+}
+
+// OpenPinned opens a pinned neighbours map for reading and writing. If a
+// schema map is pinned next to it, maps written with an unsupported
+// schema version are refused with ErrIncompatibleSchema.
+func OpenPinned(pinPath string) (*ebpf.Map, error) {
+	meta, err := ReadMeta(metaPathFor(pinPath))
+	switch {
+	case err == nil:
+		if err := checkMeta(meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", pinPath, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
 	m, err := ebpf.LoadPinnedMap(pinPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("opening pinned map %s: %w", pinPath, err)
 	}
-	defer m.Close()
+	if err := checkLayout(m); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("%s: %w", pinPath, err)
+	}
+	return m, nil
+}
+
+// ReadNeighbours reads all neighbour entries from an open map. Timestamps
+// are converted with conv, or relative to the current boot if conv is nil.
+// Entries are fetched with BPF_MAP_LOOKUP_BATCH where the kernel supports
+// it, falling back to key-by-key iteration.
+func ReadNeighbours(m *ebpf.Map, conv TimeFunc) ([]Neighbour, error) {
+	if conv == nil {
+		conv = bootTimeConv()
+	}
+	if err := checkLayout(m); err != nil {
+		return nil, err
+	}
 
+	result, err := readBatch(m, conv)
+	if errors.Is(err, ebpf.ErrNotSupported) || errors.Is(err, unix.ENOSPC) {
+		return readIterate(m, conv)
+	}
+	return result, err
+}
+
+// batchSize is the number of entries fetched per BPF_MAP_LOOKUP_BATCH call.
+const batchSize = 1024
+
+// readBatch reads all entries with BPF_MAP_LOOKUP_BATCH. It returns
+// ErrNotSupported on kernels without the batch API (< 5.6), and ENOSPC if
+// a hash bucket holds more than batchSize entries.
+func readBatch(m *ebpf.Map, conv TimeFunc) ([]Neighbour, error) {
+	var (
+		cursor ebpf.MapBatchCursor
+		keys   = make([]MacKey, batchSize)
+		vals   = make([]NeighbourEntry, batchSize)
+		result = make([]Neighbour, 0, m.MaxEntries())
+	)
+	for {
+		n, err := m.BatchLookup(&cursor, keys, vals, nil)
+		for i := 0; i < n; i++ {
+			result = append(result, entryToNeighbour(keys[i], vals[i], conv))
+		}
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readIterate reads all entries key by key.
+func readIterate(m *ebpf.Map, conv TimeFunc) ([]Neighbour, error) {
 	var (
 		key    MacKey
 		val    NeighbourEntry
@@ -118,7 +246,7 @@ func ReadMap(pinPath string) ([]Neighbour, error) {
 
 	iter := m.Iterate()
 	for iter.Next(&key, &val) {
-		n := entryToNeighbour(key, val)
+		n := entryToNeighbour(key, val, conv)
 		result = append(result, n)
 	}
 	if err := iter.Err(); err != nil {
@@ -129,16 +257,16 @@ func ReadMap(pinPath string) ([]Neighbour, error) {
 }
 
 // entryToNeighbour converts raw map key/value to a Neighbour.
-func entryToNeighbour(key MacKey, val NeighbourEntry) Neighbour {
+func entryToNeighbour(key MacKey, val NeighbourEntry, conv TimeFunc) Neighbour {
 	n := Neighbour{
 		MAC:       net.HardwareAddr(key.Addr[:]),
-		FirstSeen: ktimeToTime(val.FirstSeen),
-		LastSeen:  ktimeToTime(val.LastSeen),
+		FirstSeen: conv(val.FirstSeen),
+		LastSeen:  conv(val.LastSeen),
 	}
 
 	for i := 0; i < int(val.Ipv4Count) && i < 4; i++ {
 		ip := make(net.IP, 4)
-		binary.LittleEndian.PutUint32(ip, val.Ipv4[i])
+		copy(ip, val.Ipv4[i][:])
 		n.IPv4 = append(n.IPv4, ip)
 	}
 
@@ -158,10 +286,22 @@ func SortByLastSeen(neighbours []Neighbour) {
 	})
 }
 
-// FormatTable writes a formatted table of neighbours to the writer.
+// FormatTable writes a formatted table of neighbours to the writer, with a
+// leading INTERFACE column if any neighbour has its Interface set.
 func FormatTable(w io.Writer, neighbours []Neighbour) {
+	withIface := false
+	for i := range neighbours {
+		withIface = withIface || neighbours[i].Interface != ""
+	}
+
 	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if withIface {
+		fmt.Fprint(tw, "INTERFACE\t")
+	}
 	fmt.Fprintln(tw, "MAC\tIPv4\tIPv6\tFIRST SEEN\tLAST SEEN")
+	if withIface {
+		fmt.Fprint(tw, "---------\t")
+	}
 	fmt.Fprintln(tw, "---\t----\t----\t----------\t---------")
 
 	for _, n := range neighbours {
@@ -179,6 +319,9 @@ func FormatTable(w io.Writer, neighbours []Neighbour) {
 			macStr += " (" + vendor + ")"
 		}
 
+		if withIface {
+			fmt.Fprintf(tw, "%s\t", n.Interface)
+		}
 		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
 			macStr,
 			n.IPv4String(),
@@ -1,10 +1,17 @@
 package dump
 
 import (
+	"encoding/binary"
+	"errors"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
 )
 
 func TestFormatMAC(t *testing.T) {
@@ -161,6 +168,47 @@ func TestPinPath(t *testing.T) {
 	}
 }
 
+func TestPinnedInterfaces(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"neigh-wlan0", "neigh-eth0", "meta-eth0"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ifaces, err := PinnedInterfaces(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ifaces, ",") != "eth0,wlan0" {
+		t.Errorf("unexpected interfaces: %v", ifaces)
+	}
+	if ifaces, err := PinnedInterfaces(filepath.Join(dir, "missing")); err != nil || len(ifaces) != 0 {
+		t.Errorf("expected no interfaces, got %v, %v", ifaces, err)
+	}
+}
+
+func TestFormatTableInterface(t *testing.T) {
+	neighbours := []Neighbour{
+		{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}, Interface: "eth0"},
+		{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}, Interface: "wlan0"},
+	}
+	var buf strings.Builder
+	FormatTable(&buf, neighbours)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.HasPrefix(lines[0], "INTERFACE  MAC") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[3], "wlan0") || !strings.Contains(lines[3], "02:00:00:00:00:02") {
+		t.Errorf("unexpected row %q", lines[3])
+	}
+
+	buf.Reset()
+	FormatTable(&buf, formatNeighbours())
+	if strings.Contains(buf.String(), "INTERFACE") {
+		t.Error("single-interface table should have no interface column")
+	}
+}
+
 func TestKtimeToTimeBasic(t *testing.T) {
 	// Simulate: wall clock is 14:30:00, system has been up for 1 hour.
 	// An event at ktime=30min should map to 13:30 + 30min = 14:00.
@@ -237,3 +285,178 @@ func TestKtimeToTimeFirstAndLastSeenDiffer(t *testing.T) {
 		t.Errorf("difference between first/last seen should be %v, got %v", expectedDiff, diff)
 	}
 }
+
+// rawEntry lays out a neighbour_entry as a kernel with the given byte
+// order writes it: IPv4 addresses are __be32 (network order on every
+// architecture), counts are bytes, timestamps are host-order __u64.
+func rawEntry(order binary.ByteOrder, ipv4 []net.IP, ipv6 []net.IP, first, last uint64) []byte {
+	b := make([]byte, neighbourEntrySize)
+	for i, ip := range ipv4 {
+		copy(b[i*4:], ip.To4())
+	}
+	for i, ip := range ipv6 {
+		copy(b[16+i*16:], ip.To16())
+	}
+	b[80] = uint8(len(ipv4))
+	b[81] = uint8(len(ipv6))
+	order.PutUint64(b[88:96], first)
+	order.PutUint64(b[96:104], last)
+	return b
+}
+
+func TestDecodeEntryBothEndiannesses(t *testing.T) {
+	v4 := []net.IP{net.ParseIP("192.168.1.10"), net.ParseIP("10.0.0.1")}
+	v6 := []net.IP{net.ParseIP("fe80::1")}
+	key := MacKey{Addr: [6]uint8{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}}
+	conv := func(ktime uint64) time.Time { return time.Unix(0, int64(ktime)) }
+
+	for _, tc := range []struct {
+		name  string
+		order binary.ByteOrder
+	}{
+		{"little-endian", binary.LittleEndian},
+		{"big-endian", binary.BigEndian},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var val NeighbourEntry
+			if err := val.decode(rawEntry(tc.order, v4, v6, 1000, 2000), tc.order); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			n := entryToNeighbour(key, val, conv)
+
+			if got := n.IPv4String(); got != "192.168.1.10, 10.0.0.1" {
+				t.Errorf("IPv4: got %q", got)
+			}
+			if got := n.IPv6String(); got != "fe80::1" {
+				t.Errorf("IPv6: got %q", got)
+			}
+			if n.FirstSeen.UnixNano() != 1000 || n.LastSeen.UnixNano() != 2000 {
+				t.Errorf("timestamps: got %d/%d", n.FirstSeen.UnixNano(), n.LastSeen.UnixNano())
+			}
+		})
+	}
+}
+
+func TestDecodeEntryWrongSize(t *testing.T) {
+	var val NeighbourEntry
+	if err := val.UnmarshalBinary(make([]byte, neighbourEntrySize-8)); err == nil {
+		t.Fatal("expected error for short value")
+	}
+}
+
+func TestNeighbourEntrySize(t *testing.T) {
+	if got := binary.Size(NeighbourEntry{}); got != neighbourEntrySize {
+		t.Errorf("NeighbourEntry size %d, want %d", got, neighbourEntrySize)
+	}
+	if got := binary.Size(MacKey{}); got != macKeySize {
+		t.Errorf("MacKey size %d, want %d", got, macKeySize)
+	}
+}
+
+// filledMap creates an unpinned neighbours map holding n entries. It skips
+// if eBPF maps cannot be created (not root).
+func filledMap(tb testing.TB, n int) *ebpf.Map {
+	tb.Helper()
+	if err := rlimit.RemoveMemlock(); err != nil {
+		tb.Skipf("memlock: %v", err)
+	}
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    macKeySize,
+		ValueSize:  neighbourEntrySize,
+		MaxEntries: uint32(n),
+	})
+	if err != nil {
+		tb.Skipf("creating map: %v", err)
+	}
+	tb.Cleanup(func() { m.Close() })
+
+	keys := make([]MacKey, n)
+	vals := make([]NeighbourEntry, n)
+	for i := range keys {
+		keys[i].Addr = [6]uint8{0x02, 0x00, byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)}
+		vals[i] = NeighbourEntry{
+			Ipv4:      [4][4]uint8{{10, byte(i >> 16), byte(i >> 8), byte(i)}},
+			Ipv4Count: 1,
+			FirstSeen: uint64(i + 1),
+			LastSeen:  uint64(i + 2),
+		}
+	}
+	if _, err := m.BatchUpdate(keys, vals, nil); err != nil {
+		for i := range keys {
+			if err := m.Put(&keys[i], &vals[i]); err != nil {
+				tb.Fatalf("filling map: %v", err)
+			}
+		}
+	}
+	return m
+}
+
+func TestReadBatchMatchesIterate(t *testing.T) {
+	// More entries than batchSize to exercise the cursor.
+	m := filledMap(t, 3*batchSize+17)
+	conv := bootTimeConv()
+
+	batch, err := readBatch(m, conv)
+	if errors.Is(err, ebpf.ErrNotSupported) {
+		t.Skip("batch API not supported")
+	}
+	if err != nil {
+		t.Fatalf("readBatch: %v", err)
+	}
+	iter, err := readIterate(m, conv)
+	if err != nil {
+		t.Fatalf("readIterate: %v", err)
+	}
+	if len(batch) != 3*batchSize+17 || len(batch) != len(iter) {
+		t.Fatalf("batch read %d entries, iteration %d", len(batch), len(iter))
+	}
+
+	byMAC := make(map[string]Neighbour, len(iter))
+	for _, n := range iter {
+		byMAC[n.MAC.String()] = n
+	}
+	for _, n := range batch {
+		want, ok := byMAC[n.MAC.String()]
+		if !ok {
+			t.Fatalf("%s missing from iteration", n.MAC)
+		}
+		if n.IPv4String() != want.IPv4String() || !n.LastSeen.Equal(want.LastSeen) {
+			t.Errorf("%s: batch %+v, iteration %+v", n.MAC, n, want)
+		}
+	}
+}
+
+func benchmarkRead(b *testing.B, read func(*ebpf.Map, TimeFunc) ([]Neighbour, error)) {
+	const entries = 100000
+	m := filledMap(b, entries)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		got, err := read(m, nil)
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			b.Skip("not supported")
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(got) != entries {
+			b.Fatalf("read %d entries, want %d", len(got), entries)
+		}
+	}
+}
+
+func BenchmarkReadNeighbours100k(b *testing.B) {
+	benchmarkRead(b, ReadNeighbours)
+}
+
+func BenchmarkReadBatch100k(b *testing.B) {
+	benchmarkRead(b, func(m *ebpf.Map, _ TimeFunc) ([]Neighbour, error) {
+		return readBatch(m, bootTimeConv())
+	})
+}
+
+func BenchmarkReadIterate100k(b *testing.B) {
+	benchmarkRead(b, func(m *ebpf.Map, _ TimeFunc) ([]Neighbour, error) {
+		return readIterate(m, bootTimeConv())
+	})
+}
@@ -0,0 +1,155 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/oui"
+)
+
+// Filter selects neighbours; zero fields match everything and set fields
+// must all match.
+type Filter struct {
+	// MAC is a case-insensitive prefix, e.g. "02:42" or "02-42".
+	MAC string
+	// IP and Subnet match neighbours holding an address in them; see
+	// ParseIPNet.
+	IP     *net.IPNet
+	Subnet *net.IPNet
+	// Vendor is a case-insensitive substring of the OUI vendor.
+	Vendor string
+	// SeenSince matches neighbours last seen at or after it.
+	SeenSince time.Time
+	// HasIPv6 matches neighbours with at least one IPv6 address.
+	HasIPv6 bool
+}
+
+// ParseIPNet parses an address, as a single-address network, or a CIDR.
+func ParseIPNet(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %q (address or CIDR)", s)
+	}
+	bits := 128
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits = v4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Match reports whether n matches all the set fields of f.
+func (f *Filter) Match(n *Neighbour) bool {
+	if f.MAC != "" && !strings.HasPrefix(n.MAC.String(), strings.ToLower(strings.ReplaceAll(f.MAC, "-", ":"))) {
+		return false
+	}
+	if f.Vendor != "" && !strings.Contains(strings.ToLower(oui.Lookup(n.MAC)), strings.ToLower(f.Vendor)) {
+		return false
+	}
+	for _, ipNet := range []*net.IPNet{f.IP, f.Subnet} {
+		if ipNet != nil && !holds(n, ipNet) {
+			return false
+		}
+	}
+	if !f.SeenSince.IsZero() && n.LastSeen.Before(f.SeenSince) {
+		return false
+	}
+	if f.HasIPv6 && len(n.IPv6) == 0 {
+		return false
+	}
+	return true
+}
+
+// holds reports whether n has an address in ipNet.
+func holds(n *Neighbour, ipNet *net.IPNet) bool {
+	for _, list := range [][]net.IP{n.IPv4, n.IPv6} {
+		for _, ip := range list {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterNeighbours returns the neighbours matching f, reusing the backing
+// array of neighbours.
+func FilterNeighbours(neighbours []Neighbour, f Filter) []Neighbour {
+	out := neighbours[:0]
+	for _, n := range neighbours {
+		if f.Match(&n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// sortKeys maps the sort keys to a less function.
+var sortKeys = map[string]func(a, b *Neighbour) bool{
+	"mac": func(a, b *Neighbour) bool { return bytes.Compare(a.MAC, b.MAC) < 0 },
+	"ip": func(a, b *Neighbour) bool {
+		return bytes.Compare(firstIP(a), firstIP(b)) < 0
+	},
+	"vendor": func(a, b *Neighbour) bool {
+		return strings.ToLower(oui.Lookup(a.MAC)) < strings.ToLower(oui.Lookup(b.MAC))
+	},
+	"first_seen": func(a, b *Neighbour) bool { return a.FirstSeen.Before(b.FirstSeen) },
+	"last_seen":  func(a, b *Neighbour) bool { return a.LastSeen.Before(b.LastSeen) },
+}
+
+// SortKeys returns the keys of Sort, in their documented order.
+func SortKeys() []string {
+	return []string{"mac", "ip", "vendor", "first_seen", "last_seen"}
+}
+
+// DefaultDescending reports whether key sorts in descending order by
+// default: times do (most recent first), the others do not.
+func DefaultDescending(key string) bool {
+	return key == "first_seen" || key == "last_seen"
+}
+
+// Sort sorts neighbours by key, keeping the order of equal entries. By ip,
+// entries without addresses sort last in both orders.
+func Sort(neighbours []Neighbour, key string, desc bool) error {
+	less, ok := sortKeys[key]
+	if !ok {
+		return fmt.Errorf("invalid sort %q (supported: %s)", key, strings.Join(SortKeys(), ", "))
+	}
+	sort.SliceStable(neighbours, func(i, j int) bool {
+		a, b := &neighbours[i], &neighbours[j]
+		if key == "ip" {
+			if noA, noB := firstIP(a) == nil, firstIP(b) == nil; noA || noB {
+				return !noA && noB
+			}
+		}
+		if desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+	return nil
+}
+
+// ValidSortKey reports whether key is one of SortKeys.
+func ValidSortKey(key string) bool {
+	return slices.Contains(SortKeys(), key)
+}
+
+// firstIP returns the sort key for ip: the first IPv4 address, else the
+// first IPv6 address, as 16 bytes; nil without addresses.
+func firstIP(n *Neighbour) []byte {
+	switch {
+	case len(n.IPv4) > 0:
+		return n.IPv4[0].To16()
+	case len(n.IPv6) > 0:
+		return n.IPv6[0].To16()
+	}
+	return nil
+}
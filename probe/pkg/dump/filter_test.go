@@ -0,0 +1,137 @@
+package dump
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func filterNeighbours() []Neighbour {
+	seen := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	return []Neighbour{
+		{
+			// DC:A6:32 = Raspberry Pi Trading Ltd
+			MAC:       net.HardwareAddr{0xdc, 0xa6, 0x32, 0, 0, 0x01},
+			IPv4:      []net.IP{net.ParseIP("192.168.1.20").To4()},
+			IPv6:      []net.IP{net.ParseIP("2001:db8::20")},
+			FirstSeen: seen.Add(-2 * time.Hour),
+			LastSeen:  seen.Add(-30 * time.Minute),
+		},
+		{
+			// 28:6F:B9 = Nokia Shanghai Bell Co., Ltd.
+			MAC:       net.HardwareAddr{0x28, 0x6f, 0xb9, 0, 0, 0x01},
+			IPv4:      []net.IP{net.ParseIP("192.168.1.1").To4()},
+			FirstSeen: seen.Add(-3 * time.Hour),
+			LastSeen:  seen,
+		},
+		{
+			MAC:       net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0, 0x02},
+			IPv4:      []net.IP{net.ParseIP("10.0.0.5").To4()},
+			FirstSeen: seen.Add(-time.Hour),
+			LastSeen:  seen.Add(-5 * time.Minute),
+		},
+		{
+			MAC:       net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0, 0x03},
+			FirstSeen: seen.Add(-time.Minute),
+			LastSeen:  seen.Add(-time.Minute),
+		},
+	}
+}
+
+func macs(neighbours []Neighbour) []string {
+	out := make([]string, len(neighbours))
+	for i, n := range neighbours {
+		out[i] = n.MAC.String()
+	}
+	return out
+}
+
+func mustIPNet(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	n, err := ParseIPNet(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestParseIPNet(t *testing.T) {
+	for s, want := range map[string]string{
+		"192.168.1.1":    "192.168.1.1/32",
+		"192.168.1.0/24": "192.168.1.0/24",
+		"2001:db8::1":    "2001:db8::1/128",
+		"2001:db8::/32":  "2001:db8::/32",
+	} {
+		if got := mustIPNet(t, s).String(); got != want {
+			t.Errorf("%s: got %s, want %s", s, got, want)
+		}
+	}
+	if _, err := ParseIPNet("192.168.1"); err == nil {
+		t.Error("expected error for an invalid address")
+	}
+}
+
+func TestFilterNeighbours(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"none", Filter{}, []string{"dc:a6:32:00:00:01", "28:6f:b9:00:00:01", "02:42:ac:11:00:02", "02:42:ac:11:00:03"}},
+		{"mac prefix", Filter{MAC: "02-42-AC"}, []string{"02:42:ac:11:00:02", "02:42:ac:11:00:03"}},
+		{"ip", Filter{IP: mustIPNet(t, "10.0.0.5")}, []string{"02:42:ac:11:00:02"}},
+		{"ipv6 subnet", Filter{Subnet: mustIPNet(t, "2001:db8::/32")}, []string{"dc:a6:32:00:00:01"}},
+		{"subnet", Filter{Subnet: mustIPNet(t, "192.168.1.0/24")}, []string{"dc:a6:32:00:00:01", "28:6f:b9:00:00:01"}},
+		{"vendor", Filter{Vendor: "raspberry"}, []string{"dc:a6:32:00:00:01"}},
+		{"seen since", Filter{SeenSince: now.Add(-10 * time.Minute)}, []string{"28:6f:b9:00:00:01", "02:42:ac:11:00:02", "02:42:ac:11:00:03"}},
+		{"has ipv6", Filter{HasIPv6: true}, []string{"dc:a6:32:00:00:01"}},
+		{"combined", Filter{Subnet: mustIPNet(t, "192.168.1.0/24"), SeenSince: now.Add(-10 * time.Minute)}, []string{"28:6f:b9:00:00:01"}},
+	}
+	for _, tt := range tests {
+		got := macs(FilterNeighbours(filterNeighbours(), tt.filter))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		key  string
+		desc bool
+		want []string
+	}{
+		{"mac", false, []string{"02:42:ac:11:00:02", "02:42:ac:11:00:03", "28:6f:b9:00:00:01", "dc:a6:32:00:00:01"}},
+		{"mac", true, []string{"dc:a6:32:00:00:01", "28:6f:b9:00:00:01", "02:42:ac:11:00:03", "02:42:ac:11:00:02"}},
+		// Entries without addresses sort last in both orders.
+		{"ip", false, []string{"02:42:ac:11:00:02", "28:6f:b9:00:00:01", "dc:a6:32:00:00:01", "02:42:ac:11:00:03"}},
+		{"ip", true, []string{"dc:a6:32:00:00:01", "28:6f:b9:00:00:01", "02:42:ac:11:00:02", "02:42:ac:11:00:03"}},
+		// Unknown vendors ("") sort first, keeping their order.
+		{"vendor", false, []string{"02:42:ac:11:00:02", "02:42:ac:11:00:03", "28:6f:b9:00:00:01", "dc:a6:32:00:00:01"}},
+		{"first_seen", true, []string{"02:42:ac:11:00:03", "02:42:ac:11:00:02", "dc:a6:32:00:00:01", "28:6f:b9:00:00:01"}},
+		{"last_seen", true, []string{"28:6f:b9:00:00:01", "02:42:ac:11:00:03", "02:42:ac:11:00:02", "dc:a6:32:00:00:01"}},
+	}
+	for _, tt := range tests {
+		neighbours := filterNeighbours()
+		if err := Sort(neighbours, tt.key, tt.desc); err != nil {
+			t.Fatal(err)
+		}
+		if got := macs(neighbours); !slices.Equal(got, tt.want) {
+			t.Errorf("%s desc=%v: got %v, want %v", tt.key, tt.desc, got, tt.want)
+		}
+	}
+	if err := Sort(filterNeighbours(), "hostname", false); err == nil {
+		t.Error("expected error for an unknown sort key")
+	}
+}
+
+func TestDefaultDescending(t *testing.T) {
+	for _, key := range SortKeys() {
+		want := key == "first_seen" || key == "last_seen"
+		if got := DefaultDescending(key); got != want {
+			t.Errorf("%s: got %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dump
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// Forget deletes the entries for macs from m and returns how many were
+// present.
+func Forget(m *ebpf.Map, macs []net.HardwareAddr) (int, error) {
+	deleted := 0
+	for _, mac := range macs {
+		if len(mac) != 6 {
+			return deleted, fmt.Errorf("invalid MAC %s", mac)
+		}
+		var key MacKey
+		copy(key.Addr[:], mac)
+		err := m.Delete(&key)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("deleting %s: %w", mac, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Flush deletes every entry from m, or only those not seen for olderThan
+// if it is positive, and returns the number of deleted entries.
+func Flush(m *ebpf.Map, olderThan time.Duration) (int, error) {
+	var cutoff uint64
+	if olderThan > 0 {
+		now := monoNow()
+		if now > int64(olderThan) {
+			cutoff = uint64(now - int64(olderThan))
+		}
+	}
+
+	// Collect first: deleting while iterating a hash map restarts the walk.
+	var (
+		key  MacKey
+		val  NeighbourEntry
+		keys []MacKey
+	)
+	iter := m.Iterate()
+	for iter.Next(&key, &val) {
+		if olderThan <= 0 || val.LastSeen < cutoff {
+			keys = append(keys, key)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return 0, fmt.Errorf("iterating map: %w", err)
+	}
+
+	deleted := 0
+	for i := range keys {
+		err := m.Delete(&keys[i])
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("deleting entry: %w", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ParseMACs parses MAC address arguments.
+func ParseMACs(args []string) ([]net.HardwareAddr, error) {
+	macs := make([]net.HardwareAddr, 0, len(args))
+	for _, a := range args {
+		mac, err := net.ParseMAC(a)
+		if err != nil {
+			return nil, err
+		}
+		if len(mac) != 6 {
+			return nil, fmt.Errorf("invalid MAC %q: not an EUI-48 address", a)
+		}
+		macs = append(macs, mac)
+	}
+	return macs, nil
+}
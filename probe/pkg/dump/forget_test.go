@@ -0,0 +1,80 @@
+package dump
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseMACs(t *testing.T) {
+	macs, err := ParseMACs([]string{"02:00:00:00:00:01", "02-00-00-00-00-02"})
+	if err != nil {
+		t.Fatalf("ParseMACs: %v", err)
+	}
+	if len(macs) != 2 || macs[1].String() != "02:00:00:00:00:02" {
+		t.Errorf("unexpected MACs: %v", macs)
+	}
+
+	for _, bad := range []string{"nope", "02:00:00:00:00:00:00:01"} {
+		if _, err := ParseMACs([]string{bad}); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestForget(t *testing.T) {
+	m := filledMap(t, 4)
+
+	macs := []net.HardwareAddr{
+		{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		{0x02, 0x00, 0x00, 0x00, 0x00, 0x03},
+		{0x02, 0x00, 0x00, 0x00, 0x00, 0x09}, // not present
+	}
+	n, err := Forget(m, macs)
+	if err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 deleted, got %d", n)
+	}
+
+	left, err := ReadNeighbours(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 {
+		t.Fatalf("expected 2 entries left, got %d", len(left))
+	}
+	for _, nb := range left {
+		if nb.MAC.String() == "02:00:00:00:00:01" || nb.MAC.String() == "02:00:00:00:00:03" {
+			t.Errorf("%s not forgotten", nb.MAC)
+		}
+	}
+}
+
+func TestFlushOlderThan(t *testing.T) {
+	m := filledMap(t, 4)
+
+	// filledMap entries were last seen shortly after boot; refresh one.
+	key := MacKey{Addr: [6]uint8{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}}
+	val := NeighbourEntry{FirstSeen: 1, LastSeen: uint64(monoNow())}
+	if err := m.Put(&key, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Flush(m, time.Minute)
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 stale entries deleted, got %d", n)
+	}
+
+	n, err = Flush(m, 0)
+	if err != nil {
+		t.Fatalf("Flush all: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected remaining entry deleted, got %d", n)
+	}
+}
@@ -0,0 +1,279 @@
+package dump
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"gopkg.in/yaml.v3"
+)
+
+// Column is one field of a neighbour in the columnar formats.
+type Column struct {
+	Name string
+	// value returns a string, a []string or a time.Time.
+	value func(n *Neighbour) any
+}
+
+// columns are the available columns, in their default order.
+var columns = []Column{
+	{"mac", func(n *Neighbour) any { return n.MAC.String() }},
+	{"vendor", func(n *Neighbour) any { return oui.Lookup(n.MAC) }},
+	{"ipv4", func(n *Neighbour) any { return ipStrings(n.IPv4) }},
+	{"ipv6", func(n *Neighbour) any { return ipStrings(n.IPv6) }},
+	{"first_seen", func(n *Neighbour) any { return n.FirstSeen }},
+	{"last_seen", func(n *Neighbour) any { return n.LastSeen }},
+}
+
+// InterfaceColumn is the interface a neighbour was seen on. It is not a
+// default column: dump adds it when listing several interfaces.
+var InterfaceColumn = Column{"interface", func(n *Neighbour) any { return n.Interface }}
+
+// ColumnNames returns the names of the available columns, in their
+// default order.
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// ParseColumns returns the named columns, in the given order; nil, which
+// formats take as their default columns, if names is empty.
+func ParseColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	cols := make([]Column, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == InterfaceColumn.Name {
+			cols = append(cols, InterfaceColumn)
+			continue
+		}
+		i := columnIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q (supported: %s, %s)", name, strings.Join(ColumnNames(), ", "), InterfaceColumn.Name)
+		}
+		cols = append(cols, columns[i])
+	}
+	return cols, nil
+}
+
+func columnIndex(name string) int {
+	for i, c := range columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func ipStrings(ips []net.IP) []string {
+	strs := make([]string, len(ips))
+	for i, ip := range ips {
+		strs[i] = ip.String()
+	}
+	return strs
+}
+
+// Format writes a neighbour table in one output format. Write takes the
+// default columns if cols is nil.
+type Format struct {
+	Name string
+	// Ext is the file extension used when exporting; formats without one
+	// (table) are only printed.
+	Ext   string
+	Write func(w io.Writer, neighbours []Neighbour, cols []Column) error
+}
+
+var formats = map[string]Format{}
+
+// RegisterFormat adds f to the formats available to dump and export. It
+// panics if the name is already registered.
+func RegisterFormat(f Format) {
+	if _, ok := formats[f.Name]; ok {
+		panic("dump: format " + f.Name + " registered twice")
+	}
+	formats[f.Name] = f
+}
+
+// LookupFormat returns the format registered under name.
+func LookupFormat(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// FormatNames returns the registered format names, sorted.
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterFormat(Format{Name: "table", Write: writeTable})
+	RegisterFormat(Format{Name: "csv", Ext: ".csv", Write: writeCSV})
+	RegisterFormat(Format{Name: "ndjson", Ext: ".ndjson", Write: writeNDJSON})
+	RegisterFormat(Format{Name: "yaml", Ext: ".yaml", Write: writeYAML})
+}
+
+// text renders a column value as one string: lists joined by sep, times
+// in layout (empty if zero).
+func text(v any, sep, layout string) string {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		if layout == time.RFC3339 {
+			v = v.UTC()
+		}
+		return v.Format(layout)
+	default:
+		return v.(string)
+	}
+}
+
+// writeTable is FormatTable without columns, and a plain table of the
+// selected ones otherwise.
+func writeTable(w io.Writer, neighbours []Neighbour, cols []Column) error {
+	if len(cols) == 0 {
+		FormatTable(w, neighbours)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(cols))
+	rule := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = strings.ToUpper(strings.ReplaceAll(c.Name, "_", " "))
+		rule[i] = strings.Repeat("-", len(header[i]))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	fmt.Fprintln(tw, strings.Join(rule, "\t"))
+	row := make([]string, len(cols))
+	for i := range neighbours {
+		for j, c := range cols {
+			row[j] = text(c.value(&neighbours[i]), ", ", "2006-01-02 15:04:05")
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeCSV writes a header line and one record per neighbour; addresses
+// are space-separated within their field.
+func writeCSV(w io.Writer, neighbours []Neighbour, cols []Column) error {
+	cols = orDefault(cols)
+	cw := csv.NewWriter(w)
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		row[i] = c.Name
+	}
+	cw.Write(row)
+	for i := range neighbours {
+		for j, c := range cols {
+			row[j] = text(c.value(&neighbours[i]), " ", time.RFC3339)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeNDJSON writes one JSON object per neighbour, keys in column order.
+func writeNDJSON(w io.Writer, neighbours []Neighbour, cols []Column) error {
+	cols = orDefault(cols)
+	var b []byte
+	for i := range neighbours {
+		b = append(b[:0], '{')
+		for j, c := range cols {
+			if j > 0 {
+				b = append(b, ',')
+			}
+			k, _ := json.Marshal(c.Name)
+			v, err := json.Marshal(value(c, &neighbours[i]))
+			if err != nil {
+				return err
+			}
+			b = append(append(append(b, k...), ':'), v...)
+		}
+		b = append(b, '}', '\n')
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYAML writes a sequence of mappings, keys in column order.
+func writeYAML(w io.Writer, neighbours []Neighbour, cols []Column) error {
+	cols = orDefault(cols)
+	doc := &yaml.Node{Kind: yaml.SequenceNode}
+	if len(neighbours) == 0 {
+		doc.Style = yaml.FlowStyle
+	}
+	for i := range neighbours {
+		m := &yaml.Node{Kind: yaml.MappingNode}
+		for _, c := range cols {
+			var v yaml.Node
+			if err := v.Encode(value(c, &neighbours[i])); err != nil {
+				return err
+			}
+			if v.Kind == yaml.SequenceNode && len(v.Content) == 0 {
+				v.Style = yaml.FlowStyle
+			}
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: c.Name}, &v)
+		}
+		doc.Content = append(doc.Content, m)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// value returns the structured value of c: times as RFC 3339 UTC strings
+// (empty if zero), lists never nil.
+func value(c Column, n *Neighbour) any {
+	switch v := c.value(n).(type) {
+	case time.Time:
+		return text(v, "", time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// WithInterface returns cols, or the default columns if nil, with
+// InterfaceColumn first unless it is already selected.
+func WithInterface(cols []Column) []Column {
+	cols = orDefault(cols)
+	for _, c := range cols {
+		if c.Name == InterfaceColumn.Name {
+			return cols
+		}
+	}
+	return append([]Column{InterfaceColumn}, cols...)
+}
+
+func orDefault(cols []Column) []Column {
+	if len(cols) == 0 {
+		return columns
+	}
+	return cols
+}
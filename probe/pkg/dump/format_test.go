@@ -0,0 +1,152 @@
+package dump
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func formatNeighbours() []Neighbour {
+	seen := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	return []Neighbour{
+		{
+			// 28:6F:B9 = Nokia Shanghai Bell Co., Ltd.
+			MAC:       net.HardwareAddr{0x28, 0x6f, 0xb9, 0x11, 0x00, 0x01},
+			IPv4:      []net.IP{net.ParseIP("192.168.1.1").To4(), net.ParseIP("10.0.0.1").To4()},
+			IPv6:      []net.IP{net.ParseIP("fe80::1")},
+			FirstSeen: seen.Add(-time.Hour),
+			LastSeen:  seen,
+		},
+		{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}},
+	}
+}
+
+func writeFormat(t *testing.T, name string, cols []string) string {
+	t.Helper()
+	f, ok := LookupFormat(name)
+	if !ok {
+		t.Fatalf("format %s not registered", name)
+	}
+	c, err := ParseColumns(cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := f.Write(&buf, formatNeighbours(), c); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestFormatNames(t *testing.T) {
+	want := []string{"csv", "ndjson", "table", "yaml"}
+	if got := FormatNames(); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	cols, err := ParseColumns([]string{"last_seen", " mac"})
+	if err != nil || len(cols) != 2 || cols[0].Name != "last_seen" || cols[1].Name != "mac" {
+		t.Errorf("got %v, %v", cols, err)
+	}
+	if cols, err := ParseColumns(nil); cols != nil || err != nil {
+		t.Errorf("expected default columns, got %v, %v", cols, err)
+	}
+	if _, err := ParseColumns([]string{"mac", "hostname"}); err == nil || !strings.Contains(err.Error(), `"hostname"`) {
+		t.Errorf("expected unknown column error, got %v", err)
+	}
+}
+
+func TestInterfaceColumn(t *testing.T) {
+	cols, err := ParseColumns([]string{"interface", "mac"})
+	if err != nil || len(cols) != 2 || cols[0].Name != "interface" {
+		t.Fatalf("got %v, %v", cols, err)
+	}
+	if got := WithInterface(cols); len(got) != 2 {
+		t.Errorf("interface column added twice: %v", got)
+	}
+	got := WithInterface(nil)
+	if len(got) != len(columns)+1 || got[0].Name != "interface" || got[1].Name != "mac" {
+		t.Errorf("expected the interface before the default columns, got %v", got)
+	}
+
+	n := formatNeighbours()[:1]
+	n[0].Interface = "eth0"
+	var buf strings.Builder
+	if err := writeCSV(&buf, n, WithInterface(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "interface,mac,") || !strings.Contains(buf.String(), "\neth0,28:6f:b9:11:00:01,") {
+		t.Errorf("unexpected csv:\n%s", buf.String())
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	want := `mac,vendor,ipv4,ipv6,first_seen,last_seen
+28:6f:b9:11:00:01,"Nokia Shanghai Bell Co., Ltd.",192.168.1.1 10.0.0.1,fe80::1,2026-10-18T11:00:00Z,2026-10-18T12:00:00Z
+02:00:00:00:00:02,,,,,
+`
+	if got := writeFormat(t, "csv", nil); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	want = "ipv4,mac\n192.168.1.1 10.0.0.1,28:6f:b9:11:00:01\n,02:00:00:00:00:02\n"
+	if got := writeFormat(t, "csv", []string{"ipv4", "mac"}); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatNDJSON(t *testing.T) {
+	want := `{"mac":"28:6f:b9:11:00:01","vendor":"Nokia Shanghai Bell Co., Ltd.","ipv4":["192.168.1.1","10.0.0.1"],"ipv6":["fe80::1"],"first_seen":"2026-10-18T11:00:00Z","last_seen":"2026-10-18T12:00:00Z"}
+{"mac":"02:00:00:00:00:02","vendor":"","ipv4":[],"ipv6":[],"first_seen":"","last_seen":""}
+`
+	if got := writeFormat(t, "ndjson", nil); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	want = "{\"last_seen\":\"2026-10-18T12:00:00Z\",\"mac\":\"28:6f:b9:11:00:01\"}\n{\"last_seen\":\"\",\"mac\":\"02:00:00:00:00:02\"}\n"
+	if got := writeFormat(t, "ndjson", []string{"last_seen", "mac"}); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatYAML(t *testing.T) {
+	want := `- mac: 28:6f:b9:11:00:01
+  ipv4:
+    - 192.168.1.1
+    - 10.0.0.1
+- mac: "02:00:00:00:00:02"
+  ipv4: []
+`
+	if got := writeFormat(t, "yaml", []string{"mac", "ipv4"}); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	f, _ := LookupFormat("yaml")
+	var buf strings.Builder
+	if err := f.Write(&buf, nil, nil); err != nil || buf.String() != "[]\n" {
+		t.Errorf("empty table: got %q, %v", buf.String(), err)
+	}
+}
+
+func TestFormatTableColumns(t *testing.T) {
+	out := writeFormat(t, "table", []string{"mac", "last_seen"})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header, rule and 2 rows, got:\n%s", out)
+	}
+	if !strings.HasPrefix(lines[0], "MAC") || !strings.Contains(lines[0], "LAST SEEN") || strings.Contains(lines[0], "IPv4") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "28:6f:b9:11:00:01") || strings.Contains(lines[2], "Nokia") {
+		t.Errorf("unexpected row %q", lines[2])
+	}
+
+	// Without columns it is FormatTable.
+	var buf strings.Builder
+	FormatTable(&buf, formatNeighbours())
+	if got := writeFormat(t, "table", nil); got != buf.String() {
+		t.Errorf("default table differs from FormatTable:\n%s", got)
+	}
+}
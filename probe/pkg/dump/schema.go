@@ -0,0 +1,119 @@
+package dump
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// SchemaVersion is the layout version of the neighbours map written by
+// this build. Bump it whenever struct mac_key or struct neighbour_entry
+// change, and teach ReadMap to migrate or refuse the previous version.
+const SchemaVersion = 1
+
+// metaMagic identifies an l2radar schema map ("L2RM").
+const metaMagic = 0x4c32524d
+
+// macKeySize is sizeof(struct mac_key).
+const macKeySize = 8
+
+// ErrIncompatibleSchema is returned when a map was written with a layout
+// this build cannot decode.
+var ErrIncompatibleSchema = errors.New("incompatible map schema")
+
+// Meta is the value of the single-entry schema map pinned next to each
+// neighbours map at <pin-path>/meta-<iface>.
+type Meta struct {
+	Magic     uint32
+	Version   uint32
+	KeySize   uint32
+	ValueSize uint32
+}
+
+// MetaPinPath returns the schema map pin path for an interface.
+func MetaPinPath(pinBase, iface string) string {
+	return filepath.Join(pinBase, fmt.Sprintf("meta-%s", iface))
+}
+
+// metaPathFor derives the schema map pin path from a neighbours map pin
+// path as returned by PinPath.
+func metaPathFor(mapPinPath string) string {
+	dir, base := filepath.Split(mapPinPath)
+	return filepath.Join(dir, "meta-"+strings.TrimPrefix(base, "neigh-"))
+}
+
+// currentMeta describes the layout written by this build.
+func currentMeta() Meta {
+	return Meta{
+		Magic:     metaMagic,
+		Version:   SchemaVersion,
+		KeySize:   macKeySize,
+		ValueSize: neighbourEntrySize,
+	}
+}
+
+// NewMetaMap creates an unpinned schema map holding this build's schema
+// version and layout. The caller pins it with MetaPinPath.
+func NewMetaMap() (*ebpf.Map, error) {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "l2radar_meta",
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  16,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating schema map: %w", err)
+	}
+	if err := m.Put(uint32(0), currentMeta()); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("writing schema map: %w", err)
+	}
+	return m, nil
+}
+
+// ReadMeta reads the schema map pinned at path.
+func ReadMeta(path string) (Meta, error) {
+	var meta Meta
+	m, err := ebpf.LoadPinnedMap(path, &ebpf.LoadPinOptions{ReadOnly: true})
+	if err != nil {
+		return meta, fmt.Errorf("opening schema map %s: %w", path, err)
+	}
+	defer m.Close()
+
+	if err := m.Lookup(uint32(0), &meta); err != nil {
+		return meta, fmt.Errorf("reading schema map %s: %w", path, err)
+	}
+	return meta, nil
+}
+
+// checkMeta verifies that a map described by meta can be decoded by this
+// build. Version 1 is the first versioned layout; maps pinned by older
+// probes have no schema map and are accepted if checkLayout passes.
+func checkMeta(meta Meta) error {
+	if meta.Magic != metaMagic {
+		return fmt.Errorf("%w: bad magic %#x", ErrIncompatibleSchema, meta.Magic)
+	}
+	if meta.Version > SchemaVersion {
+		return fmt.Errorf("%w: version %d is newer than supported version %d (upgrade l2radar)",
+			ErrIncompatibleSchema, meta.Version, SchemaVersion)
+	}
+	if meta.KeySize != macKeySize || meta.ValueSize != neighbourEntrySize {
+		return fmt.Errorf("%w: version %d declares key/value size %d/%d, want %d/%d",
+			ErrIncompatibleSchema, meta.Version, meta.KeySize, meta.ValueSize, macKeySize, neighbourEntrySize)
+	}
+	return nil
+}
+
+// checkLayout verifies that m's key and value sizes match the structs
+// this build decodes.
+func checkLayout(m *ebpf.Map) error {
+	if m.KeySize() != macKeySize || m.ValueSize() != neighbourEntrySize {
+		return fmt.Errorf("%w: map key/value size %d/%d, want %d/%d",
+			ErrIncompatibleSchema, m.KeySize(), m.ValueSize(), macKeySize, neighbourEntrySize)
+	}
+	return nil
+}
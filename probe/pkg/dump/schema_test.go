@@ -0,0 +1,142 @@
+package dump
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+func TestMetaPinPath(t *testing.T) {
+	got := MetaPinPath("/sys/fs/bpf/l2radar", "eth0")
+	if got != "/sys/fs/bpf/l2radar/meta-eth0" {
+		t.Errorf("unexpected meta pin path: %s", got)
+	}
+	if derived := metaPathFor(PinPath("/sys/fs/bpf/l2radar", "eth0")); derived != got {
+		t.Errorf("metaPathFor: got %s, want %s", derived, got)
+	}
+}
+
+func TestCheckMeta(t *testing.T) {
+	if err := checkMeta(currentMeta()); err != nil {
+		t.Fatalf("current meta rejected: %v", err)
+	}
+
+	newer := currentMeta()
+	newer.Version = SchemaVersion + 1
+	bad := currentMeta()
+	bad.Magic = 0
+	resized := currentMeta()
+	resized.ValueSize += 8
+
+	for name, meta := range map[string]Meta{"newer": newer, "magic": bad, "size": resized} {
+		if err := checkMeta(meta); !errors.Is(err, ErrIncompatibleSchema) {
+			t.Errorf("%s: expected ErrIncompatibleSchema, got %v", name, err)
+		}
+	}
+}
+
+// pinnedMaps creates a neighbours map and optionally a schema map pinned
+// under a temporary bpffs directory. It skips if bpffs is unavailable.
+func pinnedMaps(t *testing.T, meta *Meta) string {
+	t.Helper()
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Skipf("memlock: %v", err)
+	}
+	dir, err := os.MkdirTemp("/sys/fs/bpf", "l2radar-test-")
+	if err != nil {
+		t.Skipf("bpffs not available: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    macKeySize,
+		ValueSize:  neighbourEntrySize,
+		MaxEntries: 16,
+	})
+	if err != nil {
+		t.Skipf("creating map: %v", err)
+	}
+	defer m.Close()
+
+	key := MacKey{Addr: [6]uint8{0x02, 0, 0, 0, 0, 1}}
+	val := NeighbourEntry{Ipv4Count: 1, Ipv4: [4][4]uint8{{192, 0, 2, 1}}}
+	if err := m.Put(&key, &val); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := m.Pin(PinPath(dir, "test0")); err != nil {
+		t.Fatalf("pin: %v", err)
+	}
+
+	if meta != nil {
+		mm, err := NewMetaMap()
+		if err != nil {
+			t.Fatalf("NewMetaMap: %v", err)
+		}
+		defer mm.Close()
+		if err := mm.Put(uint32(0), *meta); err != nil {
+			t.Fatalf("put meta: %v", err)
+		}
+		if err := mm.Pin(MetaPinPath(dir, "test0")); err != nil {
+			t.Fatalf("pin meta: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestReadMapSchema(t *testing.T) {
+	current := currentMeta()
+	newer := currentMeta()
+	newer.Version = SchemaVersion + 1
+
+	for _, tc := range []struct {
+		name    string
+		meta    *Meta
+		wantErr bool
+	}{
+		{"current", &current, false},
+		{"legacy without schema map", nil, false},
+		{"newer", &newer, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := pinnedMaps(t, tc.meta)
+			neighbours, err := ReadMap(filepath.Join(dir, "neigh-test0"))
+			if tc.wantErr {
+				if !errors.Is(err, ErrIncompatibleSchema) {
+					t.Fatalf("expected ErrIncompatibleSchema, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadMap: %v", err)
+			}
+			if len(neighbours) != 1 || neighbours[0].IPv4String() != "192.0.2.1" {
+				t.Errorf("unexpected neighbours: %+v", neighbours)
+			}
+		})
+	}
+}
+
+func TestReadNeighboursRejectsWrongLayout(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Skipf("memlock: %v", err)
+	}
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    macKeySize,
+		ValueSize:  neighbourEntrySize + 8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Skipf("creating map: %v", err)
+	}
+	defer m.Close()
+
+	if _, err := ReadNeighbours(m, nil); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("expected ErrIncompatibleSchema, got %v", err)
+	}
+}
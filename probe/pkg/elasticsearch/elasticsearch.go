@@ -0,0 +1,293 @@
+// Package elasticsearch indexes neighbour documents in Elasticsearch (or
+// OpenSearch) with the bulk API. Each export cycle indexes the entries
+// added or changed since the previous one; a document's ID is derived from
+// the probe host, interface and MAC, so it always holds the latest state.
+//
+// It is configured as a sink of type "elasticsearch" in the probe
+// configuration file.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// Type is the sink type in the configuration file.
+const Type = "elasticsearch"
+
+// Defaults; see also the sink package.
+const (
+	DefaultIndex     = "l2radar-neighbours"
+	DefaultBatchSize = 1000
+)
+
+// Options are the type-specific keys of an elasticsearch sink.
+type Options struct {
+	// URL is the base URL of the cluster, e.g. https://es:9200.
+	URL   string `yaml:"url"`
+	Index string `yaml:"index,omitempty"`
+	// Username and Password, or APIKey (the base64 "id:key" credential),
+	// authenticate requests.
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	APIKey       string `yaml:"api_key,omitempty"`
+	APIKeyFile   string `yaml:"api_key_file,omitempty"`
+	// BatchSize is the maximum number of documents per bulk request.
+	BatchSize int           `yaml:"batch_size,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+}
+
+// ParseOptions decodes and validates the options of an elasticsearch sink
+// and fills defaults. Password and API key files are read into Password
+// and APIKey.
+func ParseOptions(s config.Sink) (Options, error) {
+	var o Options
+	if err := s.Decode(&o); err != nil {
+		return o, err
+	}
+	if err := sink.CheckHTTPURL("url", o.URL); err != nil {
+		return o, err
+	}
+	var err error
+	if o.Password, err = sink.ReadSecret(o.Password, o.PasswordFile, "password"); err != nil {
+		return o, err
+	}
+	if o.APIKey, err = sink.ReadSecret(o.APIKey, o.APIKeyFile, "api_key"); err != nil {
+		return o, err
+	}
+	if o.APIKey != "" && (o.Username != "" || o.Password != "") {
+		return o, fmt.Errorf("api_key and username/password are mutually exclusive")
+	}
+	if o.Index == "" {
+		o.Index = DefaultIndex
+	}
+	if o.Index != strings.ToLower(o.Index) || strings.ContainsAny(o.Index, `\/*?"<>| ,#:`) {
+		return o, fmt.Errorf("invalid index name %q", o.Index)
+	}
+	if o.BatchSize < 0 || o.Timeout < 0 {
+		return o, fmt.Errorf("negative batch_size or timeout")
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.Timeout == 0 {
+		o.Timeout = sink.DefaultTimeout
+	}
+	return o, nil
+}
+
+// Document is an indexed neighbour.
+type Document struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Host      string    `json:"host"`
+	Interface string    `json:"interface"`
+	export.NeighbourJSON
+	Vendor string `json:"vendor,omitempty"`
+}
+
+// ID returns the document ID: <host>-<interface>-<mac>.
+func (d *Document) ID() string {
+	return d.Host + "-" + d.Interface + "-" + d.MAC
+}
+
+// Sink indexes the neighbours of the selected interfaces from Run.
+type Sink struct {
+	opts    Options
+	ifaces  []string // all if empty
+	host    string
+	bulkURL string
+	hc      *http.Client
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	tables  map[string]map[string]export.NeighbourJSON // by interface
+	batcher *sink.Batcher[Document]
+}
+
+var _ sink.Sink = (*Sink)(nil)
+
+func init() {
+	sink.Register(Type, func(s config.Sink, logger *slog.Logger) (sink.Sink, error) {
+		return New(s, logger)
+	})
+}
+
+// New returns a sink for the elasticsearch entry s. Nothing is indexed
+// until Run.
+func New(s config.Sink, logger *slog.Logger) (*Sink, error) {
+	o, err := ParseOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	host, _ := os.Hostname()
+	sk := &Sink{
+		opts:    o,
+		ifaces:  s.Interfaces,
+		host:    host,
+		bulkURL: strings.TrimSuffix(o.URL, "/") + "/_bulk",
+		hc:      &http.Client{Timeout: o.Timeout},
+		logger:  logger,
+		tables:  make(map[string]map[string]export.NeighbourJSON),
+	}
+	sk.batcher = sink.NewBatcher(sink.BatcherOptions{
+		Name:          Type,
+		Items:         "documents",
+		Target:        []any{"url", o.URL},
+		BatchSize:     o.BatchSize,
+		RetryInterval: sink.DefaultRetryInterval,
+		Logger:        logger,
+	}, sk.bulk)
+	return sk, nil
+}
+
+// Observe queues a document per entry added or changed since the
+// interface's previous snapshot (all entries on the first one).
+func (s *Sink) Observe(snap daemon.Snapshot) {
+	if !sink.Selects(s.ifaces, snap.Interface) {
+		return
+	}
+	cur := events.Table(snap.Neighbours)
+	s.mu.Lock()
+	prev := s.tables[snap.Interface]
+	s.tables[snap.Interface] = cur
+	s.mu.Unlock()
+
+	added, updated, seen, _ := events.Diff(prev, cur)
+	for _, mac := range slices.Sorted(maps.Keys(seen)) {
+		updated = append(updated, cur[mac])
+	}
+	vendors := make(map[string]string, len(snap.Neighbours))
+	for _, n := range snap.Neighbours {
+		vendors[n.MAC.String()] = oui.Lookup(n.MAC)
+	}
+	docs := make([]Document, 0, len(added)+len(updated))
+	for _, n := range append(added, updated...) {
+		docs = append(docs, Document{
+			Timestamp:     snap.Time.UTC(),
+			Host:          s.host,
+			Interface:     snap.Interface,
+			NeighbourJSON: n,
+			Vendor:        vendors[n.MAC],
+		})
+	}
+	s.batcher.Push(docs...)
+}
+
+// Detached forgets the interface's table; its documents are kept.
+func (s *Sink) Detached(iface string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tables, iface)
+}
+
+// Run indexes queued documents until ctx is cancelled, then indexes what
+// is left. Failed requests are retried every 10s; documents wait
+// meanwhile.
+func (s *Sink) Run(ctx context.Context) {
+	defer s.hc.CloseIdleConnections()
+	s.batcher.Run(ctx)
+}
+
+// bulkResponse is the part of a bulk response that is checked.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk indexes docs in one request. It returns the documents worth
+// retrying: all of them on network errors, 429 and 5xx responses and
+// responses that cannot be decoded, otherwise those whose item failed
+// with such a status. Documents the cluster rejects are dropped.
+func (s *Sink) bulk(ctx context.Context, docs []Document) (retry []Document, err error) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for i := range docs {
+		action := map[string]any{"index": map[string]string{"_index": s.opts.Index, "_id": docs[i].ID()}}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(&docs[i]); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.bulkURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("User-Agent", "l2radar")
+	switch {
+	case s.opts.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+s.opts.APIKey)
+	case s.opts.Username != "":
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+
+	resp, err := s.hc.Do(req)
+	if err != nil {
+		return docs, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err = fmt.Errorf("POST %s: %s: %s", s.bulkURL, resp.Status, bytes.TrimSpace(msg))
+		if retryable(resp.StatusCode) {
+			return docs, err
+		}
+		return nil, err
+	}
+
+	// Documents are indexed by ID, so sending them again is harmless when
+	// the outcome of each item is unknown.
+	var br bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		return docs, fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if !br.Errors {
+		return nil, nil
+	}
+	for i, item := range br.Items {
+		if i >= len(docs) {
+			break
+		}
+		for _, r := range item {
+			switch {
+			case r.Status/100 == 2:
+			case retryable(r.Status):
+				retry = append(retry, docs[i])
+			default:
+				s.logger.Error("elasticsearch: document rejected", "id", docs[i].ID(), "status", r.Status, "error", string(r.Error))
+			}
+		}
+	}
+	return retry, nil
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
@@ -0,0 +1,173 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// cluster is a fake bulk endpoint. It records the indexed documents by ID
+// and fails items whose ID is in reject with the given status.
+type cluster struct {
+	mu     sync.Mutex
+	auth   []string
+	docs   map[string]map[string]any
+	reject map[string]int
+}
+
+func (c *cluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.auth = append(c.auth, r.Header.Get("Authorization"))
+	var items []string
+	errors := false
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		var action struct {
+			Index struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			} `json:"index"`
+		}
+		json.Unmarshal(sc.Bytes(), &action)
+		sc.Scan()
+		var doc map[string]any
+		json.Unmarshal(sc.Bytes(), &doc)
+		id := action.Index.ID
+		if status, ok := c.reject[id]; ok {
+			delete(c.reject, id)
+			errors = true
+			items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":%d,"error":{"type":"rejected"}}}`, id, status))
+			continue
+		}
+		doc["_index"] = action.Index.Index
+		c.docs[id] = doc
+		items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":201}}`, id))
+	}
+	fmt.Fprintf(w, `{"took":1,"errors":%t,"items":[%s]}`, errors, strings.Join(items, ","))
+}
+
+func TestIndex(t *testing.T) {
+	c := &cluster{docs: make(map[string]map[string]any), reject: map[string]int{
+		"probe-1-eth0-02:00:00:00:00:02": http.StatusTooManyRequests,
+		"probe-1-eth0-02:00:00:00:00:03": http.StatusBadRequest,
+	}}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s, err := New(config.Sink{Type: Type, Interfaces: []string{"eth0"}, Options: map[string]any{
+		"url": srv.URL, "index": "neigh", "api_key": "a2V5",
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.host = "probe-1"
+
+	t0 := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: t0, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", t0.Add(-time.Hour), t0, "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", t0.Add(-time.Hour), t0),
+		testutil.Neighbour("02:00:00:00:00:03", t0.Add(-time.Hour), t0),
+	}})
+	s.Observe(daemon.Snapshot{Interface: "eth1", Time: t0, Neighbours: []dump.Neighbour{testutil.Neighbour("02:00:00:00:00:04", t0.Add(-time.Hour), t0)}})
+
+	// The 429 item is queued again, the 400 one dropped.
+	if s.batcher.Send(context.Background()) || s.batcher.Len() != 1 {
+		t.Fatalf("expected 1 document queued for retry, got %d", s.batcher.Len())
+	}
+
+	// Unchanged entries are not indexed again.
+	t1 := t0.Add(time.Minute)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: t1, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", t0.Add(-time.Hour), t0, "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", t0.Add(-time.Hour), t0),
+		testutil.Neighbour("02:00:00:00:00:03", t1.Add(-time.Hour), t1),
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx)
+
+	if len(c.docs) != 3 {
+		t.Fatalf("expected 3 documents, got %d: %v", len(c.docs), c.docs)
+	}
+	d := c.docs["probe-1-eth0-02:00:00:00:00:01"]
+	want := map[string]any{
+		"_index": "neigh", "@timestamp": "2026-10-18T12:00:00Z", "host": "probe-1", "interface": "eth0",
+		"mac": "02:00:00:00:00:01", "ipv4": []any{"10.0.0.1"}, "ipv6": []any{},
+		"first_seen": "2026-10-18T11:00:00Z", "last_seen": "2026-10-18T12:00:00Z",
+	}
+	if fmt.Sprint(d) != fmt.Sprint(want) {
+		t.Errorf("got  %v\nwant %v", d, want)
+	}
+	if got := c.docs["probe-1-eth0-02:00:00:00:00:03"]["last_seen"]; got != "2026-10-18T12:01:00Z" {
+		t.Errorf("updated entry not indexed, last_seen %v", got)
+	}
+	for _, a := range c.auth {
+		if a != "ApiKey a2V5" {
+			t.Errorf("Authorization = %q", a)
+		}
+	}
+}
+
+func TestBulkResponseNotDecoded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"took":1,"errors":`))
+	}))
+	defer srv.Close()
+
+	s, err := New(config.Sink{Type: Type, Options: map[string]any{"url": srv.URL}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: t0, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", t0.Add(-time.Hour), t0),
+		testutil.Neighbour("02:00:00:00:00:02", t0.Add(-time.Hour), t0),
+	}})
+	// The outcome of the items is unknown: both are queued again.
+	if s.batcher.Send(context.Background()) || s.batcher.Len() != 2 {
+		t.Fatalf("expected 2 documents queued for retry, got %d", s.batcher.Len())
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(config.Sink{Type: Type, Options: map[string]any{"url": "https://es:9200"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Index != DefaultIndex || o.BatchSize != DefaultBatchSize || o.Timeout != sink.DefaultTimeout {
+		t.Errorf("defaults not applied: %+v", o)
+	}
+
+	bad := map[string]map[string]any{
+		"no url":         {},
+		"bad url":        {"url": "es:9200"},
+		"upper index":    {"url": "https://es:9200", "index": "L2radar"},
+		"bad index":      {"url": "https://es:9200", "index": "a,b"},
+		"key and user":   {"url": "https://es:9200", "api_key": "k", "username": "u"},
+		"missing secret": {"url": "https://es:9200", "api_key_file": "/nonexistent"},
+		"unknown key":    {"url": "https://es:9200", "pipeline": "p"},
+	}
+	for name, opts := range bad {
+		if _, err := ParseOptions(config.Sink{Type: Type, Options: opts}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// Neighbour change types.
+const (
+	ChangeNewMAC     = "new_mac"
+	ChangeNewIP      = "new_ip"
+	ChangeMACRemoved = "mac_removed"
+	ChangeMACExpired = "mac_expired"
+	ChangeIPConflict = "ip_conflict"
+)
+
+// ChangeTypes lists all change types.
+var ChangeTypes = []string{ChangeNewMAC, ChangeNewIP, ChangeMACRemoved, ChangeMACExpired, ChangeIPConflict}
+
+// Change is a change of one neighbour entry between two export cycles,
+// for sinks that report individual events rather than tables. See Changes
+// and Tracker.
+type Change struct {
+	Type      string    `json:"type"`
+	Interface string    `json:"interface"`
+	Time      time.Time `json:"time"`
+	MAC       string    `json:"mac,omitempty"`
+	// IP is the new address of a new_ip change, or the contested address
+	// of an ip_conflict.
+	IP string `json:"ip,omitempty"`
+	// MACs are the holders of IP in an ip_conflict.
+	MACs []string `json:"macs,omitempty"`
+}
+
+// Table returns neighbours keyed by MAC, in the export format.
+func Table(neighbours []dump.Neighbour) map[string]export.NeighbourJSON {
+	t := make(map[string]export.NeighbourJSON, len(neighbours))
+	for _, n := range neighbours {
+		j := export.NewNeighbourJSON(n)
+		t[j.MAC] = j
+	}
+	return t
+}
+
+// Changes lists the changes from prev to cur, at time t: new_mac for each
+// added entry, new_ip for each address not previously held by its MAC
+// (including those of added entries) and mac_removed for each removed
+// entry, in that order and by MAC within each.
+func Changes(iface string, t time.Time, prev, cur map[string]export.NeighbourJSON) []Change {
+	added, updated, _, removed := Diff(prev, cur)
+
+	var out []Change
+	change := func(typ, mac, ip string) {
+		out = append(out, Change{Type: typ, Interface: iface, Time: t, MAC: mac, IP: ip})
+	}
+	for _, n := range added {
+		change(ChangeNewMAC, n.MAC, "")
+		for _, ip := range addresses(n) {
+			change(ChangeNewIP, n.MAC, ip)
+		}
+	}
+	for _, n := range updated {
+		old := make(map[string]bool)
+		for _, ip := range addresses(prev[n.MAC]) {
+			old[ip] = true
+		}
+		for _, ip := range addresses(n) {
+			if !old[ip] {
+				change(ChangeNewIP, n.MAC, ip)
+			}
+		}
+	}
+	for _, mac := range removed {
+		change(ChangeMACRemoved, mac, "")
+	}
+	return out
+}
+
+func addresses(n export.NeighbourJSON) []string {
+	out := make([]string, 0, len(n.IPv4)+len(n.IPv6))
+	out = append(out, n.IPv4...)
+	return append(out, n.IPv6...)
+}
@@ -0,0 +1,315 @@
+// Package events computes per-interface neighbour deltas between export
+// cycles and streams them to clients as Server-Sent Events.
+//
+// A client first receives a "snapshot" event per interface (the same
+// document as the export file), then a "delta" event per interface and
+// export cycle, and "detached" when an interface is removed. See
+// spec/probe.md for the format.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// Event names.
+const (
+	EventSnapshot = "snapshot"
+	EventDelta    = "delta"
+	EventDetached = "detached"
+)
+
+// Delta is the change of one interface's table between two export cycles.
+// It is sent every cycle, also when empty, so Timestamp and the interface
+// info and counters track the latest export.
+type Delta struct {
+	Interface string `json:"interface"`
+	Timestamp string `json:"timestamp"`
+	// MAC, IPv4, IPv6 and Stats are the interface's, as in a snapshot.
+	MAC   string                 `json:"mac"`
+	IPv4  []string               `json:"ipv4"`
+	IPv6  []string               `json:"ipv6"`
+	Stats *export.InterfaceStats `json:"stats"`
+	Added []export.NeighbourJSON `json:"added"`
+	// Updated holds the entries whose addresses or first seen changed.
+	Updated []export.NeighbourJSON `json:"updated"`
+	// LastSeen maps the MAC of each entry whose only change is its last
+	// seen time to the new time, the bulk of the changes of a busy link.
+	LastSeen map[string]string `json:"last_seen"`
+	Removed  []string          `json:"removed"`
+}
+
+// Empty reports whether no neighbour changed.
+func (d *Delta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.LastSeen) == 0 && len(d.Removed) == 0
+}
+
+// Detached is the payload of a detached event.
+type Detached struct {
+	Interface string `json:"interface"`
+}
+
+// Diff compares two tables keyed by MAC. An entry is updated if its
+// addresses or first seen changed; entries whose only change is their last
+// seen time are in seen, mapped to the new time. Results are sorted by
+// MAC.
+func Diff(prev, cur map[string]export.NeighbourJSON) (added, updated []export.NeighbourJSON, seen map[string]string, removed []string) {
+	added, updated, seen, removed = []export.NeighbourJSON{}, []export.NeighbourJSON{}, map[string]string{}, []string{}
+	for mac, n := range cur {
+		old, ok := prev[mac]
+		switch {
+		case !ok:
+			added = append(added, n)
+		case old.FirstSeen != n.FirstSeen || !slices.Equal(old.IPv4, n.IPv4) || !slices.Equal(old.IPv6, n.IPv6):
+			updated = append(updated, n)
+		case old.LastSeen != n.LastSeen:
+			seen[mac] = n.LastSeen
+		}
+	}
+	for mac := range prev {
+		if _, ok := cur[mac]; !ok {
+			removed = append(removed, mac)
+		}
+	}
+	byMAC := func(s []export.NeighbourJSON) {
+		sort.Slice(s, func(i, j int) bool { return s[i].MAC < s[j].MAC })
+	}
+	byMAC(added)
+	byMAC(updated)
+	sort.Strings(removed)
+	return added, updated, seen, removed
+}
+
+// event is a serialised SSE event.
+type event struct {
+	iface string
+	data  []byte // complete "event: ...\nid: ...\ndata: ...\n\n" frame
+}
+
+// ifaceState is the last snapshot of an interface.
+type ifaceState struct {
+	snapshot   []byte // serialised snapshot event
+	neighbours map[string]export.NeighbourJSON
+}
+
+// subscriber is a connected client.
+type subscriber struct {
+	ch     chan event
+	ifaces map[string]bool // all if empty
+}
+
+func (s *subscriber) wants(iface string) bool {
+	return len(s.ifaces) == 0 || s.ifaces[iface]
+}
+
+// subscriberBuffer is the number of events queued per client; a client
+// that falls further behind is disconnected and resyncs on reconnect.
+const subscriberBuffer = 256
+
+// KeepAlive is the interval of SSE comment lines that keep proxies from
+// closing idle connections.
+var KeepAlive = 15 * time.Second
+
+// Hub tracks the last table of each interface, computes deltas on each
+// export cycle and fans them out to subscribers. It implements
+// daemon.Observer and http.Handler.
+type Hub struct {
+	mu     sync.Mutex
+	seq    uint64
+	ifaces map[string]*ifaceState
+	subs   map[*subscriber]struct{}
+	logger *slog.Logger
+}
+
+var _ daemon.Observer = (*Hub)(nil)
+
+// NewHub returns an empty hub.
+func NewHub(logger *slog.Logger) *Hub {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Hub{
+		ifaces: make(map[string]*ifaceState),
+		subs:   make(map[*subscriber]struct{}),
+		logger: logger,
+	}
+}
+
+// Observe records a snapshot and broadcasts its delta.
+func (h *Hub) Observe(s daemon.Snapshot) {
+	data := export.NewInterfaceData(s.Interface, s.Time, s.ExportInterval, s.Neighbours, s.Info, s.Stats)
+	cur := make(map[string]export.NeighbourJSON, len(data.Neighbours))
+	for _, n := range data.Neighbours {
+		cur[n.MAC] = n
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	st, known := h.ifaces[s.Interface]
+	if !known {
+		st = &ifaceState{}
+		h.ifaces[s.Interface] = st
+	}
+	st.snapshot = h.frame(EventSnapshot, h.seq, data)
+
+	if !known {
+		// New interface: subscribers get the full table.
+		h.broadcast(event{iface: s.Interface, data: st.snapshot})
+	} else {
+		d := Delta{
+			Interface: s.Interface,
+			Timestamp: data.Timestamp,
+			MAC:       data.MAC,
+			IPv4:      data.IPv4,
+			IPv6:      data.IPv6,
+			Stats:     data.Stats,
+		}
+		d.Added, d.Updated, d.LastSeen, d.Removed = Diff(st.neighbours, cur)
+		h.broadcast(event{iface: s.Interface, data: h.frame(EventDelta, h.seq, d)})
+	}
+	st.neighbours = cur
+}
+
+// Detached forgets an interface and tells subscribers.
+func (h *Hub) Detached(iface string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.ifaces[iface]; !ok {
+		return
+	}
+	delete(h.ifaces, iface)
+	h.seq++
+	h.broadcast(event{iface: iface, data: h.frame(EventDetached, h.seq, Detached{Interface: iface})})
+}
+
+// frame serialises an SSE event. JSON has no raw newlines, so the payload
+// fits in one data line.
+func (h *Hub) frame(name string, id uint64, v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		h.logger.Error("events: marshal failed", "event", name, "error", err)
+		b = []byte("{}")
+	}
+	return fmt.Appendf(nil, "event: %s\nid: %d\ndata: %s\n\n", name, id, b)
+}
+
+// broadcast queues e for every interested subscriber, disconnecting the
+// ones whose queue is full. Called with h.mu held.
+func (h *Hub) broadcast(e event) {
+	for s := range h.subs {
+		if !s.wants(e.iface) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			h.logger.Warn("events: dropping slow client")
+			delete(h.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// subscribe registers a client and returns it with the current snapshots,
+// atomically so no delta is missed or applied to a stale table.
+func (h *Hub) subscribe(ifaces []string) (*subscriber, []event) {
+	s := &subscriber{ch: make(chan event, subscriberBuffer), ifaces: make(map[string]bool)}
+	for _, name := range ifaces {
+		s.ifaces[name] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := make([]string, 0, len(h.ifaces))
+	for name := range h.ifaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var initial []event
+	for _, name := range names {
+		if s.wants(name) {
+			initial = append(initial, event{iface: name, data: h.ifaces[name].snapshot})
+		}
+	}
+	h.subs[s] = struct{}{}
+	return s, initial
+}
+
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscribers returns the number of connected clients.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// ServeHTTP streams events until the client disconnects. The optional
+// iface query parameter (repeatable) restricts the stream to those
+// interfaces.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Tell nginx not to buffer the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s, initial := h.subscribe(r.URL.Query()["iface"])
+	defer h.unsubscribe(s)
+
+	// Clients reconnect after 3s; they always resync from snapshots.
+	if _, err := w.Write([]byte("retry: 3000\n\n")); err != nil {
+		return
+	}
+	for _, e := range initial {
+		if _, err := w.Write(e.data); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ping := time.NewTicker(KeepAlive)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-s.ch:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(e.data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := w.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
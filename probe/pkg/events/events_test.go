@@ -0,0 +1,315 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+var t0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func snapshot(iface string, neighbours ...dump.Neighbour) daemon.Snapshot {
+	return daemon.Snapshot{Interface: iface, Time: t0, ExportInterval: time.Second, Neighbours: neighbours}
+}
+
+func TestDiff(t *testing.T) {
+	nj := func(n dump.Neighbour) export.NeighbourJSON { return export.NewNeighbourJSON(n) }
+	prev := map[string]export.NeighbourJSON{
+		"02:00:00:00:00:01": nj(testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Second))),
+		"02:00:00:00:00:02": nj(testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(time.Second), "10.0.0.2")),
+		"02:00:00:00:00:03": nj(testutil.Neighbour("02:00:00:00:00:03", t0, t0.Add(time.Second))),
+		"02:00:00:00:00:05": nj(testutil.Neighbour("02:00:00:00:00:05", t0, t0.Add(time.Second), "10.0.0.5")),
+	}
+	cur := map[string]export.NeighbourJSON{
+		"02:00:00:00:00:01": nj(testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Second))),
+		"02:00:00:00:00:02": nj(testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(3*time.Second), "10.0.0.2", "10.0.0.22")),
+		"02:00:00:00:00:04": nj(testutil.Neighbour("02:00:00:00:00:04", t0, t0.Add(2*time.Second))),
+		"02:00:00:00:00:05": nj(testutil.Neighbour("02:00:00:00:00:05", t0, t0.Add(3*time.Second), "10.0.0.5")),
+	}
+	added, updated, seen, removed := Diff(prev, cur)
+	if len(added) != 1 || added[0].MAC != "02:00:00:00:00:04" {
+		t.Errorf("added: %+v", added)
+	}
+	if len(updated) != 1 || updated[0].MAC != "02:00:00:00:00:02" || len(updated[0].IPv4) != 2 {
+		t.Errorf("updated: %+v", updated)
+	}
+	// Only last_seen changed: not an update.
+	if len(seen) != 1 || seen["02:00:00:00:00:05"] != cur["02:00:00:00:00:05"].LastSeen {
+		t.Errorf("last seen: %v", seen)
+	}
+	if len(removed) != 1 || removed[0] != "02:00:00:00:00:03" {
+		t.Errorf("removed: %v", removed)
+	}
+
+	d := Delta{}
+	d.Added, d.Updated, d.LastSeen, d.Removed = Diff(cur, cur)
+	if !d.Empty() {
+		t.Errorf("expected empty delta, got %+v", d)
+	}
+}
+
+// sseEvent is a parsed event.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents parses events from an SSE stream, skipping comments and
+// fields other than event and data.
+func readEvents(t *testing.T, r *bufio.Reader, n int) []sseEvent {
+	t.Helper()
+	var (
+		out []sseEvent
+		cur sseEvent
+	)
+	for len(out) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v (got %+v)", err, out)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if cur.name != "" {
+				out = append(out, cur)
+			}
+			cur = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			cur.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return out
+}
+
+func subscribe(t *testing.T, srv *httptest.Server, query string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events"+query, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// waitSubscribers waits until the hub has n clients.
+func waitSubscribers(t *testing.T, h *Hub, n int) {
+	t.Helper()
+	for i := 0; i < 200 && h.Subscribers() != n; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if h.Subscribers() != n {
+		t.Fatalf("expected %d subscribers, got %d", n, h.Subscribers())
+	}
+}
+
+func TestHubStream(t *testing.T) {
+	h := NewHub(nil)
+	srv := httptest.NewServer(http.StripPrefix("/events", h))
+	t.Cleanup(srv.Close)
+
+	h.Observe(snapshot("eth0", testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Second), "10.0.0.1")))
+
+	r := subscribe(t, srv, "")
+	got := readEvents(t, r, 1)
+	if got[0].name != EventSnapshot {
+		t.Fatalf("expected snapshot first, got %+v", got[0])
+	}
+	var data export.InterfaceData
+	if err := json.Unmarshal([]byte(got[0].data), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Interface != "eth0" || len(data.Neighbours) != 1 {
+		t.Errorf("unexpected snapshot: %+v", data)
+	}
+
+	// Second cycle: one entry seen again, one new entry.
+	s := snapshot("eth0",
+		testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(5*time.Second), "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(5*time.Second)))
+	s.Stats = &export.InterfaceStats{RxPackets: 42}
+	h.Observe(s)
+	// A new interface arrives as a snapshot, then is detached.
+	h.Observe(snapshot("eth1"))
+	h.Detached("eth1")
+
+	got = readEvents(t, r, 3)
+	if got[0].name != EventDelta || got[1].name != EventSnapshot || got[2].name != EventDetached {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+	var d Delta
+	if err := json.Unmarshal([]byte(got[0].data), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Interface != "eth0" || len(d.Added) != 1 || len(d.Updated) != 0 || len(d.Removed) != 0 {
+		t.Errorf("unexpected delta: %+v", d)
+	}
+	if len(d.LastSeen) != 1 || d.LastSeen["02:00:00:00:00:01"] == "" {
+		t.Errorf("unexpected last seen: %v", d.LastSeen)
+	}
+	if d.Stats == nil || d.Stats.RxPackets != 42 {
+		t.Errorf("expected the interface stats in the delta, got %+v", d.Stats)
+	}
+	if got[2].data != `{"interface":"eth1"}` {
+		t.Errorf("unexpected detached payload: %s", got[2].data)
+	}
+}
+
+func TestHubInterfaceFilter(t *testing.T) {
+	h := NewHub(nil)
+	srv := httptest.NewServer(http.StripPrefix("/events", h))
+	t.Cleanup(srv.Close)
+
+	h.Observe(snapshot("eth0"))
+	h.Observe(snapshot("eth1"))
+
+	r := subscribe(t, srv, "?iface=eth1")
+	got := readEvents(t, r, 1)
+	if !strings.Contains(got[0].data, `"interface":"eth1"`) {
+		t.Fatalf("expected eth1 snapshot, got %+v", got[0])
+	}
+	waitSubscribers(t, h, 1)
+
+	h.Observe(snapshot("eth0", testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Second))))
+	h.Observe(snapshot("eth1", testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(time.Second))))
+	got = readEvents(t, r, 1)
+	if got[0].name != EventDelta || !strings.Contains(got[0].data, "02:00:00:00:00:02") {
+		t.Errorf("expected eth1 delta only, got %+v", got[0])
+	}
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	h := NewHub(nil)
+	s, _ := h.subscribe(nil)
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.Observe(snapshot("eth0"))
+	}
+	if h.Subscribers() != 0 {
+		t.Fatal("slow client not dropped")
+	}
+	// Drain: the channel must be closed.
+	for range s.ch {
+	}
+	h.unsubscribe(s) // no double close
+}
+
+func TestChanges(t *testing.T) {
+	nj := func(n dump.Neighbour) export.NeighbourJSON { return export.NewNeighbourJSON(n) }
+	prev := map[string]export.NeighbourJSON{
+		"02:00:00:00:00:01": nj(testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(time.Second), "10.0.0.1")),
+		"02:00:00:00:00:02": nj(testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(time.Second))),
+	}
+	cur := Table([]dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", t0, t0.Add(2*time.Second), "10.0.0.1", "10.0.0.11"),
+		testutil.Neighbour("02:00:00:00:00:03", t0, t0.Add(2*time.Second), "10.0.0.3"),
+	})
+
+	var got []string
+	for _, c := range Changes("eth0", t0, prev, cur) {
+		if c.Interface != "eth0" || !c.Time.Equal(t0) {
+			t.Errorf("unexpected change: %+v", c)
+		}
+		got = append(got, c.Type+" "+c.MAC+" "+c.IP)
+	}
+	want := []string{
+		"new_mac 02:00:00:00:00:03 ",
+		"new_ip 02:00:00:00:00:03 10.0.0.3",
+		"new_ip 02:00:00:00:00:01 10.0.0.11",
+		"mac_removed 02:00:00:00:00:02 ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A last_seen update alone is not a change.
+	cur = Table([]dump.Neighbour{testutil.Neighbour("02:00:00:00:00:02", t0, t0.Add(5*time.Second))})
+	if c := Changes("eth0", t0, prev, cur); len(c) != 1 || c[0].Type != ChangeMACRemoved {
+		t.Errorf("unexpected changes: %+v", c)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker(time.Minute)
+	at := func(d time.Duration) time.Time { return tr.started.Add(d) }
+	entry := func(mac string, firstSeen, lastSeen time.Time, ips ...string) dump.Neighbour {
+		n := testutil.Neighbour(mac, t0, t0, ips...)
+		n.FirstSeen, n.LastSeen = firstSeen, lastSeen
+		return n
+	}
+	update := func(now time.Time, neighbours ...dump.Neighbour) []string {
+		var out []string
+		for _, c := range tr.Update(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: neighbours}) {
+			s := c.Type + " " + c.MAC + " " + c.IP
+			if len(c.MACs) > 0 {
+				s += " " + strings.Join(c.MACs, ",")
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+	check := func(got []string, want ...string) {
+		t.Helper()
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	// Startup: old and already stale entries are not reported.
+	check(update(at(time.Second),
+		entry("02:00:00:00:00:01", at(-time.Hour), at(time.Second), "10.0.0.1"),
+		entry("02:00:00:00:00:02", at(-time.Hour), at(-time.Hour)),
+	))
+	// 03 joins with 01's address.
+	check(update(at(2*time.Second),
+		entry("02:00:00:00:00:01", at(-time.Hour), at(time.Second), "10.0.0.1"),
+		entry("02:00:00:00:00:02", at(-time.Hour), at(-time.Hour)),
+		entry("02:00:00:00:00:03", at(2*time.Second), at(2*time.Second), "10.0.0.1"),
+	),
+		"new_mac 02:00:00:00:00:03 ",
+		"new_ip 02:00:00:00:00:03 10.0.0.1",
+		"ip_conflict 02:00:00:00:00:03 10.0.0.1 02:00:00:00:00:01,02:00:00:00:00:03",
+	)
+	// 01 goes stale; the conflict is unchanged and not repeated.
+	check(update(at(2*time.Minute),
+		entry("02:00:00:00:00:01", at(-time.Hour), at(time.Second), "10.0.0.1"),
+		entry("02:00:00:00:00:02", at(-time.Hour), at(-time.Hour)),
+		entry("02:00:00:00:00:03", at(2*time.Second), at(2*time.Minute), "10.0.0.1"),
+	),
+		"mac_expired 02:00:00:00:00:01 ",
+	)
+	// Once only; 02 is removed.
+	check(update(at(3*time.Minute),
+		entry("02:00:00:00:00:01", at(-time.Hour), at(time.Second), "10.0.0.1"),
+		entry("02:00:00:00:00:03", at(2*time.Second), at(3*time.Minute), "10.0.0.1"),
+	),
+		"mac_removed 02:00:00:00:00:02 ",
+	)
+	// 01 is seen again, then expires again.
+	check(update(at(4*time.Minute),
+		entry("02:00:00:00:00:01", at(-time.Hour), at(4*time.Minute), "10.0.0.1"),
+	),
+		"mac_removed 02:00:00:00:00:03 ",
+	)
+	check(update(at(6*time.Minute),
+		entry("02:00:00:00:00:01", at(-time.Hour), at(4*time.Minute), "10.0.0.1"),
+	),
+		"mac_expired 02:00:00:00:00:01 ",
+	)
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// Tracker derives changes from successive snapshots of each interface:
+// those of Changes, plus mac_expired and ip_conflict. It is not safe for
+// concurrent use.
+type Tracker struct {
+	// ExpireAfter reports a MAC as expired once it has not been seen for
+	// this long; disabled if zero.
+	ExpireAfter time.Duration
+
+	started time.Time
+	ifaces  map[string]*trackState
+}
+
+// trackState is what a tracker remembers of an interface.
+type trackState struct {
+	table     map[string]export.NeighbourJSON
+	expired   map[string]bool   // MACs reported as expired
+	conflicts map[string]string // IP -> MACs (joined) last reported
+}
+
+// NewTracker returns a tracker. Entries first seen before it was created
+// are not reported as new, so a restart with pinned maps does not report
+// the whole table.
+func NewTracker(expireAfter time.Duration) *Tracker {
+	return &Tracker{
+		ExpireAfter: expireAfter,
+		started:     time.Now(),
+		ifaces:      make(map[string]*trackState),
+	}
+}
+
+// Update records s and returns its changes: those of Changes, then
+// mac_expired for entries whose last sighting became older than
+// ExpireAfter (once, until seen again), then ip_conflict when the set of
+// MACs (two or more) holding an address changes. On an interface's first
+// snapshot, only entries learned after startup are reported, and entries
+// already expired or in conflict at startup are not.
+func (t *Tracker) Update(s daemon.Snapshot) []Change {
+	cur := Table(s.Neighbours)
+	st, ok := t.ifaces[s.Interface]
+	first := !ok
+	if first {
+		st = &trackState{
+			table:     make(map[string]export.NeighbourJSON),
+			expired:   make(map[string]bool),
+			conflicts: make(map[string]string),
+		}
+		for _, n := range s.Neighbours {
+			if !n.FirstSeen.After(t.started) {
+				st.table[n.MAC.String()] = cur[n.MAC.String()]
+			}
+		}
+		// Conflicts between entries learned before startup are not
+		// reported.
+		_, st.conflicts = conflicts(st.table)
+		t.ifaces[s.Interface] = st
+	}
+
+	prev := st.table
+	out := Changes(s.Interface, s.Time, prev, cur)
+	for _, c := range out {
+		if c.Type == ChangeMACRemoved {
+			delete(st.expired, c.MAC)
+		}
+	}
+	st.table = cur
+
+	if t.ExpireAfter > 0 {
+		var expired []string
+		for _, n := range s.Neighbours {
+			mac := n.MAC.String()
+			if s.Time.Sub(n.LastSeen) < t.ExpireAfter {
+				delete(st.expired, mac)
+				continue
+			}
+			if !st.expired[mac] {
+				st.expired[mac] = true
+				// Entries already stale at startup are not reported.
+				if !first {
+					expired = append(expired, mac)
+				}
+			}
+		}
+		sort.Strings(expired)
+		for _, mac := range expired {
+			out = append(out, Change{Type: ChangeMACExpired, Interface: s.Interface, Time: s.Time, MAC: mac})
+		}
+	}
+
+	holders, contested := conflicts(cur)
+	var ips []string
+	for ip, macs := range contested {
+		if st.conflicts[ip] != macs {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		// MAC is the first holder that did not have the address before,
+		// if any.
+		var mac string
+		for _, m := range holders[ip] {
+			if !slices.Contains(addresses(prev[m]), ip) {
+				mac = m
+				break
+			}
+		}
+		out = append(out, Change{
+			Type:      ChangeIPConflict,
+			Interface: s.Interface,
+			Time:      s.Time,
+			MAC:       mac,
+			IP:        ip,
+			MACs:      holders[ip],
+		})
+	}
+	st.conflicts = contested
+	return out
+}
+
+// conflicts returns the holders of each address in t, sorted, and the
+// addresses held by more than one MAC with their holders joined.
+func conflicts(t map[string]export.NeighbourJSON) (map[string][]string, map[string]string) {
+	holders := make(map[string][]string)
+	for mac, n := range t {
+		for _, ip := range addresses(n) {
+			holders[ip] = append(holders[ip], mac)
+		}
+	}
+	contested := make(map[string]string)
+	for ip, macs := range holders {
+		sort.Strings(macs)
+		if len(macs) > 1 {
+			contested[ip] = strings.Join(macs, ",")
+		}
+	}
+	return holders, contested
+}
+
+// Forget drops an interface's state.
+func (t *Tracker) Forget(iface string) {
+	delete(t.ifaces, iface)
+}
@@ -1,11 +1,15 @@
 package export
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -105,21 +109,22 @@ func LookupInterfaceStats(name string) (*InterfaceStats, error) {
 
 // NeighbourJSON is the JSON representation of a neighbour entry.
 type NeighbourJSON struct {
-	MAC       string   `json:"mac"`
-	IPv4      []string `json:"ipv4"`
-	IPv6      []string `json:"ipv6"`
-	FirstSeen string   `json:"first_seen"`
-	LastSeen  string   `json:"last_seen"`
+	MAC       string   `json:"mac" schema:"mac"`
+	IPv4      []string `json:"ipv4" schema:"ipv4"`
+	IPv6      []string `json:"ipv6" schema:"ipv6"`
+	FirstSeen string   `json:"first_seen" schema:"date-time"`
+	LastSeen  string   `json:"last_seen" schema:"date-time"`
 }
 
 // InterfaceData is the top-level JSON structure for one interface export.
 type InterfaceData struct {
+	SchemaVersion  int             `json:"schema_version" schema:"version"`
 	Interface      string          `json:"interface"`
-	Timestamp      string          `json:"timestamp"`
-	ExportInterval string          `json:"export_interval"`
-	MAC            string          `json:"mac"`
-	IPv4           []string        `json:"ipv4"`
-	IPv6           []string        `json:"ipv6"`
+	Timestamp      string          `json:"timestamp" schema:"date-time"`
+	ExportInterval string          `json:"export_interval" schema:"duration"`
+	MAC            string          `json:"mac" schema:"mac,empty"`
+	IPv4           []string        `json:"ipv4" schema:"ipv4"`
+	IPv6           []string        `json:"ipv6" schema:"ipv6"`
 	Stats          *InterfaceStats `json:"stats"`
 	Neighbours     []NeighbourJSON `json:"neighbours"`
 }
@@ -128,6 +133,7 @@ type InterfaceData struct {
 // ifInfo and stats may be nil if unavailable.
 func NewInterfaceData(iface string, ts time.Time, interval time.Duration, neighbours []dump.Neighbour, ifInfo *InterfaceInfo, stats *InterfaceStats) InterfaceData {
 	data := InterfaceData{
+		SchemaVersion:  SchemaVersion,
 		Interface:      iface,
 		Timestamp:      ts.UTC().Format(time.RFC3339),
 		ExportInterval: interval.String(),
@@ -148,71 +154,203 @@ func NewInterfaceData(iface string, ts time.Time, interval time.Duration, neighb
 	}
 
 	for _, n := range neighbours {
-		nj := NeighbourJSON{
-			MAC:       n.MAC.String(),
-			IPv4:      make([]string, 0, len(n.IPv4)),
-			IPv6:      make([]string, 0, len(n.IPv6)),
-			FirstSeen: n.FirstSeen.UTC().Format(time.RFC3339),
-			LastSeen:  n.LastSeen.UTC().Format(time.RFC3339),
-		}
-		for _, ip := range n.IPv4 {
-			nj.IPv4 = append(nj.IPv4, ip.String())
-		}
-		for _, ip := range n.IPv6 {
-			nj.IPv6 = append(nj.IPv6, ip.String())
-		}
-		data.Neighbours = append(data.Neighbours, nj)
+		data.Neighbours = append(data.Neighbours, NewNeighbourJSON(n))
 	}
 
 	return data
 }
 
+// NewNeighbourJSON converts one dump.Neighbour to its JSON form.
+func NewNeighbourJSON(n dump.Neighbour) NeighbourJSON {
+	nj := NeighbourJSON{
+		MAC:       n.MAC.String(),
+		IPv4:      make([]string, 0, len(n.IPv4)),
+		IPv6:      make([]string, 0, len(n.IPv6)),
+		FirstSeen: n.FirstSeen.UTC().Format(time.RFC3339),
+		LastSeen:  n.LastSeen.UTC().Format(time.RFC3339),
+	}
+	for _, ip := range n.IPv4 {
+		nj.IPv4 = append(nj.IPv4, ip.String())
+	}
+	for _, ip := range n.IPv6 {
+		nj.IPv6 = append(nj.IPv6, ip.String())
+	}
+	return nj
+}
+
+// Neighbour converts nj back to a dump.Neighbour, e.g. to format an
+// export read with ReadJSON like a map dump.
+func (nj NeighbourJSON) Neighbour() (dump.Neighbour, error) {
+	var n dump.Neighbour
+	var err error
+	if n.MAC, err = net.ParseMAC(nj.MAC); err != nil {
+		return n, fmt.Errorf("invalid mac %q", nj.MAC)
+	}
+	for _, list := range []struct {
+		in  []string
+		out *[]net.IP
+	}{{nj.IPv4, &n.IPv4}, {nj.IPv6, &n.IPv6}} {
+		for _, s := range list.in {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return n, fmt.Errorf("%s: invalid address %q", nj.MAC, s)
+			}
+			if v4 := ip.To4(); v4 != nil {
+				ip = v4
+			}
+			*list.out = append(*list.out, ip)
+		}
+	}
+	if n.FirstSeen, err = time.Parse(time.RFC3339, nj.FirstSeen); err != nil {
+		return n, fmt.Errorf("%s: invalid first_seen %q", nj.MAC, nj.FirstSeen)
+	}
+	if n.LastSeen, err = time.Parse(time.RFC3339, nj.LastSeen); err != nil {
+		return n, fmt.Errorf("%s: invalid last_seen %q", nj.MAC, nj.LastSeen)
+	}
+	return n, nil
+}
+
+// FormatJSON is the default export format: the InterfaceData document
+// read by the UI. The other formats are those of dump.FormatNames with a
+// file extension.
+const FormatJSON = "json"
+
+// Formats returns the supported export formats, FormatJSON first.
+func Formats() []string {
+	names := []string{FormatJSON}
+	for _, name := range dump.FormatNames() {
+		if f, _ := dump.LookupFormat(name); f.Ext != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // OutputFileName returns the JSON file name for an interface.
 func OutputFileName(iface string) string {
 	return fmt.Sprintf("neigh-%s.json", iface)
 }
 
+// FormatFileName returns the file name of an interface's export in
+// format, which must be one of Formats.
+func FormatFileName(iface, format string) string {
+	if format == FormatJSON {
+		return OutputFileName(iface)
+	}
+	f, _ := dump.LookupFormat(format)
+	return fmt.Sprintf("neigh-%s%s", iface, f.Ext)
+}
+
+// File describes a file written to the export directory.
+type File struct {
+	Format string `json:"format"`
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256" schema:"sha256"`
+	Size   int    `json:"size"`
+}
+
 // WriteJSON writes the neighbour data for an interface to a JSON file
 // in the given output directory. The write is atomic (temp file + rename)
 // so readers never see a partial file. ifInfo may be nil.
-func WriteJSON(iface string, neighbours []dump.Neighbour, outputDir string, ts time.Time, interval time.Duration, ifInfo *InterfaceInfo, stats *InterfaceStats) error {
+func WriteJSON(iface string, neighbours []dump.Neighbour, outputDir string, ts time.Time, interval time.Duration, ifInfo *InterfaceInfo, stats *InterfaceStats) (File, error) {
 	data := NewInterfaceData(iface, ts, interval, neighbours, ifInfo, stats)
+	return writeJSONFile(outputDir, OutputFileName(iface), data)
+}
 
-	b, err := json.MarshalIndent(data, "", "  ")
+// WriteFormat writes the neighbours of an interface in format, one of
+// the dump formats with a file extension, to the output directory,
+// atomically like WriteJSON. cols may be nil for the default columns.
+func WriteFormat(iface, format string, neighbours []dump.Neighbour, cols []dump.Column, outputDir string) (File, error) {
+	f, ok := dump.LookupFormat(format)
+	if !ok || f.Ext == "" {
+		return File{}, fmt.Errorf("unsupported export format %q", format)
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf, neighbours, cols); err != nil {
+		return File{}, fmt.Errorf("formatting %s: %w", format, err)
+	}
+	return writeFile(outputDir, FormatFileName(iface, format), format, buf.Bytes())
+}
+
+// writeJSONFile writes v, indented, to name in outputDir.
+func writeJSONFile(outputDir, name string, v any) (File, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		return fmt.Errorf("marshaling JSON: %w", err)
+		return File{}, fmt.Errorf("marshaling JSON: %w", err)
 	}
 	b = append(b, '\n')
+	return writeFile(outputDir, name, FormatJSON, b)
+}
 
-	outPath := filepath.Join(outputDir, OutputFileName(iface))
+// writeFile replaces name in outputDir with b atomically.
+func writeFile(outputDir, name, format string, b []byte) (File, error) {
+	outPath := filepath.Join(outputDir, name)
 
 	// Write to temp file in the same directory, then rename for atomicity.
 	tmp, err := os.CreateTemp(outputDir, ".neigh-*.tmp")
 	if err != nil {
-		return fmt.Errorf("creating temp file: %w", err)
+		return File{}, fmt.Errorf("creating temp file: %w", err)
 	}
 	tmpName := tmp.Name()
 
 	if _, err := tmp.Write(b); err != nil {
 		tmp.Close()
 		os.Remove(tmpName)
-		return fmt.Errorf("writing temp file: %w", err)
+		return File{}, fmt.Errorf("writing temp file: %w", err)
 	}
 	if err := tmp.Close(); err != nil {
 		os.Remove(tmpName)
-		return fmt.Errorf("closing temp file: %w", err)
+		return File{}, fmt.Errorf("closing temp file: %w", err)
 	}
 
 	// Make world-readable so nginx (unprivileged) can serve the file.
 	if err := os.Chmod(tmpName, 0644); err != nil {
 		os.Remove(tmpName)
-		return fmt.Errorf("setting file permissions: %w", err)
+		return File{}, fmt.Errorf("setting file permissions: %w", err)
 	}
 
 	if err := os.Rename(tmpName, outPath); err != nil {
 		os.Remove(tmpName)
-		return fmt.Errorf("renaming temp file: %w", err)
+		return File{}, fmt.Errorf("renaming temp file: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return File{Format: format, Name: name, SHA256: hex.EncodeToString(sum[:]), Size: len(b)}, nil
+}
+
+// ReadJSON reads an interface export written by WriteJSON (or "dump -o
+// json"). Files without a schema version, written by older probes, are
+// accepted; newer schema versions are not.
+func ReadJSON(path string) (*InterfaceData, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+	var data InterfaceData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if data.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("%s: schema_version %d is not supported (this probe reads up to %d)", path, data.SchemaVersion, SchemaVersion)
+	}
+	return &data, nil
+}
 
-	return nil
+// ReadDir reads the JSON exports of all interfaces in dir, sorted by
+// interface name.
+func ReadDir(dir string) ([]*InterfaceData, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, OutputFileName("*")))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*InterfaceData, 0, len(paths))
+	for _, path := range paths {
+		data, err := ReadJSON(path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Interface < out[j].Interface })
+	return out, nil
 }
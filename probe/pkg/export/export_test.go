@@ -1,10 +1,13 @@
 package export
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -252,7 +255,7 @@ func TestWriteJSONAtomicCreatesFile(t *testing.T) {
 		},
 	}
 
-	err := WriteJSON("eth0", neighbours, dir, now, 5*time.Second, nil, nil)
+	_, err := WriteJSON("eth0", neighbours, dir, now, 5*time.Second, nil, nil)
 	if err != nil {
 		t.Fatalf("WriteJSON failed: %v", err)
 	}
@@ -276,12 +279,74 @@ func TestWriteJSONAtomicCreatesFile(t *testing.T) {
 	}
 }
 
+func TestNeighbourRoundTrip(t *testing.T) {
+	now := time.Date(2026, 2, 14, 14, 30, 0, 0, time.UTC)
+	n := dump.Neighbour{
+		MAC:       net.HardwareAddr{0xdc, 0x4b, 0xa1, 0x69, 0x38, 0x16},
+		IPv4:      []net.IP{net.ParseIP("192.168.1.33").To4()},
+		IPv6:      []net.IP{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::1")},
+		FirstSeen: now.Add(-10 * time.Minute),
+		LastSeen:  now,
+	}
+	got, err := NewNeighbourJSON(n).Neighbour()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MAC.String() != n.MAC.String() || got.IPv4String() != n.IPv4String() || got.IPv6String() != n.IPv6String() ||
+		!got.FirstSeen.Equal(n.FirstSeen) || !got.LastSeen.Equal(n.LastSeen) {
+		t.Errorf("expected %+v, got %+v", n, got)
+	}
+	if len(got.IPv4[0]) != net.IPv4len {
+		t.Errorf("expected a 4-byte IPv4 address, got %v", []byte(got.IPv4[0]))
+	}
+
+	bad := NewNeighbourJSON(n)
+	bad.LastSeen = "yesterday"
+	if _, err := bad.Neighbour(); err == nil {
+		t.Error("expected error for an invalid time")
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 2, 14, 14, 30, 0, 0, time.UTC)
+	for _, iface := range []string{"wlan0", "eth0"} {
+		if _, err := WriteJSON(iface, nil, dir, now, 5*time.Second, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Other formats and the manifest are not interface exports.
+	if _, err := WriteFormat("eth0", "csv", nil, nil, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteManifest(NewManifest(now, 5*time.Second, nil, nil), dir); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Interface != "eth0" || all[1].Interface != "wlan0" || all[0].SchemaVersion != SchemaVersion {
+		t.Errorf("unexpected exports: %+v", all)
+	}
+
+	newer := filepath.Join(dir, "neigh-eth1.json")
+	os.WriteFile(newer, []byte(`{"schema_version": 2, "interface": "eth1"}`), 0644)
+	if _, err := ReadJSON(newer); err == nil {
+		t.Error("expected error for a newer schema version")
+	}
+	if _, err := ReadDir(dir); err == nil {
+		t.Error("expected ReadDir to fail on an unreadable export")
+	}
+}
+
 func TestWriteJSONOverwritesExisting(t *testing.T) {
 	dir := t.TempDir()
 	now := time.Now()
 
 	// Write first version
-	err := WriteJSON("eth0", nil, dir, now, 5*time.Second, nil, nil)
+	_, err := WriteJSON("eth0", nil, dir, now, 5*time.Second, nil, nil)
 	if err != nil {
 		t.Fatalf("first WriteJSON failed: %v", err)
 	}
@@ -294,7 +359,7 @@ func TestWriteJSONOverwritesExisting(t *testing.T) {
 			LastSeen:  now,
 		},
 	}
-	err = WriteJSON("eth0", neighbours, dir, now, 5*time.Second, nil, nil)
+	_, err = WriteJSON("eth0", neighbours, dir, now, 5*time.Second, nil, nil)
 	if err != nil {
 		t.Fatalf("second WriteJSON failed: %v", err)
 	}
@@ -316,7 +381,7 @@ func TestWriteJSONFilePermissions(t *testing.T) {
 	dir := t.TempDir()
 	now := time.Now()
 
-	err := WriteJSON("eth0", nil, dir, now, 5*time.Second, nil, nil)
+	_, err := WriteJSON("eth0", nil, dir, now, 5*time.Second, nil, nil)
 	if err != nil {
 		t.Fatalf("WriteJSON failed: %v", err)
 	}
@@ -343,6 +408,67 @@ func TestOutputFileName(t *testing.T) {
 	}
 }
 
+func TestFormats(t *testing.T) {
+	want := []string{"json", "csv", "ndjson", "yaml"}
+	if got := Formats(); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for format, want := range map[string]string{
+		"json":   "neigh-eth0.json",
+		"csv":    "neigh-eth0.csv",
+		"ndjson": "neigh-eth0.ndjson",
+		"yaml":   "neigh-eth0.yaml",
+	} {
+		if got := FormatFileName("eth0", format); got != want {
+			t.Errorf("%s: expected %s, got %s", format, want, got)
+		}
+	}
+}
+
+func TestWriteFormat(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	neighbours := []dump.Neighbour{
+		{
+			MAC:       net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+			IPv4:      []net.IP{net.ParseIP("10.0.0.1").To4()},
+			FirstSeen: now,
+			LastSeen:  now,
+		},
+	}
+	cols, err := dump.ParseColumns([]string{"mac", "ipv4", "last_seen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := WriteFormat("eth0", "csv", neighbours, cols, dir)
+	if err != nil {
+		t.Fatalf("WriteFormat failed: %v", err)
+	}
+
+	outPath := filepath.Join(dir, "neigh-eth0.csv")
+	b, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("reading output file: %v", err)
+	}
+	want := "mac,ipv4,last_seen\n02:00:00:00:00:01,10.0.0.1,2026-10-18T12:00:00Z\n"
+	if string(b) != want {
+		t.Errorf("expected %q, got %q", want, b)
+	}
+	sum := sha256.Sum256(b)
+	if f.Name != "neigh-eth0.csv" || f.Format != "csv" || f.Size != len(b) || f.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected file description %+v", f)
+	}
+	if info, _ := os.Stat(outPath); info.Mode().Perm() != 0644 {
+		t.Errorf("expected permissions 0644, got %04o", info.Mode().Perm())
+	}
+
+	for _, format := range []string{"json", "table", "xml"} {
+		if _, err := WriteFormat("eth0", format, neighbours, nil, dir); err == nil {
+			t.Errorf("%s: expected error", format)
+		}
+	}
+}
+
 // TestGoldenFileSchema validates that our JSON output matches the golden
 // file schema used as a contract between probe and UI.
 func TestGoldenFileSchema(t *testing.T) {
@@ -466,3 +592,25 @@ func TestLookupInterfaceStatsNotFound(t *testing.T) {
 		t.Error("expected nil stats for nonexistent interface")
 	}
 }
+
+func BenchmarkWriteJSON100k(b *testing.B) {
+	const entries = 100000
+	now := time.Now()
+	neighbours := make([]dump.Neighbour, entries)
+	for i := range neighbours {
+		neighbours[i] = dump.Neighbour{
+			MAC:       net.HardwareAddr{0x02, 0x00, byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)},
+			IPv4:      []net.IP{net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))},
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+	}
+	dir := b.TempDir()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := WriteJSON("eth0", neighbours, dir, now, 5*time.Second, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
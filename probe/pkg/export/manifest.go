@@ -0,0 +1,190 @@
+package export
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/version"
+)
+
+// ManifestFileName is the index of the export directory, rewritten after
+// each export cycle.
+const ManifestFileName = "manifest.json"
+
+// CombinedFileName is the optional export merging all interfaces.
+const CombinedFileName = "neighbours.json"
+
+// bootIDPath identifies the current boot.
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// Manifest describes the files of an export directory, so consumers
+// need a single entry point instead of listing it.
+type Manifest struct {
+	SchemaVersion  int                 `json:"schema_version" schema:"version"`
+	ProbeVersion   string              `json:"probe_version"`
+	Hostname       string              `json:"hostname"`
+	BootID         string              `json:"boot_id"`
+	Timestamp      string              `json:"timestamp" schema:"date-time"`
+	ExportInterval string              `json:"export_interval" schema:"duration"`
+	Interfaces     []ManifestInterface `json:"interfaces"`
+	// Combined is the combined export, nil if disabled.
+	Combined *File `json:"combined,omitempty"`
+}
+
+// ManifestInterface lists the files of one interface.
+type ManifestInterface struct {
+	Name string `json:"name"`
+	// Timestamp is the time of the export that wrote the files.
+	Timestamp  string `json:"timestamp" schema:"date-time"`
+	Neighbours int    `json:"neighbours"`
+	Files      []File `json:"files"`
+}
+
+// NewManifest returns the manifest of interfaces, sorted by name, with
+// the identity of this host and probe.
+func NewManifest(ts time.Time, interval time.Duration, interfaces []ManifestInterface, combined *File) Manifest {
+	hostname, _ := os.Hostname()
+	sorted := append([]ManifestInterface{}, interfaces...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return Manifest{
+		SchemaVersion:  SchemaVersion,
+		ProbeVersion:   version.String(),
+		Hostname:       hostname,
+		BootID:         bootID(),
+		Timestamp:      ts.UTC().Format(time.RFC3339),
+		ExportInterval: interval.String(),
+		Interfaces:     sorted,
+		Combined:       combined,
+	}
+}
+
+// WriteManifest writes m to the output directory, atomically like
+// WriteJSON.
+func WriteManifest(m Manifest, outputDir string) (File, error) {
+	return writeJSONFile(outputDir, ManifestFileName, m)
+}
+
+// bootID returns the kernel's boot ID, empty if unavailable.
+var bootID = sync.OnceValue(func() string {
+	b, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+})
+
+// Combined is the combined export: the neighbours of all interfaces,
+// merged by MAC address.
+type Combined struct {
+	SchemaVersion int                 `json:"schema_version" schema:"version"`
+	Timestamp     string              `json:"timestamp" schema:"date-time"`
+	Interfaces    []string            `json:"interfaces"`
+	Neighbours    []CombinedNeighbour `json:"neighbours"`
+}
+
+// CombinedNeighbour is a MAC address seen on one or more interfaces: the
+// union of its addresses, its earliest and latest sighting, and its entry
+// on each interface.
+type CombinedNeighbour struct {
+	MAC       string     `json:"mac" schema:"mac"`
+	IPv4      []string   `json:"ipv4" schema:"ipv4"`
+	IPv6      []string   `json:"ipv6" schema:"ipv6"`
+	FirstSeen string     `json:"first_seen" schema:"date-time"`
+	LastSeen  string     `json:"last_seen" schema:"date-time"`
+	Sightings []Sighting `json:"sightings"`
+}
+
+// Sighting is a neighbour entry of one interface.
+type Sighting struct {
+	Interface string   `json:"interface"`
+	IPv4      []string `json:"ipv4" schema:"ipv4"`
+	IPv6      []string `json:"ipv6" schema:"ipv6"`
+	FirstSeen string   `json:"first_seen" schema:"date-time"`
+	LastSeen  string   `json:"last_seen" schema:"date-time"`
+}
+
+// NewCombined merges the neighbours of each interface. Neighbours are
+// sorted by last seen, most recent first, and sightings by interface.
+func NewCombined(ts time.Time, neighbours map[string][]dump.Neighbour) Combined {
+	c := Combined{
+		SchemaVersion: SchemaVersion,
+		Timestamp:     ts.UTC().Format(time.RFC3339),
+		Interfaces:    make([]string, 0, len(neighbours)),
+		Neighbours:    []CombinedNeighbour{},
+	}
+	for iface := range neighbours {
+		c.Interfaces = append(c.Interfaces, iface)
+	}
+	sort.Strings(c.Interfaces)
+
+	type merged struct {
+		first, last time.Time
+		n           *CombinedNeighbour
+	}
+	byMAC := make(map[string]*merged)
+	var order []*merged
+	for _, iface := range c.Interfaces {
+		for _, n := range neighbours[iface] {
+			nj := NewNeighbourJSON(n)
+			m, ok := byMAC[nj.MAC]
+			if !ok {
+				m = &merged{first: n.FirstSeen, last: n.LastSeen, n: &CombinedNeighbour{
+					MAC:  nj.MAC,
+					IPv4: []string{},
+					IPv6: []string{},
+				}}
+				byMAC[nj.MAC] = m
+				order = append(order, m)
+			}
+			if n.FirstSeen.Before(m.first) {
+				m.first = n.FirstSeen
+			}
+			if n.LastSeen.After(m.last) {
+				m.last = n.LastSeen
+			}
+			m.n.IPv4 = appendNew(m.n.IPv4, nj.IPv4)
+			m.n.IPv6 = appendNew(m.n.IPv6, nj.IPv6)
+			m.n.Sightings = append(m.n.Sightings, Sighting{
+				Interface: iface,
+				IPv4:      nj.IPv4,
+				IPv6:      nj.IPv6,
+				FirstSeen: nj.FirstSeen,
+				LastSeen:  nj.LastSeen,
+			})
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if !order[i].last.Equal(order[j].last) {
+			return order[i].last.After(order[j].last)
+		}
+		return order[i].n.MAC < order[j].n.MAC
+	})
+	for _, m := range order {
+		m.n.FirstSeen = m.first.UTC().Format(time.RFC3339)
+		m.n.LastSeen = m.last.UTC().Format(time.RFC3339)
+		c.Neighbours = append(c.Neighbours, *m.n)
+	}
+	return c
+}
+
+// WriteCombined writes the combined export to the output directory,
+// atomically like WriteJSON.
+func WriteCombined(c Combined, outputDir string) (File, error) {
+	return writeJSONFile(outputDir, CombinedFileName, c)
+}
+
+// appendNew appends the strings of add not in list.
+func appendNew(list, add []string) []string {
+	for _, s := range add {
+		if !slices.Contains(list, s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
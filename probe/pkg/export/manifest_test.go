@@ -0,0 +1,97 @@
+package export
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+func TestNewCombined(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	shared := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	c := NewCombined(now, map[string][]dump.Neighbour{
+		"eth1": {
+			{MAC: shared, IPv4: []net.IP{net.ParseIP("10.0.1.1").To4()}, FirstSeen: now.Add(-time.Hour), LastSeen: now.Add(-time.Minute)},
+		},
+		"eth0": {
+			{MAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 2}, FirstSeen: now.Add(-time.Hour), LastSeen: now.Add(-time.Hour)},
+			{MAC: shared, IPv4: []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("10.0.1.1").To4()}, FirstSeen: now.Add(-2 * time.Hour), LastSeen: now.Add(-30 * time.Minute)},
+		},
+	})
+
+	if c.Timestamp != "2026-10-18T12:00:00Z" || len(c.Interfaces) != 2 || c.Interfaces[0] != "eth0" {
+		t.Errorf("unexpected header: %+v", c)
+	}
+	if len(c.Neighbours) != 2 {
+		t.Fatalf("expected 2 neighbours, got %d", len(c.Neighbours))
+	}
+	n := c.Neighbours[0]
+	if n.MAC != "02:00:00:00:00:01" {
+		t.Fatalf("expected the most recently seen MAC first, got %s", n.MAC)
+	}
+	if len(n.IPv4) != 2 || n.IPv4[0] != "10.0.0.1" || n.IPv4[1] != "10.0.1.1" {
+		t.Errorf("expected the union of addresses, got %v", n.IPv4)
+	}
+	if n.FirstSeen != "2026-10-18T10:00:00Z" || n.LastSeen != "2026-10-18T11:59:00Z" {
+		t.Errorf("expected earliest and latest sighting, got %s %s", n.FirstSeen, n.LastSeen)
+	}
+	if len(n.Sightings) != 2 || n.Sightings[0].Interface != "eth0" || n.Sightings[1].Interface != "eth1" ||
+		len(n.Sightings[1].IPv4) != 1 || n.Sightings[1].LastSeen != "2026-10-18T11:59:00Z" {
+		t.Errorf("unexpected sightings: %+v", n.Sightings)
+	}
+	if s := c.Neighbours[1].Sightings; len(s) != 1 || s[0].Interface != "eth0" {
+		t.Errorf("unexpected sightings: %+v", s)
+	}
+
+	// No interfaces.
+	b, _ := json.Marshal(NewCombined(now, nil))
+	if string(b) != `{"schema_version":1,"timestamp":"2026-10-18T12:00:00Z","interfaces":[],"neighbours":[]}` {
+		t.Errorf("unexpected empty export: %s", b)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	f, err := WriteJSON("eth1", nil, dir, now, 5*time.Second, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManifest(now, 5*time.Second, []ManifestInterface{
+		{Name: "eth1", Timestamp: "2026-10-18T12:00:00Z", Files: []File{f}},
+		{Name: "eth0", Timestamp: "2026-10-18T12:00:00Z"},
+	}, nil)
+	if _, err := WriteManifest(m, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"probe_version", "hostname", "boot_id", "timestamp", "export_interval", "interfaces"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("manifest missing key %q", key)
+		}
+	}
+	if _, ok := raw["combined"]; ok {
+		t.Error("combined should be omitted when disabled")
+	}
+	var got Manifest
+	json.Unmarshal(b, &got)
+	if len(got.Interfaces) != 2 || got.Interfaces[0].Name != "eth0" || got.Interfaces[1].Files[0] != f {
+		t.Errorf("unexpected interfaces: %+v", got.Interfaces)
+	}
+	if host, _ := os.Hostname(); got.Hostname != host {
+		t.Errorf("expected hostname %q, got %q", host, got.Hostname)
+	}
+}
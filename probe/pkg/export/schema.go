@@ -0,0 +1,338 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the export documents (interface
+// files, manifest and combined export). It is bumped on any change of
+// their fields; consumers should reject versions they do not know.
+const SchemaVersion = 1
+
+// Document kinds, each with a JSON Schema generated from its Go type.
+const (
+	KindInterface = "interface"
+	KindManifest  = "manifest"
+	KindCombined  = "combined"
+)
+
+var documents = map[string]struct {
+	typ   reflect.Type
+	title string
+	file  string // published schema file name
+}{
+	KindInterface: {reflect.TypeFor[InterfaceData](), "l2radar interface export (neigh-<iface>.json)", "neigh.schema.json"},
+	KindManifest:  {reflect.TypeFor[Manifest](), "l2radar export manifest (" + ManifestFileName + ")", "manifest.schema.json"},
+	KindCombined:  {reflect.TypeFor[Combined](), "l2radar combined export (" + CombinedFileName + ")", "neighbours.schema.json"},
+}
+
+// Kinds returns the document kinds, sorted.
+func Kinds() []string {
+	kinds := make([]string, 0, len(documents))
+	for k := range documents {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// SchemaFileName returns the name of the published schema of kind.
+func SchemaFileName(kind string) string {
+	return documents[kind].file
+}
+
+// KindOf returns the document kind of an export file name: the manifest,
+// the combined export, or an interface file otherwise.
+func KindOf(name string) string {
+	switch name {
+	case ManifestFileName:
+		return KindManifest
+	case CombinedFileName:
+		return KindCombined
+	}
+	return KindInterface
+}
+
+// patterns are the string formats checked with a regular expression.
+var patterns = map[string]string{
+	"mac":    "^[0-9a-f]{2}(:[0-9a-f]{2}){5}$",
+	"sha256": "^[0-9a-f]{64}$",
+}
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of kind, generated
+// from its Go type: fields without omitempty are required, pointers may
+// be null, integers are not negative and unknown fields are rejected.
+//
+// The schema tag of a field refines it: "version" is the constant
+// SchemaVersion; on a string (or the items of a string slice) date-time,
+// duration, ipv4 and ipv6 are formats, mac and sha256 patterns, and a
+// ",empty" suffix on a pattern also accepts the empty string.
+func JSONSchema(kind string) (map[string]any, error) {
+	doc, ok := documents[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown document kind %q (supported: %s)", kind, strings.Join(Kinds(), ", "))
+	}
+	s := typeSchema(doc.typ, "")
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = doc.title
+	return s, nil
+}
+
+func typeSchema(t reflect.Type, format string) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := typeSchema(t.Elem(), format)
+		s["type"] = []any{s["type"], "null"}
+		return s
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []any{}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = typeSchema(f.Type, f.Tag.Get("schema"))
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), format)}
+	case reflect.String:
+		s := map[string]any{"type": "string"}
+		format, empty := strings.CutSuffix(format, ",empty")
+		if p, ok := patterns[format]; ok {
+			if empty {
+				p = "^$|" + p
+			}
+			s["pattern"] = p
+		} else if format != "" {
+			s["format"] = format
+		}
+		return s
+	case reflect.Int, reflect.Uint64:
+		if format == "version" {
+			return map[string]any{"type": "integer", "const": SchemaVersion}
+		}
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	}
+	panic("export: no JSON Schema for " + t.String())
+}
+
+// ValidationError is a violation of the schema at a JSON path.
+type ValidationError struct {
+	Path string
+	Msg  string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// Validate checks the JSON document b against the schema of kind. It
+// returns the violations found, or an error if b is not JSON or was
+// written with another schema version.
+func Validate(kind string, b []byte) ([]ValidationError, error) {
+	s, err := JSONSchema(kind)
+	if err != nil {
+		return nil, err
+	}
+	// The schema is marshalled and decoded again so that validation
+	// sees the published form.
+	sb, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(sb, &schema); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if obj, ok := doc.(map[string]any); ok {
+		switch v := obj["schema_version"].(type) {
+		case nil:
+			return nil, fmt.Errorf("no schema_version: written by a probe older than schema version 1")
+		case json.Number:
+			if v.String() != fmt.Sprint(SchemaVersion) {
+				return nil, fmt.Errorf("schema_version %s is not supported (this probe writes version %d)", v, SchemaVersion)
+			}
+		}
+	}
+
+	var vd validator
+	vd.validate(schema, doc, "$")
+	return vd.errs, nil
+}
+
+// validator checks a document against the subset of JSON Schema that
+// JSONSchema generates.
+type validator struct {
+	errs     []ValidationError
+	patterns map[string]*regexp.Regexp
+}
+
+func (vd *validator) validate(s map[string]any, v any, path string) {
+	fail := func(format string, args ...any) {
+		vd.errs = append(vd.errs, ValidationError{path, fmt.Sprintf(format, args...)})
+	}
+	if !typeMatches(s["type"], v) {
+		fail("expected %s, got %s", typeNames(s["type"]), jsonType(v))
+		return
+	}
+	if c, ok := s["const"]; ok && fmt.Sprint(c) != fmt.Sprint(v) {
+		fail("expected %v, got %v", c, v)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		for _, r := range s["required"].([]any) {
+			if _, ok := v[r.(string)]; !ok {
+				fail("missing required field %q", r)
+			}
+		}
+		for _, k := range sortedKeys(v) {
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				if s["additionalProperties"] == false {
+					fail("unknown field %q", k)
+				}
+				continue
+			}
+			vd.validate(ps, v[k], path+"."+k)
+		}
+	case []any:
+		items := s["items"].(map[string]any)
+		for i, item := range v {
+			vd.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case string:
+		p, _ := s["pattern"].(string)
+		if p != "" && !vd.regexp(p).MatchString(v) {
+			fail("%q does not match %s", v, p)
+		}
+		if f, ok := s["format"].(string); ok && !formatMatches(f, v) {
+			fail("%q is not a valid %s", v, f)
+		}
+	case json.Number:
+		if min, ok := s["minimum"].(float64); ok {
+			if n, err := v.Float64(); err == nil && n < min {
+				fail("%s is less than %v", v, min)
+			}
+		}
+	}
+}
+
+func (vd *validator) regexp(p string) *regexp.Regexp {
+	re, ok := vd.patterns[p]
+	if !ok {
+		if vd.patterns == nil {
+			vd.patterns = make(map[string]*regexp.Regexp)
+		}
+		re = regexp.MustCompile(p)
+		vd.patterns[p] = re
+	}
+	return re
+}
+
+func formatMatches(format, v string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	case "ipv4":
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil
+	case "ipv6":
+		return net.ParseIP(v) != nil && strings.Contains(v, ":")
+	case "duration":
+		_, err := time.ParseDuration(v)
+		return err == nil
+	}
+	return true
+}
+
+func typeMatches(t any, v any) bool {
+	if ts, ok := t.([]any); ok {
+		for _, t := range ts {
+			if typeMatches(t, v) {
+				return true
+			}
+		}
+		return false
+	}
+	got := jsonType(v)
+	return got == t || t == "number" && got == "integer"
+}
+
+func typeNames(t any) string {
+	if ts, ok := t.([]any); ok {
+		names := make([]string, len(ts))
+		for i, t := range ts {
+			names[i] = fmt.Sprint(t)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// jsonType returns the JSON Schema type of a value decoded with
+// UseNumber.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if _, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+var update = flag.Bool("update", false, "rewrite the published JSON Schemas")
+
+// TestSchemaFiles checks that the published schemas match the Go types;
+// run with -update after changing them.
+func TestSchemaFiles(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", "spec", "schema")
+	for _, kind := range Kinds() {
+		s, err := JSONSchema(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, '\n')
+		path := filepath.Join(dir, SchemaFileName(kind))
+		if *update {
+			if err := os.WriteFile(path, want, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading published schema: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date: run go test ./pkg/export -run TestSchemaFiles -update", path)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	s, err := JSONSchema(KindInterface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := s["properties"].(map[string]any)
+	if v := props["schema_version"].(map[string]any); v["const"] != SchemaVersion {
+		t.Errorf("schema_version should be the constant %d: %v", SchemaVersion, v)
+	}
+	if st := props["stats"].(map[string]any); len(st["type"].([]any)) != 2 {
+		t.Errorf("stats should be nullable: %v", st["type"])
+	}
+	n := props["neighbours"].(map[string]any)["items"].(map[string]any)
+	if mac := n["properties"].(map[string]any)["mac"].(map[string]any); mac["pattern"] != patterns["mac"] {
+		t.Errorf("unexpected neighbour mac schema: %v", mac)
+	}
+	if _, err := JSONSchema("pcap"); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+}
+
+func TestValidateGoldenFiles(t *testing.T) {
+	for _, file := range []string{"neigh-eth0.json", "neigh-wlan0.json"} {
+		b, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs, err := Validate(KindInterface, b)
+		if err != nil || len(errs) > 0 {
+			t.Errorf("%s: %v %v", file, err, errs)
+		}
+	}
+}
+
+func TestValidateWritten(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	neighbours := []dump.Neighbour{{
+		MAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		IPv4:      []net.IP{net.ParseIP("10.0.0.1").To4()},
+		IPv6:      []net.IP{net.ParseIP("fe80::1")},
+		FirstSeen: now,
+		LastSeen:  now,
+	}}
+	stats := &InterfaceStats{RxBytes: 1}
+	f, err := WriteJSON("eth0", neighbours, dir, now, 5*time.Second, nil, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := WriteCombined(NewCombined(now, map[string][]dump.Neighbour{"eth0": neighbours}), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManifest(now, 5*time.Second, []ManifestInterface{{Name: "eth0", Timestamp: "2026-10-18T12:00:00Z", Neighbours: 1, Files: []File{f}}}, &c)
+	if _, err := WriteManifest(m, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"neigh-eth0.json", CombinedFileName, ManifestFileName} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs, err := Validate(KindOf(name), b)
+		if err != nil || len(errs) > 0 {
+			t.Errorf("%s: %v %v", name, err, errs)
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	valid := `{"schema_version": 1, "interface": "eth0", "timestamp": "2026-10-18T12:00:00Z",
+		"export_interval": "5s", "mac": "", "ipv4": [], "ipv6": [], "stats": null, "neighbours": [%s]}`
+	neighbour := `{"mac": "02:00:00:00:00:01", "ipv4": ["10.0.0.1"], "ipv6": [], "first_seen": "2026-10-18T12:00:00Z", "last_seen": "2026-10-18T12:00:00Z"}`
+
+	tests := map[string]struct {
+		doc  string
+		want string // first violation
+	}{
+		"valid":         {strings.Replace(valid, "%s", neighbour, 1), ""},
+		"bad mac":       {strings.Replace(valid, "%s", strings.Replace(neighbour, "02:00:00:00:00:01", "02-00-00-00-00-01", 1), 1), "$.neighbours[0].mac: "},
+		"ipv6 in ipv4":  {strings.Replace(valid, "%s", strings.Replace(neighbour, `"10.0.0.1"`, `"fe80::1"`, 1), 1), "$.neighbours[0].ipv4[0]: "},
+		"null list":     {strings.Replace(valid, "%s", strings.Replace(neighbour, `"ipv6": []`, `"ipv6": null`, 1), 1), "$.neighbours[0].ipv6: expected array, got null"},
+		"bad time":      {strings.Replace(strings.Replace(valid, "%s", "", 1), "2026-10-18T12:00:00Z", "yesterday", 1), "$.timestamp: "},
+		"missing field": {strings.Replace(strings.Replace(valid, "%s", "", 1), `"mac": "", `, "", 1), `$: missing required field "mac"`},
+		"unknown field": {strings.Replace(strings.Replace(valid, "%s", "", 1), `"stats": null`, `"stats": null, "vlan": 3`, 1), `$: unknown field "vlan"`},
+		"negative":      {strings.Replace(strings.Replace(valid, "%s", "", 1), `"stats": null`, `"stats": {"tx_bytes": -1, "rx_bytes": 0, "tx_packets": 0, "rx_packets": 0, "tx_errors": 0, "rx_errors": 0, "tx_dropped": 0, "rx_dropped": 0}`, 1), "$.stats.tx_bytes: -1 is less than 0"},
+	}
+	for name, tt := range tests {
+		errs, err := Validate(KindInterface, []byte(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		switch {
+		case tt.want == "" && len(errs) > 0:
+			t.Errorf("%s: unexpected errors %v", name, errs)
+		case tt.want != "" && (len(errs) == 0 || !strings.HasPrefix(errs[0].Error(), tt.want)):
+			t.Errorf("%s: expected %q, got %v", name, tt.want, errs)
+		}
+	}
+
+	for doc, want := range map[string]string{
+		`{"interface": "eth0"}`:     "no schema_version",
+		`{"schema_version": 2}`:     "schema_version 2 is not supported",
+		`{"schema_version": 1`:      "invalid JSON",
+		`{"schema_version": "1.0"}`: "",
+	} {
+		_, err := Validate(KindInterface, []byte(doc))
+		if want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", doc, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", doc, want, err)
+		}
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	for name, want := range map[string]string{
+		"neigh-eth0.json": KindInterface,
+		"manifest.json":   KindManifest,
+		"neighbours.json": KindCombined,
+	} {
+		if got := KindOf(name); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
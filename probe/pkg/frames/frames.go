@@ -0,0 +1,189 @@
+// Package frames builds synthetic Ethernet frames understood by the eBPF
+// program: ARP, NDP (RS/RA/NS/NA), plain IPv4/IPv6 and 802.1Q-tagged
+// variants. Checksums are left zero since the program never validates them.
+package frames
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Ethertypes handled by the eBPF program.
+const (
+	EtherTypeIPv4 = 0x0800
+	EtherTypeARP  = 0x0806
+	EtherTypeVLAN = 0x8100
+	EtherTypeIPv6 = 0x86DD
+)
+
+// ARP opcodes.
+const (
+	ARPRequest = 1
+	ARPReply   = 2
+)
+
+// ICMPv6 NDP message types.
+const (
+	RouterSolicitation    = 133
+	RouterAdvertisement   = 134
+	NeighborSolicitation  = 135
+	NeighborAdvertisement = 136
+)
+
+// NDP option types.
+const (
+	OptSourceLLAddr = 1
+	OptTargetLLAddr = 2
+)
+
+// Broadcast is the Ethernet broadcast address.
+var Broadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// AllNodes is the IPv6 all-nodes multicast MAC (33:33:00:00:00:01).
+var AllNodes = net.HardwareAddr{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+
+// Ethernet constructs an untagged Ethernet frame.
+func Ethernet(dst, src net.HardwareAddr, etherType uint16, payload []byte) []byte {
+	frame := make([]byte, 14+len(payload))
+	copy(frame[0:6], dst)
+	copy(frame[6:12], src)
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+	copy(frame[14:], payload)
+	return frame
+}
+
+// VLAN inserts an 802.1Q tag with the given VLAN ID into an untagged frame.
+func VLAN(frame []byte, vlanID uint16) []byte {
+	tagged := make([]byte, len(frame)+4)
+	copy(tagged[0:12], frame[0:12])
+	binary.BigEndian.PutUint16(tagged[12:14], EtherTypeVLAN)
+	binary.BigEndian.PutUint16(tagged[14:16], vlanID&0x0fff)
+	copy(tagged[16:], frame[12:])
+	return tagged
+}
+
+// ARP constructs an IPv4-over-Ethernet ARP packet with Ethernet header.
+func ARP(ethDst, ethSrc net.HardwareAddr, opcode uint16,
+	senderMAC net.HardwareAddr, senderIP net.IP,
+	targetMAC net.HardwareAddr, targetIP net.IP) []byte {
+
+	arp := make([]byte, 28)
+	binary.BigEndian.PutUint16(arp[0:2], 1)      // htype = Ethernet
+	binary.BigEndian.PutUint16(arp[2:4], 0x0800) // ptype = IPv4
+	arp[4] = 6                                   // hlen
+	arp[5] = 4                                   // plen
+	binary.BigEndian.PutUint16(arp[6:8], opcode)
+	copy(arp[8:14], senderMAC)
+	copy(arp[14:18], senderIP.To4())
+	copy(arp[18:24], targetMAC)
+	copy(arp[24:28], targetIP.To4())
+
+	return Ethernet(ethDst, ethSrc, EtherTypeARP, arp)
+}
+
+// IPv4 constructs an IPv4/UDP frame with payloadLen zero bytes of payload.
+func IPv4(ethDst, ethSrc net.HardwareAddr, src, dst net.IP, payloadLen int) []byte {
+	pkt := make([]byte, 20+8+payloadLen)
+	pkt[0] = 0x45 // version 4, IHL 5
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	pkt[8] = 64 // TTL
+	pkt[9] = 17 // UDP
+	copy(pkt[12:16], src.To4())
+	copy(pkt[16:20], dst.To4())
+	binary.BigEndian.PutUint16(pkt[20:22], 40000)
+	binary.BigEndian.PutUint16(pkt[22:24], 9)
+	binary.BigEndian.PutUint16(pkt[24:26], uint16(8+payloadLen))
+	return Ethernet(ethDst, ethSrc, EtherTypeIPv4, pkt)
+}
+
+// ipv6Header constructs a minimal IPv6 header.
+func ipv6Header(src, dst net.IP, nextHeader uint8, payloadLen int) []byte {
+	hdr := make([]byte, 40)
+	hdr[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(hdr[4:6], uint16(payloadLen))
+	hdr[6] = nextHeader
+	hdr[7] = 255 // hop limit (required by NDP)
+	copy(hdr[8:24], src.To16())
+	copy(hdr[24:40], dst.To16())
+	return hdr
+}
+
+// IPv6 constructs an IPv6/UDP frame with payloadLen zero bytes of payload.
+func IPv6(ethDst, ethSrc net.HardwareAddr, src, dst net.IP, payloadLen int) []byte {
+	udp := make([]byte, 8+payloadLen)
+	binary.BigEndian.PutUint16(udp[0:2], 40000)
+	binary.BigEndian.PutUint16(udp[2:4], 9)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(udp)))
+	pkt := append(ipv6Header(src, dst, 17, len(udp)), udp...)
+	return Ethernet(ethDst, ethSrc, EtherTypeIPv6, pkt)
+}
+
+// NDPOption constructs a link-layer address NDP option (8 bytes).
+func NDPOption(optType uint8, mac net.HardwareAddr) []byte {
+	opt := make([]byte, 8)
+	opt[0] = optType
+	opt[1] = 1 // length in 8-byte units
+	copy(opt[2:8], mac)
+	return opt
+}
+
+// ndp wraps an ICMPv6 NDP body in IPv6 and Ethernet headers.
+func ndp(ethDst, ethSrc net.HardwareAddr, src, dst net.IP, body []byte) []byte {
+	pkt := append(ipv6Header(src, dst, 58, len(body)), body...)
+	return Ethernet(ethDst, ethSrc, EtherTypeIPv6, pkt)
+}
+
+// NS constructs a Neighbor Solicitation for target carrying a Source
+// Link-Layer Address option with the sender's MAC.
+func NS(ethSrc net.HardwareAddr, src, target net.IP) []byte {
+	body := make([]byte, 24, 32)
+	body[0] = NeighborSolicitation
+	copy(body[8:24], target.To16())
+	body = append(body, NDPOption(OptSourceLLAddr, ethSrc)...)
+	dst := solicitedNode(target)
+	return ndp(multicastMAC(dst), ethSrc, src, dst, body)
+}
+
+// NA constructs an unsolicited Neighbor Advertisement for target carrying
+// a Target Link-Layer Address option with the sender's MAC.
+func NA(ethSrc net.HardwareAddr, src, target net.IP) []byte {
+	body := make([]byte, 24, 32)
+	body[0] = NeighborAdvertisement
+	body[4] = 0x20 // O flag (override)
+	copy(body[8:24], target.To16())
+	body = append(body, NDPOption(OptTargetLLAddr, ethSrc)...)
+	return ndp(AllNodes, ethSrc, src, net.ParseIP("ff02::1"), body)
+}
+
+// RS constructs a Router Solicitation with a Source Link-Layer Address option.
+func RS(ethSrc net.HardwareAddr, src net.IP) []byte {
+	body := make([]byte, 8, 16)
+	body[0] = RouterSolicitation
+	body = append(body, NDPOption(OptSourceLLAddr, ethSrc)...)
+	dst := net.ParseIP("ff02::2")
+	return ndp(multicastMAC(dst), ethSrc, src, dst, body)
+}
+
+// RA constructs a Router Advertisement with a Source Link-Layer Address option.
+func RA(ethSrc net.HardwareAddr, src net.IP) []byte {
+	body := make([]byte, 16, 24)
+	body[0] = RouterAdvertisement
+	body[4] = 64 // current hop limit
+	body = append(body, NDPOption(OptSourceLLAddr, ethSrc)...)
+	return ndp(AllNodes, ethSrc, src, net.ParseIP("ff02::1"), body)
+}
+
+// solicitedNode returns the solicited-node multicast address for ip.
+func solicitedNode(ip net.IP) net.IP {
+	sn := net.ParseIP("ff02::1:ff00:0")
+	ip16 := ip.To16()
+	copy(sn[13:16], ip16[13:16])
+	return sn
+}
+
+// multicastMAC returns the Ethernet multicast address for an IPv6
+// multicast group (33:33 followed by the low 32 bits).
+func multicastMAC(ip net.IP) net.HardwareAddr {
+	ip16 := ip.To16()
+	return net.HardwareAddr{0x33, 0x33, ip16[12], ip16[13], ip16[14], ip16[15]}
+}
@@ -0,0 +1,117 @@
+package frames
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+var (
+	testMAC = net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	testDst = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+)
+
+func TestEthernet(t *testing.T) {
+	f := Ethernet(testDst, testMAC, EtherTypeIPv4, []byte{1, 2, 3})
+	if len(f) != 17 {
+		t.Fatalf("expected 17 bytes, got %d", len(f))
+	}
+	if !bytes.Equal(f[0:6], testDst) || !bytes.Equal(f[6:12], testMAC) {
+		t.Error("wrong MAC addresses")
+	}
+	if binary.BigEndian.Uint16(f[12:14]) != EtherTypeIPv4 {
+		t.Error("wrong ethertype")
+	}
+}
+
+func TestVLAN(t *testing.T) {
+	f := ARP(Broadcast, testMAC, ARPRequest, testMAC, net.ParseIP("10.0.0.1"), nil, net.ParseIP("10.0.0.2"))
+	tagged := VLAN(f, 100)
+	if len(tagged) != len(f)+4 {
+		t.Fatalf("expected %d bytes, got %d", len(f)+4, len(tagged))
+	}
+	if binary.BigEndian.Uint16(tagged[12:14]) != EtherTypeVLAN {
+		t.Error("expected 802.1Q TPID")
+	}
+	if binary.BigEndian.Uint16(tagged[14:16]) != 100 {
+		t.Error("expected VLAN ID 100")
+	}
+	if binary.BigEndian.Uint16(tagged[16:18]) != EtherTypeARP {
+		t.Error("expected inner ARP ethertype")
+	}
+	if !bytes.Equal(tagged[18:], f[14:]) {
+		t.Error("payload not preserved")
+	}
+}
+
+func TestARP(t *testing.T) {
+	f := ARP(testDst, testMAC, ARPReply, testMAC, net.ParseIP("192.168.1.1"), testDst, net.ParseIP("192.168.1.2"))
+	arp := f[14:]
+	if len(arp) != 28 {
+		t.Fatalf("expected 28-byte ARP payload, got %d", len(arp))
+	}
+	if binary.BigEndian.Uint16(arp[6:8]) != ARPReply {
+		t.Error("wrong opcode")
+	}
+	if !net.IP(arp[14:18]).Equal(net.ParseIP("192.168.1.1")) {
+		t.Errorf("wrong sender IP %v", net.IP(arp[14:18]))
+	}
+	if !net.IP(arp[24:28]).Equal(net.ParseIP("192.168.1.2")) {
+		t.Errorf("wrong target IP %v", net.IP(arp[24:28]))
+	}
+}
+
+func TestNDPMessages(t *testing.T) {
+	src := net.ParseIP("fe80::1")
+	target := net.ParseIP("fe80::2")
+
+	cases := []struct {
+		name      string
+		frame     []byte
+		icmpType  uint8
+		optOffset int
+		optType   uint8
+	}{
+		{"ns", NS(testMAC, src, target), NeighborSolicitation, 24, OptSourceLLAddr},
+		{"na", NA(testMAC, src, target), NeighborAdvertisement, 24, OptTargetLLAddr},
+		{"rs", RS(testMAC, src), RouterSolicitation, 8, OptSourceLLAddr},
+		{"ra", RA(testMAC, src), RouterAdvertisement, 16, OptSourceLLAddr},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if binary.BigEndian.Uint16(tc.frame[12:14]) != EtherTypeIPv6 {
+				t.Fatal("expected IPv6 ethertype")
+			}
+			if tc.frame[0]&0x01 == 0 {
+				t.Error("expected multicast destination MAC")
+			}
+			ip6 := tc.frame[14:]
+			if ip6[6] != 58 {
+				t.Fatalf("expected ICMPv6 next header, got %d", ip6[6])
+			}
+			if int(binary.BigEndian.Uint16(ip6[4:6])) != len(ip6)-40 {
+				t.Error("wrong IPv6 payload length")
+			}
+			icmp := ip6[40:]
+			if icmp[0] != tc.icmpType {
+				t.Errorf("expected ICMPv6 type %d, got %d", tc.icmpType, icmp[0])
+			}
+			opt := icmp[tc.optOffset:]
+			if opt[0] != tc.optType || opt[1] != 1 || !bytes.Equal(opt[2:8], testMAC) {
+				t.Errorf("unexpected option %x", opt)
+			}
+		})
+	}
+}
+
+func TestNSSolicitedNodeDestination(t *testing.T) {
+	f := NS(testMAC, net.ParseIP("fe80::1"), net.ParseIP("fe80::aa:bbcc"))
+	want := net.HardwareAddr{0x33, 0x33, 0xff, 0xaa, 0xbb, 0xcc}
+	if !bytes.Equal(f[0:6], want) {
+		t.Errorf("expected destination %s, got %s", want, net.HardwareAddr(f[0:6]))
+	}
+	if !net.IP(f[14+24 : 14+40]).Equal(net.ParseIP("ff02::1:ffaa:bbcc")) {
+		t.Errorf("unexpected IPv6 destination %s", net.IP(f[14+24:14+40]))
+	}
+}
@@ -0,0 +1,287 @@
+// Package influx writes per-interface counts and per-neighbour activity to
+// InfluxDB in line protocol over HTTP, using the v2 write API (org, bucket,
+// token) or the v1 one (database, username, password).
+//
+// It is configured as a sink of type "influxdb" in the probe configuration
+// file.
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// Type is the sink type in the configuration file.
+const Type = "influxdb"
+
+// Measurements written.
+const (
+	MeasurementInterface = "l2radar_interface"
+	MeasurementNeighbour = "l2radar_neighbour"
+)
+
+// DefaultBatchSize is the maximum number of points per request when none
+// is configured.
+const DefaultBatchSize = 5000
+
+// Options are the type-specific keys of an influxdb sink. Org, Bucket and
+// Token select the v2 API; Database, RetentionPolicy, Username and
+// Password the v1 one.
+type Options struct {
+	// URL is the base URL of the server, e.g. http://influxdb:8086.
+	URL string `yaml:"url"`
+
+	Org       string `yaml:"org,omitempty"`
+	Bucket    string `yaml:"bucket,omitempty"`
+	Token     string `yaml:"token,omitempty"`
+	TokenFile string `yaml:"token_file,omitempty"`
+
+	Database        string `yaml:"database,omitempty"`
+	RetentionPolicy string `yaml:"retention_policy,omitempty"`
+	Username        string `yaml:"username,omitempty"`
+	Password        string `yaml:"password,omitempty"`
+	PasswordFile    string `yaml:"password_file,omitempty"`
+
+	// BatchSize is the maximum number of points per request.
+	BatchSize int           `yaml:"batch_size,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+}
+
+// ParseOptions decodes and validates the options of an influxdb sink and
+// fills defaults. Token and password files are read into Token and
+// Password.
+func ParseOptions(s config.Sink) (Options, error) {
+	var o Options
+	if err := s.Decode(&o); err != nil {
+		return o, err
+	}
+	if err := sink.CheckHTTPURL("url", o.URL); err != nil {
+		return o, err
+	}
+	var err error
+	if o.Token, err = sink.ReadSecret(o.Token, o.TokenFile, "token"); err != nil {
+		return o, err
+	}
+	if o.Password, err = sink.ReadSecret(o.Password, o.PasswordFile, "password"); err != nil {
+		return o, err
+	}
+	v2 := o.Org != "" || o.Bucket != "" || o.Token != ""
+	v1 := o.Database != "" || o.RetentionPolicy != "" || o.Username != "" || o.Password != ""
+	switch {
+	case v1 && v2:
+		return o, fmt.Errorf("org, bucket and token (v2) cannot be combined with database, username and password (v1)")
+	case v2 && o.Bucket == "":
+		return o, fmt.Errorf("bucket is required with org or token")
+	case !v2 && o.Database == "":
+		return o, fmt.Errorf("bucket (v2) or database (v1) is required")
+	}
+	if o.BatchSize < 0 || o.Timeout < 0 {
+		return o, fmt.Errorf("negative batch_size or timeout")
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.Timeout == 0 {
+		o.Timeout = sink.DefaultTimeout
+	}
+	return o, nil
+}
+
+// Sink turns the snapshots of the selected interfaces into points and
+// writes them from Run.
+type Sink struct {
+	opts     Options
+	ifaces   []string // all if empty
+	host     string
+	writeURL string
+	hc       *http.Client
+
+	mu       sync.Mutex
+	lastSeen map[string]map[string]time.Time // by interface and MAC
+	batcher  *sink.Batcher[string]
+}
+
+var _ sink.Sink = (*Sink)(nil)
+
+func init() {
+	sink.Register(Type, func(s config.Sink, logger *slog.Logger) (sink.Sink, error) {
+		return New(s, logger)
+	})
+}
+
+// New returns a sink for the influxdb entry s. Nothing is written until
+// Run.
+func New(s config.Sink, logger *slog.Logger) (*Sink, error) {
+	o, err := ParseOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	host, _ := os.Hostname()
+	sk := &Sink{
+		opts:     o,
+		ifaces:   s.Interfaces,
+		host:     host,
+		writeURL: writeURL(o),
+		hc:       &http.Client{Timeout: o.Timeout},
+		lastSeen: make(map[string]map[string]time.Time),
+	}
+	sk.batcher = sink.NewBatcher(sink.BatcherOptions{
+		Name:          Type,
+		Items:         "points",
+		Target:        []any{"url", o.URL},
+		BatchSize:     o.BatchSize,
+		RetryInterval: sink.DefaultRetryInterval,
+		Logger:        logger,
+	}, sk.write)
+	return sk, nil
+}
+
+// writeURL returns the write endpoint of the configured API version.
+func writeURL(o Options) string {
+	q := url.Values{"precision": {"s"}}
+	path := "/write"
+	if o.Bucket != "" {
+		path = "/api/v2/write"
+		q.Set("bucket", o.Bucket)
+		if o.Org != "" {
+			q.Set("org", o.Org)
+		}
+	} else {
+		q.Set("db", o.Database)
+		if o.RetentionPolicy != "" {
+			q.Set("rp", o.RetentionPolicy)
+		}
+	}
+	return strings.TrimSuffix(o.URL, "/") + path + "?" + q.Encode()
+}
+
+// Observe queues a point with the interface's counts and one per neighbour
+// seen since the interface's previous snapshot (all on the first one).
+func (s *Sink) Observe(snap daemon.Snapshot) {
+	if !sink.Selects(s.ifaces, snap.Interface) {
+		return
+	}
+	cur := make(map[string]time.Time, len(snap.Neighbours))
+	for _, n := range snap.Neighbours {
+		cur[n.MAC.String()] = n.LastSeen
+	}
+	s.mu.Lock()
+	prev := s.lastSeen[snap.Interface]
+	s.lastSeen[snap.Interface] = cur
+	s.mu.Unlock()
+
+	ts := snap.Time.Unix()
+	tags := []string{"host", s.host, "interface", snap.Interface}
+	var active []string
+	for _, n := range snap.Neighbours {
+		mac := n.MAC.String()
+		if seen, ok := prev[mac]; ok && !n.LastSeen.After(seen) {
+			continue
+		}
+		t := append(slices.Clip(tags), "mac", mac)
+		if vendor := oui.Lookup(n.MAC); vendor != "" {
+			t = append(t, "vendor", vendor)
+		}
+		active = append(active, line(MeasurementNeighbour, t, []field{
+			{"ipv4", n.IPv4String()},
+			{"ipv6", n.IPv6String()},
+			{"first_seen", n.FirstSeen.Unix()},
+			{"last_seen", n.LastSeen.Unix()},
+		}, ts))
+	}
+	s.batcher.Push(append([]string{interfacePoint(snap, tags, len(active), ts)}, active...)...)
+}
+
+// interfacePoint returns the l2radar_interface point of a snapshot where
+// active neighbours were seen since the previous one.
+func interfacePoint(snap daemon.Snapshot, tags []string, active int, ts int64) string {
+	var v4, v6 int64
+	for _, n := range snap.Neighbours {
+		if len(n.IPv4) > 0 {
+			v4++
+		}
+		if len(n.IPv6) > 0 {
+			v6++
+		}
+	}
+	fields := []field{
+		{"neighbours", int64(len(snap.Neighbours))},
+		{"neighbours_active", int64(active)},
+		{"neighbours_ipv4", v4},
+		{"neighbours_ipv6", v6},
+	}
+	if st := snap.Stats; st != nil {
+		fields = append(fields,
+			field{"rx_bytes", st.RxBytes}, field{"rx_packets", st.RxPackets},
+			field{"rx_errors", st.RxErrors}, field{"rx_dropped", st.RxDropped},
+			field{"tx_bytes", st.TxBytes}, field{"tx_packets", st.TxPackets},
+			field{"tx_errors", st.TxErrors}, field{"tx_dropped", st.TxDropped})
+	}
+	return line(MeasurementInterface, tags, fields, ts)
+}
+
+// Detached forgets the interface's neighbours.
+func (s *Sink) Detached(iface string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lastSeen, iface)
+}
+
+// Run writes queued points until ctx is cancelled, then writes what is
+// left. Failed writes are retried every 10s; points wait meanwhile.
+func (s *Sink) Run(ctx context.Context) {
+	defer s.hc.CloseIdleConnections()
+	s.batcher.Run(ctx)
+}
+
+// write sends lines in one request. It returns them to retry on network
+// errors, 429 and 5xx responses; lines the server rejects are dropped.
+func (s *Sink) write(ctx context.Context, lines []string) (retry []string, err error) {
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "l2radar")
+	switch {
+	case s.opts.Token != "":
+		req.Header.Set("Authorization", "Token "+s.opts.Token)
+	case s.opts.Username != "":
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+
+	resp, err := s.hc.Do(req)
+	if err != nil {
+		return lines, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("POST %s: %s: %s", s.opts.URL, resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return lines, err
+	}
+	return nil, err
+}
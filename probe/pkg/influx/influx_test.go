@@ -0,0 +1,179 @@
+package influx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+func TestLine(t *testing.T) {
+	got := line("m 1", []string{"host", "a b", "interface", "eth0", "vendor", ""}, []field{
+		{"s", `say "hi"\`},
+		{"i", int64(-3)},
+		{"u", uint64(7)},
+	}, 1700000000)
+	want := `m\ 1,host=a\ b,interface=eth0 s="say \"hi\"\\",i=-3i,u=7i 1700000000`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+// receiver records the requests of a fake InfluxDB server.
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	status   int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, string(body))
+	if r.status != 0 {
+		http.Error(w, "unavailable", r.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWrite(t *testing.T) {
+	r := &receiver{}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	s, err := New(config.Sink{Type: Type, Interfaces: []string{"eth0"}, Options: map[string]any{
+		"url": srv.URL, "org": "home", "bucket": "l2radar", "token": "t0ken",
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.host = "probe-1"
+
+	t0 := time.Unix(1700000000, 0)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: t0,
+		Neighbours: []dump.Neighbour{testutil.Neighbour("02:00:00:00:00:01", t0.Add(-time.Hour), t0, "10.0.0.1"), testutil.Neighbour("02:00:00:00:00:02", t0.Add(-time.Hour), t0)},
+		Stats:      &export.InterfaceStats{RxBytes: 100, TxBytes: 200},
+	})
+	// Only 02:00:00:00:00:02 was seen again.
+	t1 := t0.Add(time.Minute)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: t1,
+		Neighbours: []dump.Neighbour{testutil.Neighbour("02:00:00:00:00:01", t0.Add(-time.Hour), t0, "10.0.0.1"), testutil.Neighbour("02:00:00:00:00:02", t1.Add(-time.Hour), t1)},
+	})
+	s.Observe(daemon.Snapshot{Interface: "eth1", Time: t1, Neighbours: []dump.Neighbour{testutil.Neighbour("02:00:00:00:00:03", t1.Add(-time.Hour), t1)}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx)
+
+	if len(r.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(r.requests))
+	}
+	req := r.requests[0]
+	if req.URL.Path != "/api/v2/write" || req.URL.Query().Get("bucket") != "l2radar" || req.URL.Query().Get("org") != "home" || req.URL.Query().Get("precision") != "s" {
+		t.Errorf("unexpected URL %s", req.URL)
+	}
+	if got := req.Header.Get("Authorization"); got != "Token t0ken" {
+		t.Errorf("Authorization = %q", got)
+	}
+	want := strings.Join([]string{
+		`l2radar_interface,host=probe-1,interface=eth0 neighbours=2i,neighbours_active=2i,neighbours_ipv4=1i,neighbours_ipv6=0i,rx_bytes=100i,rx_packets=0i,rx_errors=0i,rx_dropped=0i,tx_bytes=200i,tx_packets=0i,tx_errors=0i,tx_dropped=0i 1700000000`,
+		`l2radar_neighbour,host=probe-1,interface=eth0,mac=02:00:00:00:00:01 ipv4="10.0.0.1",ipv6="",first_seen=1699996400i,last_seen=1700000000i 1700000000`,
+		`l2radar_neighbour,host=probe-1,interface=eth0,mac=02:00:00:00:00:02 ipv4="",ipv6="",first_seen=1699996400i,last_seen=1700000000i 1700000000`,
+		`l2radar_interface,host=probe-1,interface=eth0 neighbours=2i,neighbours_active=1i,neighbours_ipv4=1i,neighbours_ipv6=0i 1700000060`,
+		`l2radar_neighbour,host=probe-1,interface=eth0,mac=02:00:00:00:00:02 ipv4="",ipv6="",first_seen=1699996460i,last_seen=1700000060i 1700000060`,
+	}, "\n") + "\n"
+	if r.bodies[0] != want {
+		t.Errorf("got body\n%s\nwant\n%s", r.bodies[0], want)
+	}
+}
+
+func TestWriteV1(t *testing.T) {
+	r := &receiver{}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	pw := filepath.Join(t.TempDir(), "pw")
+	os.WriteFile(pw, []byte("s3cret\n"), 0o600)
+	s, err := New(config.Sink{Type: Type, Options: map[string]any{
+		"url": srv.URL + "/", "database": "l2radar", "retention_policy": "week", "username": "probe", "password_file": pw,
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: time.Unix(1700000000, 0)})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx)
+
+	if len(r.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(r.requests))
+	}
+	req := r.requests[0]
+	if req.URL.Path != "/write" || req.URL.Query().Get("db") != "l2radar" || req.URL.Query().Get("rp") != "week" {
+		t.Errorf("unexpected URL %s", req.URL)
+	}
+	if u, p, ok := req.BasicAuth(); !ok || u != "probe" || p != "s3cret" {
+		t.Errorf("unexpected basic auth %q %q", u, p)
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	r := &receiver{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	s, err := New(config.Sink{Type: Type, Options: map[string]any{"url": srv.URL, "database": "l2radar"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: time.Unix(1700000000, 0)})
+	if s.batcher.Send(context.Background()) || s.batcher.Len() != 1 {
+		t.Errorf("expected the point to stay queued after a 503, got %d", s.batcher.Len())
+	}
+	r.status = http.StatusBadRequest
+	if s.batcher.Send(context.Background()) || s.batcher.Len() != 0 {
+		t.Errorf("expected the point to be dropped after a 400, got %d", s.batcher.Len())
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(config.Sink{Type: Type, Options: map[string]any{"url": "http://influxdb:8086", "bucket": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.BatchSize != DefaultBatchSize || o.Timeout != sink.DefaultTimeout {
+		t.Errorf("defaults not applied: %+v", o)
+	}
+
+	bad := map[string]map[string]any{
+		"no url":      {"bucket": "b"},
+		"bad url":     {"url": "influxdb:8086", "bucket": "b"},
+		"no target":   {"url": "http://influxdb:8086"},
+		"no bucket":   {"url": "http://influxdb:8086", "org": "o", "token": "t"},
+		"v1 and v2":   {"url": "http://influxdb:8086", "bucket": "b", "database": "d"},
+		"both tokens": {"url": "http://influxdb:8086", "bucket": "b", "token": "t", "token_file": "/x"},
+		"unknown key": {"url": "http://influxdb:8086", "bucket": "b", "measurement": "x"},
+	}
+	for name, opts := range bad {
+		if _, err := ParseOptions(config.Sink{Type: Type, Options: opts}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package influx
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// field is a line protocol field: value is a string, int64 or uint64.
+type field struct {
+	key   string
+	value any
+}
+
+// line renders a point in line protocol with a timestamp in seconds. tags
+// are key/value pairs; those with an empty value are omitted.
+func line(measurement string, tags []string, fields []field, ts int64) string {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(measurement))
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] == "" {
+			continue
+		}
+		b.WriteString("," + keyEscaper.Replace(tags[i]) + "=" + keyEscaper.Replace(tags[i+1]))
+	}
+	for i, f := range fields {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(keyEscaper.Replace(f.key) + "=")
+		switch v := f.value.(type) {
+		case string:
+			b.WriteString(`"` + stringEscaper.Replace(v) + `"`)
+		case int64:
+			b.WriteString(strconv.FormatInt(v, 10) + "i")
+		case uint64:
+			// As an integer: unsigned fields are not enabled on InfluxDB 1.x.
+			b.WriteString(strconv.FormatUint(min(v, math.MaxInt64), 10) + "i")
+		}
+	}
+	b.WriteString(" " + strconv.FormatInt(ts, 10))
+	return b.String()
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
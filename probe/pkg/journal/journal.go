@@ -0,0 +1,296 @@
+// Package journal keeps an append-only audit trail of neighbour changes
+// (see events.Tracker) as NDJSON in the export directory, next to the
+// neigh-<iface>.json snapshots.
+//
+// The active file is journal.ndjson. It is rotated to
+// journal-<UTC time>.ndjson once it reaches a size or age limit, and
+// rotated files beyond a count or age are deleted. Reader reads the
+// rotated files and the active one in order, and can follow the latter
+// across rotations.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// FileName is the name of the active journal file.
+const FileName = "journal.ndjson"
+
+const (
+	rotatedPrefix = "journal-"
+	rotatedSuffix = ".ndjson"
+	rotatedTime   = "20060102T150405Z"
+)
+
+// Entry is a journal line: a change with the vendor of its MAC.
+type Entry struct {
+	events.Change
+	Vendor string `json:"vendor,omitempty"`
+}
+
+// Options configure a Journal.
+type Options struct {
+	// Dir is the export directory.
+	Dir string
+	// MaxSize rotates the active file before it exceeds this many bytes
+	// (no limit if 0).
+	MaxSize int64
+	// MaxAge rotates the active file once its first entry is this old (no
+	// limit if 0).
+	MaxAge time.Duration
+	// Keep is the number of rotated files kept (no limit if 0).
+	Keep int
+	// Retention deletes rotated files last written longer ago than this
+	// (no limit if 0).
+	Retention   time.Duration
+	ExpireAfter time.Duration
+	Logger      *slog.Logger
+}
+
+// Journal appends the changes of every interface to the active file. It
+// implements daemon.Observer; writes happen in Observe, like the export
+// files, and rotation when an entry would exceed a limit.
+type Journal struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	tracker *events.Tracker
+	f       *os.File
+	size    int64
+	started time.Time // time of the first entry of the active file
+}
+
+var _ daemon.Observer = (*Journal)(nil)
+
+// Open opens (or creates) the active journal file of o.Dir for appending.
+func Open(o Options) (*Journal, error) {
+	if o.ExpireAfter == 0 {
+		o.ExpireAfter = sink.DefaultExpireAfter
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	j := &Journal{opts: o, logger: o.Logger, tracker: events.NewTracker(o.ExpireAfter)}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	j.prune(time.Now())
+	return j, nil
+}
+
+func (j *Journal) path() string {
+	return filepath.Join(j.opts.Dir, FileName)
+}
+
+func (j *Journal) open() error {
+	f, err := os.OpenFile(j.path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	j.f, j.size, j.started = f, fi.Size(), time.Time{}
+	if j.size > 0 {
+		j.started = firstEntryTime(j.path(), fi.ModTime())
+	}
+	return nil
+}
+
+// firstEntryTime returns the time of the first entry of path, or fallback
+// if it cannot be read.
+func firstEntryTime(path string, fallback time.Time) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return fallback
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	var e Entry
+	if err != nil || json.Unmarshal(line, &e) != nil || e.Time.IsZero() {
+		return fallback
+	}
+	return e.Time
+}
+
+// Observe appends the changes since the interface's previous snapshot.
+func (j *Journal) Observe(snap daemon.Snapshot) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var b []byte
+	for _, c := range j.tracker.Update(snap) {
+		e := Entry{Change: c}
+		if hw, err := net.ParseMAC(c.MAC); err == nil {
+			e.Vendor = oui.Lookup(hw)
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			j.logger.Error("journal: encoding entry", "error", err)
+			continue
+		}
+		b = append(append(b, line...), '\n')
+	}
+	if len(b) == 0 || j.f == nil {
+		return
+	}
+	if j.rotationDue(int64(len(b)), snap.Time) {
+		if err := j.rotate(snap.Time); err != nil {
+			j.logger.Error("journal: rotation failed", "error", err)
+			if j.f == nil {
+				return
+			}
+		}
+	}
+	n, err := j.f.Write(b)
+	if j.size == 0 {
+		j.started = snap.Time
+	}
+	j.size += int64(n)
+	if err != nil {
+		j.logger.Error("journal: write failed", "path", j.path(), "error", err)
+	}
+}
+
+// rotationDue reports whether writing n bytes at now must go to a new
+// file.
+func (j *Journal) rotationDue(n int64, now time.Time) bool {
+	if j.size == 0 {
+		return false
+	}
+	return (j.opts.MaxSize > 0 && j.size+n > j.opts.MaxSize) ||
+		(j.opts.MaxAge > 0 && now.Sub(j.started) >= j.opts.MaxAge)
+}
+
+// Detached forgets the interface's table.
+func (j *Journal) Detached(iface string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tracker.Forget(iface)
+}
+
+// rotate renames the active file to journal-<now>.ndjson, opens a new one
+// and deletes rotated files beyond the limits.
+func (j *Journal) rotate(now time.Time) error {
+	if err := j.f.Close(); err != nil {
+		j.logger.Warn("journal: closing before rotation", "error", err)
+	}
+	j.f = nil
+	name := rotatedPrefix + now.UTC().Format(rotatedTime)
+	dst := filepath.Join(j.opts.Dir, name+rotatedSuffix)
+	for i := 1; fileExists(dst); i++ {
+		dst = filepath.Join(j.opts.Dir, fmt.Sprintf("%s-%d%s", name, i, rotatedSuffix))
+	}
+	renameErr := os.Rename(j.path(), dst)
+	if err := j.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	j.prune(now)
+	return nil
+}
+
+// prune deletes the oldest rotated files beyond Keep, and those last
+// written before Retention.
+func (j *Journal) prune(now time.Time) {
+	files, err := rotated(j.opts.Dir)
+	if err != nil {
+		j.logger.Warn("journal: listing rotated files", "error", err)
+		return
+	}
+	for i, f := range files {
+		expired := j.opts.Retention > 0 && now.Sub(f.modTime) > j.opts.Retention
+		if (j.opts.Keep <= 0 || len(files)-i <= j.opts.Keep) && !expired {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			j.logger.Warn("journal: deleting rotated file", "path", f.path, "error", err)
+		}
+	}
+}
+
+// Close closes the active file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// rotated lists the rotated files of dir, oldest first.
+func rotated(dir string) ([]rotatedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []rotatedFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, rotatedPrefix) || !strings.HasSuffix(name, rotatedSuffix) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{filepath.Join(dir, name), fi.ModTime()})
+	}
+	sort.SliceStable(files, func(a, b int) bool {
+		if !files[a].modTime.Equal(files[b].modTime) {
+			return files[a].modTime.Before(files[b].modTime)
+		}
+		return files[a].path < files[b].path
+	})
+	return files, nil
+}
+
+// Files returns the journal files of dir, oldest first: the rotated ones,
+// then the active one if it exists.
+func Files(dir string) ([]string, error) {
+	files, err := rotated(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	if active := filepath.Join(dir, FileName); fileExists(active) {
+		paths = append(paths, active)
+	}
+	return paths, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,195 @@
+package journal
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/events"
+)
+
+// readAll returns the entries of dir matching f, as "type mac ip".
+func readAll(t *testing.T, dir string, f Filter) []string {
+	t.Helper()
+	r := NewReader(dir, f)
+	defer r.Close()
+	var got []string
+	if err := r.ReadAll(func(e Entry) error {
+		got = append(got, strings.TrimSpace(e.Type+" "+e.MAC+" "+e.IP))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Add(time.Second)
+	j.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now, "10.0.0.1"),
+	}})
+	j.Observe(daemon.Snapshot{Interface: "eth1", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:02", now, now, "10.0.0.1"),
+	}})
+	j.Observe(daemon.Snapshot{Interface: "eth0", Time: now.Add(time.Minute), Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now, "10.0.0.1", "10.0.0.2"),
+	}})
+	j.Close()
+
+	// Reopening appends.
+	j, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Observe(daemon.Snapshot{Interface: "eth0", Time: now.Add(2 * time.Minute)})
+	j.Close()
+
+	want := []string{
+		"new_mac 02:00:00:00:00:01",
+		"new_ip 02:00:00:00:00:01 10.0.0.1",
+		"new_mac 02:00:00:00:00:02",
+		"new_ip 02:00:00:00:00:02 10.0.0.1",
+		"new_ip 02:00:00:00:00:01 10.0.0.2",
+	}
+	if got := readAll(t, dir, Filter{}); !slices.Equal(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	filters := map[string]struct {
+		f    Filter
+		want []string
+	}{
+		"iface": {Filter{Interfaces: []string{"eth1"}}, want[2:4]},
+		"type":  {Filter{Types: []string{events.ChangeNewMAC}}, []string{want[0], want[2]}},
+		"mac":   {Filter{MAC: "02:00:00:00:00:0A"}, nil},
+		"ip":    {Filter{IP: "10.0.0.2"}, want[4:]},
+		"since": {Filter{Since: now.Add(time.Second)}, want[4:]},
+		"until": {Filter{Until: now.Add(time.Second)}, want[:4]},
+	}
+	for name, tt := range filters {
+		if got := readAll(t, dir, tt.f); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+// snapshot returns a snapshot of eth0 at now with n new MACs, numbered
+// from first.
+func snapshot(now time.Time, first, n int) daemon.Snapshot {
+	var ns []dump.Neighbour
+	for i := range first + n {
+		hw := net.HardwareAddr{2, 0, 0, 0, byte(i >> 8), byte(i)}
+		ns = append(ns, testutil.Neighbour(hw.String(), now, now))
+	}
+	return daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: ns}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	// Each new_mac line is about 100 bytes: rotate every 2 snapshots.
+	j, err := Open(Options{Dir: dir, MaxSize: 300, Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	now := time.Now().Add(time.Second)
+	for i := range 8 {
+		j.Observe(snapshot(now.Add(time.Duration(i)*time.Minute), i, 1))
+	}
+
+	files, _ := Files(dir)
+	if len(files) != 3 || filepath.Base(files[2]) != FileName {
+		t.Fatalf("expected 2 rotated files and the active one, got %q", files)
+	}
+	got := readAll(t, dir, Filter{})
+	if len(got) != 6 || got[0] != "new_mac 02:00:00:00:00:02" || got[5] != "new_mac 02:00:00:00:00:07" {
+		t.Errorf("unexpected entries after pruning: %q", got)
+	}
+
+	// Age-based rotation.
+	j.opts.MaxSize, j.opts.MaxAge = 0, time.Hour
+	j.Observe(snapshot(now.Add(2*time.Hour), 8, 1))
+	files, _ = Files(dir)
+	got = readAll(t, dir, Filter{})
+	if len(files) != 3 || len(got) != 5 || got[0] != "new_mac 02:00:00:00:00:04" || got[4] != "new_mac 02:00:00:00:00:08" {
+		t.Errorf("expected rotation by age, got %q: %q", files, got)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "journal-20200101T000000Z.ndjson")
+	os.WriteFile(old, nil, 0o644)
+	os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	recent := filepath.Join(dir, "journal-20200102T000000Z.ndjson")
+	os.WriteFile(recent, nil, 0o644)
+
+	j, err := Open(Options{Dir: dir, Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+	if _, err := os.Stat(old); err == nil {
+		t.Error("file older than retention not deleted")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Error("recent file deleted")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(Options{Dir: dir, MaxSize: 250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	now := time.Now().Add(time.Second)
+	j.Observe(snapshot(now, 0, 1))
+
+	r := NewReader(dir, Filter{})
+	defer r.Close()
+	got := make(chan string, 10)
+	fn := func(e Entry) error {
+		got <- e.MAC
+		return nil
+	}
+	if err := r.ReadAll(fn); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Follow(ctx, fn) }()
+
+	// The second snapshot fills the file, the third rotates it.
+	for i := 1; i < 3; i++ {
+		j.Observe(snapshot(now.Add(time.Duration(i)*time.Minute), i, 1))
+	}
+	for i := range 3 {
+		select {
+		case mac := <-got:
+			if want := (net.HardwareAddr{2, 0, 0, 0, 0, byte(i)}).String(); mac != want {
+				t.Errorf("entry %d: got %s, want %s", i, mac, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for entry %d", i)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
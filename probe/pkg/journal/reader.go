@@ -0,0 +1,209 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PollInterval is how often Follow checks the active file for new entries.
+const PollInterval = 250 * time.Millisecond
+
+// Filter selects journal entries. Zero fields match everything.
+type Filter struct {
+	Interfaces []string
+	Types      []string
+	// MAC matches the entry's MAC or, for ip_conflict, one of the holders
+	// (case-insensitive).
+	MAC string
+	// IP matches the entry's address.
+	IP           string
+	Since, Until time.Time
+}
+
+// Match reports whether e is selected by f.
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case len(f.Interfaces) > 0 && !slices.Contains(f.Interfaces, e.Interface),
+		len(f.Types) > 0 && !slices.Contains(f.Types, e.Type),
+		f.IP != "" && e.IP != f.IP,
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	if f.MAC != "" {
+		mac := strings.ToLower(f.MAC)
+		return e.MAC == mac || slices.Contains(e.MACs, mac)
+	}
+	return true
+}
+
+// Reader reads the journal of a directory in order: the rotated files,
+// then the active one, which Follow keeps reading as it grows and is
+// rotated.
+type Reader struct {
+	dir    string
+	filter Filter
+
+	active  *os.File
+	partial []byte // incomplete last line of the active file
+
+	// Skipped counts lines that could not be decoded.
+	Skipped int
+}
+
+// NewReader returns a reader of the journal in dir.
+func NewReader(dir string, f Filter) *Reader {
+	return &Reader{dir: dir, filter: f}
+}
+
+// ReadAll calls fn for each selected entry currently in the journal,
+// oldest first, and leaves the reader at the end of the active file.
+func (r *Reader) ReadAll(fn func(Entry) error) error {
+	files, err := Files(r.dir)
+	if err != nil {
+		return err
+	}
+	active := filepath.Join(r.dir, FileName)
+	for _, path := range files {
+		if path == active {
+			break
+		}
+		if err := r.readFile(path, fn); err != nil {
+			return err
+		}
+	}
+	if err := r.openActive(); err != nil {
+		return err
+	}
+	return r.readActive(fn)
+}
+
+func (r *Reader) readFile(path string, fn func(Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // deleted by retention meanwhile
+		}
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if err := r.line(sc.Bytes(), fn); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// openActive opens the active file if it exists and is not open yet.
+func (r *Reader) openActive() error {
+	if r.active != nil {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(r.dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	r.active, r.partial = f, nil
+	return err
+}
+
+// readActive reads the complete lines appended to the active file.
+func (r *Reader) readActive(fn func(Entry) error) error {
+	if r.active == nil {
+		return nil
+	}
+	b, err := io.ReadAll(r.active)
+	if err != nil {
+		return err
+	}
+	b = append(r.partial, b...)
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			break
+		}
+		if err := r.line(b[:i], fn); err != nil {
+			return err
+		}
+		b = b[i+1:]
+	}
+	r.partial = append([]byte(nil), b...)
+	return nil
+}
+
+func (r *Reader) line(b []byte, fn func(Entry) error) error {
+	if len(b) == 0 {
+		return nil
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		r.Skipped++
+		return nil
+	}
+	if !r.filter.Match(e) {
+		return nil
+	}
+	return fn(e)
+}
+
+// Follow calls fn for each selected entry appended to the journal after
+// ReadAll, until ctx is cancelled. When the active file is rotated, the
+// rest of it is read before switching to the new one.
+func (r *Reader) Follow(ctx context.Context, fn func(Entry) error) error {
+	t := time.NewTicker(PollInterval)
+	defer t.Stop()
+	for {
+		if err := r.readActive(fn); err != nil {
+			return err
+		}
+		if r.rotated() {
+			if err := r.readActive(fn); err != nil {
+				return err
+			}
+			r.active.Close()
+			r.active = nil
+		}
+		if err := r.openActive(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// rotated reports whether the open active file is no longer the one at
+// the active path.
+func (r *Reader) rotated() bool {
+	if r.active == nil {
+		return false
+	}
+	cur, err := os.Stat(filepath.Join(r.dir, FileName))
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+	open, err := r.active.Stat()
+	return err == nil && !os.SameFile(cur, open)
+}
+
+// Close closes the active file.
+func (r *Reader) Close() error {
+	if r.active == nil {
+		return nil
+	}
+	return r.active.Close()
+}
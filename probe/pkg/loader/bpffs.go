@@ -0,0 +1,38 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNotBPFFS is returned by CheckBPFFS when the pin path is not on bpffs.
+var ErrNotBPFFS = errors.New("not on a bpffs mount")
+
+// CheckBPFFS verifies that pinBase is (or would be created) on a bpffs
+// mount. Missing directories are resolved to their nearest existing
+// ancestor, since Attach creates the pin directory itself.
+func CheckBPFFS(pinBase string) error {
+	dir := filepath.Clean(pinBase)
+	for {
+		var st unix.Statfs_t
+		err := unix.Statfs(dir, &st)
+		if err == nil {
+			if st.Type != unix.BPF_FS_MAGIC {
+				return fmt.Errorf("%s: %w (mount it with: mount -t bpf bpf %s)", dir, ErrNotBPFFS, dir)
+			}
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("statfs %s: %w", dir, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("statfs %s: %w", dir, err)
+		}
+		dir = parent
+	}
+}
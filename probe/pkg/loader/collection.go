@@ -0,0 +1,51 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+// Collection is a loaded copy of the eBPF program and its neighbours map
+// that is neither attached to an interface nor pinned. Frames are fed to
+// the program with BPF_PROG_TEST_RUN, so the map only ever sees traffic
+// supplied by the caller.
+type Collection struct {
+	objs *l2radarObjects
+}
+
+// Load loads the eBPF program and a private neighbours map.
+func Load() (*Collection, error) {
+	// Best effort, as in Attach: kernels with memcg accounting do not need it.
+	_ = rlimit.RemoveMemlock()
+
+	var objs l2radarObjects
+	if err := loadL2radarObjects(&objs, &ebpf.CollectionOptions{}); err != nil {
+		return nil, fmt.Errorf("loading eBPF objects: %w", err)
+	}
+	return &Collection{objs: &objs}, nil
+}
+
+// Run feeds a single Ethernet frame through the program.
+func (c *Collection) Run(frame []byte) error {
+	if _, err := c.objs.L2radar.Run(&ebpf.RunOptions{Data: frame}); err != nil {
+		return fmt.Errorf("running eBPF program: %w", err)
+	}
+	return nil
+}
+
+// Program returns the loaded TC program.
+func (c *Collection) Program() *ebpf.Program {
+	return c.objs.L2radar
+}
+
+// Neighbours returns the collection's private neighbours map.
+func (c *Collection) Neighbours() *ebpf.Map {
+	return c.objs.Neighbours
+}
+
+// Close releases the program and map.
+func (c *Collection) Close() error {
+	return c.objs.Close()
+}
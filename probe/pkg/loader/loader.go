@@ -10,6 +10,8 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/rlimit"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
 )
 
 const (
@@ -26,11 +28,14 @@ type Probe struct {
 	objs    *l2radarObjects
 	link    link.Link
 	pinPath string
+	meta    *ebpf.Map
+	metaPin string
 	logger  *slog.Logger
 }
 
 // Attach loads the eBPF program, attaches it to the given interface via
-// TCX ingress, and pins the neighbours map at <pinBase>/neigh-<iface>.
+// TCX ingress, and pins the neighbours map at <pinBase>/neigh-<iface> and
+// its schema map at <pinBase>/meta-<iface>.
 func Attach(iface string, pinBase string, logger *slog.Logger) (*Probe, error) {
 	if logger == nil {
 		logger = slog.Default()
@@ -69,6 +74,28 @@ func Attach(iface string, pinBase string, logger *slog.Logger) (*Probe, error) {
 		return nil, fmt.Errorf("setting map permissions: %w", err)
 	}
 
+	// Pin the schema map so readers can detect incompatible layouts
+	meta, err := dump.NewMetaMap()
+	if err != nil {
+		os.Remove(mapPinPath)
+		objs.Close()
+		return nil, err
+	}
+	metaPinPath := dump.MetaPinPath(pinBase, iface)
+	if err := meta.Pin(metaPinPath); err != nil {
+		meta.Close()
+		os.Remove(mapPinPath)
+		objs.Close()
+		return nil, fmt.Errorf("pinning schema map at %s: %w", metaPinPath, err)
+	}
+	if err := os.Chmod(metaPinPath, MapPinPermissions); err != nil {
+		os.Remove(metaPinPath)
+		meta.Close()
+		os.Remove(mapPinPath)
+		objs.Close()
+		return nil, fmt.Errorf("setting schema map permissions: %w", err)
+	}
+
 	// Attach via TCX ingress
 	tcxLink, err := link.AttachTCX(link.TCXOptions{
 		Interface: ifObj.Index,
@@ -76,6 +103,8 @@ func Attach(iface string, pinBase string, logger *slog.Logger) (*Probe, error) {
 		Attach:    ebpf.AttachTCXIngress,
 	})
 	if err != nil {
+		os.Remove(metaPinPath)
+		meta.Close()
 		os.Remove(mapPinPath)
 		objs.Close()
 		return nil, fmt.Errorf("attaching TCX to %s: %w", iface, err)
@@ -92,11 +121,13 @@ func Attach(iface string, pinBase string, logger *slog.Logger) (*Probe, error) {
 		objs:    &objs,
 		link:    tcxLink,
 		pinPath: mapPinPath,
+		meta:    meta,
+		metaPin: metaPinPath,
 		logger:  logger,
 	}, nil
 }
 
-// Close detaches the eBPF program and unpins the map.
+// Close detaches the eBPF program and unpins the maps.
 func (p *Probe) Close() error {
 	var errs []error
 
@@ -112,6 +143,18 @@ func (p *Probe) Close() error {
 		}
 	}
 
+	if p.metaPin != "" {
+		if err := os.Remove(p.metaPin); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("removing pin %s: %w", p.metaPin, err))
+		}
+	}
+
+	if p.meta != nil {
+		if err := p.meta.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing schema map: %w", err))
+		}
+	}
+
 	if p.objs != nil {
 		if err := p.objs.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("closing objects: %w", err))
@@ -131,6 +174,12 @@ func (p *Probe) Interface() string {
 	return p.iface
 }
 
+// Map returns the probe's neighbours map. The handle stays valid until
+// Close, so long-running readers need not reopen the pin.
+func (p *Probe) Map() *ebpf.Map {
+	return p.objs.Neighbours
+}
+
 // MapPinPath returns the filesystem path where the map is pinned.
 func (p *Probe) MapPinPath() string {
 	return p.pinPath
@@ -1,10 +1,15 @@
 package loader
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"unsafe"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
 )
 
 func TestPinPathFormat(t *testing.T) {
@@ -84,13 +89,48 @@ func TestAttachAndClose(t *testing.T) {
 		t.Errorf("expected permissions %o, got %o", MapPinPermissions, info.Mode().Perm())
 	}
 
+	metaPin := dump.MetaPinPath(pinBase, iface)
+	if _, err := dump.ReadMeta(metaPin); err != nil {
+		t.Errorf("schema map not readable: %v", err)
+	}
+
 	// Close should clean up
 	if err := probe.Close(); err != nil {
 		t.Fatalf("close failed: %v", err)
 	}
 
-	// Pin should be removed
+	// Pins should be removed
 	if _, err := os.Stat(expectedPin); !os.IsNotExist(err) {
 		t.Error("pin file should be removed after close")
 	}
+	if _, err := os.Stat(metaPin); !os.IsNotExist(err) {
+		t.Error("schema pin file should be removed after close")
+	}
+}
+
+func TestDumpTypesMatchGeneratedLayout(t *testing.T) {
+	// dump mirrors the bpf2go types by hand; keep the sizes in lockstep
+	// with both the generated Go types and the object's BTF.
+	if got, want := binary.Size(dump.MacKey{}), int(unsafe.Sizeof(l2radarMacKey{})); got != want {
+		t.Errorf("dump.MacKey size %d, generated %d", got, want)
+	}
+	if got, want := binary.Size(dump.NeighbourEntry{}), int(unsafe.Sizeof(l2radarNeighbourEntry{})); got != want {
+		t.Errorf("dump.NeighbourEntry size %d, generated %d", got, want)
+	}
+
+	spec, err := loadL2radar()
+	if err != nil {
+		t.Fatalf("loading spec: %v", err)
+	}
+	ms := spec.Maps["neighbours"]
+	if int(ms.KeySize) != binary.Size(dump.MacKey{}) || int(ms.ValueSize) != binary.Size(dump.NeighbourEntry{}) {
+		t.Errorf("map spec key/value size %d/%d does not match dump types", ms.KeySize, ms.ValueSize)
+	}
+}
+
+func TestCheckBPFFSRejectsTmpfs(t *testing.T) {
+	err := CheckBPFFS(filepath.Join(t.TempDir(), "l2radar"))
+	if !errors.Is(err, ErrNotBPFFS) {
+		t.Fatalf("expected ErrNotBPFFS, got %v", err)
+	}
 }
@@ -0,0 +1,337 @@
+// Package metrics exposes probe metrics in the Prometheus text format,
+// served on the HTTP API (/metrics) or written as a node_exporter textfile.
+//
+// Neighbour counts come from the export cycle snapshots (config filters
+// applied); map fill and export timings from the daemon status at scrape
+// time.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+// ContentType is the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultActiveWindow is the default window of the active neighbour gauge,
+// matching the UI's "seen in the last 5 minutes".
+const DefaultActiveWindow = 5 * time.Minute
+
+// StatusSource provides the daemon status; implemented by daemon.Daemon.
+type StatusSource interface {
+	Status() daemon.Status
+}
+
+// ifaceMetrics holds the values of one interface's last snapshot.
+type ifaceMetrics struct {
+	total, active, ipv4, ipv6 int
+	newMACs                   uint64
+	known                     map[string]struct{}
+	stats                     *export.InterfaceStats
+}
+
+// Collector records snapshots and renders metrics. It implements
+// daemon.Observer and http.Handler.
+type Collector struct {
+	src     StatusSource
+	window  time.Duration
+	started time.Time
+
+	mu     sync.Mutex
+	ifaces map[string]*ifaceMetrics
+}
+
+var _ daemon.Observer = (*Collector)(nil)
+
+// NewCollector returns a collector; neighbours seen within window count
+// as active (DefaultActiveWindow if zero).
+func NewCollector(src StatusSource, window time.Duration) *Collector {
+	if window <= 0 {
+		window = DefaultActiveWindow
+	}
+	return &Collector{
+		src:     src,
+		window:  window,
+		started: time.Now(),
+		ifaces:  make(map[string]*ifaceMetrics),
+	}
+}
+
+// Observe updates the neighbour gauges of s.Interface. A MAC counts as new
+// when it was not in the previous snapshot; on the first snapshot, only
+// entries first seen after the collector was created do, so a restart
+// with pinned maps does not count the whole table.
+func (c *Collector) Observe(s daemon.Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.ifaces[s.Interface]
+	first := !ok
+	if first {
+		m = &ifaceMetrics{}
+		c.ifaces[s.Interface] = m
+	}
+
+	known := make(map[string]struct{}, len(s.Neighbours))
+	m.total, m.active, m.ipv4, m.ipv6 = len(s.Neighbours), 0, 0, 0
+	for _, n := range s.Neighbours {
+		mac := n.MAC.String()
+		known[mac] = struct{}{}
+		if s.Time.Sub(n.LastSeen) <= c.window {
+			m.active++
+		}
+		if len(n.IPv4) > 0 {
+			m.ipv4++
+		}
+		if len(n.IPv6) > 0 {
+			m.ipv6++
+		}
+		if first {
+			if n.FirstSeen.After(c.started) {
+				m.newMACs++
+			}
+		} else if _, ok := m.known[mac]; !ok {
+			m.newMACs++
+		}
+	}
+	m.known = known
+	m.stats = s.Stats
+}
+
+// Detached drops the interface's metrics.
+func (c *Collector) Detached(iface string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ifaces, iface)
+}
+
+// Metric types.
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Label is a metric label.
+type Label struct {
+	Name, Value string
+}
+
+// Sample is one labelled value of a metric family.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Family is a metric with its samples. Counters count from the
+// collector's start time.
+type Family struct {
+	Name, Type, Help string
+	Samples          []Sample
+}
+
+// Families returns the current metrics; families without samples are
+// omitted.
+func (c *Collector) Families() []Family {
+	// Read the status before locking c: Observe is called with the daemon
+	// locked, so the locks must not be nested the other way round.
+	st := c.src.Status()
+
+	c.mu.Lock()
+	all := c.families(st)
+	c.mu.Unlock()
+
+	out := make([]Family, 0, len(all))
+	for _, f := range all {
+		if len(f.Samples) > 0 {
+			out = append(out, *f)
+		}
+	}
+	return out
+}
+
+// Started returns the collector's start time, the start of its counters.
+func (c *Collector) Started() time.Time {
+	return c.started
+}
+
+// WriteTo writes all metrics in the Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, f := range c.Families() {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", f.Name, f.Help, f.Name, f.Type)
+		for _, s := range f.Samples {
+			if len(s.Labels) > 0 {
+				fmt.Fprintf(&buf, "%s{%s} %s\n", f.Name, formatLabels(s.Labels), formatValue(s.Value))
+			} else {
+				fmt.Fprintf(&buf, "%s %s\n", f.Name, formatValue(s.Value))
+			}
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// families builds the metric families. Called with c.mu held.
+func (c *Collector) families(st daemon.Status) []*Family {
+	gauge := func(name, help string) *Family { return &Family{Name: name, Type: Gauge, Help: help} }
+	counter := func(name, help string) *Family { return &Family{Name: name, Type: Counter, Help: help} }
+
+	var (
+		started = gauge("l2radar_start_time_seconds", "Start time of the probe since the epoch.")
+
+		neighbours = gauge("l2radar_neighbours", "Neighbour entries.")
+		active     = gauge("l2radar_neighbours_active", fmt.Sprintf("Neighbour entries seen in the last %s.", c.window))
+		withIPv4   = gauge("l2radar_neighbours_ipv4", "Neighbour entries with at least one IPv4 address.")
+		withIPv6   = gauge("l2radar_neighbours_ipv6", "Neighbour entries with at least one IPv6 address.")
+		newMACs    = counter("l2radar_new_macs_total", "MAC addresses added to the neighbour table.")
+
+		mapEntries = gauge("l2radar_map_entries", "Entries in the BPF neighbour map.")
+		mapMax     = gauge("l2radar_map_max_entries", "Capacity of the BPF neighbour map.")
+		mapFill    = gauge("l2radar_map_fill_ratio", "Fill ratio of the BPF neighbour map (0-1).")
+
+		exportDuration = gauge("l2radar_export_duration_seconds", "Duration of the last export cycle.")
+		exportLast     = gauge("l2radar_export_last_timestamp_seconds", "Time of the last export cycle since the epoch.")
+		exportErrors   = counter("l2radar_export_errors_total", "Failed export cycles.")
+
+		ifBytes   = counter("l2radar_interface_bytes_total", "Interface bytes from /sys/class/net statistics.")
+		ifPackets = counter("l2radar_interface_packets_total", "Interface packets from /sys/class/net statistics.")
+		ifErrors  = counter("l2radar_interface_errors_total", "Interface errors from /sys/class/net statistics.")
+		ifDropped = counter("l2radar_interface_dropped_total", "Interface drops from /sys/class/net statistics.")
+	)
+
+	started.Samples = append(started.Samples, Sample{Value: unixSeconds(st.Started)})
+
+	for _, is := range st.Interfaces {
+		l := []Label{{"interface", is.Name}}
+		mapEntries.Samples = append(mapEntries.Samples, Sample{l, float64(is.MapEntries)})
+		mapMax.Samples = append(mapMax.Samples, Sample{l, float64(is.MapMaxEntries)})
+		if is.MapMaxEntries > 0 {
+			mapFill.Samples = append(mapFill.Samples, Sample{l, float64(is.MapEntries) / float64(is.MapMaxEntries)})
+		}
+		if !is.LastExport.IsZero() {
+			exportDuration.Samples = append(exportDuration.Samples, Sample{l, is.ExportDurationMs / 1000})
+			exportLast.Samples = append(exportLast.Samples, Sample{l, unixSeconds(is.LastExport)})
+			exportErrors.Samples = append(exportErrors.Samples, Sample{l, float64(is.ExportErrors)})
+		}
+	}
+
+	names := make([]string, 0, len(c.ifaces))
+	for name := range c.ifaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := c.ifaces[name]
+		l := []Label{{"interface", name}}
+		neighbours.Samples = append(neighbours.Samples, Sample{l, float64(m.total)})
+		active.Samples = append(active.Samples, Sample{l, float64(m.active)})
+		withIPv4.Samples = append(withIPv4.Samples, Sample{l, float64(m.ipv4)})
+		withIPv6.Samples = append(withIPv6.Samples, Sample{l, float64(m.ipv6)})
+		newMACs.Samples = append(newMACs.Samples, Sample{l, float64(m.newMACs)})
+
+		if s := m.stats; s != nil {
+			for _, dir := range []struct {
+				name                          string
+				bytes, packets, errors, drops uint64
+			}{
+				{"rx", s.RxBytes, s.RxPackets, s.RxErrors, s.RxDropped},
+				{"tx", s.TxBytes, s.TxPackets, s.TxErrors, s.TxDropped},
+			} {
+				dl := []Label{{"interface", name}, {"direction", dir.name}}
+				ifBytes.Samples = append(ifBytes.Samples, Sample{dl, float64(dir.bytes)})
+				ifPackets.Samples = append(ifPackets.Samples, Sample{dl, float64(dir.packets)})
+				ifErrors.Samples = append(ifErrors.Samples, Sample{dl, float64(dir.errors)})
+				ifDropped.Samples = append(ifDropped.Samples, Sample{dl, float64(dir.drops)})
+			}
+		}
+	}
+
+	return []*Family{
+		started,
+		neighbours, active, withIPv4, withIPv6, newMACs,
+		mapEntries, mapMax, mapFill,
+		exportDuration, exportLast, exportErrors,
+		ifBytes, ifPackets, ifErrors, ifDropped,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	c.WriteTo(w)
+}
+
+// WriteTextfile atomically writes the metrics to path, for the
+// node_exporter textfile collector (the file name must end in .prom).
+func (c *Collector) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = c.WriteTo(tmp)
+	if err == nil {
+		// CreateTemp uses 0600; node_exporter may run as another user.
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// RunTextfile writes the textfile every interval until ctx is cancelled,
+// and removes it on exit so node_exporter does not report stale values.
+func (c *Collector) RunTextfile(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer os.Remove(path)
+	for {
+		if err := c.WriteTextfile(path); err != nil {
+			logger.Error("failed to write metrics textfile", "path", path, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatLabels renders labels in the text format.
+func formatLabels(labels []Label) string {
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		parts = append(parts, l.Name+`="`+labelEscaper.Replace(l.Value)+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+)
+
+type fakeStatus struct {
+	st daemon.Status
+}
+
+func (f *fakeStatus) Status() daemon.Status { return f.st }
+
+func newTestCollector() (*Collector, *fakeStatus) {
+	src := &fakeStatus{st: daemon.Status{
+		Started: time.Unix(1700000000, 0),
+		Interfaces: []daemon.InterfaceStatus{{
+			Name:             "eth0",
+			MapEntries:       1024,
+			MapMaxEntries:    4096,
+			LastExport:       time.Unix(1700000100, 0),
+			ExportDurationMs: 2.5,
+			ExportErrors:     3,
+		}},
+	}}
+	return NewCollector(src, 5*time.Minute), src
+}
+
+func TestCollector(t *testing.T) {
+	c, _ := newTestCollector()
+	now := c.started.Add(time.Hour)
+	old := c.started.Add(-time.Hour)
+
+	// First snapshot: only entries learned after start are new.
+	c.Observe(daemon.Snapshot{
+		Interface: "eth0",
+		Time:      now,
+		Neighbours: []dump.Neighbour{
+			testutil.Neighbour("02:00:00:00:00:01", old, now, "10.0.0.1", "fe80::1"),
+			testutil.Neighbour("02:00:00:00:00:02", now.Add(-time.Minute), now.Add(-10*time.Minute), "10.0.0.1"),
+		},
+		Stats: &export.InterfaceStats{RxBytes: 100, TxBytes: 200, RxDropped: 1},
+	})
+	// Second snapshot: 02 expired, 03 learned.
+	c.Observe(daemon.Snapshot{
+		Interface: "eth0",
+		Time:      now,
+		Neighbours: []dump.Neighbour{
+			testutil.Neighbour("02:00:00:00:00:01", old, now, "10.0.0.1", "fe80::1"),
+			testutil.Neighbour("02:00:00:00:00:03", now, now),
+		},
+	})
+
+	var b strings.Builder
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE l2radar_neighbours gauge\n",
+		`l2radar_neighbours{interface="eth0"} 2`,
+		`l2radar_neighbours_active{interface="eth0"} 2`,
+		`l2radar_neighbours_ipv4{interface="eth0"} 1`,
+		`l2radar_neighbours_ipv6{interface="eth0"} 1`,
+		"# TYPE l2radar_new_macs_total counter\n",
+		`l2radar_new_macs_total{interface="eth0"} 2`,
+		`l2radar_map_fill_ratio{interface="eth0"} 0.25`,
+		`l2radar_map_max_entries{interface="eth0"} 4096`,
+		`l2radar_export_duration_seconds{interface="eth0"} 0.0025`,
+		`l2radar_export_errors_total{interface="eth0"} 3`,
+		`l2radar_export_last_timestamp_seconds{interface="eth0"} 1.7000001e+09`,
+		"l2radar_start_time_seconds 1.7e+09\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	// Stats of the last snapshot only (none).
+	if strings.Contains(out, "l2radar_interface_bytes_total") {
+		t.Errorf("unexpected interface stats in:\n%s", out)
+	}
+}
+
+func TestCollectorInterfaceStatsAndDetach(t *testing.T) {
+	c, _ := newTestCollector()
+	c.Observe(daemon.Snapshot{
+		Interface: "eth1",
+		Time:      time.Now(),
+		Stats:     &export.InterfaceStats{RxBytes: 100, TxBytes: 200, RxDropped: 1},
+	})
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("content type %q", ct)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{
+		`l2radar_interface_bytes_total{interface="eth1",direction="rx"} 100`,
+		`l2radar_interface_bytes_total{interface="eth1",direction="tx"} 200`,
+		`l2radar_interface_dropped_total{interface="eth1",direction="rx"} 1`,
+		`l2radar_neighbours{interface="eth1"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	c.Detached("eth1")
+	var b strings.Builder
+	c.WriteTo(&b)
+	if strings.Contains(b.String(), `interface="eth1"`) {
+		t.Errorf("detached interface still reported:\n%s", b.String())
+	}
+}
+
+func TestLabelsEscaped(t *testing.T) {
+	if got := formatLabels([]Label{{"interface", `a"b\c`}}); got != `interface="a\"b\\c"` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	c, _ := newTestCollector()
+	path := filepath.Join(t.TempDir(), "l2radar.prom")
+	if err := c.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Errorf("mode %v", fi.Mode().Perm())
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), "l2radar_map_entries") {
+		t.Errorf("unexpected textfile:\n%s", b)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
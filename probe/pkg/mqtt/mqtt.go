@@ -0,0 +1,440 @@
+// Package mqtt publishes neighbour presence and changes to an MQTT broker,
+// with optional Home Assistant discovery of watched MACs as device_tracker
+// entities.
+//
+// Topics, under <topic_prefix>/<host>:
+//
+//	status                       "online"/"offline" (retained, last will)
+//	<iface>/<mac>/state          "home"/"not_home" (retained)
+//	<iface>/<mac>/attributes     the neighbour entry as JSON (retained)
+//	<iface>/events               neighbour changes (see events.Tracker)
+//
+// <mac> is lower-case hex without separators. It is configured as a sink
+// of type "mqtt" in the probe configuration file.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// Type is the sink type in the configuration file.
+const Type = "mqtt"
+
+// Presence states.
+const (
+	StateHome    = "home"
+	StateNotHome = "not_home"
+)
+
+// Defaults.
+const (
+	DefaultTopicPrefix     = "l2radar"
+	DefaultDiscoveryPrefix = "homeassistant"
+	DefaultQoS             = 1
+	DefaultAwayAfter       = 10 * time.Minute
+)
+
+// DefaultEvents are the change types published when none are configured.
+var DefaultEvents = []string{events.ChangeNewMAC, events.ChangeNewIP, events.ChangeMACExpired, events.ChangeIPConflict}
+
+// Options are the type-specific keys of an mqtt sink.
+type Options struct {
+	// Broker is tcp://, ssl:// or ws(s):// host:port.
+	Broker       string `yaml:"broker"`
+	ClientID     string `yaml:"client_id,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	TopicPrefix  string `yaml:"topic_prefix,omitempty"`
+	QoS          *byte  `yaml:"qos,omitempty"`
+	// Events are the change types to publish (default DefaultEvents);
+	// an empty list (events: []) publishes none.
+	Events []string `yaml:"events,omitempty"`
+	// AwayAfter marks a MAC not_home (and expired) once not seen for this
+	// long.
+	AwayAfter time.Duration `yaml:"away_after,omitempty"`
+	// Discovery publishes Home Assistant discovery messages for Watch.
+	Discovery       bool          `yaml:"discovery,omitempty"`
+	DiscoveryPrefix string        `yaml:"discovery_prefix,omitempty"`
+	Watch           []Watched     `yaml:"watch,omitempty"`
+	Timeout         time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Watched is a MAC announced to Home Assistant.
+type Watched struct {
+	MAC  string `yaml:"mac"`
+	Name string `yaml:"name,omitempty"`
+}
+
+// ParseOptions decodes and validates the options of an mqtt sink and
+// fills defaults. The password file, if any, is read into Password.
+func ParseOptions(s config.Sink) (Options, error) {
+	var o Options
+	if err := s.Decode(&o); err != nil {
+		return o, err
+	}
+	u, err := url.Parse(o.Broker)
+	if err != nil || u.Host == "" || !slices.Contains([]string{"tcp", "ssl", "tls", "ws", "wss", "mqtt", "mqtts"}, u.Scheme) {
+		return o, fmt.Errorf("broker must be a tcp://, ssl://, ws:// or wss:// URL, got %q", o.Broker)
+	}
+	if o.Events == nil {
+		o.Events = DefaultEvents
+	}
+	for _, e := range o.Events {
+		if !slices.Contains(events.ChangeTypes, e) {
+			return o, fmt.Errorf("unknown event %q (supported: %s)", e, strings.Join(events.ChangeTypes, ", "))
+		}
+	}
+	if o.Password, err = sink.ReadSecret(o.Password, o.PasswordFile, "password"); err != nil {
+		return o, err
+	}
+	if o.QoS == nil {
+		qos := byte(DefaultQoS)
+		o.QoS = &qos
+	}
+	if *o.QoS > 2 {
+		return o, fmt.Errorf("qos must be 0, 1 or 2")
+	}
+	if o.AwayAfter < 0 || o.Timeout < 0 {
+		return o, fmt.Errorf("negative away_after or timeout")
+	}
+	for i, w := range o.Watch {
+		hw, err := net.ParseMAC(w.MAC)
+		if err != nil || len(hw) != 6 {
+			return o, fmt.Errorf("watch[%d]: invalid MAC %q", i, w.MAC)
+		}
+		o.Watch[i].MAC = hw.String()
+	}
+	if len(o.Watch) > 0 && !o.Discovery {
+		return o, fmt.Errorf("watch requires discovery: true")
+	}
+	if o.TopicPrefix == "" {
+		o.TopicPrefix = DefaultTopicPrefix
+	}
+	o.TopicPrefix = strings.TrimSuffix(o.TopicPrefix, "/")
+	if o.DiscoveryPrefix == "" {
+		o.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if o.AwayAfter == 0 {
+		o.AwayAfter = DefaultAwayAfter
+	}
+	if o.Timeout == 0 {
+		o.Timeout = sink.DefaultTimeout
+	}
+	return o, nil
+}
+
+// message is a pending publication.
+type message struct {
+	topic    string
+	payload  []byte
+	retained bool
+}
+
+// ifaceState is what the sink has published for an interface.
+type ifaceState struct {
+	states     map[string]string // MAC -> last published state
+	discovered bool
+}
+
+// Sink publishes presence and changes of the selected interfaces from Run.
+// It implements daemon.Observer.
+type Sink struct {
+	opts   Options
+	ifaces []string // all if empty
+	host   string
+	base   string // <prefix>/<host>
+	client paho.Client
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	tracker *events.Tracker
+	state   map[string]*ifaceState
+	queue   *sink.Queue[message]
+}
+
+var _ sink.Sink = (*Sink)(nil)
+
+func init() {
+	sink.Register(Type, func(s config.Sink, logger *slog.Logger) (sink.Sink, error) {
+		return New(s, logger)
+	})
+}
+
+// New returns a sink for the mqtt entry s. The broker is not contacted
+// until Run.
+func New(s config.Sink, logger *slog.Logger) (*Sink, error) {
+	o, err := ParseOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "l2radar"
+	}
+	sk := &Sink{
+		opts:    o,
+		ifaces:  s.Interfaces,
+		host:    host,
+		base:    o.TopicPrefix + "/" + topicSafe(host),
+		logger:  logger,
+		tracker: events.NewTracker(o.AwayAfter),
+		state:   make(map[string]*ifaceState),
+		queue:   sink.NewQueue[message](sink.DefaultQueueSize),
+	}
+
+	clientID := o.ClientID
+	if clientID == "" {
+		clientID = "l2radar-" + host
+	}
+	co := paho.NewClientOptions().
+		AddBroker(o.Broker).
+		SetClientID(clientID).
+		SetUsername(o.Username).
+		SetPassword(o.Password).
+		SetConnectTimeout(o.Timeout).
+		SetWriteTimeout(o.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetWill(sk.statusTopic(), "offline", *o.QoS, true).
+		SetOnConnectHandler(func(c paho.Client) {
+			// Announce availability on every (re)connect and publish
+			// what was queued meanwhile.
+			c.Publish(sk.statusTopic(), *o.QoS, true, "online")
+			logger.Info("mqtt: connected", "broker", o.Broker)
+			sk.queue.Wake()
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Warn("mqtt: connection lost", "broker", o.Broker, "error", err)
+		})
+	sk.client = paho.NewClient(co)
+	return sk, nil
+}
+
+func (s *Sink) statusTopic() string { return s.base + "/status" }
+
+func (s *Sink) deviceTopic(iface, mac string) string {
+	return s.base + "/" + topicSafe(iface) + "/" + strings.ReplaceAll(mac, ":", "")
+}
+
+// Observe queues the presence, attribute and event messages of the
+// interface's snapshot: states and attributes of all entries on the first
+// snapshot, then of entries whose presence or addresses changed.
+func (s *Sink) Observe(snap daemon.Snapshot) {
+	if !sink.Selects(s.ifaces, snap.Interface) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.state[snap.Interface]
+	if !ok {
+		st = &ifaceState{states: make(map[string]string)}
+		s.state[snap.Interface] = st
+	}
+	table := events.Table(snap.Neighbours)
+	if s.opts.Discovery && !st.discovered {
+		for _, w := range s.opts.Watch {
+			s.enqueueJSON(s.discoveryTopic(snap.Interface, w.MAC), s.discoveryConfig(snap.Interface, w), true)
+			// Give watched MACs not in the table a state too.
+			if _, ok := table[w.MAC]; !ok {
+				s.enqueue(message{topic: s.deviceTopic(snap.Interface, w.MAC) + "/state", payload: []byte(StateNotHome), retained: true})
+			}
+		}
+		st.discovered = true
+	}
+
+	changes := s.tracker.Update(snap)
+	changedIPs := make(map[string]bool)
+	for _, c := range changes {
+		if c.Type == events.ChangeNewIP {
+			changedIPs[c.MAC] = true
+		}
+	}
+
+	for _, n := range snap.Neighbours {
+		mac := n.MAC.String()
+		state := StateHome
+		if snap.Time.Sub(n.LastSeen) >= s.opts.AwayAfter {
+			state = StateNotHome
+		}
+		if st.states[mac] == state && !changedIPs[mac] {
+			continue
+		}
+		st.states[mac] = state
+		topic := s.deviceTopic(snap.Interface, mac)
+		s.enqueueJSON(topic+"/attributes", attributes(snap.Interface, table[mac]), true)
+		s.enqueue(message{topic: topic + "/state", payload: []byte(state), retained: true})
+	}
+	for mac := range st.states {
+		if _, ok := table[mac]; !ok {
+			delete(st.states, mac)
+			s.enqueue(message{topic: s.deviceTopic(snap.Interface, mac) + "/state", payload: []byte(StateNotHome), retained: true})
+		}
+	}
+
+	for _, c := range changes {
+		if slices.Contains(s.opts.Events, c.Type) {
+			s.enqueueJSON(s.base+"/"+topicSafe(snap.Interface)+"/events", newEvent(c), false)
+		}
+	}
+}
+
+// Detached forgets the interface; its retained topics are kept.
+func (s *Sink) Detached(iface string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker.Forget(iface)
+	delete(s.state, iface)
+}
+
+func (s *Sink) enqueueJSON(topic string, v any, retained bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Error("mqtt: encoding message", "topic", topic, "error", err)
+		return
+	}
+	s.enqueue(message{topic: topic, payload: b, retained: retained})
+}
+
+func (s *Sink) enqueue(m message) {
+	s.queue.Push(m)
+}
+
+// Run connects to the broker and publishes queued messages until ctx is
+// cancelled, then publishes what is left and "offline", and disconnects.
+// The connection is retried in the background; messages wait meanwhile.
+func (s *Sink) Run(ctx context.Context) {
+	s.client.Connect()
+	for {
+		select {
+		case <-ctx.Done():
+			s.publishQueued()
+			if s.client.IsConnectionOpen() {
+				s.client.Publish(s.statusTopic(), *s.opts.QoS, true, "offline").WaitTimeout(s.opts.Timeout)
+			}
+			s.client.Disconnect(250)
+			return
+		case <-s.queue.Ready():
+			s.publishQueued()
+		}
+	}
+}
+
+// publishQueued publishes the queued messages if connected; otherwise
+// they stay queued until connected.
+func (s *Sink) publishQueued() {
+	if !s.client.IsConnectionOpen() {
+		return
+	}
+	if dropped := s.queue.Dropped(); dropped > 0 {
+		s.logger.Warn("mqtt: dropped queued messages", "broker", s.opts.Broker, "count", dropped)
+	}
+	queue := s.queue.Take(0)
+	for i, m := range queue {
+		t := s.client.Publish(m.topic, *s.opts.QoS, m.retained, m.payload)
+		if !t.WaitTimeout(s.opts.Timeout) || t.Error() != nil {
+			err := t.Error()
+			if err == nil {
+				err = fmt.Errorf("timeout")
+			}
+			s.logger.Warn("mqtt: publish failed", "topic", m.topic, "error", err)
+			// Keep it and the rest for the next cycle.
+			s.queue.Requeue(queue[i:])
+			return
+		}
+	}
+}
+
+// Event is a change as published, with the vendor of its MAC.
+type Event struct {
+	events.Change
+	Vendor string `json:"vendor,omitempty"`
+}
+
+func newEvent(c events.Change) Event {
+	e := Event{Change: c}
+	if hw, err := net.ParseMAC(c.MAC); err == nil {
+		e.Vendor = oui.Lookup(hw)
+	}
+	return e
+}
+
+// Attributes is the payload of an attributes topic (also the Home
+// Assistant entity attributes).
+type Attributes struct {
+	export.NeighbourJSON
+	Interface string `json:"interface"`
+}
+
+func attributes(iface string, n export.NeighbourJSON) Attributes {
+	return Attributes{NeighbourJSON: n, Interface: iface}
+}
+
+// discoveryTopic is the Home Assistant discovery topic of a watched MAC.
+func (s *Sink) discoveryTopic(iface, mac string) string {
+	return s.opts.DiscoveryPrefix + "/device_tracker/" + s.objectID(iface, mac) + "/config"
+}
+
+func (s *Sink) objectID(iface, mac string) string {
+	return "l2radar_" + topicSafe(s.host) + "_" + topicSafe(iface) + "_" + strings.ReplaceAll(mac, ":", "")
+}
+
+// discoveryConfig is the Home Assistant MQTT device_tracker configuration
+// of a watched MAC.
+func (s *Sink) discoveryConfig(iface string, w Watched) map[string]any {
+	name := w.Name
+	if name == "" {
+		name = w.MAC
+	}
+	device := map[string]any{
+		"identifiers": []string{"l2radar_" + strings.ReplaceAll(w.MAC, ":", "")},
+		"connections": [][]string{{"mac", w.MAC}},
+		"name":        name,
+	}
+	if hw, err := net.ParseMAC(w.MAC); err == nil {
+		if v := oui.Lookup(hw); v != "" {
+			device["manufacturer"] = v
+		}
+	}
+	topic := s.deviceTopic(iface, w.MAC)
+	return map[string]any{
+		"name":                  nil, // use the device name
+		"unique_id":             s.objectID(iface, w.MAC),
+		"state_topic":           topic + "/state",
+		"json_attributes_topic": topic + "/attributes",
+		"payload_home":          StateHome,
+		"payload_not_home":      StateNotHome,
+		"source_type":           "router",
+		"availability_topic":    s.statusTopic(),
+		"device":                device,
+	}
+}
+
+// topicSafe replaces characters that are special in MQTT topics.
+func topicSafe(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_").Replace(s)
+}
@@ -0,0 +1,267 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+)
+
+// published is a message received by the broker.
+type published struct {
+	topic    string
+	payload  string
+	retained bool
+}
+
+// broker is a minimal MQTT 3.1.1 broker that records publications.
+type broker struct {
+	mu   sync.Mutex
+	msgs []published
+}
+
+func startBroker(t *testing.T) (*broker, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	b := &broker{}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go b.serve(conn)
+		}
+	}()
+	return b, "tcp://" + l.Addr().String()
+}
+
+func (b *broker) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		// Remaining length: base-128 varint.
+		var n, shift int
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			n |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 2, 0, 0})
+		case 3: // PUBLISH
+			qos := header >> 1 & 3
+			tl := int(binary.BigEndian.Uint16(body))
+			m := published{topic: string(body[2 : 2+tl]), retained: header&1 != 0}
+			rest := body[2+tl:]
+			if qos > 0 {
+				id := rest[:2]
+				rest = rest[2:]
+				ack := byte(0x40) // PUBACK
+				if qos == 2 {
+					ack = 0x50 // PUBREC
+				}
+				conn.Write([]byte{ack, 2, id[0], id[1]})
+			}
+			m.payload = string(rest)
+			b.mu.Lock()
+			b.msgs = append(b.msgs, m)
+			b.mu.Unlock()
+		case 6: // PUBREL
+			conn.Write([]byte{0x70, 2, body[0], body[1]})
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0})
+		case 14: // DISCONNECT
+			conn.Close()
+			return
+		}
+	}
+}
+
+// wait waits for a message on topic with payload (any if empty), and
+// returns it.
+func (b *broker) wait(t *testing.T, topic, payload string) published {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		for _, m := range b.msgs {
+			if m.topic == topic && (payload == "" || m.payload == payload) {
+				b.mu.Unlock()
+				return m
+			}
+		}
+		b.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no message %q on %s", payload, topic)
+	return published{}
+}
+
+func (b *broker) on(topic string) []published {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []published
+	for _, m := range b.msgs {
+		if m.topic == topic {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestPublish(t *testing.T) {
+	b, addr := startBroker(t)
+	s, err := New(config.Sink{Type: Type, Interfaces: []string{"eth0"}, Options: map[string]any{
+		"broker":     addr,
+		"away_after": "1m",
+		"events":     []string{"new_mac", "mac_expired"},
+		"discovery":  true,
+		"watch": []map[string]any{
+			{"mac": "02:00:00:00:00:01", "name": "Phone"},
+			{"mac": "02-00-00-00-00-09"},
+		},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	base := "l2radar/" + topicSafe(host)
+
+	now := time.Now().Add(time.Second)
+	old := now.Add(-time.Hour)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now, "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", old, now.Add(-2*time.Minute)),
+	}})
+	// Not a selected interface.
+	s.Observe(daemon.Snapshot{Interface: "eth1", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:03", now, now),
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	// Messages queued before connecting are published once connected.
+	b.wait(t, base+"/status", "online")
+
+	if m := b.wait(t, base+"/eth0/020000000001/state", StateHome); !m.retained {
+		t.Error("state not retained")
+	}
+	b.wait(t, base+"/eth0/020000000002/state", StateNotHome)
+	b.wait(t, base+"/eth0/020000000009/state", StateNotHome)
+
+	var attrs Attributes
+	if err := json.Unmarshal([]byte(b.wait(t, base+"/eth0/020000000001/attributes", "").payload), &attrs); err != nil ||
+		attrs.Interface != "eth0" || len(attrs.IPv4) != 1 || attrs.IPv4[0] != "10.0.0.1" {
+		t.Errorf("attributes: %+v, %v", attrs, err)
+	}
+
+	objectID := "l2radar_" + topicSafe(host) + "_eth0_020000000001"
+	var disc map[string]any
+	m := b.wait(t, "homeassistant/device_tracker/"+objectID+"/config", "")
+	if err := json.Unmarshal([]byte(m.payload), &disc); err != nil || !m.retained {
+		t.Fatalf("discovery: %s, %v", m.payload, err)
+	}
+	if disc["state_topic"] != base+"/eth0/020000000001/state" || disc["unique_id"] != objectID ||
+		disc["availability_topic"] != base+"/status" || disc["device"].(map[string]any)["name"] != "Phone" {
+		t.Errorf("unexpected discovery config: %v", disc)
+	}
+
+	// 01 goes away, 02 is removed.
+	later := now.Add(2 * time.Minute)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: later, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now, "10.0.0.1"),
+	}})
+	b.wait(t, base+"/eth0/020000000001/state", StateNotHome)
+
+	var got []string
+	for _, m := range b.on(base + "/eth0/events") {
+		var e Event
+		if err := json.Unmarshal([]byte(m.payload), &e); err != nil || m.retained {
+			t.Fatalf("event %s: %v", m.payload, err)
+		}
+		got = append(got, e.Type+" "+e.MAC)
+	}
+	want := "new_mac 02:00:00:00:00:01,mac_expired 02:00:00:00:00:01"
+	if strings.Join(got, ",") != want {
+		t.Errorf("events: got %v, want %s", got, want)
+	}
+	if n := len(b.on(base + "/eth1/020000000003/state")); n != 0 {
+		t.Errorf("published %d states of an unselected interface", n)
+	}
+
+	cancel()
+	<-done
+	b.wait(t, base+"/status", "offline")
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(config.Sink{Type: Type, Options: map[string]any{"broker": "tcp://localhost:1883"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *o.QoS != DefaultQoS || o.TopicPrefix != DefaultTopicPrefix || len(o.Events) != len(DefaultEvents) {
+		t.Errorf("defaults not applied: %+v", o)
+	}
+	o, err = ParseOptions(config.Sink{Type: Type, Options: map[string]any{"broker": "tcp://localhost:1883", "qos": 0, "events": []string{}}})
+	if err != nil || *o.QoS != 0 || len(o.Events) != 0 {
+		t.Errorf("explicit qos 0 and no events: %+v, %v", o, err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string]map[string]any{
+		"no broker":     {},
+		"empty secret":  {"broker": "tcp://x:1883", "password_file": empty},
+		"bad scheme":    {"broker": "http://localhost"},
+		"bad qos":       {"broker": "tcp://x:1883", "qos": 3},
+		"unknown event": {"broker": "tcp://x:1883", "events": []string{"new_cat"}},
+		"bad mac":       {"broker": "tcp://x:1883", "discovery": true, "watch": []map[string]any{{"mac": "nope"}}},
+		"no discovery":  {"broker": "tcp://x:1883", "watch": []map[string]any{{"mac": "02:00:00:00:00:01"}}},
+		"unknown key":   {"broker": "tcp://x:1883", "retain": false},
+	}
+	for name, opts := range bad {
+		if _, err := ParseOptions(config.Sink{Type: Type, Options: opts}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// client sends OTLP export requests.
+type client interface {
+	exportMetrics(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error
+	exportLogs(ctx context.Context, req *collogpb.ExportLogsServiceRequest) error
+	close() error
+}
+
+func newClient(opts Opts) (client, error) {
+	switch opts.Protocol {
+	case ProtocolGRPC:
+		return newGRPCClient(opts)
+	case ProtocolHTTP:
+		return newHTTPClient(opts)
+	}
+	return nil, fmt.Errorf("invalid OTLP protocol %q (supported: grpc, http)", opts.Protocol)
+}
+
+// grpcClient exports over OTLP/gRPC.
+type grpcClient struct {
+	conn    *grpc.ClientConn
+	metrics colmetricpb.MetricsServiceClient
+	logs    collogpb.LogsServiceClient
+	md      metadata.MD
+}
+
+func newGRPCClient(opts Opts) (*grpcClient, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if opts.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(opts.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClient{
+		conn:    conn,
+		metrics: colmetricpb.NewMetricsServiceClient(conn),
+		logs:    collogpb.NewLogsServiceClient(conn),
+		md:      metadata.New(opts.Headers),
+	}, nil
+}
+
+func (c *grpcClient) exportMetrics(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error {
+	_, err := c.metrics.Export(metadata.NewOutgoingContext(ctx, c.md), req)
+	return err
+}
+
+func (c *grpcClient) exportLogs(ctx context.Context, req *collogpb.ExportLogsServiceRequest) error {
+	_, err := c.logs.Export(metadata.NewOutgoingContext(ctx, c.md), req)
+	return err
+}
+
+func (c *grpcClient) close() error {
+	return c.conn.Close()
+}
+
+// httpClient exports over OTLP/HTTP with binary protobuf payloads.
+type httpClient struct {
+	base    string // e.g. "http://localhost:4318"
+	headers map[string]string
+	hc      *http.Client
+}
+
+func newHTTPClient(opts Opts) (*httpClient, error) {
+	base := strings.TrimSuffix(opts.Endpoint, "/")
+	if !strings.Contains(base, "://") {
+		scheme := "https://"
+		if opts.Insecure {
+			scheme = "http://"
+		}
+		base = scheme + base
+	}
+	return &httpClient{
+		base:    base,
+		headers: opts.Headers,
+		hc:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (c *httpClient) exportMetrics(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error {
+	return c.post(ctx, "/v1/metrics", req)
+}
+
+func (c *httpClient) exportLogs(ctx context.Context, req *collogpb.ExportLogsServiceRequest) error {
+	return c.post(ctx, "/v1/logs", req)
+}
+
+func (c *httpClient) post(ctx context.Context, path string, m proto.Message) error {
+	body, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (c *httpClient) close() error {
+	c.hc.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,380 @@
+// Package otlp pushes the probe's metrics and neighbour changes to an
+// OpenTelemetry collector over OTLP (gRPC or HTTP/protobuf).
+//
+// Metrics are the same families as the Prometheus endpoint (see package
+// metrics), exported every interval. Neighbour changes (see
+// events.Tracker) are exported as log records. Each interface is its own
+// resource, with host, interface and probe version attributes.
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/metrics"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/version"
+)
+
+// Protocols.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// DefaultInterval is the default export interval.
+const DefaultInterval = 30 * time.Second
+
+// maxPending bounds the queued log records while the collector is
+// unreachable; the oldest are dropped.
+const maxPending = 10000
+
+// Resource attribute keys.
+const (
+	attrServiceName    = "service.name"
+	attrServiceVersion = "service.version"
+	attrHostName       = "host.name"
+	attrInterface      = "network.interface.name"
+)
+
+// MetricSource provides the metrics to export; implemented by
+// metrics.Collector.
+type MetricSource interface {
+	Families() []metrics.Family
+	Started() time.Time
+}
+
+// Opts holds exporter options.
+type Opts struct {
+	// Endpoint is host:port for gRPC (default port 4317) or a base URL
+	// for HTTP (e.g. http://collector:4318); /v1/metrics and /v1/logs are
+	// appended.
+	Endpoint string
+	Protocol string
+	// Insecure disables TLS.
+	Insecure bool
+	// Headers are sent with every request (e.g. authentication).
+	Headers map[string]string
+	// Attributes are added to every resource.
+	Attributes map[string]string
+	Interval   time.Duration
+	Logger     *slog.Logger
+}
+
+// Exporter queues neighbour changes and exports them with the metrics
+// every interval. It implements daemon.Observer.
+type Exporter struct {
+	src      MetricSource
+	opts     Opts
+	client   client
+	resource []*commonpb.KeyValue
+	scope    *commonpb.InstrumentationScope
+
+	mu      sync.Mutex
+	tracker *events.Tracker
+	pending []events.Change
+	dropped int
+}
+
+var _ daemon.Observer = (*Exporter)(nil)
+
+// New returns an exporter for opts.Endpoint. Connections are made lazily,
+// so an unreachable collector is not an error here.
+func New(src MetricSource, opts Opts) (*Exporter, error) {
+	if opts.Endpoint == "" {
+		return nil, fmt.Errorf("OTLP endpoint is required")
+	}
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolGRPC
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	probeVersion := version.String()
+	host, _ := os.Hostname()
+	attrs := map[string]string{
+		attrServiceName:    "l2radar",
+		attrServiceVersion: probeVersion,
+		attrHostName:       host,
+	}
+	for k, v := range opts.Attributes {
+		attrs[k] = v
+	}
+	var resource []*commonpb.KeyValue
+	for _, k := range sortedKeys(attrs) {
+		resource = append(resource, keyValue(k, attrs[k]))
+	}
+
+	return &Exporter{
+		src:      src,
+		opts:     opts,
+		client:   c,
+		resource: resource,
+		scope:    &commonpb.InstrumentationScope{Name: "github.com/marc/l2radar/probe", Version: probeVersion},
+		tracker:  events.NewTracker(0),
+	}, nil
+}
+
+// Observe queues the changes since the interface's previous snapshot (see
+// events.Tracker; entries learned before startup are not reported).
+func (e *Exporter) Observe(s daemon.Snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending = append(e.pending, e.tracker.Update(s)...)
+	if n := len(e.pending) - maxPending; n > 0 {
+		e.pending = e.pending[n:]
+		e.dropped += n
+	}
+}
+
+// Detached forgets the interface's table.
+func (e *Exporter) Detached(iface string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tracker.Forget(iface)
+}
+
+// Flush exports the current metrics and the queued changes. Changes that
+// fail to export are queued again.
+func (e *Exporter) Flush(ctx context.Context) error {
+	now := time.Now()
+	var errs []error
+
+	if fams := e.src.Families(); len(fams) > 0 {
+		if err := e.client.exportMetrics(ctx, e.metricsRequest(fams, now)); err != nil {
+			errs = append(errs, fmt.Errorf("exporting metrics: %w", err))
+		}
+	}
+
+	e.mu.Lock()
+	changes, dropped := e.pending, e.dropped
+	e.pending, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		e.opts.Logger.Warn("otlp: dropped queued neighbour changes", "count", dropped)
+	}
+	if len(changes) > 0 {
+		if err := e.client.exportLogs(ctx, e.logsRequest(changes, now)); err != nil {
+			errs = append(errs, fmt.Errorf("exporting logs: %w", err))
+			e.mu.Lock()
+			e.pending = append(changes, e.pending...)
+			if n := len(e.pending) - maxPending; n > 0 {
+				e.pending = e.pending[n:]
+				e.dropped += n
+			}
+			e.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run exports every interval until ctx is cancelled, then flushes once
+// more and closes the connection.
+func (e *Exporter) Run(ctx context.Context) {
+	defer e.client.close()
+
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := e.Flush(flushCtx); err != nil {
+				e.opts.Logger.Warn("otlp: final export failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+			reqCtx, cancel := context.WithTimeout(ctx, e.opts.Interval)
+			if err := e.Flush(reqCtx); err != nil {
+				e.opts.Logger.Warn("otlp: export failed", "endpoint", e.opts.Endpoint, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// resourceFor returns the resource of an interface ("" for probe-wide
+// metrics).
+func (e *Exporter) resourceFor(iface string) *resourcepb.Resource {
+	attrs := append([]*commonpb.KeyValue{}, e.resource...)
+	if iface != "" {
+		attrs = append(attrs, keyValue(attrInterface, iface))
+	}
+	return &resourcepb.Resource{Attributes: attrs}
+}
+
+// metricsRequest converts metric families, one resource per interface.
+// The interface label becomes a resource attribute, other labels data
+// point attributes. Counters are cumulative sums from the collector's
+// start and lose their _total suffix.
+func (e *Exporter) metricsRequest(fams []metrics.Family, now time.Time) *colmetricpb.ExportMetricsServiceRequest {
+	byIface := make(map[string][]*metricpb.Metric)
+	start := uint64(e.src.Started().UnixNano())
+	for _, f := range fams {
+		points := make(map[string][]*metricpb.NumberDataPoint)
+		for _, s := range f.Samples {
+			var iface string
+			var attrs []*commonpb.KeyValue
+			for _, l := range s.Labels {
+				if l.Name == "interface" {
+					iface = l.Value
+				} else {
+					attrs = append(attrs, keyValue(l.Name, l.Value))
+				}
+			}
+			dp := &metricpb.NumberDataPoint{
+				Attributes:   attrs,
+				TimeUnixNano: uint64(now.UnixNano()),
+				Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: s.Value},
+			}
+			if f.Type == metrics.Counter {
+				dp.StartTimeUnixNano = start
+			}
+			points[iface] = append(points[iface], dp)
+		}
+		for iface, dps := range points {
+			byIface[iface] = append(byIface[iface], newMetric(f, dps))
+		}
+	}
+
+	req := &colmetricpb.ExportMetricsServiceRequest{}
+	for _, iface := range sortedKeys(byIface) {
+		req.ResourceMetrics = append(req.ResourceMetrics, &metricpb.ResourceMetrics{
+			Resource:     e.resourceFor(iface),
+			ScopeMetrics: []*metricpb.ScopeMetrics{{Scope: e.scope, Metrics: byIface[iface]}},
+		})
+	}
+	return req
+}
+
+func newMetric(f metrics.Family, dps []*metricpb.NumberDataPoint) *metricpb.Metric {
+	m := &metricpb.Metric{Name: f.Name, Description: f.Help}
+	if strings.HasSuffix(f.Name, "_seconds") {
+		m.Unit = "s"
+	}
+	if f.Type == metrics.Counter {
+		m.Name = strings.TrimSuffix(f.Name, "_total")
+		m.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             dps,
+			AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	} else {
+		m.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: dps}}
+	}
+	return m
+}
+
+// logsRequest converts changes to log records, one resource per interface.
+// The event name is "l2radar.<type>"; the MAC, IP and vendor are
+// attributes.
+func (e *Exporter) logsRequest(changes []events.Change, now time.Time) *collogpb.ExportLogsServiceRequest {
+	byIface := make(map[string][]*logpb.LogRecord)
+	for _, c := range changes {
+		attrs := []*commonpb.KeyValue{keyValue("l2radar.mac", c.MAC)}
+		if c.IP != "" {
+			attrs = append(attrs, keyValue("l2radar.ip", c.IP))
+		}
+		if len(c.MACs) > 0 {
+			attrs = append(attrs, keyValue("l2radar.macs", strings.Join(c.MACs, ",")))
+		}
+		severity, severityText := logpb.SeverityNumber_SEVERITY_NUMBER_INFO, "INFO"
+		if c.Type == events.ChangeIPConflict {
+			severity, severityText = logpb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARN"
+		}
+		if hw, err := net.ParseMAC(c.MAC); err == nil {
+			if v := oui.Lookup(hw); v != "" {
+				attrs = append(attrs, keyValue("l2radar.vendor", v))
+			}
+		}
+		byIface[c.Interface] = append(byIface[c.Interface], &logpb.LogRecord{
+			TimeUnixNano:         uint64(c.Time.UnixNano()),
+			ObservedTimeUnixNano: uint64(now.UnixNano()),
+			SeverityNumber:       severity,
+			SeverityText:         severityText,
+			EventName:            "l2radar." + c.Type,
+			Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: message(c)}},
+			Attributes:           attrs,
+		})
+	}
+
+	req := &collogpb.ExportLogsServiceRequest{}
+	for _, iface := range sortedKeys(byIface) {
+		req.ResourceLogs = append(req.ResourceLogs, &logpb.ResourceLogs{
+			Resource:  e.resourceFor(iface),
+			ScopeLogs: []*logpb.ScopeLogs{{Scope: e.scope, LogRecords: byIface[iface]}},
+		})
+	}
+	return req
+}
+
+func message(c events.Change) string {
+	switch c.Type {
+	case events.ChangeNewMAC:
+		return fmt.Sprintf("new MAC %s on %s", c.MAC, c.Interface)
+	case events.ChangeNewIP:
+		return fmt.Sprintf("MAC %s on %s has new IP %s", c.MAC, c.Interface, c.IP)
+	case events.ChangeMACRemoved:
+		return fmt.Sprintf("MAC %s removed from %s", c.MAC, c.Interface)
+	case events.ChangeMACExpired:
+		return fmt.Sprintf("MAC %s on %s not seen recently", c.MAC, c.Interface)
+	case events.ChangeIPConflict:
+		return fmt.Sprintf("IP %s on %s claimed by %s", c.IP, c.Interface, strings.Join(c.MACs, ", "))
+	}
+	return c.Type
+}
+
+func keyValue(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseKeyValues parses "key=value" pairs, as given to --otlp-header and
+// --otlp-attribute.
+func ParseKeyValues(pairs []string) (map[string]string, error) {
+	out := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid %q (expected key=value)", p)
+		}
+		out[strings.TrimSpace(k)] = v
+	}
+	return out, nil
+}
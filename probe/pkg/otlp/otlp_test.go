@@ -0,0 +1,267 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/metrics"
+)
+
+// receiver is a local OTLP receiver recording what it gets.
+type receiver struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+
+	mu      sync.Mutex
+	metrics []*colmetricpb.ExportMetricsServiceRequest
+	logs    []*collogpb.ExportLogsServiceRequest
+	headers []string // values of the x-token header
+}
+
+func (r *receiver) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, req)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		r.headers = append(r.headers, md.Get("x-token")...)
+	}
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+// logsServer adapts receiver to the logs service, whose Export has a
+// different signature.
+type logsServer struct {
+	collogpb.UnimplementedLogsServiceServer
+	r *receiver
+}
+
+func (s logsServer) Export(ctx context.Context, req *collogpb.ExportLogsServiceRequest) (*collogpb.ExportLogsServiceResponse, error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.r.logs = append(s.r.logs, req)
+	return &collogpb.ExportLogsServiceResponse{}, nil
+}
+
+func startGRPC(t *testing.T) (*receiver, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &receiver{}
+	srv := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(srv, r)
+	collogpb.RegisterLogsServiceServer(srv, logsServer{r: r})
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	return r, l.Addr().String()
+}
+
+func startHTTP(t *testing.T) (*receiver, string) {
+	t.Helper()
+	r := &receiver{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/metrics", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		m := &colmetricpb.ExportMetricsServiceRequest{}
+		if req.Header.Get("Content-Type") != "application/x-protobuf" || proto.Unmarshal(body, m) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.metrics = append(r.metrics, m)
+		r.headers = append(r.headers, req.Header.Get("X-Token"))
+		r.mu.Unlock()
+	})
+	mux.HandleFunc("POST /v1/logs", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		m := &collogpb.ExportLogsServiceRequest{}
+		if proto.Unmarshal(body, m) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.logs = append(r.logs, m)
+		r.mu.Unlock()
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+// fakeMetrics is a fixed metric source.
+type fakeMetrics struct{}
+
+func (fakeMetrics) Started() time.Time { return time.Unix(1700000000, 0) }
+
+func (fakeMetrics) Families() []metrics.Family {
+	return []metrics.Family{
+		{Name: "l2radar_start_time_seconds", Type: metrics.Gauge, Samples: []metrics.Sample{{Value: 1.7e9}}},
+		{Name: "l2radar_neighbours", Type: metrics.Gauge, Samples: []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "interface", Value: "eth0"}}, Value: 3},
+		}},
+		{Name: "l2radar_interface_bytes_total", Type: metrics.Counter, Samples: []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "interface", Value: "eth0"}, {Name: "direction", Value: "rx"}}, Value: 100},
+		}},
+	}
+}
+
+func attr(attrs []*commonpb.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+func testExport(t *testing.T, protocol string, r *receiver, endpoint string) {
+	e, err := New(fakeMetrics{}, Opts{
+		Endpoint:   endpoint,
+		Protocol:   protocol,
+		Insecure:   true,
+		Headers:    map[string]string{"x-token": "secret"},
+		Attributes: map[string]string{"deployment.environment": "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.client.close()
+
+	now := time.Now().Add(time.Second)
+	old := now.Add(-time.Hour)
+	// Pre-existing entries are not reported on the first snapshot.
+	e.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", old, old, "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", now, now, "10.0.0.2"),
+	}})
+	e.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", old, old, "10.0.0.1", "10.0.0.11"),
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.metrics) != 1 || len(r.logs) != 1 {
+		t.Fatalf("got %d metrics and %d logs requests", len(r.metrics), len(r.logs))
+	}
+	if len(r.headers) != 1 || r.headers[0] != "secret" {
+		t.Errorf("headers: %v", r.headers)
+	}
+
+	// Probe-wide resource first, then eth0.
+	rms := r.metrics[0].ResourceMetrics
+	if len(rms) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(rms))
+	}
+	res := rms[1].Resource.Attributes
+	if attr(res, "network.interface.name") != "eth0" || attr(res, "service.name") != "l2radar" ||
+		attr(res, "deployment.environment") != "test" || attr(res, "host.name") == "" || attr(res, "service.version") == "" {
+		t.Errorf("unexpected resource: %v", res)
+	}
+	if attr(rms[0].Resource.Attributes, "network.interface.name") != "" {
+		t.Errorf("probe-wide resource has an interface")
+	}
+	ms := rms[1].ScopeMetrics[0].Metrics
+	if len(ms) != 2 || ms[0].Name != "l2radar_neighbours" || ms[0].GetGauge().DataPoints[0].GetAsDouble() != 3 {
+		t.Fatalf("unexpected metrics: %v", ms)
+	}
+	sum := ms[1].GetSum()
+	if ms[1].Name != "l2radar_interface_bytes" || sum == nil || !sum.IsMonotonic ||
+		sum.DataPoints[0].StartTimeUnixNano != uint64(time.Unix(1700000000, 0).UnixNano()) ||
+		attr(sum.DataPoints[0].Attributes, "direction") != "rx" {
+		t.Errorf("unexpected counter: %v", ms[1])
+	}
+
+	var got []string
+	for _, rec := range r.logs[0].ResourceLogs[0].ScopeLogs[0].LogRecords {
+		got = append(got, rec.EventName+" "+attr(rec.Attributes, "l2radar.mac")+" "+attr(rec.Attributes, "l2radar.ip"))
+	}
+	want := []string{
+		// First snapshot
+		"l2radar.new_mac 02:00:00:00:00:02 ",
+		"l2radar.new_ip 02:00:00:00:00:02 10.0.0.2",
+		// Second snapshot
+		"l2radar.new_ip 02:00:00:00:00:01 10.0.0.11",
+		"l2radar.mac_removed 02:00:00:00:00:02 ",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("log records:\n%v\nwant:\n%v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExportGRPC(t *testing.T) {
+	r, addr := startGRPC(t)
+	testExport(t, ProtocolGRPC, r, addr)
+}
+
+func TestExportHTTP(t *testing.T) {
+	r, url := startHTTP(t)
+	testExport(t, ProtocolHTTP, r, url)
+}
+
+func TestFailedLogsRequeued(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e, err := New(fakeMetrics{}, Opts{Endpoint: srv.URL, Protocol: ProtocolHTTP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := time.Now().Add(time.Second)
+	e.Observe(daemon.Snapshot{Interface: "eth0", Time: time.Now(), Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", seen, seen),
+	}})
+	if err := e.Flush(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(e.pending) != 1 {
+		t.Errorf("expected the change to be queued again, got %d", len(e.pending))
+	}
+}
+
+func TestInvalidOpts(t *testing.T) {
+	if _, err := New(fakeMetrics{}, Opts{}); err == nil {
+		t.Error("expected error for missing endpoint")
+	}
+	if _, err := New(fakeMetrics{}, Opts{Endpoint: "x:1", Protocol: "udp"}); err == nil {
+		t.Error("expected error for invalid protocol")
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	m, err := ParseKeyValues([]string{"authorization=Bearer x=y", " k =v"})
+	if err != nil || m["authorization"] != "Bearer x=y" || m["k"] != "v" {
+		t.Errorf("got %v, %v", m, err)
+	}
+	if _, err := ParseKeyValues([]string{"novalue"}); err == nil {
+		t.Error("expected error")
+	}
+}
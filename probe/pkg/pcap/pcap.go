@@ -0,0 +1,172 @@
+// Package pcap reads and writes classic libpcap capture files.
+//
+// Only Ethernet captures (LINKTYPE_ETHERNET) are supported, since that is
+// the only link type the eBPF program understands. pcapng files are
+// rejected; convert them first with "editcap -F pcap".
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	magicMicro = 0xa1b2c3d4
+	magicNano  = 0xa1b23c4d
+	magicNG    = 0x0a0d0d0a
+
+	// LinkTypeEthernet is the pcap link type for Ethernet frames.
+	LinkTypeEthernet = 1
+
+	// DefaultSnapLen is the snapshot length written by NewWriter.
+	DefaultSnapLen = 262144
+
+	globalHeaderLen = 24
+	recordHeaderLen = 16
+)
+
+// Packet is a single captured frame.
+type Packet struct {
+	Timestamp time.Time
+	// Data holds the captured bytes, which may be shorter than OrigLen
+	// if the capture was truncated by the snapshot length.
+	Data    []byte
+	OrigLen int
+}
+
+// Reader reads packets from a pcap stream.
+type Reader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	snapLen  uint32
+	linkType uint32
+	hdr      [recordHeaderLen]byte
+}
+
+// NewReader parses the pcap global header and returns a Reader positioned
+// at the first packet.
+func NewReader(r io.Reader) (*Reader, error) {
+	var hdr [globalHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading pcap header: %w", err)
+	}
+
+	pr := &Reader{r: r}
+	switch magic := binary.LittleEndian.Uint32(hdr[0:4]); magic {
+	case magicMicro:
+		pr.order = binary.LittleEndian
+	case magicNano:
+		pr.order, pr.nano = binary.LittleEndian, true
+	case swap32(magicMicro):
+		pr.order = binary.BigEndian
+	case swap32(magicNano):
+		pr.order, pr.nano = binary.BigEndian, true
+	case magicNG:
+		return nil, errors.New("pcapng files are not supported (convert with: editcap -F pcap in.pcapng out.pcap)")
+	default:
+		return nil, fmt.Errorf("not a pcap file (magic 0x%08x)", magic)
+	}
+
+	pr.snapLen = pr.order.Uint32(hdr[16:20])
+	pr.linkType = pr.order.Uint32(hdr[20:24])
+	if pr.linkType != LinkTypeEthernet {
+		return nil, fmt.Errorf("unsupported link type %d (only Ethernet is supported)", pr.linkType)
+	}
+
+	return pr, nil
+}
+
+// LinkType returns the link type from the global header.
+func (r *Reader) LinkType() uint32 {
+	return r.linkType
+}
+
+// Next returns the next packet, or io.EOF when the stream is exhausted.
+func (r *Reader) Next() (Packet, error) {
+	if _, err := io.ReadFull(r.r, r.hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, fmt.Errorf("truncated packet header: %w", err)
+		}
+		return Packet{}, err
+	}
+
+	sec := r.order.Uint32(r.hdr[0:4])
+	frac := r.order.Uint32(r.hdr[4:8])
+	capLen := r.order.Uint32(r.hdr[8:12])
+	origLen := r.order.Uint32(r.hdr[12:16])
+
+	// Guard against corrupt headers asking for absurd allocations.
+	limit := r.snapLen
+	if limit < DefaultSnapLen {
+		limit = DefaultSnapLen
+	}
+	if capLen > limit {
+		return Packet{}, fmt.Errorf("packet length %d exceeds snapshot length %d", capLen, limit)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Packet{}, fmt.Errorf("truncated packet data: %w", err)
+	}
+
+	nsec := int64(frac)
+	if !r.nano {
+		nsec *= 1000
+	}
+
+	return Packet{
+		Timestamp: time.Unix(int64(sec), nsec),
+		Data:      data,
+		OrigLen:   int(origLen),
+	}, nil
+}
+
+// Writer writes packets to a pcap stream with nanosecond timestamps.
+type Writer struct {
+	w       io.Writer
+	snapLen uint32
+}
+
+// NewWriter writes the pcap global header for an Ethernet capture.
+func NewWriter(w io.Writer) (*Writer, error) {
+	var hdr [globalHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], magicNano)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], DefaultSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:24], LinkTypeEthernet)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return nil, fmt.Errorf("writing pcap header: %w", err)
+	}
+	return &Writer{w: w, snapLen: DefaultSnapLen}, nil
+}
+
+// WritePacket appends a single frame captured at ts.
+func (w *Writer) WritePacket(ts time.Time, data []byte) error {
+	if uint32(len(data)) > w.snapLen {
+		return fmt.Errorf("packet length %d exceeds snapshot length %d", len(data), w.snapLen)
+	}
+	var hdr [recordHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(ts.Nanosecond()))
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(len(data)))
+	if _, err := w.w.Write(hdr[:]); err != nil {
+		return fmt.Errorf("writing packet header: %w", err)
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return fmt.Errorf("writing packet data: %w", err)
+	}
+	return nil
+}
+
+func swap32(v uint32) uint32 {
+	return v>>24 | (v>>8)&0xff00 | (v<<8)&0xff0000 | v<<24
+}
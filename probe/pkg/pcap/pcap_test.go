@@ -0,0 +1,152 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+
+	ts1 := time.Date(2026, 2, 14, 14, 30, 0, 123456789, time.UTC)
+	ts2 := ts1.Add(time.Second)
+	frames := [][]byte{
+		bytes.Repeat([]byte{0xaa}, 60),
+		bytes.Repeat([]byte{0xbb}, 42),
+	}
+	if err := w.WritePacket(ts1, frames[0]); err != nil {
+		t.Fatalf("WritePacket: %v", err)
+	}
+	if err := w.WritePacket(ts2, frames[1]); err != nil {
+		t.Fatalf("WritePacket: %v", err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if r.LinkType() != LinkTypeEthernet {
+		t.Errorf("expected link type %d, got %d", LinkTypeEthernet, r.LinkType())
+	}
+
+	for i, want := range []time.Time{ts1, ts2} {
+		pkt, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next %d: %v", i, err)
+		}
+		if !pkt.Timestamp.Equal(want) {
+			t.Errorf("packet %d: expected timestamp %v, got %v", i, want, pkt.Timestamp)
+		}
+		if !bytes.Equal(pkt.Data, frames[i]) {
+			t.Errorf("packet %d: data mismatch", i)
+		}
+		if pkt.OrigLen != len(frames[i]) {
+			t.Errorf("packet %d: expected orig len %d, got %d", i, len(frames[i]), pkt.OrigLen)
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+// buildMicroBE builds a big-endian, microsecond-resolution capture by hand.
+func buildMicroBE(linkType uint32, ts time.Time, data []byte) []byte {
+	var buf bytes.Buffer
+	hdr := make([]byte, globalHeaderLen)
+	binary.BigEndian.PutUint32(hdr[0:4], magicMicro)
+	binary.BigEndian.PutUint16(hdr[4:6], 2)
+	binary.BigEndian.PutUint16(hdr[6:8], 4)
+	binary.BigEndian.PutUint32(hdr[16:20], 65535)
+	binary.BigEndian.PutUint32(hdr[20:24], linkType)
+	buf.Write(hdr)
+
+	rec := make([]byte, recordHeaderLen)
+	binary.BigEndian.PutUint32(rec[0:4], uint32(ts.Unix()))
+	binary.BigEndian.PutUint32(rec[4:8], uint32(ts.Nanosecond()/1000))
+	binary.BigEndian.PutUint32(rec[8:12], uint32(len(data)))
+	binary.BigEndian.PutUint32(rec[12:16], uint32(len(data)+10))
+	buf.Write(rec)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestReadBigEndianMicroseconds(t *testing.T) {
+	ts := time.Date(2026, 2, 14, 14, 30, 0, 250000000, time.UTC)
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	r, err := NewReader(bytes.NewReader(buildMicroBE(LinkTypeEthernet, ts, data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	pkt, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if !pkt.Timestamp.Equal(ts) {
+		t.Errorf("expected timestamp %v, got %v", ts, pkt.Timestamp)
+	}
+	if !bytes.Equal(pkt.Data, data) {
+		t.Errorf("data mismatch: %v", pkt.Data)
+	}
+	if pkt.OrigLen != len(data)+10 {
+		t.Errorf("expected orig len %d, got %d", len(data)+10, pkt.OrigLen)
+	}
+}
+
+func TestReadRejectsNonEthernet(t *testing.T) {
+	// 113 = LINKTYPE_LINUX_SLL
+	_, err := NewReader(bytes.NewReader(buildMicroBE(113, time.Now(), nil)))
+	if err == nil || !strings.Contains(err.Error(), "link type") {
+		t.Fatalf("expected link type error, got %v", err)
+	}
+}
+
+func TestReadRejectsPcapng(t *testing.T) {
+	hdr := make([]byte, globalHeaderLen)
+	binary.LittleEndian.PutUint32(hdr[0:4], magicNG)
+	_, err := NewReader(bytes.NewReader(hdr))
+	if err == nil || !strings.Contains(err.Error(), "pcapng") {
+		t.Fatalf("expected pcapng error, got %v", err)
+	}
+}
+
+func TestReadRejectsGarbage(t *testing.T) {
+	_, err := NewReader(strings.NewReader("this is definitely not a pcap"))
+	if err == nil || !strings.Contains(err.Error(), "not a pcap file") {
+		t.Fatalf("expected magic error, got %v", err)
+	}
+}
+
+func TestReadTruncatedPacket(t *testing.T) {
+	b := buildMicroBE(LinkTypeEthernet, time.Now(), make([]byte, 20))
+	r, err := NewReader(bytes.NewReader(b[:len(b)-5]))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+}
+
+func TestReadMissingPacketData(t *testing.T) {
+	// Cut right after the record header: the data is missing entirely.
+	b := buildMicroBE(LinkTypeEthernet, time.Now(), make([]byte, 20))
+	r, err := NewReader(bytes.NewReader(b[:globalHeaderLen+recordHeaderLen]))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	_, err = r.Next()
+	if errors.Is(err, io.EOF) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+// Package preflight checks whether the running kernel and process can run
+// the probe, before anything is attached.
+//
+// Each requirement (capabilities, eBPF program/map types and helpers,
+// TCX, bpffs, BTF, verifier acceptance) is reported as a separate check
+// with a hint on how to fix it, so a failing deployment produces an
+// actionable message instead of a raw errno from loader.Attach.
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+
+	"github.com/marc/l2radar/probe/pkg/loader"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// minTCXVersion is the first kernel release with TCX (6.6).
+const minTCXVersion = 6<<16 | 6<<8
+
+// Check is the outcome of one preflight check.
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Hint says how to fix a failed or degraded check.
+	Hint string `json:"hint,omitempty"`
+}
+
+// Report collects the checks of a preflight run.
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Passed reports whether no check failed. Warnings do not prevent the
+// probe from running.
+func (r *Report) Passed() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the failed checks.
+func (r *Report) Failures() []Check {
+	var failed []Check
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Err returns nil if the report passed, otherwise an error listing every
+// failed check with its hint.
+func (r *Report) Err() error {
+	failed := r.Failures()
+	if len(failed) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "kernel preflight failed (%d of %d checks):", len(failed), len(r.Checks))
+	for _, c := range failed {
+		fmt.Fprintf(&b, "\n  %s: %s", c.Name, c.Detail)
+		if c.Hint != "" {
+			fmt.Fprintf(&b, "\n    hint: %s", c.Hint)
+		}
+	}
+	return errors.New(b.String())
+}
+
+// Format writes the report as a table, followed by the hints of failed and
+// degraded checks and a summary line.
+func (r *Report) Format(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	fmt.Fprintln(tw, "-----\t------\t------")
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, strings.ToUpper(string(c.Status)), c.Detail)
+	}
+	tw.Flush()
+
+	first := true
+	for _, c := range r.Checks {
+		if c.Status == StatusOK || c.Hint == "" {
+			continue
+		}
+		if first {
+			fmt.Fprintln(w)
+			first = false
+		}
+		fmt.Fprintf(w, "%s: %s\n", c.Name, c.Hint)
+	}
+
+	if failed := len(r.Failures()); failed > 0 {
+		fmt.Fprintf(w, "\npreflight FAILED (%d of %d checks failed)\n", failed, len(r.Checks))
+	} else {
+		fmt.Fprintf(w, "\npreflight PASSED (%d checks)\n", len(r.Checks))
+	}
+}
+
+func (r *Report) add(name string, status Status, detail, hint string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: detail, Hint: hint})
+}
+
+// Opts configures a preflight run.
+type Opts struct {
+	// PinPath is the bpffs base path maps will be pinned under.
+	PinPath string
+	// Ifaces, if set, are checked for existence.
+	Ifaces []string
+}
+
+// Run performs all checks and returns the report. Checks are independent:
+// a failure does not stop later checks, so the report lists every problem
+// at once.
+func Run(opts Opts) *Report {
+	r := &Report{}
+	checkKernel(r)
+	checkCapabilities(r)
+	checkMemlock(r)
+	checkProgramType(r)
+	checkMapType(r)
+	checkHelpers(r)
+	checkTCX(r)
+	checkBTF(r)
+	checkBPFFS(r, opts.PinPath)
+	checkLoad(r)
+	for _, iface := range opts.Ifaces {
+		checkInterface(r, iface)
+	}
+	return r
+}
+
+func checkKernel(r *Report) {
+	var uts unix.Utsname
+	release := "unknown"
+	if err := unix.Uname(&uts); err == nil {
+		release = unix.ByteSliceToString(uts.Release[:])
+	}
+
+	code, err := features.LinuxVersionCode()
+	if err != nil {
+		r.add("kernel", StatusWarn, fmt.Sprintf("%s (version: %v)", release, err), "")
+		return
+	}
+	if code < minTCXVersion {
+		r.add("kernel", StatusWarn, release, "Linux 6.6 or newer is required for TCX")
+		return
+	}
+	r.add("kernel", StatusOK, release, "")
+}
+
+// hintCaps is shown when the process lacks the capabilities to load and
+// attach eBPF programs.
+const hintCaps = "run as root, or grant CAP_BPF and CAP_NET_ADMIN (docker: --privileged)"
+
+func checkCapabilities(r *Report) {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		r.add("capabilities", StatusWarn, fmt.Sprintf("capget: %v", err), "")
+		return
+	}
+	has := func(c int) bool {
+		return data[c/32].Effective&(1<<(uint(c)%32)) != 0
+	}
+
+	sysAdmin := has(unix.CAP_SYS_ADMIN)
+	var missing []string
+	if !has(unix.CAP_BPF) && !sysAdmin {
+		missing = append(missing, "CAP_BPF")
+	}
+	if !has(unix.CAP_NET_ADMIN) && !sysAdmin {
+		missing = append(missing, "CAP_NET_ADMIN")
+	}
+	if len(missing) > 0 {
+		r.add("capabilities", StatusFail, "missing "+strings.Join(missing, ", "), hintCaps)
+		return
+	}
+	if sysAdmin {
+		r.add("capabilities", StatusOK, "CAP_SYS_ADMIN", "")
+		return
+	}
+	r.add("capabilities", StatusOK, "CAP_BPF, CAP_NET_ADMIN", "")
+}
+
+func checkMemlock(r *Report) {
+	// Kernels >= 5.11 account eBPF memory to the cgroup, in which case
+	// RemoveMemlock is a no-op.
+	if err := rlimit.RemoveMemlock(); err != nil {
+		r.add("memlock", StatusWarn, err.Error(),
+			"raise RLIMIT_MEMLOCK (ulimit -l unlimited; docker: --ulimit memlock=-1)")
+		return
+	}
+	r.add("memlock", StatusOK, "", "")
+}
+
+// featureStatus maps a cilium/ebpf feature probe result to a check.
+func featureStatus(r *Report, name, ok string, err error, hint string) {
+	switch {
+	case err == nil:
+		r.add(name, StatusOK, ok, "")
+	case errors.Is(err, ebpf.ErrNotSupported):
+		r.add(name, StatusFail, "not supported by this kernel", hint)
+	default:
+		r.add(name, StatusFail, err.Error(), hintFor(err, hint))
+	}
+}
+
+// hintFor returns a permission hint for EPERM, the usual reason feature
+// probes fail with an error other than ErrNotSupported.
+func hintFor(err error, fallback string) string {
+	if errors.Is(err, unix.EPERM) {
+		return hintCaps
+	}
+	return fallback
+}
+
+func checkProgramType(r *Report) {
+	featureStatus(r, "program-type", "sched_cls", features.HaveProgramType(ebpf.SchedCLS),
+		"kernel needs CONFIG_BPF_SYSCALL and CONFIG_NET_CLS_BPF")
+}
+
+func checkMapType(r *Report) {
+	featureStatus(r, "map-type", "hash", features.HaveMapType(ebpf.Hash),
+		"kernel needs CONFIG_BPF_SYSCALL")
+}
+
+func checkHelpers(r *Report) {
+	helpers := []asm.BuiltinFunc{asm.FnKtimeGetBootNs, asm.FnSkbPullData}
+	var missing []string
+	for _, fn := range helpers {
+		err := features.HaveProgramHelper(ebpf.SchedCLS, fn)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ebpf.ErrNotSupported) {
+			r.add("helpers", StatusFail, err.Error(), hintFor(err, ""))
+			return
+		}
+		missing = append(missing, fn.String())
+	}
+	if len(missing) > 0 {
+		r.add("helpers", StatusFail, "missing "+strings.Join(missing, ", "),
+			"bpf_ktime_get_boot_ns requires Linux 5.8 or newer")
+		return
+	}
+	r.add("helpers", StatusOK, "ktime_get_boot_ns, skb_pull_data", "")
+}
+
+// checkTCX attaches a trivial program to a non-existent interface: kernels
+// with TCX reject it with ENODEV, older ones with ErrNotSupported.
+func checkTCX(r *Report) {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:    ebpf.SchedCLS,
+		License: "Apache-2.0",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		r.add("tcx", StatusFail, fmt.Sprintf("loading test program: %v", err), hintFor(err, ""))
+		return
+	}
+	defer prog.Close()
+
+	l, err := link.AttachTCX(link.TCXOptions{
+		Interface: math.MaxInt32,
+		Program:   prog,
+		Attach:    ebpf.AttachTCXIngress,
+	})
+	switch {
+	case err == nil:
+		l.Close()
+	case errors.Is(err, unix.ENODEV):
+		err = nil
+	}
+	featureStatus(r, "tcx", "ingress", err, "TCX requires Linux 6.6 or newer")
+}
+
+func checkBTF(r *Report) {
+	// The program does not use CO-RE, so kernel BTF is only needed for
+	// readable verifier errors and bpftool output.
+	if _, err := btf.LoadKernelSpec(); err != nil {
+		r.add("btf", StatusWarn, err.Error(), "kernel BTF (CONFIG_DEBUG_INFO_BTF) is recommended but not required")
+		return
+	}
+	r.add("btf", StatusOK, "/sys/kernel/btf/vmlinux", "")
+}
+
+func checkBPFFS(r *Report, pinPath string) {
+	if pinPath == "" {
+		pinPath = loader.DefaultPinPath
+	}
+	if err := loader.CheckBPFFS(pinPath); err != nil {
+		hint := "mount bpffs (mount -t bpf bpf /sys/fs/bpf); docker: -v /sys/fs/bpf:/sys/fs/bpf"
+		if !errors.Is(err, loader.ErrNotBPFFS) {
+			hint = hintFor(err, hint)
+		}
+		r.add("bpffs", StatusFail, err.Error(), hint)
+		return
+	}
+	r.add("bpffs", StatusOK, pinPath, "")
+}
+
+// checkLoad loads the real program and map, which runs the verifier.
+func checkLoad(r *Report) {
+	col, err := loader.Load()
+	if err != nil {
+		r.add("load", StatusFail, err.Error(),
+			hintFor(err, "the verifier rejected the program; include this output in a bug report"))
+		return
+	}
+	col.Close()
+	r.add("load", StatusOK, "verifier accepted l2radar program", "")
+}
+
+func checkInterface(r *Report, iface string) {
+	name := "iface " + iface
+	if _, err := net.InterfaceByName(iface); err != nil {
+		r.add(name, StatusFail, err.Error(), "check the name with: ip link show")
+		return
+	}
+	r.add(name, StatusOK, "", "")
+}
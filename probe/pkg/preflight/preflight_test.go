@@ -0,0 +1,93 @@
+package preflight
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReportPassedIgnoresWarnings(t *testing.T) {
+	r := &Report{}
+	r.add("a", StatusOK, "", "")
+	r.add("b", StatusWarn, "degraded", "fix b")
+	if !r.Passed() {
+		t.Fatal("expected report with only warnings to pass")
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+}
+
+func TestReportErrListsFailuresWithHints(t *testing.T) {
+	r := &Report{}
+	r.add("tcx", StatusFail, "not supported by this kernel", "TCX requires Linux 6.6 or newer")
+	r.add("btf", StatusWarn, "missing", "recommended")
+	r.add("bpffs", StatusFail, "not on a bpffs mount", "")
+
+	if r.Passed() {
+		t.Fatal("expected report to fail")
+	}
+	err := r.Err()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	msg := err.Error()
+	for _, want := range []string{"2 of 3 checks", "tcx: not supported", "hint: TCX requires", "bpffs: not on a bpffs mount"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "btf") {
+		t.Errorf("warnings must not be listed in error:\n%s", msg)
+	}
+}
+
+func TestReportFormat(t *testing.T) {
+	r := &Report{}
+	r.add("kernel", StatusOK, "6.6.0", "")
+	r.add("tcx", StatusFail, "not supported by this kernel", "TCX requires Linux 6.6 or newer")
+
+	var buf bytes.Buffer
+	r.Format(&buf)
+	out := buf.String()
+	for _, want := range []string{"CHECK", "kernel", "OK", "FAIL", "tcx: TCX requires Linux 6.6 or newer", "preflight FAILED (1 of 2 checks failed)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunReportsInterfaces(t *testing.T) {
+	r := Run(Opts{PinPath: t.TempDir(), Ifaces: []string{"lo", "l2r-nonexistent0"}})
+
+	byName := make(map[string]Check)
+	for _, c := range r.Checks {
+		byName[c.Name] = c
+	}
+	if c := byName["iface lo"]; c.Status != StatusOK {
+		t.Errorf("expected lo to pass, got %+v", c)
+	}
+	if c := byName["iface l2r-nonexistent0"]; c.Status != StatusFail || c.Hint == "" {
+		t.Errorf("expected missing interface to fail with hint, got %+v", c)
+	}
+	// A temp dir is never on bpffs.
+	if c := byName["bpffs"]; c.Status != StatusFail {
+		t.Errorf("expected bpffs check to fail for %s, got %+v", t.TempDir(), c)
+	}
+}
+
+func TestRunAsRoot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	r := Run(Opts{PinPath: "/sys/fs/bpf"})
+	for _, c := range r.Checks {
+		if c.Name == "bpffs" {
+			continue // depends on the host mounts
+		}
+		if c.Status == StatusFail {
+			t.Errorf("check %s failed: %s (%s)", c.Name, c.Detail, c.Hint)
+		}
+	}
+}
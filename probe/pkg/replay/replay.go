@@ -0,0 +1,121 @@
+// Package replay feeds captured frames through the eBPF program offline.
+//
+// Frames are run with BPF_PROG_TEST_RUN against a private (unpinned) map,
+// so the parsing logic is exactly the one used in production. The program
+// stamps entries with bpf_ktime_get_boot_ns at replay time; replay records
+// the boot clock before each frame and maps those stamps back to the
+// capture timestamps of the frames that produced them.
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/pcap"
+)
+
+// minFrameLen is the smallest input BPF_PROG_TEST_RUN accepts for SKB
+// programs (an Ethernet header).
+const minFrameLen = 14
+
+// Program is the subset of loader.Collection used by Replay.
+type Program interface {
+	Run(frame []byte) error
+	Neighbours() *ebpf.Map
+}
+
+// Result holds the neighbour table produced by a replay.
+type Result struct {
+	Neighbours []dump.Neighbour
+	// Frames is the number of frames fed to the program.
+	Frames int
+	// Skipped counts frames too short to contain an Ethernet header.
+	Skipped int
+	// First and Last are the capture timestamps of the first and last
+	// frames read.
+	First time.Time
+	Last  time.Time
+}
+
+// bootNow is overridable for testing.
+var bootNow = defaultBootNow
+
+// defaultBootNow returns CLOCK_BOOTTIME in nanoseconds, the clock behind
+// bpf_ktime_get_boot_ns().
+func defaultBootNow() uint64 {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &ts); err != nil {
+		return 0
+	}
+	return uint64(ts.Sec)*1e9 + uint64(ts.Nsec)
+}
+
+// mark records the boot clock just before a frame was run.
+type mark struct {
+	ktime uint64
+	ts    time.Time
+}
+
+// timeline maps kernel timestamps back to capture timestamps.
+type timeline []mark
+
+// convert returns the capture timestamp of the frame that was running when
+// the kernel clock read ktime.
+func (tl timeline) convert(ktime uint64) time.Time {
+	if ktime == 0 || len(tl) == 0 {
+		return time.Time{}
+	}
+	// First mark strictly after ktime; the frame before it was running.
+	i := sort.Search(len(tl), func(i int) bool { return tl[i].ktime > ktime })
+	if i == 0 {
+		return tl[0].ts
+	}
+	return tl[i-1].ts
+}
+
+// Replay runs every frame from r through prog and returns the resulting
+// neighbour table with capture timestamps.
+func Replay(r *pcap.Reader, prog Program) (*Result, error) {
+	res := &Result{}
+	var tl timeline
+
+	for {
+		pkt, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading frame %d: %w", res.Frames+res.Skipped+1, err)
+		}
+
+		if res.First.IsZero() {
+			res.First = pkt.Timestamp
+		}
+		res.Last = pkt.Timestamp
+
+		if len(pkt.Data) < minFrameLen {
+			res.Skipped++
+			continue
+		}
+
+		tl = append(tl, mark{ktime: bootNow(), ts: pkt.Timestamp})
+		if err := prog.Run(pkt.Data); err != nil {
+			return nil, fmt.Errorf("frame %d: %w", res.Frames+res.Skipped+1, err)
+		}
+		res.Frames++
+	}
+
+	neighbours, err := dump.ReadNeighbours(prog.Neighbours(), tl.convert)
+	if err != nil {
+		return nil, err
+	}
+	res.Neighbours = neighbours
+	return res, nil
+}
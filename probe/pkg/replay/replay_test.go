@@ -0,0 +1,143 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/marc/l2radar/probe/pkg/loader"
+	"github.com/marc/l2radar/probe/pkg/pcap"
+)
+
+func TestTimelineConvert(t *testing.T) {
+	t0 := time.Date(2026, 2, 14, 14, 0, 0, 0, time.UTC)
+	tl := timeline{
+		{ktime: 1000, ts: t0},
+		{ktime: 2000, ts: t0.Add(time.Minute)},
+		{ktime: 3000, ts: t0.Add(time.Hour)},
+	}
+
+	cases := []struct {
+		ktime uint64
+		want  time.Time
+	}{
+		{0, time.Time{}},
+		{500, t0}, // before the first mark: clamp
+		{1000, t0},
+		{1999, t0},
+		{2000, t0.Add(time.Minute)},
+		{2500, t0.Add(time.Minute)},
+		{9999, t0.Add(time.Hour)},
+	}
+	for _, tc := range cases {
+		if got := tl.convert(tc.ktime); !got.Equal(tc.want) {
+			t.Errorf("convert(%d): expected %v, got %v", tc.ktime, tc.want, got)
+		}
+	}
+}
+
+func TestTimelineConvertEmpty(t *testing.T) {
+	if got := timeline(nil).convert(1234); !got.IsZero() {
+		t.Errorf("expected zero time, got %v", got)
+	}
+}
+
+// buildARPRequest constructs a broadcast ARP request frame.
+func buildARPRequest(senderMAC net.HardwareAddr, senderIP, targetIP net.IP) []byte {
+	frame := make([]byte, 14+28)
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], senderMAC)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0806)
+
+	arp := frame[14:]
+	binary.BigEndian.PutUint16(arp[0:2], 1)
+	binary.BigEndian.PutUint16(arp[2:4], 0x0800)
+	arp[4] = 6
+	arp[5] = 4
+	binary.BigEndian.PutUint16(arp[6:8], 1)
+	copy(arp[8:14], senderMAC)
+	copy(arp[14:18], senderIP.To4())
+	copy(arp[24:28], targetIP.To4())
+	return frame
+}
+
+func TestReplayARP(t *testing.T) {
+	col, err := loader.Load()
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) || errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("skipping: insufficient privileges to load eBPF programs")
+		}
+		t.Fatalf("loading eBPF objects: %v", err)
+	}
+	defer col.Close()
+
+	mac1 := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x10}
+	mac2 := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x20}
+	t0 := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w, err := pcap.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	writes := []struct {
+		ts    time.Time
+		frame []byte
+	}{
+		{t0, buildARPRequest(mac1, net.ParseIP("192.168.1.10"), net.ParseIP("192.168.1.1"))},
+		{t0.Add(time.Second), []byte{0x01, 0x02}}, // runt, skipped
+		{t0.Add(time.Minute), buildARPRequest(mac2, net.ParseIP("192.168.1.20"), net.ParseIP("192.168.1.1"))},
+		{t0.Add(time.Hour), buildARPRequest(mac1, net.ParseIP("192.168.1.11"), net.ParseIP("192.168.1.1"))},
+	}
+	for _, wr := range writes {
+		if err := w.WritePacket(wr.ts, wr.frame); err != nil {
+			t.Fatalf("WritePacket: %v", err)
+		}
+	}
+
+	r, err := pcap.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	res, err := Replay(r, col)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if res.Frames != 3 || res.Skipped != 1 {
+		t.Errorf("expected 3 frames and 1 skipped, got %d and %d", res.Frames, res.Skipped)
+	}
+	if !res.First.Equal(t0) || !res.Last.Equal(t0.Add(time.Hour)) {
+		t.Errorf("unexpected capture span %v - %v", res.First, res.Last)
+	}
+	if len(res.Neighbours) != 2 {
+		t.Fatalf("expected 2 neighbours, got %d", len(res.Neighbours))
+	}
+
+	for _, n := range res.Neighbours {
+		switch n.MAC.String() {
+		case mac1.String():
+			if len(n.IPv4) != 2 {
+				t.Errorf("expected 2 IPv4 for %s, got %v", mac1, n.IPv4)
+			}
+			if !n.FirstSeen.Equal(t0) {
+				t.Errorf("expected first seen %v, got %v", t0, n.FirstSeen)
+			}
+			if !n.LastSeen.Equal(t0.Add(time.Hour)) {
+				t.Errorf("expected last seen %v, got %v", t0.Add(time.Hour), n.LastSeen)
+			}
+		case mac2.String():
+			if !n.FirstSeen.Equal(t0.Add(time.Minute)) || !n.LastSeen.Equal(t0.Add(time.Minute)) {
+				t.Errorf("unexpected timestamps for %s: %v - %v", mac2, n.FirstSeen, n.LastSeen)
+			}
+		default:
+			t.Errorf("unexpected neighbour %s", n.MAC)
+		}
+	}
+}
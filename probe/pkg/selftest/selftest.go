@@ -0,0 +1,537 @@
+// Package selftest verifies the full probe pipeline end to end.
+//
+// A temporary network namespace and veth pair are created; the probe is
+// attached to the host end and crafted ARP/NDP/VLAN frames are injected
+// from the peer end inside the namespace. The pinned map is then read back
+// with dump.ReadMap and rendered as export JSON, and every stage is
+// reported as a separate check so a failing deployment can be narrowed
+// down to the kernel, bpffs, TCX or the parser.
+package selftest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/export"
+	"github.com/marc/l2radar/probe/pkg/frames"
+	"github.com/marc/l2radar/probe/pkg/loader"
+)
+
+// DefaultTimeout bounds how long Run waits for injected frames to show up
+// in the map.
+const DefaultTimeout = 3 * time.Second
+
+// Check is the outcome of one selftest stage.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report collects the checks of a selftest run.
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Passed reports whether every check succeeded.
+func (r *Report) Passed() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return len(r.Checks) > 0
+}
+
+// Failed returns the number of failed checks.
+func (r *Report) Failed() int {
+	n := 0
+	for _, c := range r.Checks {
+		if !c.OK {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) pass(name, detail string) {
+	r.Checks = append(r.Checks, Check{Name: name, OK: true, Detail: detail})
+}
+
+func (r *Report) fail(name string, err error) {
+	r.Checks = append(r.Checks, Check{Name: name, OK: false, Detail: err.Error()})
+}
+
+// Format writes the report as a table followed by a summary line.
+func (r *Report) Format(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	fmt.Fprintln(tw, "-----\t------\t------")
+	for _, c := range r.Checks {
+		result := "PASS"
+		if !c.OK {
+			result = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, result, c.Detail)
+	}
+	tw.Flush()
+
+	if r.Passed() {
+		fmt.Fprintf(w, "\nselftest PASSED (%d checks)\n", len(r.Checks))
+	} else {
+		fmt.Fprintf(w, "\nselftest FAILED (%d of %d checks failed)\n", r.Failed(), len(r.Checks))
+	}
+}
+
+// Expectation is a neighbour the injected frames must produce.
+type Expectation struct {
+	Name string
+	MAC  net.HardwareAddr
+	// IP must be among the neighbour's addresses; nil checks the MAC only.
+	IP net.IP
+	// Absent inverts the check: the MAC must not be tracked.
+	Absent bool
+}
+
+// Case is a crafted frame and the neighbours it must (not) produce.
+type Case struct {
+	Name   string
+	Frame  []byte
+	Expect []Expectation
+}
+
+var (
+	macARPReq    = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x01}
+	macARPRep    = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x02}
+	macARPTgt    = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x03}
+	macNS        = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x04}
+	macNA        = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x05}
+	macRA        = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x06}
+	macVLAN      = net.HardwareAddr{0x02, 0x5e, 0x1f, 0x00, 0x00, 0x07}
+	macMulticast = net.HardwareAddr{0x03, 0x5e, 0x1f, 0x00, 0x00, 0x08}
+)
+
+// Cases returns the built-in injection cases.
+func Cases() []Case {
+	ipReq := net.ParseIP("198.51.100.1")
+	ipRep := net.ParseIP("198.51.100.2")
+	ipTgt := net.ParseIP("198.51.100.3")
+	ipNS := net.ParseIP("fe80::5e1f:4")
+	ipNA := net.ParseIP("fe80::5e1f:5")
+	ipRA := net.ParseIP("fe80::5e1f:6")
+	ipVLAN := net.ParseIP("198.51.100.7")
+
+	return []Case{
+		{
+			Name:   "arp request",
+			Frame:  frames.ARP(frames.Broadcast, macARPReq, frames.ARPRequest, macARPReq, ipReq, nil, ipTgt),
+			Expect: []Expectation{{Name: "arp request sender", MAC: macARPReq, IP: ipReq}},
+		},
+		{
+			Name:  "arp reply",
+			Frame: frames.ARP(macARPTgt, macARPRep, frames.ARPReply, macARPRep, ipRep, macARPTgt, ipTgt),
+			Expect: []Expectation{
+				{Name: "arp reply sender", MAC: macARPRep, IP: ipRep},
+				{Name: "arp reply target", MAC: macARPTgt, IP: ipTgt},
+			},
+		},
+		{
+			Name:   "ndp ns",
+			Frame:  frames.NS(macNS, ipNS, ipNA),
+			Expect: []Expectation{{Name: "ndp ns source", MAC: macNS, IP: ipNS}},
+		},
+		{
+			Name:   "ndp na",
+			Frame:  frames.NA(macNA, ipNA, ipNA),
+			Expect: []Expectation{{Name: "ndp na target", MAC: macNA, IP: ipNA}},
+		},
+		{
+			Name:   "ndp ra",
+			Frame:  frames.RA(macRA, ipRA),
+			Expect: []Expectation{{Name: "ndp ra source", MAC: macRA, IP: ipRA}},
+		},
+		{
+			Name:   "vlan arp",
+			Frame:  frames.VLAN(frames.ARP(frames.Broadcast, macVLAN, frames.ARPRequest, macVLAN, ipVLAN, nil, ipTgt), 42),
+			Expect: []Expectation{{Name: "vlan arp sender", MAC: macVLAN, IP: ipVLAN}},
+		},
+		{
+			Name:   "multicast source",
+			Frame:  frames.ARP(frames.Broadcast, macMulticast, frames.ARPRequest, macMulticast, ipTgt, nil, ipReq),
+			Expect: []Expectation{{Name: "multicast source ignored", MAC: macMulticast, Absent: true}},
+		},
+	}
+}
+
+// Verify checks neighbours against the expectations of all cases and
+// records one check per expectation. It returns true if all were met.
+func Verify(r *Report, neighbours []dump.Neighbour, cases []Case) bool {
+	byMAC := make(map[string]dump.Neighbour, len(neighbours))
+	for _, n := range neighbours {
+		byMAC[n.MAC.String()] = n
+	}
+
+	ok := true
+	for _, c := range cases {
+		for _, e := range c.Expect {
+			if err := verifyOne(byMAC, e); err != nil {
+				r.fail(e.Name, err)
+				ok = false
+				continue
+			}
+			detail := e.MAC.String()
+			if e.IP != nil {
+				detail += " " + e.IP.String()
+			}
+			r.pass(e.Name, detail)
+		}
+	}
+	return ok
+}
+
+// met reports whether every expectation is already satisfied, without
+// recording checks. Used while polling the map.
+func met(neighbours []dump.Neighbour, cases []Case) bool {
+	byMAC := make(map[string]dump.Neighbour, len(neighbours))
+	for _, n := range neighbours {
+		byMAC[n.MAC.String()] = n
+	}
+	for _, c := range cases {
+		for _, e := range c.Expect {
+			if verifyOne(byMAC, e) != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func verifyOne(byMAC map[string]dump.Neighbour, e Expectation) error {
+	n, found := byMAC[e.MAC.String()]
+	if e.Absent {
+		if found {
+			return fmt.Errorf("%s should not be tracked", e.MAC)
+		}
+		return nil
+	}
+	if !found {
+		return fmt.Errorf("%s not found in map", e.MAC)
+	}
+	if e.IP == nil {
+		return nil
+	}
+	for _, ip := range append(append([]net.IP{}, n.IPv4...), n.IPv6...) {
+		if ip.Equal(e.IP) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s found without %s (ipv4=[%s] ipv6=[%s])", e.MAC, e.IP, n.IPv4String(), n.IPv6String())
+}
+
+// VerifyExport renders neighbours as export JSON for iface, decodes it
+// again and checks the expected MACs survive the round trip.
+func VerifyExport(iface string, neighbours []dump.Neighbour, cases []Case) error {
+	info, err := export.LookupInterfaceInfo(iface)
+	if err != nil {
+		return err
+	}
+	stats, err := export.LookupInterfaceStats(iface)
+	if err != nil {
+		return err
+	}
+
+	data := export.NewInterfaceData(iface, time.Now(), 0, neighbours, info, stats)
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %w", err)
+	}
+
+	var decoded export.InterfaceData
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return fmt.Errorf("decoding JSON: %w", err)
+	}
+	if decoded.Interface != iface {
+		return fmt.Errorf("interface %q, expected %q", decoded.Interface, iface)
+	}
+	if decoded.Stats == nil || decoded.Stats.RxPackets == 0 {
+		return fmt.Errorf("interface stats missing or zero rx_packets")
+	}
+
+	macs := make(map[string]bool, len(decoded.Neighbours))
+	for _, n := range decoded.Neighbours {
+		macs[n.MAC] = true
+	}
+	for _, c := range cases {
+		for _, e := range c.Expect {
+			if !e.Absent && !macs[e.MAC.String()] {
+				return fmt.Errorf("%s missing from export", e.MAC)
+			}
+		}
+	}
+	return nil
+}
+
+// Opts configures a selftest run.
+type Opts struct {
+	// PinPath is the bpffs base directory; the test pins below a unique
+	// subdirectory and removes it afterwards.
+	PinPath string
+	Timeout time.Duration
+	Logger  *slog.Logger
+}
+
+// env holds the resources created for one run, released by cleanup.
+type env struct {
+	origNS  netns.NsHandle
+	testNS  netns.NsHandle
+	host    string
+	peer    string
+	pinBase string
+	probe   *loader.Probe
+}
+
+// Run executes the selftest and returns a report. It only returns an error
+// for invalid options; every failure is recorded as a check.
+func Run(opts Opts) (*Report, error) {
+	if opts.PinPath == "" {
+		return nil, fmt.Errorf("pin path is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	// Namespace switches apply to the calling OS thread only.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	r := &Report{}
+	e := &env{origNS: -1, testNS: -1}
+	defer e.cleanup(opts.Logger)
+
+	suffix, err := randomSuffix()
+	if err != nil {
+		r.fail("setup", err)
+		return r, nil
+	}
+	e.host = "l2rst" + suffix
+	e.peer = "l2rsp" + suffix
+	e.pinBase = filepath.Join(opts.PinPath, "selftest-"+suffix)
+
+	if err := checkLoad(); err != nil {
+		r.fail("load eBPF program", err)
+		return r, nil
+	}
+	r.pass("load eBPF program", "")
+
+	if err := loader.CheckBPFFS(opts.PinPath); err != nil {
+		r.fail("bpffs", err)
+		return r, nil
+	}
+	r.pass("bpffs", opts.PinPath)
+
+	if err := e.createNetns(); err != nil {
+		r.fail("create netns", err)
+		return r, nil
+	}
+	r.pass("create netns", "")
+
+	if err := e.createVeth(); err != nil {
+		r.fail("create veth pair", err)
+		return r, nil
+	}
+	r.pass("create veth pair", e.host+" <-> "+e.peer)
+
+	probe, err := loader.Attach(e.host, e.pinBase, opts.Logger)
+	if err != nil {
+		r.fail("attach TCX and pin map", err)
+		return r, nil
+	}
+	e.probe = probe
+	r.pass("attach TCX and pin map", probe.MapPinPath())
+
+	cases := Cases()
+	if err := e.inject(cases); err != nil {
+		r.fail("inject frames", err)
+		return r, nil
+	}
+	r.pass("inject frames", fmt.Sprintf("%d frames", len(cases)))
+
+	// Frames are delivered asynchronously via the backlog; poll the map.
+	var neighbours []dump.Neighbour
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		neighbours, err = dump.ReadMap(probe.MapPinPath())
+		if err != nil || met(neighbours, cases) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		r.fail("read pinned map", err)
+		return r, nil
+	}
+	r.pass("read pinned map", fmt.Sprintf("%d entries", len(neighbours)))
+
+	Verify(r, neighbours, cases)
+
+	if err := VerifyExport(e.host, neighbours, cases); err != nil {
+		r.fail("export JSON", err)
+	} else {
+		r.pass("export JSON", "")
+	}
+
+	return r, nil
+}
+
+// checkLoad loads and immediately releases the program to separate
+// verifier/kernel problems from attach problems.
+func checkLoad() error {
+	col, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	return col.Close()
+}
+
+// createNetns creates an anonymous namespace and switches back to the
+// original one, keeping a handle to the new namespace.
+func (e *env) createNetns() error {
+	orig, err := netns.Get()
+	if err != nil {
+		return fmt.Errorf("getting current netns: %w", err)
+	}
+	e.origNS = orig
+
+	ns, err := netns.New()
+	if err != nil {
+		return fmt.Errorf("creating netns: %w", err)
+	}
+	e.testNS = ns
+
+	if err := netns.Set(orig); err != nil {
+		return fmt.Errorf("restoring netns: %w", err)
+	}
+	return nil
+}
+
+// createVeth creates the veth pair, moves the peer into the test namespace
+// and brings both ends up.
+func (e *env) createVeth() error {
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: e.host},
+		PeerName:  e.peer,
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return fmt.Errorf("adding veth: %w", err)
+	}
+
+	peer, err := netlink.LinkByName(e.peer)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", e.peer, err)
+	}
+	if err := netlink.LinkSetNsFd(peer, int(e.testNS)); err != nil {
+		return fmt.Errorf("moving %s to netns: %w", e.peer, err)
+	}
+
+	host, err := netlink.LinkByName(e.host)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", e.host, err)
+	}
+	if err := netlink.LinkSetUp(host); err != nil {
+		return fmt.Errorf("bringing up %s: %w", e.host, err)
+	}
+
+	h, err := netlink.NewHandleAt(e.testNS)
+	if err != nil {
+		return fmt.Errorf("netlink handle in netns: %w", err)
+	}
+	defer h.Close()
+	peer, err = h.LinkByName(e.peer)
+	if err != nil {
+		return fmt.Errorf("looking up %s in netns: %w", e.peer, err)
+	}
+	if err := h.LinkSetUp(peer); err != nil {
+		return fmt.Errorf("bringing up %s: %w", e.peer, err)
+	}
+	return nil
+}
+
+// inject sends every case's frame out of the peer end of the veth pair.
+func (e *env) inject(cases []Case) error {
+	if err := netns.Set(e.testNS); err != nil {
+		return fmt.Errorf("entering netns: %w", err)
+	}
+	ifi, ifErr := net.InterfaceByName(e.peer)
+	fd, sockErr := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err := netns.Set(e.origNS); err != nil {
+		if sockErr == nil {
+			unix.Close(fd)
+		}
+		return fmt.Errorf("leaving netns: %w", err)
+	}
+	if sockErr != nil {
+		return fmt.Errorf("opening packet socket: %w", sockErr)
+	}
+	defer unix.Close(fd)
+	if ifErr != nil {
+		return fmt.Errorf("looking up %s: %w", e.peer, ifErr)
+	}
+
+	addr := &unix.SockaddrLinklayer{Ifindex: ifi.Index}
+	for _, c := range cases {
+		if err := unix.Sendto(fd, c.Frame, 0, addr); err != nil {
+			return fmt.Errorf("sending %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// cleanup detaches the probe and removes everything Run created.
+func (e *env) cleanup(logger *slog.Logger) {
+	if e.probe != nil {
+		if err := e.probe.Close(); err != nil {
+			logger.Warn("selftest: closing probe", "error", err)
+		}
+	}
+	if e.pinBase != "" {
+		os.Remove(e.pinBase)
+	}
+	if e.host != "" {
+		if link, err := netlink.LinkByName(e.host); err == nil {
+			netlink.LinkDel(link)
+		}
+	}
+	if e.testNS >= 0 {
+		e.testNS.Close()
+	}
+	if e.origNS >= 0 {
+		netns.Set(e.origNS)
+		e.origNS.Close()
+	}
+}
+
+func randomSuffix() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
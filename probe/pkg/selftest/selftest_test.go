@@ -0,0 +1,113 @@
+package selftest
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/loader"
+)
+
+func TestVerify(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	other := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+	cases := []Case{{
+		Name: "test",
+		Expect: []Expectation{
+			{Name: "with ip", MAC: mac, IP: net.ParseIP("10.0.0.1")},
+			{Name: "wrong ip", MAC: mac, IP: net.ParseIP("10.0.0.2")},
+			{Name: "missing", MAC: other},
+			{Name: "absent", MAC: other, Absent: true},
+			{Name: "not absent", MAC: mac, Absent: true},
+		},
+	}}
+	neighbours := []dump.Neighbour{{MAC: mac, IPv4: []net.IP{net.ParseIP("10.0.0.1").To4()}}}
+
+	r := &Report{}
+	if Verify(r, neighbours, cases) {
+		t.Fatal("expected Verify to fail")
+	}
+
+	want := map[string]bool{"with ip": true, "wrong ip": false, "missing": false, "absent": true, "not absent": false}
+	if len(r.Checks) != len(want) {
+		t.Fatalf("expected %d checks, got %d", len(want), len(r.Checks))
+	}
+	for _, c := range r.Checks {
+		if c.OK != want[c.Name] {
+			t.Errorf("check %q: expected ok=%v, got %v (%s)", c.Name, want[c.Name], c.OK, c.Detail)
+		}
+	}
+	if r.Failed() != 3 {
+		t.Errorf("expected 3 failures, got %d", r.Failed())
+	}
+}
+
+func TestCasesExpectationsMetByOwnFrames(t *testing.T) {
+	for _, c := range Cases() {
+		if len(c.Frame) < 14 {
+			t.Errorf("case %q: frame too short", c.Name)
+		}
+		if len(c.Expect) == 0 {
+			t.Errorf("case %q: no expectations", c.Name)
+		}
+	}
+}
+
+func TestReportFormat(t *testing.T) {
+	r := &Report{}
+	r.pass("load eBPF program", "")
+	var buf strings.Builder
+	r.Format(&buf)
+	if !strings.Contains(buf.String(), "PASS") || !strings.Contains(buf.String(), "selftest PASSED (1 checks)") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+
+	r.Checks = append(r.Checks, Check{Name: "attach", Detail: "boom"})
+	buf.Reset()
+	r.Format(&buf)
+	if !strings.Contains(buf.String(), "FAIL") || !strings.Contains(buf.String(), "1 of 2 checks failed") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+	if r.Passed() {
+		t.Error("report with a failed check should not pass")
+	}
+}
+
+func TestReportEmptyDoesNotPass(t *testing.T) {
+	if (&Report{}).Passed() {
+		t.Error("empty report should not pass")
+	}
+}
+
+func TestRunRequiresPinPath(t *testing.T) {
+	if _, err := Run(Opts{}); err == nil {
+		t.Fatal("expected error without pin path")
+	}
+}
+
+func TestRun(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("skipping: selftest requires root")
+	}
+	if err := loader.CheckBPFFS("/sys/fs/bpf"); err != nil {
+		t.Skipf("skipping: %v", err)
+	}
+	pinPath := filepath.Join("/sys/fs/bpf", "l2radar-test-"+t.Name())
+	defer os.RemoveAll(pinPath)
+
+	r, err := Run(Opts{PinPath: pinPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !r.Passed() {
+		var buf strings.Builder
+		r.Format(&buf)
+		t.Fatalf("selftest failed:\n%s", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(pinPath, "selftest-")); err == nil {
+		t.Error("pin directory should be removed")
+	}
+}
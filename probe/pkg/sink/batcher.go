@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// flushTimeout bounds the delivery of queued items on shutdown.
+const flushTimeout = 5 * time.Second
+
+// DeliverFunc sends a batch of items. It returns the items worth
+// delivering again later and the error of a failed delivery; items neither
+// delivered nor returned are dropped. Cancelling ctx should stop retries,
+// and items not delivered because of it should be returned.
+type DeliverFunc[T any] func(ctx context.Context, batch []T) (retry []T, err error)
+
+// BatcherOptions configure a Batcher.
+type BatcherOptions struct {
+	// Name is the sink type, which prefixes log messages.
+	Name string
+	// Items names the queued items in log messages, e.g. "documents".
+	Items string
+	// Target identifies the destination in log messages, as key-value
+	// pairs, e.g. "url", u.
+	Target []any
+	// BatchSize is the maximum number of items per delivery (all queued
+	// items if 0).
+	BatchSize int
+	// BatchWait delays a delivery that is not full, to batch the items of
+	// successive export cycles.
+	BatchWait time.Duration
+	// RetryInterval is the delay before delivering items queued again
+	// after a failure; if 0, they wait for the next Push.
+	RetryInterval time.Duration
+	Logger        *slog.Logger
+}
+
+// Batcher is the delivery loop of a sink: Observe pushes encoded items,
+// and Run delivers them in batches with the sink's DeliverFunc, queues
+// again those to retry and flushes the queue on shutdown.
+type Batcher[T any] struct {
+	opts    BatcherOptions
+	deliver DeliverFunc[T]
+	queue   *Queue[T]
+}
+
+// NewBatcher returns a batcher delivering with deliver. Items are queued
+// up to DefaultQueueSize.
+func NewBatcher[T any](opts BatcherOptions, deliver DeliverFunc[T]) *Batcher[T] {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Batcher[T]{opts: opts, deliver: deliver, queue: NewQueue[T](DefaultQueueSize)}
+}
+
+// Push queues items and wakes Run.
+func (b *Batcher[T]) Push(items ...T) {
+	b.queue.Push(items...)
+}
+
+// Len returns the number of queued items.
+func (b *Batcher[T]) Len() int {
+	return b.queue.Len()
+}
+
+// Run delivers queued items until ctx is cancelled, then delivers what is
+// left for up to 5s, without waiting for batches to fill.
+func (b *Batcher[T]) Run(ctx context.Context) {
+	var retry <-chan time.Time
+	if b.opts.RetryInterval > 0 {
+		t := time.NewTicker(b.opts.RetryInterval)
+		defer t.Stop()
+		retry = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			defer cancel()
+			for b.Send(flushCtx) {
+			}
+			if n := b.queue.Len(); n > 0 {
+				b.log(slog.LevelWarn, b.opts.Items+" not delivered before shutdown", "count", n)
+			}
+			return
+		case <-b.queue.Ready():
+		case <-retry:
+		}
+		if b.opts.BatchWait > 0 && b.queue.Len() < b.opts.BatchSize {
+			select {
+			case <-ctx.Done():
+				continue
+			case <-time.After(b.opts.BatchWait):
+			}
+		}
+		for b.Send(ctx) {
+		}
+	}
+}
+
+// Send delivers one batch and reports whether to deliver the next one
+// right away: false once the queue is empty or items were queued again.
+func (b *Batcher[T]) Send(ctx context.Context) bool {
+	if dropped := b.queue.Dropped(); dropped > 0 {
+		b.log(slog.LevelWarn, "dropped queued "+b.opts.Items, "count", dropped)
+	}
+	batch := b.queue.Take(b.opts.BatchSize)
+	if len(batch) == 0 {
+		return false
+	}
+	retry, err := b.deliver(ctx, batch)
+	switch {
+	case len(retry) > 0:
+		b.queue.Requeue(retry)
+		if err != nil && ctx.Err() == nil {
+			b.log(slog.LevelWarn, "delivery failed, retrying", "count", len(retry), "error", err)
+		}
+		return false
+	case err != nil:
+		b.log(slog.LevelError, "dropped "+b.opts.Items, "count", len(batch), "error", err)
+	}
+	return b.queue.Len() > 0
+}
+
+func (b *Batcher[T]) log(level slog.Level, msg string, args ...any) {
+	args = append(slices.Clip(b.opts.Target), args...)
+	b.opts.Logger.Log(context.Background(), level, b.opts.Name+": "+msg, args...)
+}
@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+func TestBatcher(t *testing.T) {
+	var (
+		batches [][]int
+		fail    error
+		retry   bool
+	)
+	b := NewBatcher(BatcherOptions{Name: "test", Items: "ints", BatchSize: 2, Logger: discard}, func(ctx context.Context, batch []int) ([]int, error) {
+		batches = append(batches, batch)
+		if retry {
+			return batch[1:], fail
+		}
+		return nil, fail
+	})
+
+	// Batches are sent until the queue is empty.
+	b.Push(1, 2, 3)
+	for b.Send(context.Background()) {
+	}
+	if len(batches) != 2 || !slices.Equal(batches[1], []int{3}) || b.Len() != 0 {
+		t.Fatalf("batches %v, %d queued", batches, b.Len())
+	}
+
+	// Items returned are queued again and stop the round.
+	batches, fail, retry = nil, errors.New("unavailable"), true
+	b.Push(4, 5, 6)
+	if b.Send(context.Background()) || b.Len() != 2 {
+		t.Fatalf("expected 5 requeued and to stop, %d queued", b.Len())
+	}
+
+	// A batch that fails without items to retry is dropped, and the next
+	// one can be sent right away.
+	retry = false
+	b.Push(7)
+	if !b.Send(context.Background()) || b.Len() != 1 {
+		t.Fatalf("expected the batch dropped and to continue, %d queued", b.Len())
+	}
+	if len(batches) != 2 || !slices.Equal(batches[1], []int{5, 6}) {
+		t.Errorf("batches %v", batches)
+	}
+}
+
+func TestBatcherFlushOnStop(t *testing.T) {
+	var sent []int
+	b := NewBatcher(BatcherOptions{Name: "test", Items: "ints", BatchSize: 1, Logger: discard}, func(ctx context.Context, batch []int) ([]int, error) {
+		if ctx.Err() != nil {
+			return batch, ctx.Err()
+		}
+		sent = append(sent, batch...)
+		return nil, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Push(1, 2, 3)
+	b.Run(ctx)
+	if !slices.Equal(sent, []int{1, 2, 3}) || b.Len() != 0 {
+		t.Errorf("sent %v on stop, %d left", sent, b.Len())
+	}
+}
@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Defaults shared by the sinks.
+const (
+	// DefaultTimeout bounds a request to, or a connection attempt with, a
+	// sink's destination.
+	DefaultTimeout = 10 * time.Second
+	// DefaultRetryInterval is the delay before delivering items queued
+	// again after a failure.
+	DefaultRetryInterval = 10 * time.Second
+	// DefaultExpireAfter reports a MAC as expired once not seen for this
+	// long (see events.Tracker).
+	DefaultExpireAfter = 30 * time.Minute
+)
+
+// ReadSecret returns value, or the trimmed contents of file, for the
+// options key and key_file. Setting both, or an empty file, is an error.
+func ReadSecret(value, file, key string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("%s and %s_file are mutually exclusive", key, key)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", key, err)
+	}
+	if value = strings.TrimSpace(string(b)); value == "" {
+		return "", fmt.Errorf("%s file %s is empty", key, file)
+	}
+	return value, nil
+}
+
+// CheckHTTPURL checks that the option key is an http(s) URL with a host.
+func CheckHTTPURL(key, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http(s) URL, got %q", key, raw)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package sink
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte(" s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := ReadSecret("inline", "", "token"); err != nil || got != "inline" {
+		t.Errorf("inline: %q, %v", got, err)
+	}
+	if got, err := ReadSecret("", file, "token"); err != nil || got != "s3cret" {
+		t.Errorf("file: %q, %v", got, err)
+	}
+	for name, args := range map[string][2]string{
+		"both":    {"inline", file},
+		"missing": {"", filepath.Join(dir, "nonexistent")},
+		"empty":   {"", empty},
+	} {
+		if _, err := ReadSecret(args[0], args[1], "token"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCheckHTTPURL(t *testing.T) {
+	for _, raw := range []string{"http://influxdb:8086", "https://es:9200/"} {
+		if err := CheckHTTPURL("url", raw); err != nil {
+			t.Errorf("%s: %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "not a url", "tcp://broker:1883", "http://"} {
+		if err := CheckHTTPURL("url", raw); err == nil {
+			t.Errorf("%q: expected error", raw)
+		}
+	}
+}
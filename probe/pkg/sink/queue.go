@@ -0,0 +1,95 @@
+package sink
+
+import "sync"
+
+// DefaultQueueSize bounds the items a sink queues while its destination is
+// unreachable.
+const DefaultQueueSize = 10000
+
+// Queue is a bounded FIFO between a sink's Observe and Run. When full, the
+// oldest items are dropped and counted. It is safe for concurrent use.
+type Queue[T any] struct {
+	max   int
+	ready chan struct{}
+
+	mu      sync.Mutex
+	items   []T
+	dropped int
+}
+
+// NewQueue returns a queue holding at most max items.
+func NewQueue[T any](max int) *Queue[T] {
+	return &Queue[T]{max: max, ready: make(chan struct{}, 1)}
+}
+
+// Push appends items and, if any, wakes the consumer.
+func (q *Queue[T]) Push(items ...T) {
+	if len(items) == 0 {
+		return
+	}
+	q.mu.Lock()
+	q.items = append(q.items, items...)
+	q.trim()
+	q.mu.Unlock()
+	q.Wake()
+}
+
+// Requeue puts items back at the front, e.g. after a failed delivery.
+// Items beyond the bound are dropped from the front, as with Push.
+func (q *Queue[T]) Requeue(items []T) {
+	if len(items) == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(append([]T(nil), items...), q.items...)
+	q.trim()
+}
+
+func (q *Queue[T]) trim() {
+	if n := len(q.items) - q.max; n > 0 {
+		q.items = q.items[n:]
+		q.dropped += n
+	}
+}
+
+// Take removes and returns up to n items (all if n <= 0).
+func (q *Queue[T]) Take(n int) []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n <= 0 || n > len(q.items) {
+		n = len(q.items)
+	}
+	out := q.items[:n:n]
+	q.items = q.items[n:]
+	return out
+}
+
+// Len returns the number of queued items.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Dropped returns the number of items dropped since the previous call.
+func (q *Queue[T]) Dropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := q.dropped
+	q.dropped = 0
+	return n
+}
+
+// Ready receives a value after Push or Wake; several are coalesced.
+func (q *Queue[T]) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// Wake makes Ready receive without pushing, e.g. once reconnected.
+func (q *Queue[T]) Wake() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,44 @@
+package sink
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue[int](4)
+	q.Push()
+	select {
+	case <-q.Ready():
+		t.Fatal("empty push woke the consumer")
+	default:
+	}
+
+	q.Push(1, 2, 3, 4, 5, 6)
+	select {
+	case <-q.Ready():
+	default:
+		t.Fatal("push did not wake the consumer")
+	}
+	if got := q.Take(0); !slices.Equal(got, []int{3, 4, 5, 6}) {
+		t.Errorf("Take(0) = %v", got)
+	}
+	if n := q.Dropped(); n != 2 {
+		t.Errorf("Dropped() = %d, want 2", n)
+	}
+	if n := q.Dropped(); n != 0 {
+		t.Errorf("Dropped() not reset: %d", n)
+	}
+
+	q.Push(7, 8, 9)
+	batch := q.Take(2)
+	if !slices.Equal(batch, []int{7, 8}) || q.Len() != 1 {
+		t.Fatalf("Take(2) = %v, %d left", batch, q.Len())
+	}
+	q.Push(10, 11)
+	// Requeued items go first; the oldest beyond the bound are dropped.
+	q.Requeue(batch)
+	if got := q.Take(0); !slices.Equal(got, []int{8, 9, 10, 11}) || q.Dropped() != 1 {
+		t.Errorf("after Requeue: %v", got)
+	}
+}
@@ -0,0 +1,74 @@
+// Package sink defines the outputs configured under "sinks" in the probe
+// configuration file and the registry they are built from.
+//
+// A sink observes the export loop (daemon.Observer), queues what it
+// derives from the snapshots and delivers it from Run, usually with a
+// Batcher, so that it only encodes and delivers batches. To add an output,
+// implement Sink in its own package, Register a Factory for its type in an
+// init function and import the package in the probe CLI. The registry is
+// the only list of sink types: New rejects the others.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+)
+
+// Sink is a configured output. Observe and Detached are called with the
+// daemon locked and must not block; delivery happens in Run, which returns
+// once ctx is cancelled and pending output is flushed (or given up on).
+type Sink interface {
+	daemon.Observer
+	Run(ctx context.Context)
+}
+
+// Factory builds a sink from its configuration entry, validating its
+// options. It must not start anything or contact the network, so that
+// "config check" can use it.
+type Factory func(s config.Sink, logger *slog.Logger) (Sink, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a sink type available. It panics if the type is already
+// registered.
+func Register(typ string, f Factory) {
+	if _, ok := factories[typ]; ok {
+		panic("sink: type " + typ + " registered twice")
+	}
+	factories[typ] = f
+}
+
+// Types returns the registered sink types, sorted.
+func Types() []string {
+	types := make([]string, 0, len(factories))
+	for t := range factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New builds the sink of a configuration entry.
+func New(s config.Sink, logger *slog.Logger) (Sink, error) {
+	f, ok := factories[s.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type %q (available: %s)", s.Type, strings.Join(Types(), ", "))
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return f(s, logger)
+}
+
+// Selects reports whether a sink restricted to interfaces (all if empty)
+// takes snapshots of iface.
+func Selects(interfaces []string, iface string) bool {
+	return len(interfaces) == 0 || slices.Contains(interfaces, iface)
+}
@@ -0,0 +1,243 @@
+package syslog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/marc/l2radar/probe/pkg/events"
+)
+
+// Payload formats.
+const (
+	FormatRFC5424 = "rfc5424"
+	FormatCEF     = "cef"
+	FormatLEEF    = "leef"
+)
+
+// Syslog severities used.
+const (
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+)
+
+// facilities maps names to syslog facility codes.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// sdID is the structured data ID of the rfc5424 format (32473 is the
+// private enterprise number reserved for documentation, RFC 5612).
+const sdID = "l2radar@32473"
+
+// severity returns the syslog severity of a change.
+func severity(c events.Change) int {
+	switch c.Type {
+	case events.ChangeIPConflict:
+		return severityWarning
+	case events.ChangeMACExpired, events.ChangeMACRemoved:
+		return severityNotice
+	}
+	return severityInfo
+}
+
+// name is a short description of a change type, the CEF name field.
+func name(typ string) string {
+	switch typ {
+	case events.ChangeNewMAC:
+		return "New device"
+	case events.ChangeNewIP:
+		return "New IP address"
+	case events.ChangeMACRemoved:
+		return "Device removed"
+	case events.ChangeMACExpired:
+		return "Device expired"
+	case events.ChangeIPConflict:
+		return "IP address conflict"
+	}
+	return typ
+}
+
+// text is the human-readable message of a change.
+func text(c events.Change) string {
+	switch c.Type {
+	case events.ChangeNewMAC:
+		return fmt.Sprintf("new MAC %s on %s", c.MAC, c.Interface)
+	case events.ChangeNewIP:
+		return fmt.Sprintf("MAC %s on %s has new IP %s", c.MAC, c.Interface, c.IP)
+	case events.ChangeMACRemoved:
+		return fmt.Sprintf("MAC %s removed from %s", c.MAC, c.Interface)
+	case events.ChangeMACExpired:
+		return fmt.Sprintf("MAC %s on %s not seen recently", c.MAC, c.Interface)
+	case events.ChangeIPConflict:
+		return fmt.Sprintf("IP %s on %s claimed by %s", c.IP, c.Interface, strings.Join(c.MACs, ", "))
+	}
+	return c.Type
+}
+
+// formatter renders syslog messages.
+type formatter struct {
+	format   string
+	facility int
+	hostname string
+	version  string
+	pid      string
+}
+
+// message renders an RFC 5424 message for a change with the given vendor
+// (may be empty):
+//
+//	<PRI>1 TIMESTAMP HOSTNAME l2radar PROCID MSGID SD MSG
+//
+// MSGID is the change type. With the rfc5424 format, the change is in the
+// structured data and MSG is text; with cef and leef, SD is "-" and MSG is
+// the CEF or LEEF record.
+func (f *formatter) message(c events.Change, vendor string) string {
+	var sd, msg string
+	switch f.format {
+	case FormatCEF:
+		sd, msg = "-", f.cef(c, vendor)
+	case FormatLEEF:
+		sd, msg = "-", f.leef(c, vendor)
+	default:
+		sd, msg = structuredData(c, vendor), text(c)
+	}
+	return fmt.Sprintf("<%d>1 %s %s l2radar %s %s %s %s",
+		f.facility*8+severity(c),
+		c.Time.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		headerField(f.hostname), f.pid, c.Type, sd, msg)
+}
+
+// headerField returns s for an RFC 5424 header field: printable ASCII
+// without spaces, "-" if empty.
+func headerField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func structuredData(c events.Change, vendor string) string {
+	var b strings.Builder
+	b.WriteString("[" + sdID)
+	param := func(k, v string) {
+		if v != "" {
+			b.WriteString(" " + k + `="` + sdEscaper.Replace(v) + `"`)
+		}
+	}
+	param("iface", c.Interface)
+	param("mac", c.MAC)
+	param("ip", c.IP)
+	param("macs", strings.Join(c.MACs, ","))
+	param("vendor", vendor)
+	b.WriteString("]")
+	return b.String()
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// recordSeverity is the 0-10 severity of CEF and LEEF records.
+func recordSeverity(c events.Change) int {
+	switch severity(c) {
+	case severityWarning:
+		return 7
+	case severityNotice:
+		return 4
+	}
+	return 3
+}
+
+// cef renders an ArcSight Common Event Format record.
+func (f *formatter) cef(c events.Change, vendor string) string {
+	ext := []string{
+		"rt=" + fmt.Sprint(c.Time.UnixMilli()),
+		"deviceInboundInterface=" + cefExtEscaper.Replace(c.Interface),
+		"dvchost=" + cefExtEscaper.Replace(f.hostname),
+	}
+	if c.MAC != "" {
+		ext = append(ext, "smac="+c.MAC)
+	}
+	if c.IP != "" {
+		if strings.Contains(c.IP, ":") {
+			ext = append(ext, "c6a2="+c.IP, "c6a2Label=Source IPv6 Address")
+		} else {
+			ext = append(ext, "src="+c.IP)
+		}
+	}
+	if len(c.MACs) > 0 {
+		ext = append(ext, "cs1="+strings.Join(c.MACs, ","), "cs1Label=Conflicting MACs")
+	}
+	if vendor != "" {
+		ext = append(ext, "cs2="+cefExtEscaper.Replace(vendor), "cs2Label=Vendor")
+	}
+	ext = append(ext, "msg="+cefExtEscaper.Replace(text(c)))
+	return fmt.Sprintf("CEF:0|l2radar|l2radar|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(f.version), c.Type, name(c.Type), recordSeverity(c), strings.Join(ext, " "))
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// leef renders an IBM QRadar LEEF 1.0 record (tab-separated attributes).
+func (f *formatter) leef(c events.Change, vendor string) string {
+	attrs := []string{
+		"cat=" + c.Type,
+		"devTime=" + c.Time.UTC().Format("Jan 02 2006 15:04:05.000 MST"),
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+		fmt.Sprintf("sev=%d", recordSeverity(c)),
+		"iface=" + leefEscaper.Replace(c.Interface),
+	}
+	if c.MAC != "" {
+		attrs = append(attrs, "srcMAC="+c.MAC)
+	}
+	if c.IP != "" {
+		attrs = append(attrs, "src="+c.IP)
+	}
+	if len(c.MACs) > 0 {
+		attrs = append(attrs, "conflictingMACs="+strings.Join(c.MACs, ","))
+	}
+	if vendor != "" {
+		attrs = append(attrs, "vendor="+leefEscaper.Replace(vendor))
+	}
+	attrs = append(attrs, "msg="+leefEscaper.Replace(text(c)))
+	return fmt.Sprintf("LEEF:1.0|l2radar|l2radar|%s|%s|%s",
+		cefHeaderEscaper.Replace(f.version), c.Type, strings.Join(attrs, "\t"))
+}
+
+var leefEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+func newFormatter(format, facility, hostname, version string) (*formatter, error) {
+	switch format {
+	case "", FormatRFC5424:
+		format = FormatRFC5424
+	case FormatCEF, FormatLEEF:
+	default:
+		return nil, fmt.Errorf("invalid format %q (supported: %s, %s, %s)", format, FormatRFC5424, FormatCEF, FormatLEEF)
+	}
+	fac, ok := facilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("invalid facility %q", facility)
+	}
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	return &formatter{
+		format:   format,
+		facility: fac,
+		hostname: hostname,
+		version:  version,
+		pid:      fmt.Sprint(os.Getpid()),
+	}, nil
+}
@@ -0,0 +1,311 @@
+// Package syslog sends neighbour changes (see events.Tracker) as RFC 5424
+// syslog messages over UDP, TCP, TLS or a local unix socket (/dev/log),
+// with the change in structured data or as a CEF or LEEF record.
+//
+// It is configured as a sink of type "syslog" in the probe configuration
+// file or with the --syslog flags.
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/sink"
+	"github.com/marc/l2radar/probe/pkg/version"
+)
+
+// Type is the sink type in the configuration file.
+const Type = "syslog"
+
+// LocalSocket is the local syslog socket, used for the address "local".
+const LocalSocket = "/dev/log"
+
+// Framings of TCP and TLS streams.
+const (
+	// FramingOctetCounting prefixes messages with their length (RFC 6587,
+	// required by RFC 5425 for TLS).
+	FramingOctetCounting = "octet-counting"
+	// FramingNewline terminates messages with LF.
+	FramingNewline = "newline"
+)
+
+// DefaultFacility is the facility of messages when none is configured.
+const DefaultFacility = "local0"
+
+// DefaultEvents are the change types sent when none are configured.
+var DefaultEvents = []string{events.ChangeNewMAC, events.ChangeNewIP, events.ChangeMACExpired, events.ChangeIPConflict}
+
+// Options are the type-specific keys of a syslog sink.
+type Options struct {
+	// Address is udp://host[:514], tcp://host[:514], tls://host[:6514],
+	// unix:///path or "local" (/dev/log).
+	Address string `yaml:"address"`
+	// Format is rfc5424 (default), cef or leef.
+	Format   string `yaml:"format,omitempty"`
+	Facility string `yaml:"facility,omitempty"`
+	// Framing of TCP and TLS streams: octet-counting (default) or newline.
+	Framing string `yaml:"framing,omitempty"`
+	// TLSCA is a PEM file of CAs to verify the server with (system roots
+	// if empty).
+	TLSCA       string `yaml:"tls_ca,omitempty"`
+	TLSInsecure bool   `yaml:"tls_insecure,omitempty"`
+	// Hostname overrides the HOSTNAME field.
+	Hostname string `yaml:"hostname,omitempty"`
+	// Events are the change types to send (default DefaultEvents).
+	Events []string `yaml:"events,omitempty"`
+	// ExpireAfter reports a MAC as expired once not seen for this long.
+	ExpireAfter time.Duration `yaml:"expire_after,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+}
+
+// ParseOptions decodes and validates the options of a syslog sink and
+// fills defaults.
+func ParseOptions(s config.Sink) (Options, error) {
+	var o Options
+	if err := s.Decode(&o); err != nil {
+		return o, err
+	}
+	if _, _, err := parseAddress(o.Address); err != nil {
+		return o, err
+	}
+	if o.Format == "" {
+		o.Format = FormatRFC5424
+	}
+	if o.Facility == "" {
+		o.Facility = DefaultFacility
+	}
+	if _, err := newFormatter(o.Format, o.Facility, o.Hostname, ""); err != nil {
+		return o, err
+	}
+	switch o.Framing {
+	case "":
+		o.Framing = FramingOctetCounting
+	case FramingOctetCounting, FramingNewline:
+	default:
+		return o, fmt.Errorf("invalid framing %q (supported: %s, %s)", o.Framing, FramingOctetCounting, FramingNewline)
+	}
+	if len(o.Events) == 0 {
+		o.Events = DefaultEvents
+	}
+	for _, e := range o.Events {
+		if !slices.Contains(events.ChangeTypes, e) {
+			return o, fmt.Errorf("unknown event %q (supported: %s)", e, strings.Join(events.ChangeTypes, ", "))
+		}
+	}
+	if o.ExpireAfter < 0 || o.Timeout < 0 {
+		return o, fmt.Errorf("negative expire_after or timeout")
+	}
+	if o.ExpireAfter == 0 {
+		o.ExpireAfter = sink.DefaultExpireAfter
+	}
+	if o.Timeout == 0 {
+		o.Timeout = sink.DefaultTimeout
+	}
+	return o, nil
+}
+
+// parseAddress returns the network ("udp", "tcp", "tls" or "unixgram") and
+// address of a sink address, with default ports.
+func parseAddress(addr string) (network, address string, err error) {
+	if addr == "local" {
+		return "unixgram", LocalSocket, nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("invalid address %q: missing socket path", addr)
+		}
+		return "unixgram", u.Path, nil
+	case "udp", "tcp", "tls":
+		if u.Hostname() == "" {
+			return "", "", fmt.Errorf("invalid address %q: missing host", addr)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "514"
+			if u.Scheme == "tls" {
+				port = "6514"
+			}
+		}
+		return u.Scheme, net.JoinHostPort(u.Hostname(), port), nil
+	}
+	return "", "", fmt.Errorf("invalid address %q (expected udp://, tcp://, tls://, unix:// or \"local\")", addr)
+}
+
+// Sink queues the messages of the selected interfaces and sends them from
+// Run. It implements daemon.Observer.
+type Sink struct {
+	opts    Options
+	ifaces  []string // all if empty
+	network string
+	address string
+	tls     *tls.Config
+	format  *formatter
+
+	mu      sync.Mutex
+	tracker *events.Tracker
+	batcher *sink.Batcher[string]
+
+	conn net.Conn // used by Run only
+}
+
+var _ sink.Sink = (*Sink)(nil)
+
+func init() {
+	sink.Register(Type, func(s config.Sink, logger *slog.Logger) (sink.Sink, error) {
+		return New(s, logger)
+	})
+}
+
+// New returns a sink for the syslog entry s. Nothing is sent until Run.
+func New(s config.Sink, logger *slog.Logger) (*Sink, error) {
+	o, err := ParseOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	network, address, _ := parseAddress(o.Address)
+	f, err := newFormatter(o.Format, o.Facility, o.Hostname, version.String())
+	if err != nil {
+		return nil, err
+	}
+	sk := &Sink{
+		opts:    o,
+		ifaces:  s.Interfaces,
+		network: network,
+		address: address,
+		format:  f,
+		tracker: events.NewTracker(o.ExpireAfter),
+	}
+	sk.batcher = sink.NewBatcher(sink.BatcherOptions{
+		Name:          Type,
+		Items:         "messages",
+		Target:        []any{"address", o.Address},
+		RetryInterval: sink.DefaultRetryInterval,
+		Logger:        logger,
+	}, sk.deliver)
+	if network == "tls" {
+		host, _, _ := net.SplitHostPort(address)
+		sk.tls = &tls.Config{ServerName: host, InsecureSkipVerify: o.TLSInsecure}
+		if o.TLSCA != "" {
+			pem, err := os.ReadFile(o.TLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("reading tls_ca: %w", err)
+			}
+			sk.tls.RootCAs = x509.NewCertPool()
+			if !sk.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("tls_ca %s: no certificates found", o.TLSCA)
+			}
+		}
+	}
+	return sk, nil
+}
+
+// Observe queues a message per selected change since the interface's
+// previous snapshot.
+func (s *Sink) Observe(snap daemon.Snapshot) {
+	if !sink.Selects(s.ifaces, snap.Interface) {
+		return
+	}
+	s.mu.Lock()
+	changes := s.tracker.Update(snap)
+	s.mu.Unlock()
+
+	var msgs []string
+	for _, c := range changes {
+		if !slices.Contains(s.opts.Events, c.Type) {
+			continue
+		}
+		var vendor string
+		if hw, err := net.ParseMAC(c.MAC); err == nil {
+			vendor = oui.Lookup(hw)
+		}
+		msgs = append(msgs, s.format.message(c, vendor))
+	}
+	s.batcher.Push(msgs...)
+}
+
+// Detached forgets the interface's table.
+func (s *Sink) Detached(iface string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker.Forget(iface)
+}
+
+// Run sends queued messages until ctx is cancelled, then sends what is
+// left and closes the connection. Failed connections are retried every
+// 10s; messages wait meanwhile.
+func (s *Sink) Run(ctx context.Context) {
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+	s.batcher.Run(ctx)
+}
+
+// deliver writes batch, connecting first if needed, and returns the
+// messages not written.
+func (s *Sink) deliver(ctx context.Context, batch []string) ([]string, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(s.opts.Timeout)
+	}
+	if s.conn == nil {
+		conn, err := s.dial(deadline)
+		if err != nil {
+			return batch, fmt.Errorf("connection failed: %w", err)
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(deadline)
+	for i, m := range batch {
+		if _, err := s.conn.Write(s.frame(m)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return batch[i:], fmt.Errorf("write failed: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+func (s *Sink) dial(deadline time.Time) (net.Conn, error) {
+	d := &net.Dialer{Deadline: deadline}
+	if s.network == "tls" {
+		return tls.DialWithDialer(d, "tcp", s.address, s.tls)
+	}
+	return d.Dial(s.network, s.address)
+}
+
+// frame returns m as written to the connection: one datagram per message,
+// or framed for streams.
+func (s *Sink) frame(m string) []byte {
+	switch {
+	case s.network != "tcp" && s.network != "tls":
+		return []byte(m)
+	case s.opts.Framing == FramingNewline:
+		return []byte(m + "\n")
+	}
+	return []byte(strconv.Itoa(len(m)) + " " + m)
+}
@@ -0,0 +1,250 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/events"
+)
+
+var (
+	t0       = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	conflict = events.Change{
+		Type:      events.ChangeIPConflict,
+		Interface: "eth0",
+		Time:      t0,
+		MAC:       "02:00:00:00:00:02",
+		IP:        "10.0.0.1",
+		MACs:      []string{"02:00:00:00:00:01", "02:00:00:00:00:02"},
+	}
+)
+
+func testFormatter(t *testing.T, format string) *formatter {
+	t.Helper()
+	f, err := newFormatter(format, "local3", "probe-1", "v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.pid = "42"
+	return f
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format, vendor, want string
+	}{{
+		FormatRFC5424, `Acme "A]"`,
+		`<156>1 2026-10-18T12:00:00.000000Z probe-1 l2radar 42 ip_conflict [l2radar@32473 iface="eth0" mac="02:00:00:00:00:02" ip="10.0.0.1" macs="02:00:00:00:00:01,02:00:00:00:00:02" vendor="Acme \"A\]\""] IP 10.0.0.1 on eth0 claimed by 02:00:00:00:00:01, 02:00:00:00:00:02`,
+	}, {
+		FormatCEF, "A=B",
+		`<156>1 2026-10-18T12:00:00.000000Z probe-1 l2radar 42 ip_conflict - CEF:0|l2radar|l2radar|v1.2.3|ip_conflict|IP address conflict|7|rt=1792324800000 deviceInboundInterface=eth0 dvchost=probe-1 smac=02:00:00:00:00:02 src=10.0.0.1 cs1=02:00:00:00:00:01,02:00:00:00:00:02 cs1Label=Conflicting MACs cs2=A\=B cs2Label=Vendor msg=IP 10.0.0.1 on eth0 claimed by 02:00:00:00:00:01, 02:00:00:00:00:02`,
+	}, {
+		FormatLEEF, "",
+		"<156>1 2026-10-18T12:00:00.000000Z probe-1 l2radar 42 ip_conflict - LEEF:1.0|l2radar|l2radar|v1.2.3|ip_conflict|" +
+			"cat=ip_conflict\tdevTime=Oct 18 2026 12:00:00.000 UTC\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\tsev=7\tiface=eth0\t" +
+			"srcMAC=02:00:00:00:00:02\tsrc=10.0.0.1\tconflictingMACs=02:00:00:00:00:01,02:00:00:00:00:02\t" +
+			"msg=IP 10.0.0.1 on eth0 claimed by 02:00:00:00:00:01, 02:00:00:00:00:02",
+	}}
+	for _, tt := range tests {
+		if got := testFormatter(t, tt.format).message(conflict, tt.vendor); got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.format, got, tt.want)
+		}
+	}
+
+	// new_mac on local3 is info: 19*8+6.
+	m := testFormatter(t, FormatRFC5424).message(events.Change{Type: events.ChangeNewMAC, Interface: "eth0", Time: t0, MAC: "02:00:00:00:00:01"}, "")
+	if !strings.HasPrefix(m, "<158>1 ") || !strings.Contains(m, `[l2radar@32473 iface="eth0" mac="02:00:00:00:00:01"]`) {
+		t.Errorf("unexpected new_mac message %s", m)
+	}
+}
+
+// deliver runs a sink for address, feeds it one snapshot with two new
+// MACs on eth0 and one on eth1, and stops it.
+func deliver(t *testing.T, address string, opts map[string]any) {
+	t.Helper()
+	o := map[string]any{"address": address}
+	for k, v := range opts {
+		o[k] = v
+	}
+	s, err := New(config.Sink{Type: Type, Interfaces: []string{"eth0"}, Options: o}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	now := time.Now().Add(time.Second)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now),
+		testutil.Neighbour("02:00:00:00:00:02", now, now),
+	}})
+	s.Observe(daemon.Snapshot{Interface: "eth1", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:03", now, now),
+	}})
+	cancel()
+	<-done
+}
+
+// checkReceived checks the messages of deliver.
+func checkReceived(t *testing.T, got []string) {
+	t.Helper()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 messages, got %d: %q", len(got), got)
+	}
+	for i, m := range got {
+		mac := fmt.Sprintf(`mac="02:00:00:00:00:0%d"`, i+1)
+		if !strings.HasPrefix(m, "<134>1 ") || !strings.Contains(m, " new_mac [") || !strings.Contains(m, mac) {
+			t.Errorf("message %d: %s", i, m)
+		}
+	}
+}
+
+func TestUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	deliver(t, "udp://"+pc.LocalAddr().String(), nil)
+
+	var got []string
+	buf := make([]byte, 2048)
+	for range 2 {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	checkReceived(t, got)
+}
+
+func TestUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	deliver(t, "unix://"+path, nil)
+
+	var got []string
+	buf := make([]byte, 2048)
+	for range 2 {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	checkReceived(t, got)
+}
+
+func TestTCPFraming(t *testing.T) {
+	for _, framing := range []string{FramingOctetCounting, FramingNewline} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan []string)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				close(received)
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			var got []string
+			for {
+				var m string
+				if framing == FramingNewline {
+					m, err = r.ReadString('\n')
+					m = strings.TrimSuffix(m, "\n")
+				} else {
+					var n string
+					if n, err = r.ReadString(' '); err == nil {
+						size, _ := strconv.Atoi(strings.TrimSpace(n))
+						b := make([]byte, size)
+						_, err = io.ReadFull(r, b)
+						m = string(b)
+					}
+				}
+				if err != nil {
+					break
+				}
+				got = append(got, m)
+			}
+			received <- got
+		}()
+
+		deliver(t, "tcp://"+l.Addr().String(), map[string]any{"framing": framing})
+		checkReceived(t, <-received)
+		l.Close()
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := map[string]string{
+		"local":                "unixgram /dev/log",
+		"unix:///run/log":      "unixgram /run/log",
+		"udp://10.0.0.1":       "udp 10.0.0.1:514",
+		"tcp://siem:1514":      "tcp siem:1514",
+		"tls://siem.example":   "tls siem.example:6514",
+		"udp://[2001:db8::1]":  "udp [2001:db8::1]:514",
+		"http://siem":          "",
+		"udp://":               "",
+		"siem.example.com:514": "",
+		"unix://":              "",
+	}
+	for addr, want := range tests {
+		network, address, err := parseAddress(addr)
+		if got := network + " " + address; (err != nil) != (want == "") || (err == nil && got != want) {
+			t.Errorf("%s: got %q, %v; want %q", addr, got, err, want)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(config.Sink{Type: Type, Options: map[string]any{"address": "local"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Format != FormatRFC5424 || o.Facility != DefaultFacility || o.Framing != FramingOctetCounting || len(o.Events) != len(DefaultEvents) {
+		t.Errorf("defaults not applied: %+v", o)
+	}
+
+	bad := map[string]map[string]any{
+		"no address":    {},
+		"bad format":    {"address": "local", "format": "gelf"},
+		"bad facility":  {"address": "local", "facility": "local9"},
+		"bad framing":   {"address": "local", "framing": "nul"},
+		"unknown event": {"address": "local", "events": []string{"new_cat"}},
+		"unknown key":   {"address": "local", "port": 514},
+	}
+	for name, opts := range bad {
+		if _, err := ParseOptions(config.Sink{Type: Type, Options: opts}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := New(config.Sink{Type: Type, Options: map[string]any{"address": "tls://x", "tls_ca": "/nonexistent"}}, nil); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
@@ -0,0 +1,13 @@
+// Package version reports the version of the probe, e.g. in OTLP
+// resources.
+package version
+
+import "runtime/debug"
+
+// String returns the module version embedded at build time.
+func String() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
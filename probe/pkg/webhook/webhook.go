@@ -0,0 +1,322 @@
+// Package webhook POSTs neighbour changes (see events.Tracker) as JSON to
+// an HTTP endpoint. Changes are batched, signed with HMAC-SHA256 when a
+// secret is configured, and retried with exponential backoff.
+//
+// It is configured as a sink of type "webhook" in the probe configuration
+// file.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/oui"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// Type is the sink type in the configuration file.
+const Type = "webhook"
+
+// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>".
+const SignatureHeader = "X-L2radar-Signature"
+
+// Defaults; see also the sink package.
+const (
+	DefaultBatchSize    = 100
+	DefaultMaxRetries   = 5
+	DefaultRetryBackoff = time.Second
+)
+
+// DefaultEvents are the change types sent when none are configured.
+var DefaultEvents = []string{events.ChangeNewMAC, events.ChangeNewIP, events.ChangeMACExpired, events.ChangeIPConflict}
+
+// maxBackoff caps the delay between retries.
+const maxBackoff = time.Minute
+
+// Options are the type-specific keys of a webhook sink.
+type Options struct {
+	URL string `yaml:"url"`
+	// Events are the change types to send (default DefaultEvents).
+	Events []string `yaml:"events,omitempty"`
+	// Secret, or the contents of SecretFile, signs request bodies.
+	Secret     string            `yaml:"secret,omitempty"`
+	SecretFile string            `yaml:"secret_file,omitempty"`
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	// BatchSize is the maximum number of changes per request.
+	BatchSize int `yaml:"batch_size,omitempty"`
+	// BatchWait delays a request that is not full, to batch changes of
+	// successive export cycles.
+	BatchWait time.Duration `yaml:"batch_wait,omitempty"`
+	// MaxRetries is the number of retries of a failed request; -1
+	// disables retries.
+	MaxRetries int `yaml:"max_retries,omitempty"`
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// retry up to a minute.
+	RetryBackoff time.Duration `yaml:"retry_backoff,omitempty"`
+	// ExpireAfter reports a MAC as expired once not seen for this long.
+	ExpireAfter time.Duration `yaml:"expire_after,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+}
+
+// ParseOptions decodes and validates the options of a webhook sink and
+// fills defaults. The secret file, if any, is read into Secret.
+func ParseOptions(s config.Sink) (Options, error) {
+	var o Options
+	if err := s.Decode(&o); err != nil {
+		return o, err
+	}
+	if err := sink.CheckHTTPURL("url", o.URL); err != nil {
+		return o, err
+	}
+	if len(o.Events) == 0 {
+		o.Events = DefaultEvents
+	}
+	for _, e := range o.Events {
+		if !slices.Contains(events.ChangeTypes, e) {
+			return o, fmt.Errorf("unknown event %q (supported: %s)", e, strings.Join(events.ChangeTypes, ", "))
+		}
+	}
+	var err error
+	if o.Secret, err = sink.ReadSecret(o.Secret, o.SecretFile, "secret"); err != nil {
+		return o, err
+	}
+	if o.BatchSize < 0 || o.BatchWait < 0 || o.MaxRetries < -1 || o.RetryBackoff < 0 || o.ExpireAfter < 0 || o.Timeout < 0 {
+		return o, fmt.Errorf("negative batch, retry, expiry or timeout setting")
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultMaxRetries
+	}
+	if o.RetryBackoff == 0 {
+		o.RetryBackoff = DefaultRetryBackoff
+	}
+	if o.ExpireAfter == 0 {
+		o.ExpireAfter = sink.DefaultExpireAfter
+	}
+	if o.Timeout == 0 {
+		o.Timeout = sink.DefaultTimeout
+	}
+	return o, nil
+}
+
+// Event is a change as sent, with the vendor of its MAC.
+type Event struct {
+	events.Change
+	Vendor string `json:"vendor,omitempty"`
+}
+
+// Payload is the body of a request.
+type Payload struct {
+	Source string    `json:"source"`
+	Host   string    `json:"host"`
+	SentAt time.Time `json:"sent_at"`
+	Events []Event   `json:"events"`
+}
+
+// Sink queues the changes of the selected interfaces and sends them from
+// Run. It implements daemon.Observer.
+type Sink struct {
+	opts   Options
+	ifaces []string // all if empty
+	host   string
+	hc     *http.Client
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	tracker *events.Tracker
+	batcher *sink.Batcher[Event]
+}
+
+var _ sink.Sink = (*Sink)(nil)
+
+func init() {
+	sink.Register(Type, func(s config.Sink, logger *slog.Logger) (sink.Sink, error) {
+		return New(s, logger)
+	})
+}
+
+// New returns a sink for the webhook entry s. Nothing is sent until Run.
+func New(s config.Sink, logger *slog.Logger) (*Sink, error) {
+	o, err := ParseOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	host, _ := os.Hostname()
+	sk := &Sink{
+		opts:    o,
+		ifaces:  s.Interfaces,
+		host:    host,
+		hc:      &http.Client{Timeout: o.Timeout},
+		logger:  logger,
+		tracker: events.NewTracker(o.ExpireAfter),
+	}
+	sk.batcher = sink.NewBatcher(sink.BatcherOptions{
+		Name:      Type,
+		Items:     "neighbour changes",
+		Target:    []any{"url", o.URL},
+		BatchSize: o.BatchSize,
+		BatchWait: o.BatchWait,
+		Logger:    logger,
+	}, sk.deliver)
+	return sk, nil
+}
+
+// Observe queues the selected changes since the interface's previous
+// snapshot.
+func (s *Sink) Observe(snap daemon.Snapshot) {
+	if !sink.Selects(s.ifaces, snap.Interface) {
+		return
+	}
+	s.mu.Lock()
+	changes := s.tracker.Update(snap)
+	s.mu.Unlock()
+
+	var queued []Event
+	for _, c := range changes {
+		if !slices.Contains(s.opts.Events, c.Type) {
+			continue
+		}
+		e := Event{Change: c}
+		if hw, err := net.ParseMAC(c.MAC); err == nil {
+			e.Vendor = oui.Lookup(hw)
+		}
+		queued = append(queued, e)
+	}
+	s.batcher.Push(queued...)
+}
+
+// Detached forgets the interface's table.
+func (s *Sink) Detached(iface string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker.Forget(iface)
+}
+
+// Run sends queued changes until ctx is cancelled, then sends what is
+// left, without waiting for batches to fill.
+func (s *Sink) Run(ctx context.Context) {
+	defer s.hc.CloseIdleConnections()
+	s.batcher.Run(ctx)
+}
+
+// deliver sends batch with post. A batch that cannot be delivered is
+// dropped, unless ctx was cancelled first: it is then returned for the
+// final flush.
+func (s *Sink) deliver(ctx context.Context, batch []Event) ([]Event, error) {
+	if err := s.post(ctx, batch); err != nil {
+		if ctx.Err() != nil {
+			return batch, err
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// post POSTs batch, retrying on network errors, 408, 429 and 5xx
+// responses. Cancelling ctx stops retries but not a request in flight,
+// which is bounded by the timeout option.
+func (s *Sink) post(ctx context.Context, batch []Event) error {
+	backoff := s.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		retryAfter, err := s.request(context.WithoutCancel(ctx), batch)
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) || attempt >= s.opts.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		delay := backoff
+		if retryAfter > 0 {
+			delay = min(retryAfter, maxBackoff)
+		}
+		s.logger.Warn("webhook: request failed, retrying", "url", s.opts.URL, "in", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// permanentError is a response that retrying will not fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// request sends one request. On a retryable response it also returns the
+// delay asked for by Retry-After, if any.
+func (s *Sink) request(ctx context.Context, batch []Event) (time.Duration, error) {
+	body, err := json.Marshal(Payload{Source: "l2radar", Host: s.host, SentAt: time.Now().UTC(), Events: batch})
+	if err != nil {
+		return 0, &permanentError{err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "l2radar")
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(s.opts.Secret), body))
+	}
+
+	resp, err := s.hc.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("POST %s: %s: %s", s.opts.URL, resp.Status, bytes.TrimSpace(msg))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		secs, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(secs) * time.Second, err
+	case resp.StatusCode == http.StatusRequestTimeout:
+		return 0, err
+	}
+	return 0, &permanentError{err}
+}
+
+// Sign returns the signature header value of body: "sha256=" followed by
+// the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
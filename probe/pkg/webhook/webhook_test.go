@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marc/l2radar/probe/internal/testutil"
+	"github.com/marc/l2radar/probe/pkg/config"
+	"github.com/marc/l2radar/probe/pkg/daemon"
+	"github.com/marc/l2radar/probe/pkg/dump"
+	"github.com/marc/l2radar/probe/pkg/events"
+	"github.com/marc/l2radar/probe/pkg/sink"
+)
+
+// receiver records the payloads POSTed to it, checking signatures made
+// with the secret "s3cret". The first failures requests are answered with
+// 503.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	payloads []Payload
+	sigs     []string
+	got      chan struct{}
+}
+
+func startReceiver(t *testing.T, failures int) (*receiver, string) {
+	t.Helper()
+	r := &receiver{failures: failures, got: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		if r.requests <= r.failures {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var p Payload
+		if req.Header.Get("Content-Type") != "application/json" || json.Unmarshal(body, &p) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		sig := req.Header.Get(SignatureHeader)
+		if sig != "" && sig != Sign([]byte("s3cret"), body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		r.payloads = append(r.payloads, p)
+		r.sigs = append(r.sigs, sig)
+		r.got <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func newSink(t *testing.T, ifaces []string, opts map[string]any) *Sink {
+	t.Helper()
+	s, err := New(config.Sink{Type: Type, Interfaces: ifaces, Options: opts}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// run runs s until the returned function is called.
+func run(s *Sink) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	r, url := startReceiver(t, 0)
+	s := newSink(t, []string{"eth0"}, map[string]any{
+		"url":        url,
+		"secret":     "s3cret",
+		"batch_size": 2,
+		"events":     []string{events.ChangeNewMAC, events.ChangeIPConflict},
+	})
+
+	now := time.Now().Add(time.Second)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now, "10.0.0.1"),
+		testutil.Neighbour("02:00:00:00:00:02", now, now, "10.0.0.2"),
+		testutil.Neighbour("02:00:00:00:00:03", now, now, "10.0.0.1"),
+	}})
+	// Not a selected interface.
+	s.Observe(daemon.Snapshot{Interface: "eth1", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:04", now, now),
+	}})
+	stop := run(s)
+	for range 2 {
+		select {
+		case <-r.got:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for requests")
+		}
+	}
+	stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.payloads) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(r.payloads))
+	}
+	for _, sig := range r.sigs {
+		if sig == "" {
+			t.Error("request not signed")
+		}
+	}
+	var got []string
+	for _, p := range r.payloads {
+		if p.Source != "l2radar" || p.SentAt.IsZero() {
+			t.Errorf("unexpected payload header %+v", p)
+		}
+		for _, e := range p.Events {
+			if e.Interface != "eth0" {
+				t.Errorf("event of %s", e.Interface)
+			}
+			got = append(got, e.Type+" "+e.MAC+" "+e.IP)
+		}
+	}
+	want := []string{
+		"new_mac 02:00:00:00:00:01 ",
+		"new_mac 02:00:00:00:00:02 ",
+		"new_mac 02:00:00:00:00:03 ",
+		"ip_conflict 02:00:00:00:00:01 10.0.0.1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events:\n%v\nwant:\n%v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r, url := startReceiver(t, 2)
+	s := newSink(t, nil, map[string]any{"url": url, "retry_backoff": "10ms"})
+
+	now := time.Now().Add(time.Second)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now),
+	}})
+	stop := run(s)
+	defer stop()
+	select {
+	case <-r.got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for request")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.requests != 3 || len(r.payloads) != 1 || r.sigs[0] != "" {
+		t.Errorf("got %d requests, %d payloads, signatures %q", r.requests, len(r.payloads), r.sigs)
+	}
+}
+
+func TestPermanentFailureNotRetried(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	s := newSink(t, nil, map[string]any{"url": srv.URL, "retry_backoff": "10ms"})
+	if retry, err := s.deliver(context.Background(), []Event{{}}); err == nil || len(retry) != 0 {
+		t.Fatalf("expected the batch dropped with an error, got %v, %v", retry, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestFlushOnStop(t *testing.T) {
+	r, url := startReceiver(t, 0)
+	s := newSink(t, nil, map[string]any{"url": url, "batch_wait": "1h"})
+	stop := run(s)
+
+	now := time.Now().Add(time.Second)
+	s.Observe(daemon.Snapshot{Interface: "eth0", Time: now, Neighbours: []dump.Neighbour{
+		testutil.Neighbour("02:00:00:00:00:01", now, now),
+	}})
+	stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.payloads) != 1 {
+		t.Errorf("expected the queued change to be sent on stop, got %d requests", len(r.payloads))
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(config.Sink{Type: Type, Options: map[string]any{"url": "https://example.com/hook"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Events) != len(DefaultEvents) || o.BatchSize != DefaultBatchSize ||
+		o.MaxRetries != DefaultMaxRetries || o.ExpireAfter != sink.DefaultExpireAfter {
+		t.Errorf("defaults not applied: %+v", o)
+	}
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o, err = ParseOptions(config.Sink{Type: Type, Options: map[string]any{"url": "http://x", "secret_file": secret}})
+	if err != nil || o.Secret != "s3cret" {
+		t.Errorf("secret file: %q, %v", o.Secret, err)
+	}
+
+	bad := map[string]map[string]any{
+		"no url":        {},
+		"bad scheme":    {"url": "ftp://x"},
+		"unknown event": {"url": "http://x", "events": []string{"new_cat"}},
+		"both secrets":  {"url": "http://x", "secret": "a", "secret_file": secret},
+		"missing file":  {"url": "http://x", "secret_file": "/nonexistent"},
+		"negative":      {"url": "http://x", "batch_size": -1},
+		"unknown key":   {"url": "http://x", "retires": 3},
+	}
+	for name, opts := range bad {
+		if _, err := ParseOptions(config.Sink{Type: Type, Options: opts}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
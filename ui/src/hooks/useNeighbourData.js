@@ -1,12 +1,19 @@
 import { useState, useEffect, useCallback, useRef } from 'react'
 import { mergeNeighbours } from '../lib/parseNeighbours'
+import { applyDelta } from '../lib/applyDelta'
 
 const DATA_BASE_URL = '/data/'
+const EVENTS_URL = '/events'
 const DEFAULT_POLL_INTERVAL = 5000
 
 /**
- * Hook that discovers and polls JSON files from the data endpoint.
- * Uses If-Modified-Since to avoid re-downloading unchanged files.
+ * Hook that keeps the neighbour tables up to date.
+ *
+ * It subscribes to the probe's event stream (Server-Sent Events): a
+ * snapshot per interface, then deltas every export cycle. If the stream
+ * is unavailable (e.g. the probe has no API socket), it falls back to
+ * discovering and polling JSON files from the data endpoint, using
+ * If-Modified-Since to avoid re-downloading unchanged files.
  */
 export function useNeighbourData(pollInterval = DEFAULT_POLL_INTERVAL) {
   const [neighbours, setNeighbours] = useState([])
@@ -15,10 +22,12 @@ export function useNeighbourData(pollInterval = DEFAULT_POLL_INTERVAL) {
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState(null)
   const lastModifiedRef = useRef({})
+  const fetchRef = useRef(null)
 
   const fetchData = useCallback(async () => {
     try {
-      // Discover available JSON files via nginx autoindex
+      // Discover the per-interface files via nginx autoindex (manifest.json
+      // and neighbours.json are not interface exports)
       const listResp = await fetch(DATA_BASE_URL)
       if (!listResp.ok) {
         throw new Error(`Failed to list data files: ${listResp.status}`)
@@ -26,7 +35,7 @@ export function useNeighbourData(pollInterval = DEFAULT_POLL_INTERVAL) {
 
       const listing = await listResp.json()
       const jsonFiles = listing
-        .filter((entry) => entry.name.endsWith('.json'))
+        .filter((entry) => entry.name.startsWith('neigh-') && entry.name.endsWith('.json'))
         .map((entry) => entry.name)
 
       if (jsonFiles.length === 0) {
@@ -97,11 +106,78 @@ export function useNeighbourData(pollInterval = DEFAULT_POLL_INTERVAL) {
     }
   }, [loading])
 
+  fetchRef.current = fetchData
+
   useEffect(() => {
-    fetchData()
-    const id = setInterval(fetchData, pollInterval)
-    return () => clearInterval(id)
-  }, [fetchData, pollInterval])
+    let pollId = null
+    const startPolling = () => {
+      if (pollId === null) {
+        fetchRef.current()
+        pollId = setInterval(() => fetchRef.current(), pollInterval)
+      }
+    }
+
+    if (typeof EventSource === 'undefined') {
+      startPolling()
+      return () => clearInterval(pollId)
+    }
+
+    // Interface data by name, as in the export files
+    const tables = {}
+    const publish = () => {
+      let merged
+      try {
+        merged = mergeNeighbours(Object.values(tables))
+      } catch (err) {
+        // e.g. an unsupported schema_version
+        setError(err.message)
+        setLoading(false)
+        return
+      }
+      setNeighbours(merged.neighbours)
+      setTimestamps(merged.timestamps)
+      setInterfaceInfo(merged.interfaceInfo)
+      setError(null)
+      setLoading(false)
+    }
+
+    const es = new EventSource(EVENTS_URL)
+    es.addEventListener('open', () => {
+      // (Re)connected: the server resends a snapshot per interface
+      for (const name of Object.keys(tables)) {
+        delete tables[name]
+      }
+      setLoading(false)
+    })
+    es.addEventListener('snapshot', (e) => {
+      const data = JSON.parse(e.data)
+      tables[data.interface] = data
+      publish()
+    })
+    es.addEventListener('delta', (e) => {
+      const delta = JSON.parse(e.data)
+      if (tables[delta.interface]) {
+        tables[delta.interface] = applyDelta(tables[delta.interface], delta)
+        publish()
+      }
+    })
+    es.addEventListener('detached', (e) => {
+      delete tables[JSON.parse(e.data).interface]
+      publish()
+    })
+    es.addEventListener('error', () => {
+      // EventSource retries by itself unless the server refused the
+      // stream (non-200, e.g. 502 when there is no probe socket).
+      if (es.readyState === EventSource.CLOSED) {
+        startPolling()
+      }
+    })
+
+    return () => {
+      es.close()
+      clearInterval(pollId)
+    }
+  }, [pollInterval])
 
   return { neighbours, timestamps, interfaceInfo, loading, error }
 }
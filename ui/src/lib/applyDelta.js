@@ -0,0 +1,46 @@
+/**
+ * Apply a "delta" event from the probe's event stream to the interface
+ * data of the last "snapshot" event. Returns a new object in the export
+ * file format, so it can be passed to mergeNeighbours.
+ *
+ * Removed entries are dropped, updated entries replaced, entries in
+ * last_seen get their new last seen time and added entries are appended;
+ * the timestamp and the interface info and stats become the delta's.
+ */
+export function applyDelta(data, delta) {
+  const removed = new Set(delta.removed || [])
+  const changed = new Map()
+  for (const n of [...(delta.updated || []), ...(delta.added || [])]) {
+    changed.set(n.mac, n)
+  }
+
+  const lastSeen = delta.last_seen || {}
+
+  const neighbours = []
+  for (const n of data.neighbours || []) {
+    if (removed.has(n.mac)) {
+      continue
+    }
+    if (changed.has(n.mac)) {
+      neighbours.push(changed.get(n.mac))
+      changed.delete(n.mac)
+    } else if (n.mac in lastSeen) {
+      neighbours.push({ ...n, last_seen: lastSeen[n.mac] })
+    } else {
+      neighbours.push(n)
+    }
+  }
+  neighbours.push(...changed.values())
+
+  const result = {
+    ...data,
+    timestamp: delta.timestamp || data.timestamp,
+    neighbours,
+  }
+  for (const key of ['mac', 'ipv4', 'ipv6', 'stats']) {
+    if (key in delta) {
+      result[key] = delta[key]
+    }
+  }
+  return result
+}
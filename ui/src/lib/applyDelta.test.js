@@ -0,0 +1,79 @@
+import { describe, it, expect } from 'vitest'
+import { applyDelta } from './applyDelta'
+import eth0Data from '../../../testdata/neigh-eth0.json'
+
+const entry = (mac, lastSeen, ipv4 = []) => ({
+  mac,
+  ipv4,
+  ipv6: [],
+  first_seen: '2026-01-01T00:00:00Z',
+  last_seen: lastSeen,
+})
+
+const base = {
+  interface: 'eth0',
+  timestamp: '2026-01-01T00:00:05Z',
+  neighbours: [
+    entry('02:00:00:00:00:01', '2026-01-01T00:00:01Z'),
+    entry('02:00:00:00:00:02', '2026-01-01T00:00:02Z'),
+    entry('02:00:00:00:00:03', '2026-01-01T00:00:03Z'),
+  ],
+}
+
+describe('applyDelta', () => {
+  it('adds, updates and removes entries', () => {
+    const result = applyDelta(base, {
+      interface: 'eth0',
+      timestamp: '2026-01-01T00:00:10Z',
+      added: [entry('02:00:00:00:00:04', '2026-01-01T00:00:09Z')],
+      updated: [entry('02:00:00:00:00:02', '2026-01-01T00:00:08Z', ['10.0.0.2'])],
+      removed: ['02:00:00:00:00:03'],
+    })
+
+    expect(result.timestamp).toBe('2026-01-01T00:00:10Z')
+    expect(result.neighbours.map((n) => n.mac)).toEqual([
+      '02:00:00:00:00:01',
+      '02:00:00:00:00:02',
+      '02:00:00:00:00:04',
+    ])
+    expect(result.neighbours[1].ipv4).toEqual(['10.0.0.2'])
+  })
+
+  it('does not modify the input', () => {
+    const before = JSON.stringify(base)
+    applyDelta(base, { removed: ['02:00:00:00:00:01'], added: [], updated: [] })
+    expect(JSON.stringify(base)).toBe(before)
+  })
+
+  it('keeps the table and interface info for an empty delta', () => {
+    const result = applyDelta(eth0Data, {
+      interface: 'eth0',
+      timestamp: '2030-01-01T00:00:00Z',
+      added: [],
+      updated: [],
+      removed: [],
+    })
+    expect(result.neighbours).toEqual(eth0Data.neighbours)
+    expect(result.mac).toBe(eth0Data.mac)
+    expect(result.timestamp).toBe('2030-01-01T00:00:00Z')
+  })
+
+  it('applies last seen times and the interface stats', () => {
+    const stats = { rx_packets: 42 }
+    const result = applyDelta(base, {
+      interface: 'eth0',
+      timestamp: '2026-01-01T00:00:10Z',
+      mac: 'aa:bb:cc:dd:ee:ff',
+      stats,
+      added: [],
+      updated: [],
+      last_seen: { '02:00:00:00:00:03': '2026-01-01T00:00:09Z' },
+      removed: [],
+    })
+
+    expect(result.neighbours[2].last_seen).toBe('2026-01-01T00:00:09Z')
+    expect(result.neighbours[0]).toBe(base.neighbours[0])
+    expect(result.mac).toBe('aa:bb:cc:dd:ee:ff')
+    expect(result.stats).toEqual(stats)
+  })
+})
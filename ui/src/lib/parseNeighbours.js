@@ -1,11 +1,24 @@
+/**
+ * Highest export schema_version this UI reads (the probe's
+ * export.SchemaVersion). Files without it predate versioning.
+ */
+export const SCHEMA_VERSION = 1
+
 /**
  * Parse a single interface JSON file into an array of neighbour objects,
- * each tagged with the interface name.
+ * each tagged with the interface name. Files of a newer schema_version
+ * are rejected rather than misread.
  */
 export function parseInterfaceData(data) {
   if (!data || !data.interface || !Array.isArray(data.neighbours)) {
     throw new Error('Invalid interface data: missing required fields')
   }
+  if (data.schema_version > SCHEMA_VERSION) {
+    throw new Error(
+      `Unsupported schema_version ${data.schema_version} for ${data.interface} ` +
+        `(this UI reads up to ${SCHEMA_VERSION}): update the UI`
+    )
+  }
 
   return data.neighbours.map((n) => ({
     interface: data.interface,
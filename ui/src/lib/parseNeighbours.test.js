@@ -1,5 +1,5 @@
 import { describe, it, expect } from 'vitest'
-import { parseInterfaceData, mergeNeighbours } from './parseNeighbours'
+import { parseInterfaceData, mergeNeighbours, SCHEMA_VERSION } from './parseNeighbours'
 import eth0Data from '../../../testdata/neigh-eth0.json'
 import wlan0Data from '../../../testdata/neigh-wlan0.json'
 
@@ -89,6 +89,18 @@ describe('parseInterfaceData', () => {
   it('rejects data without neighbours field', () => {
     expect(() => parseInterfaceData({ interface: 'eth0' })).toThrow()
   })
+
+  it('accepts the current schema_version and files without one', () => {
+    expect(parseInterfaceData({ ...eth0Data, schema_version: SCHEMA_VERSION })).toHaveLength(4)
+    const { schema_version: _, ...unversioned } = eth0Data
+    expect(parseInterfaceData(unversioned)).toHaveLength(4)
+  })
+
+  it('rejects a newer schema_version', () => {
+    const data = { ...eth0Data, schema_version: SCHEMA_VERSION + 1 }
+    expect(() => parseInterfaceData(data)).toThrow(/Unsupported schema_version 2 for eth0/)
+    expect(() => mergeNeighbours([wlan0Data, data])).toThrow(/schema_version/)
+  })
 })
 
 describe('mergeNeighbours', () => {